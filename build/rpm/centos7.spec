@@ -42,6 +42,8 @@ install -m 755 %{gopath}/bin/prj_setacl %{buildroot}/%{_bindir}/prj_setacl
 install -m 755 %{gopath}/bin/prj_getacl %{buildroot}/%{_bindir}/prj_getacl
 install -m 755 %{gopath}/bin/prj_delacl %{buildroot}/%{_bindir}/prj_delacl
 install -m 755 %{gopath}/bin/prj_chown  %{buildroot}/%{_bindir}/prj_chown
+install -m 755 %{gopath}/bin/prj_rewrite  %{buildroot}/%{_bindir}/prj_rewrite
+install -m 755 %{gopath}/bin/prj_du %{buildroot}/%{_bindir}/prj_du
 
 %files
 %{_sbindir}/pdbutil
@@ -53,6 +55,8 @@ install -m 755 %{gopath}/bin/prj_chown  %{buildroot}/%{_bindir}/prj_chown
 %{_bindir}/prj_getacl
 %{_bindir}/prj_delacl
 %{_bindir}/prj_chown
+%{_bindir}/prj_rewrite
+%{_bindir}/prj_du
 
 %post
 echo "setting linux capabilities for ACL utilities ..."
@@ -60,6 +64,8 @@ setcap cap_fowner,cap_sys_admin+eip %{_bindir}/prj_delacl
 setcap cap_fowner,cap_sys_admin+eip %{_bindir}/prj_setacl
 setcap cap_sys_admin+eip %{_bindir}/prj_getacl
 setcap cap_chown+eip %{_bindir}/prj_chown
+# prj_rewrite sets ACLs and the `trusted.managers` xattr on CephFS besides the owner.
+setcap cap_chown,cap_fowner,cap_sys_admin+eip %{_bindir}/prj_rewrite
 
 %clean
 chmod -R +w %{gopath}
@@ -17,7 +17,7 @@ import (
 var (
 	optsDate    *string
 	optsConfig  *string
-	optsLabMod  pdb.Lab
+	optsLabMod  = pdb.EEG
 	optsVerbose *bool
 	optsJson    *bool
 )
@@ -20,9 +20,8 @@ import (
 var (
 	cmdDump2dcm string = "dump2dcm" // command for dump2dcm
 	dryRun      bool
-	date        string                                     // date format YYYY-MM-DD
-	scanners    = []string{"prisma", "prismafit", "skyra"} // valid scanner names
-	store       string                                     // path of the worklist (for both .dump and .wl files) store
+	date        string // date format YYYY-MM-DD
+	store       string // path of the worklist (for both .dump and .wl files) store
 )
 
 // data structure for a DICOM worklist
@@ -92,37 +91,48 @@ func init() {
 }
 
 var generateCmd = &cobra.Command{
-	Use:   fmt.Sprintf("generate {%s [...]}", strings.Join(scanners, "|")),
+	Use:   "generate {scanner [...]}",
 	Short: "Generate DICOM worklist from Lab bookings of the DCCN's MR scanners",
-	Long: fmt.Sprintf(`Generate DICOM worklist from Lab bookings of the DCCN's MR scanners.
+	Long: `Generate DICOM worklist from Lab bookings of the DCCN's MR scanners.
 	
-Use the argument to specify one or multiple available scanners: %s
-`, strings.Join(scanners, ", ")),
-	ValidArgs: scanners,
-	Args:      cobra.MatchAll(cobra.MinimumNArgs(1), cobra.OnlyValidArgs),
+Use the argument to specify one or multiple available scanners.  The scanners are those
+of the MRI lab defined in the "pdb.labs" section of the configuration file.
+`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 
 		pdb := loadPdb()
 
+		mri, err := ipdb.LookupLab(ipdb.MRI)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range args {
+			if _, ok := mri.Scanner(s); !ok {
+				return fmt.Errorf("invalid scanner %q, available scanners: %s", s, strings.Join(mri.ScannerNames(), ", "))
+			}
+		}
+
 		bookings, err := pdb.GetLabBookingsForWorklist(ipdb.MRI, date)
 		if err != nil {
 			log.Errorf("cannot retrieve labbookings, reason: %+v", err)
 			os.Exit(100)
 		}
 
-		// internal function to check if a booking is valid for creating worklist
-		validBooking := func(booking *ipdb.LabBooking) bool {
-			_scanner := strings.ToLower(booking.Lab)
+		// internal function to get the scanner of a booking valid for creating worklist
+		bookedScanner := func(booking *ipdb.LabBooking) (ipdb.Scanner, bool) {
 			for _, s := range args {
-				if _scanner == s {
-					return true
+				if strings.EqualFold(booking.Lab, s) {
+					return mri.Scanner(s)
 				}
 			}
-			return false
+			return ipdb.Scanner{}, false
 		}
 
 		for _, booking := range bookings {
-			if !validBooking(booking) {
+			scanner, ok := bookedScanner(booking)
+			if !ok {
 				// skip invalid booking
 				continue
 			}
@@ -175,7 +185,7 @@ Use the argument to specify one or multiple available scanners: %s
 				SessionTitle: fmt.Sprintf("MR session %s", _sessId),
 				ProjectTitle: booking.ProjectTitle,
 				Physician:    fmt.Sprintf("%s %s", booking.Operator.Firstname, booking.Operator.Lastname),
-				ModalityAE:   strings.ToUpper(booking.Lab),
+				ModalityAE:   scanner.AETitle,
 			}
 
 			wl, err := composeWorklist(_data)
@@ -0,0 +1,87 @@
+package config
+
+import "time"
+
+// AlertsConfiguration defines the policies of the project alerts sent by email, i.e. the
+// out-of-quota (ooq) and the out-of-time (oot) alerts.
+type AlertsConfiguration struct {
+	// Sender is the name of the alert sender.
+	Sender string `mapstructure:"sender"`
+	// SenderEmail is the email address from which the alerts are sent.
+	SenderEmail string `mapstructure:"sender_email"`
+	Ooq         OoqAlertConfiguration
+	Oot         OotAlertConfiguration
+	Digest      DigestAlertConfiguration
+	Membership  MembershipNotificationConfiguration
+}
+
+// MembershipNotificationConfiguration defines the notifications on the project roles
+// granted, changed or revoked by the pending actions.  The notifications are sent to the
+// members of whom the roles are changed, and to the managers of the projects.
+type MembershipNotificationConfiguration struct {
+	// Template is the path of the template file of the message to the members.
+	Template string `mapstructure:"template"`
+	// ManagerTemplate is the path of the template file of the message to the managers.
+	ManagerTemplate string `mapstructure:"manager_template"`
+	// CarbonCopy is a list of email addresses in carbon copy of every notification.
+	CarbonCopy []string `mapstructure:"cc"`
+}
+
+// DigestAlertConfiguration defines the policy of the alert digests, each combining all
+// the alerts to a recipient into one message.
+type DigestAlertConfiguration struct {
+	// Template is the path of the template file of the digest message.
+	Template string `mapstructure:"template"`
+	// CarbonCopy is a list of email addresses in carbon copy of every digest, in addition
+	// to the carbon copies of the combined alerts.
+	CarbonCopy []string `mapstructure:"cc"`
+}
+
+// OoqAlertConfiguration defines the policy of the alerts concerning projects (close to)
+// running out of storage quota.
+type OoqAlertConfiguration struct {
+	// Recipients are the roles of the project to which the alerts are sent, i.e. "owner",
+	// "manager", "contributor" and "viewer".
+	Recipients []string `mapstructure:"recipients"`
+	// CarbonCopy is a list of email addresses in carbon copy of every alert.
+	CarbonCopy []string `mapstructure:"cc"`
+	// Template is the path of the template file used by the bands without own template.
+	Template string `mapstructure:"template"`
+	// Bands are the storage usage bands for which the alerts are sent.
+	Bands []OoqAlertBand `mapstructure:"bands"`
+}
+
+// OoqAlertBand defines a storage usage band starting from the usage `Threshold` in
+// percent, up to the threshold of the next band.
+type OoqAlertBand struct {
+	Threshold int `mapstructure:"threshold"`
+	// Interval is the minimal time between two alerts of the same project within the band.
+	Interval time.Duration `mapstructure:"interval"`
+	Template string        `mapstructure:"template"`
+}
+
+// OotAlertConfiguration defines the policy of the alerts concerning projects expiring or
+// past the end date.
+type OotAlertConfiguration struct {
+	// Recipients are the roles of the project to which the alerts are sent, i.e. "owner",
+	// "manager", "contributor" and "viewer".
+	Recipients []string `mapstructure:"recipients"`
+	// CarbonCopy is a list of email addresses in carbon copy of every alert.
+	CarbonCopy []string `mapstructure:"cc"`
+	// Template is the path of the template file used by the modes without own template.
+	Template string `mapstructure:"template"`
+	// Modes are the alert modes, each selecting projects at a given number of days
+	// before (or after, if negative) the end date.
+	Modes []OotAlertMode `mapstructure:"modes"`
+}
+
+// OotAlertMode defines an alert mode for projects ending in `Days` days from now.
+//
+// In the alert, the number of days is given to the template as `ExpiringInDays`; if `Months`
+// is not zero, it is given as `ExpiringInMonths` instead.
+type OotAlertMode struct {
+	Name     string `mapstructure:"name"`
+	Days     int    `mapstructure:"days"`
+	Months   int    `mapstructure:"months"`
+	Template string `mapstructure:"template"`
+}
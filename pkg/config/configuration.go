@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -19,6 +20,8 @@ type Configuration struct {
 	Repository    RepositoryConfiguration
 	VolumeManager VolumeManagerConfiguration
 	Mailer        MailerConfiguration
+	Identity      IdentityConfiguration
+	Alerts        AlertsConfiguration
 }
 
 // LoadConfig reads configuration file `cpath` and returns the
@@ -42,6 +45,11 @@ func LoadConfig(cpath string) (Configuration, error) {
 		return conf, fmt.Errorf("cannot read config file, %s", err)
 	}
 
+	// the mock project database can also be selected with version "mock".
+	if strings.EqualFold(viper.GetString("pdb.version"), "mock") {
+		viper.Set("pdb.version", 0)
+	}
+
 	err = viper.Unmarshal(&conf)
 	if err != nil {
 		return conf, fmt.Errorf("unable to decode into struct, %v", err)
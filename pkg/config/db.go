@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 // DBConfiguration is the data structure for marshaling the
 // SQL database configuration sessions of the config.yml file
 // using the viper configuration framework.
@@ -9,4 +11,13 @@ type DBConfiguration struct {
 	UserSQL     string `mapstructure:"db_user"`
 	PassSQL     string `mapstructure:"db_pass"`
 	DatabaseSQL string `mapstructure:"db_name"`
+	// MaxOpenConns is the maximum number of open connections of the pool; no limit if it
+	// is zero.
+	MaxOpenConns int `mapstructure:"max_open_conns"`
+	// MaxIdleConns is the maximum number of idle connections kept in the pool; the
+	// default of the `database/sql` package is used if it is zero.
+	MaxIdleConns int `mapstructure:"max_idle_conns"`
+	// ConnMaxLifetime is the maximum time a connection is reused; connections are reused
+	// forever if it is zero.
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
 }
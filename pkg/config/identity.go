@@ -0,0 +1,24 @@
+package config
+
+// IdentityConfiguration defines the configuration parameters for resolving the
+// identity of users before they are granted with project roles.
+type IdentityConfiguration struct {
+	// Source is the identity source, one of "passwd", "ldap" or "pdb".
+	Source string
+	LDAP   LDAPConfiguration
+}
+
+// LDAPConfiguration defines the configuration parameters for connecting the LDAP server
+// as the identity source.
+type LDAPConfiguration struct {
+	URL          string `mapstructure:"url"`
+	BindDN       string `mapstructure:"bind_dn"`
+	BindPassword string `mapstructure:"bind_password"`
+	BaseDN       string `mapstructure:"base_dn"`
+	// UserFilter is the search filter for the user entry, with `%s` substituted by the username.
+	UserFilter string `mapstructure:"user_filter"`
+	// InactiveAttribute and InactiveValue determine an inactive user, i.e. the user entry
+	// having the attribute with the value.
+	InactiveAttribute string `mapstructure:"inactive_attribute"`
+	InactiveValue     string `mapstructure:"inactive_value"`
+}
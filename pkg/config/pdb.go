@@ -1,10 +1,58 @@
 package config
 
+import "time"
+
 // PDBConfiguration defines the configuration parameters for project database.
 type PDBConfiguration struct {
 	Version int
 	V1      DBConfiguration
 	V2      CoreAPIConfiguration
+	Mock    MockPDBConfiguration
+	Cache   PDBCacheConfiguration
+	// Labs are the labs of the lab bookings; the built-in labs are used if it is empty.
+	Labs []LabConfiguration
+}
+
+// LabConfiguration defines a lab of which the bookings are retrieved from the project
+// database.
+type LabConfiguration struct {
+	// Name is the name of the lab, e.g. "MRI".
+	Name string `mapstructure:"name"`
+	// Description is the regular expression matching the upper-cased calendar description
+	// of the lab resources in the project database v1.  The first submatch is taken as the
+	// lab of the bookings.  It is required.
+	Description string `mapstructure:"description"`
+	// Modality is the regular expression matching the modality ids of the lab resources
+	// in the project database v2.  It is required.
+	Modality string `mapstructure:"modality"`
+	// Scanners are the scanners of the lab for which the DICOM worklist can be generated.
+	Scanners []ScannerConfiguration `mapstructure:"scanners"`
+}
+
+// ScannerConfiguration defines a scanner of a lab.
+type ScannerConfiguration struct {
+	// Name is the name of the scanner, matched case-insensitively with the lab of the
+	// bookings.
+	Name string `mapstructure:"name"`
+	// AETitle is the DICOM application entity title of the scanner; the upper-cased name is
+	// used if it is empty.
+	AETitle string `mapstructure:"ae_title"`
+}
+
+// PDBCacheConfiguration defines the configuration parameters for caching data retrieved
+// from the project database.
+type PDBCacheConfiguration struct {
+	// TTL is the time-to-live of the cached data.  Caching is disabled if it is zero.
+	TTL time.Duration `mapstructure:"ttl"`
+	// Path is the local database file for keeping the cached data across runs.  Data are
+	// cached in memory if it is empty.
+	Path string `mapstructure:"path"`
+}
+
+// MockPDBConfiguration defines the configuration parameters for the mock project database
+// (version 0), which is loaded from a YAML or JSON fixture file.
+type MockPDBConfiguration struct {
+	Fixture string
 }
 
 // CoreAPIConfiguration defines the configuration parameters for the core api of the project database v2.
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"os/user"
+	"strings"
+	"syscall"
 )
 
 // AcquireLock creates a lock file at the path specified by the flock argument, and writes a piece of information to the file.
@@ -26,3 +28,45 @@ func AcquireLock(flock string) error {
 	f.Close()
 	return nil
 }
+
+// Lock is an exclusive lock on a lock file, held by the current process.
+//
+// Unlike `AcquireLock`, the lock is an advisory lock (flock) released by the kernel when
+// the process exits; a crashed run therefore doesn't leave a stale lock behind.
+type Lock struct {
+	f *os.File
+}
+
+// TryLock acquires the exclusive lock on the file `flock` without blocking.  The information
+// of the lock holder, i.e. the user id, the hostname and the process id, is written to the
+// file.  An error is returned if the lock is held by another process.
+func TryLock(flock string) (*Lock, error) {
+	f, err := os.OpenFile(flock, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		holder, _ := os.ReadFile(flock)
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, fmt.Errorf("program locked by another run (%s): %s", strings.TrimSpace(string(holder)), flock)
+		}
+		return nil, err
+	}
+
+	u, _ := user.Current()
+	h, _ := os.Hostname()
+	f.Truncate(0)
+	f.WriteAt([]byte(fmt.Sprintf("%s %s %d\n", u.Username, h, os.Getpid())), 0)
+
+	return &Lock{f: f}, nil
+}
+
+// Unlock releases the lock.  The lock file is emptied but not removed, as removing it
+// would allow two processes to hold locks on different files of the same path.
+func (l *Lock) Unlock() error {
+	defer l.f.Close()
+	l.f.Truncate(0)
+	return syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
+}
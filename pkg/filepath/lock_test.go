@@ -0,0 +1,36 @@
+package filepath
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTryLock(t *testing.T) {
+	flock := filepath.Join(t.TempDir(), "test.lock")
+
+	l, err := TryLock(flock)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// the second lock, on another open file description, fails.
+	if _, err := TryLock(flock); err == nil {
+		t.Errorf("expect error on locked file")
+	}
+
+	if err := l.Unlock(); err != nil {
+		t.Errorf("%s", err)
+	}
+	if data, _ := os.ReadFile(flock); len(data) != 0 {
+		t.Errorf("lock file not emptied: %s", data)
+	}
+
+	// a stale lock file doesn't block the lock.
+	os.WriteFile(flock, []byte("nobody nohost 1\n"), 0644)
+	l, err = TryLock(flock)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	l.Unlock()
+}
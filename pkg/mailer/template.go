@@ -50,6 +50,34 @@ type ProjectAlertTemplateData struct {
 	ExpiringInDays   int    // number of days before the project's end date
 	ExpiringInMonths int    // number of months before the project's end date
 	QuotaUsageRatio  int    // project storage quota usage ratio
+	AlertType        string // type of the alert, i.e. "ooq" (out-of-quota) or "oot" (out-of-time)
+}
+
+// ProjectAlertDigestTemplateData extends `ProjectAlertTemplateData` to a list of projects
+// for composing a single alert digest message to a recipient.
+type ProjectAlertDigestTemplateData struct {
+	RecipientName string                     // full name of the alert recipient to be addressed
+	SenderName    string                     // full name of the alert sender
+	Projects      []ProjectAlertTemplateData // alerts of the projects
+}
+
+// ProjectRoleChangeTemplateData is a change of the role of a member in a project.
+type ProjectRoleChangeTemplateData struct {
+	ProjectID    string // project id
+	ProjectTitle string // project title
+	MemberID     string // user id of the member
+	MemberName   string // full name of the member
+	Change       string // kind of the change, i.e. "granted", "changed" or "revoked"
+	OldRole      string // role before the change; empty if the role is granted
+	NewRole      string // role after the change; empty if the role is revoked
+}
+
+// ProjectMembershipTemplateData is the data for composing a message notifying a recipient
+// of the changes of the project roles.
+type ProjectMembershipTemplateData struct {
+	RecipientName string                          // full name of the recipient to be addressed
+	SenderName    string                          // full name of the sender
+	Changes       []ProjectRoleChangeTemplateData // changes of the project roles
 }
 
 // template function definition
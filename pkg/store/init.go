@@ -1,12 +1,18 @@
 package store
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 )
 
+// ErrKeyNotFound is returned by `KVStore.Get` if the key is not in the bucket.
+var ErrKeyNotFound = errors.New("key not found")
+
 // KVPair is a set of key-value pair.
 type KVPair struct {
 	Key   []byte
@@ -16,9 +22,12 @@ type KVPair struct {
 // KVStore provides interface to interact with a local database for
 // managing/bookkeeping user map and exported collections.
 type KVStore struct {
-	Path  string
-	mutex sync.Mutex
-	db    *bolt.DB
+	Path string
+	// Timeout is the amount of time to wait for the lock on the database file held
+	// by another process.  It waits indefinitely if the value is zero.
+	Timeout time.Duration
+	mutex   sync.Mutex
+	db      *bolt.DB
 }
 
 // Connect establishes the bolt db connection.
@@ -27,7 +36,7 @@ func (s *KVStore) Connect() (err error) {
 		return nil
 	}
 
-	if s.db, err = bolt.Open(s.Path, 0600, nil); err != nil {
+	if s.db, err = bolt.Open(s.Path, 0600, &bolt.Options{Timeout: s.Timeout}); err != nil {
 		return fmt.Errorf("cannot connect blot db: %s", err)
 	}
 	return nil
@@ -66,7 +75,7 @@ func (s *KVStore) Init(buckets []string) error {
 }
 
 // Get returns a value of the given key within the given bucket
-// in the bolt database.
+// in the bolt database.  The error wraps `ErrKeyNotFound` if the key is not in the bucket.
 func (s *KVStore) Get(bucket string, key []byte) ([]byte, error) {
 
 	if s.db == nil {
@@ -77,9 +86,12 @@ func (s *KVStore) Get(bucket string, key []byte) ([]byte, error) {
 
 	if err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return fmt.Errorf("bucket not found: %s", bucket)
+		}
 		v = b.Get(key)
 		if v == nil {
-			return fmt.Errorf("key %+v not in bucket %s", key, bucket)
+			return fmt.Errorf("%w: %+v in bucket %s", ErrKeyNotFound, key, bucket)
 		}
 		return nil
 	}); err != nil {
@@ -117,6 +129,60 @@ func (s *KVStore) GetAll(bucket string) ([]KVPair, error) {
 	return data, nil
 }
 
+// ForEach calls `fn` on each key-value pair of a bucket in byte-sorted order of the keys.
+// The key and value are only valid within `fn`; the iteration stops at the first error
+// returned by `fn`.
+func (s *KVStore) ForEach(bucket string, fn func(key, value []byte) error) error {
+
+	if s.db == nil {
+		return fmt.Errorf("no connected db")
+	}
+
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return fmt.Errorf("bucket not found: %s", bucket)
+		}
+		return b.ForEach(fn)
+	})
+}
+
+// GetRange retrieves the key-value pairs from a bucket with the key in between `min` and
+// `max` in byte-sorted order; `min` is inclusive and `max` exclusive.  The range is
+// unbounded above if `max` is nil.
+func (s *KVStore) GetRange(bucket string, min, max []byte) ([]KVPair, error) {
+
+	if s.db == nil {
+		return nil, fmt.Errorf("no connected db")
+	}
+
+	var data []KVPair
+
+	if err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return fmt.Errorf("bucket not found: %s", bucket)
+		}
+		c := b.Cursor()
+
+		for k, v := c.Seek(min); k != nil; k, v = c.Next() {
+			if max != nil && bytes.Compare(k, max) >= 0 {
+				break
+			}
+			// the key and value are only valid within the transaction.
+			data = append(data, KVPair{
+				Key:   append([]byte(nil), k...),
+				Value: append([]byte(nil), v...),
+			})
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
 // Set insert/update a key-value pair in the given bucket.
 func (s *KVStore) Set(bucket string, key []byte, value []byte) error {
 
@@ -139,3 +205,72 @@ func (s *KVStore) Set(bucket string, key []byte, value []byte) error {
 
 	return nil
 }
+
+// Delete removes the key-value pair of the given key from the given bucket.
+func (s *KVStore) Delete(bucket string, key []byte) error {
+
+	if s.db == nil {
+		return fmt.Errorf("no connected db")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.Delete(key)
+	})
+}
+
+// DeleteRange removes the key-value pairs from a bucket with the key in between `min` and
+// `max` in byte-sorted order; `min` is inclusive and `max` exclusive.  The range is
+// unbounded above if `max` is nil.
+func (s *KVStore) DeleteRange(bucket string, min, max []byte) error {
+
+	if s.db == nil {
+		return fmt.Errorf("no connected db")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+
+		// deleting via the cursor moves it to the next key.
+		for k, _ := c.Seek(min); k != nil; k, _ = c.Seek(min) {
+			if max != nil && bytes.Compare(k, max) >= 0 {
+				break
+			}
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Clear removes all key-value pairs from the given bucket.
+func (s *KVStore) Clear(bucket string) error {
+
+	if s.db == nil {
+		return fmt.Errorf("no connected db")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(bucket)) != nil {
+			if err := tx.DeleteBucket([]byte(bucket)); err != nil {
+				return err
+			}
+		}
+		_, err := tx.CreateBucket([]byte(bucket))
+		return err
+	})
+}
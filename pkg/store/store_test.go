@@ -2,6 +2,8 @@ package store
 
 import (
 	"encoding/json"
+	"errors"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -118,3 +120,174 @@ func TestKVStoreGetAllObjects(t *testing.T) {
 		t.Errorf("u1 != u2")
 	}
 }
+
+func TestKVStoreDeleteClear(t *testing.T) {
+	store := KVStore{
+		Path: filepath.Join(t.TempDir(), "testKVStoreDeleteClear.db"),
+	}
+
+	if err := store.Connect(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer store.Disconnect()
+
+	if err := store.Init([]string{"users"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for _, k := range []string{"1", "2", "3"} {
+		if err := store.Set("users", []byte(k), []byte(k)); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	// delete a single key
+	if err := store.Delete("users", []byte("1")); err != nil {
+		t.Errorf("%s", err)
+	}
+	if v, err := store.Get("users", []byte("1")); v != nil || !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("key not deleted: %s %v", v, err)
+	}
+
+	// a missing bucket is not a missing key
+	if _, err := store.Get("groups", []byte("1")); err == nil || errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("unexpected error on missing bucket: %v", err)
+	}
+
+	// clear the bucket
+	if err := store.Clear("users"); err != nil {
+		t.Errorf("%s", err)
+	}
+	if kvpairs, _ := store.GetAll("users"); len(kvpairs) != 0 {
+		t.Errorf("bucket not cleared: %d pairs left", len(kvpairs))
+	}
+}
+
+func TestKVStoreGetRange(t *testing.T) {
+	store := KVStore{
+		Path: filepath.Join(t.TempDir(), "testKVStoreGetRange.db"),
+	}
+
+	if err := store.Connect(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer store.Disconnect()
+
+	if err := store.Init([]string{"alerts"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for _, k := range []string{"a/1", "a/2", "a/3", "b/1", "c/1"} {
+		if err := store.Set("alerts", []byte(k), []byte(k)); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	cases := []struct {
+		name     string
+		min, max []byte
+		expected []string
+	}{
+		{"prefix", []byte("a/"), []byte("a0"), []string{"a/1", "a/2", "a/3"}},
+		{"bounded", []byte("a/2"), []byte("b/1"), []string{"a/2", "a/3"}},
+		{"unbounded", []byte("b/"), nil, []string{"b/1", "c/1"}},
+		{"empty", []byte("d/"), nil, []string{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kvpairs, err := store.GetRange("alerts", c.min, c.max)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			keys := []string{}
+			for _, kv := range kvpairs {
+				keys = append(keys, string(kv.Key))
+			}
+			if !reflect.DeepEqual(keys, c.expected) {
+				t.Errorf("unexpected keys: %v, expected %v", keys, c.expected)
+			}
+		})
+	}
+
+	if _, err := store.GetRange("groups", []byte("a"), nil); err == nil {
+		t.Errorf("expected error on missing bucket")
+	}
+}
+
+func TestKVStoreDeleteRange(t *testing.T) {
+	store := KVStore{
+		Path: filepath.Join(t.TempDir(), "testKVStoreDeleteRange.db"),
+	}
+
+	if err := store.Connect(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer store.Disconnect()
+
+	if err := store.Init([]string{"cache"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for _, k := range []string{"a/1", "a/2", "a/3", "b/1", "c/1"} {
+		if err := store.Set("cache", []byte(k), []byte(k)); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	if err := store.DeleteRange("cache", []byte("a/"), []byte("a0")); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	kvpairs, err := store.GetAll("cache")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	keys := []string{}
+	for _, kv := range kvpairs {
+		keys = append(keys, string(kv.Key))
+	}
+	if expected := []string{"b/1", "c/1"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("unexpected keys: %v, expected %v", keys, expected)
+	}
+
+	if err := store.DeleteRange("groups", []byte("a"), nil); err != nil {
+		t.Errorf("unexpected error on missing bucket: %s", err)
+	}
+}
+
+func TestKVStoreForEach(t *testing.T) {
+	store := KVStore{
+		Path: filepath.Join(t.TempDir(), "testKVStoreForEach.db"),
+	}
+
+	if err := store.Connect(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer store.Disconnect()
+
+	if err := store.Init([]string{"cache"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for _, k := range []string{"b", "a", "c"} {
+		if err := store.Set("cache", []byte(k), []byte(k)); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	keys := []string{}
+	if err := store.ForEach("cache", func(k, v []byte) error {
+		keys = append(keys, string(k))
+		return nil
+	}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if expected := []string{"a", "b", "c"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("unexpected keys: %v, expected %v", keys, expected)
+	}
+
+	if err := store.ForEach("groups", func(k, v []byte) error { return nil }); err == nil {
+		t.Errorf("expected error on missing bucket")
+	}
+}
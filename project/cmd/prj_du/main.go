@@ -0,0 +1,142 @@
+// This program summarizes the disk usage of a project storage by file owner
+// and by top-level sub-directory, together with histograms on the file age.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	ustr "github.com/dccn-tg/tg-toolset-golang/pkg/strings"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/du"
+)
+
+var optsPath *string
+var optsNthreads *int
+var optsJSON *bool
+var optsVerbose *bool
+
+func init() {
+	optsPath = flag.String("d", "/project", "root path of project storage")
+	optsNthreads = flag.Int("n", 8, "number of concurrent processing threads")
+	optsJSON = flag.Bool("j", false, "output in json format")
+	optsVerbose = flag.Bool("v", false, "print debug messages")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	cfg := log.Configuration{
+		EnableConsole:     true,
+		ConsoleJSONFormat: false,
+		ConsoleLevel:      log.Info,
+	}
+
+	if *optsVerbose {
+		cfg.ConsoleLevel = log.Debug
+	}
+
+	// initialize logger
+	log.NewLogger(cfg, log.InstanceLogrusLogger)
+}
+
+func usage() {
+	fmt.Printf("\nSummarizing disk usage of a project or a path by owner and top-level sub-directory.\n")
+	fmt.Printf("\nUSAGE: %s [OPTIONS] projectId|path\n", os.Args[0])
+	fmt.Printf("\nOPTIONS:\n")
+	flag.PrintDefaults()
+	fmt.Printf("\nEXAMPLES:\n")
+	fmt.Printf("\n%s\n", ustr.StringWrap("Summarizing disk usage of project 3010000.01", 80))
+	fmt.Printf("\n  %s 3010000.01\n", os.Args[0])
+	fmt.Printf("\n%s\n", ustr.StringWrap("Summarizing disk usage of a sub-directory in JSON format, using 16 threads", 80))
+	fmt.Printf("\n  %s -j -n 16 /project/3010000.01/raw\n", os.Args[0])
+	fmt.Printf("\n")
+}
+
+func main() {
+
+	// command-line arguments
+	args := flag.Args()
+
+	if len(args) < 1 {
+		flag.Usage()
+		log.Fatalf("unknown project number: %v", args)
+	}
+
+	ppath := args[0]
+	// the input argument starts with 7 digits (considered as project number)
+	if matched, _ := regexp.MatchString("^[0-9]{7,}", ppath); matched {
+		ppath = filepath.Join(*optsPath, ppath)
+	} else {
+		ppath, _ = filepath.Abs(ppath)
+	}
+
+	usage, err := du.Scan(ppath, *optsNthreads)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	if *optsJSON {
+		out, err := json.Marshal(usage)
+		if err != nil {
+			log.Fatalf("cannot format output in JSON: %s", err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	printUsage(usage)
+}
+
+// printUsage prints the `usage` in human-readable tables.
+func printUsage(usage *du.Usage) {
+
+	fmt.Printf("path: %s\n", usage.Path)
+	fmt.Printf("scan: %s\n", usage.ScanTime.Format("2006-01-02 15:04:05"))
+	fmt.Printf("dirs: %d, files: %d, size: %s\n", usage.Dirs, usage.Total.Files, humanSize(usage.Total.Size))
+
+	fmt.Printf("\n")
+	printTable("owner", usage.SortedOwners(), usage.Owners)
+
+	fmt.Printf("\n")
+	printTable("directory", usage.SortedSubdirs(), usage.Subdirs)
+}
+
+// printTable prints a table with a row per key in `keys`, showing size, file
+// count and the file-age histogram in size.
+func printTable(title string, keys []string, stats map[string]*du.Stat) {
+
+	header := []string{fmt.Sprintf("%-24s", title), fmt.Sprintf("%10s", "size"), fmt.Sprintf("%10s", "files")}
+	for _, b := range du.AgeBins {
+		header = append(header, fmt.Sprintf("%10s", b.Label))
+	}
+	fmt.Println(strings.Join(header, " "))
+
+	for _, k := range keys {
+		s := stats[k]
+		row := []string{fmt.Sprintf("%-24s", k), fmt.Sprintf("%10s", humanSize(s.Size)), fmt.Sprintf("%10d", s.Files)}
+		for _, b := range du.AgeBins {
+			row = append(row, fmt.Sprintf("%10s", humanSize(s.AgeSize[b.Label])))
+		}
+		fmt.Println(strings.Join(row, " "))
+	}
+}
+
+// humanSize converts `size` in bytes into a human-readable string in power of 1024.
+func humanSize(size int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	s := float64(size)
+	i := 0
+	for s >= 1024 && i < len(units)-1 {
+		s /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d%s", size, units[i])
+	}
+	return fmt.Sprintf("%.1f%s", s, units[i])
+}
@@ -31,6 +31,7 @@ var nthreads *int
 var verbose *bool
 var optsFollowLink *bool
 var optsSkipFiles *bool
+var optsExport *string
 
 func init() {
 	path = flag.String("d", "/project", "root path of project storage")
@@ -39,6 +40,7 @@ func init() {
 	verbose = flag.Bool("v", false, "print debug messages")
 	optsFollowLink = flag.Bool("l", false, "`follow` symlinks to set roles on referents")
 	optsSkipFiles = flag.Bool("k", false, "`skip` getting roles on existing files")
+	optsExport = flag.String("export", "", "export roles to a portable `file` for \"prj_setacl -import\"")
 
 	flag.Usage = usage
 	flag.Parse()
@@ -69,6 +71,8 @@ func usage() {
 	fmt.Printf("\n  %s -r 3010000.01\n", os.Args[0])
 	fmt.Printf("\n%s\n", ustr.StringWrap("Getting users with access permission on a specific file/directory", 80))
 	fmt.Printf("\n  %s /project/3010000.01/test.txt\n", os.Args[0])
+	fmt.Printf("\n%s\n", ustr.StringWrap("Exporting roles of all directories under project 3010000.01 to a portable file", 80))
+	fmt.Printf("\n  %s -r -k -export 3010000.01.acl.json 3010000.01\n", os.Args[0])
 	fmt.Printf("\n")
 }
 
@@ -96,7 +100,36 @@ func main() {
 		Nthreads:   *nthreads,
 	}
 
+	if *optsExport != "" {
+		if err := exportRoles(runner, *optsExport); err != nil {
+			log.Fatalf("%s", err)
+		}
+		return
+	}
+
 	if err := runner.PrintRoles(*recursion); err != nil {
 		log.Fatalf("%s", err)
 	}
 }
+
+// exportRoles writes roles retrieved by the `runner` to the portable file `fpath`.
+func exportRoles(runner acl.Runner, fpath string) error {
+
+	pacl, err := runner.ExportRoles(*recursion)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(fpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := pacl.Write(f); err != nil {
+		return err
+	}
+
+	log.Infof("roles of %d paths exported to %s", len(pacl.Entries), fpath)
+	return nil
+}
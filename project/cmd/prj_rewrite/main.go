@@ -0,0 +1,96 @@
+// This program transfers the ownership of files and directories in a project from
+// user OLD to user NEW, and rewrites ACL entries referring to OLD into NEW in the same
+// pass.  All changes are recorded in a journal file that can be replayed with the
+// `--rollback` option to revert the changes.  Only project managers are allowed to
+// rewrite the paths in the project.
+//
+// The program uses the following linux capabilities:
+//
+//   - CAP_CHOWN: for changing the owner of files and directories not owned by the
+//     caller.
+//
+//   - CAP_FOWNER: for allowing managers to set ACLs without being the owner of files
+//     and directories.
+//
+//   - CAP_SYS_ADMIN: for accessing the `trusted.managers` xattr that maintains a list
+//     of project managers on the CephFS (see `prj_setacl`).
+//
+// They are kept out of `prj_chown`, which only requires the CAP_CHOWN capability.  In
+// order to allow this program to work, this executable should be set in advance to
+// allow using the linux capabilities using the following command.
+//
+// ```
+// $ sudo setcap cap_chown,cap_fowner,cap_sys_admin+eip prj_rewrite
+// ```
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/acl"
+)
+
+func init() {
+
+	cfg := log.Configuration{
+		EnableConsole:     true,
+		ConsoleJSONFormat: false,
+		ConsoleLevel:      log.Error,
+	}
+
+	// use DEBUG=1 environment variable to enable debug logs
+	if os.Getenv("DEBUG") == "1" {
+		cfg.ConsoleLevel = log.Debug
+	}
+
+	// initialize logger
+	log.NewLogger(cfg, log.InstanceLogrusLogger)
+}
+
+func usage() {
+	fmt.Printf("\nAllow manager to transfer ownership and ACL entries from one user to another\n")
+	fmt.Printf("\nUSAGE: %s --rewrite OLD:NEW [--preview] [--journal FILE] [--nthreads N] PATH...\n", os.Args[0])
+	fmt.Printf("\n       %s --rollback FILE\n", os.Args[0])
+	fmt.Printf("\nThe --rewrite option transfers ownership from user OLD to NEW, and rewrites\n")
+	fmt.Printf("ACL entries referring to OLD into NEW recursively. Changes are recorded in the\n")
+	fmt.Printf("journal FILE, which can be used by the --rollback option to revert them.\n")
+}
+
+func main() {
+	if err := rewriteMain(os.Args[1:]); err != nil {
+		log.Fatalf("%s", err)
+	}
+}
+
+// isManager determines whether the given user is a manager of the path, using
+// the `acl.Runner`.
+func isManager(path, username string) bool {
+
+	ppath, _ := filepath.Abs(path)
+
+	runner := acl.Runner{
+		RootPath:   ppath,
+		FollowLink: true,
+		SkipFiles:  false,
+		Nthreads:   1,
+	}
+
+	chanOut, err := runner.GetRoles(false)
+	if err != nil {
+		log.Errorf("cannot get user role on path %s: %s", path, err)
+		return false
+	}
+
+	for o := range chanOut {
+		for _, u := range o.RoleMap[acl.Manager] {
+			if u == username {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	ufp "github.com/dccn-tg/tg-toolset-golang/pkg/filepath"
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/acl"
+)
+
+// rewriteMain implements the principal rewrite (`--rewrite`) and the rollback
+// (`--rollback`) modes of the program.
+func rewriteMain(args []string) error {
+
+	fs := flag.NewFlagSet("prj_rewrite", flag.ExitOnError)
+	optsRewrite := fs.String("rewrite", "", "rewrite owner and ACL entries from user `OLD:NEW`")
+	optsPreview := fs.Bool("preview", false, "show the changes without applying them")
+	optsJournal := fs.String("journal", "", "`path` of the journal file (default: prj_rewrite-<timestamp>.journal)")
+	optsRollback := fs.String("rollback", "", "revert changes recorded in the journal `file`")
+	optsNthreads := fs.Int("nthreads", 4, "`number` of concurrent processing threads")
+	fs.Usage = usage
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	caller, err := user.Current()
+	if err != nil {
+		return err
+	}
+
+	if *optsRollback != "" {
+		return rollback(*optsRollback, caller)
+	}
+
+	if *optsRewrite == "" {
+		fs.Usage()
+		return fmt.Errorf("neither --rewrite nor --rollback is given")
+	}
+
+	users := strings.Split(*optsRewrite, ":")
+	if len(users) != 2 {
+		return fmt.Errorf("invalid rewrite specification: %s", *optsRewrite)
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no path is given")
+	}
+
+	rewriter, err := acl.NewPrincipalRewriter(users[0], users[1], *optsPreview)
+	if err != nil {
+		return err
+	}
+
+	// the journal is only written when changes are applied.
+	var journal *acl.Journal
+	if !*optsPreview {
+		jpath := *optsJournal
+		if jpath == "" {
+			jpath = fmt.Sprintf("prj_rewrite-%s.journal", time.Now().Format("20060102T150405"))
+		}
+		f, err := os.OpenFile(jpath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("cannot open journal %s: %s", jpath, err)
+		}
+		defer f.Close()
+		if journal, err = acl.NewJournal(f, users[0], users[1]); err != nil {
+			return fmt.Errorf("cannot write journal %s: %s", jpath, err)
+		}
+		fmt.Printf("journal: %s\n", jpath)
+	}
+
+	for _, p := range fs.Args() {
+
+		ppath, _ := filepath.Abs(p)
+
+		if !isManager(ppath, caller.Username) {
+			log.Errorf("permission denied: %s is not a manager of %s", caller.Username, ppath)
+			continue
+		}
+
+		rewriteTree(ppath, rewriter, journal, *optsNthreads)
+	}
+
+	return nil
+}
+
+// rewriteTree walks through the `root` and rewrites principals on every visited path
+// using `nthreads` concurrent workers.  Changes are printed to the stdout and written to
+// the `journal` if it is not nil.
+func rewriteTree(root string, rewriter *acl.PrincipalRewriter, journal *acl.Journal, nthreads int) {
+
+	chanF := ufp.GoFastWalk(root, false, false, nthreads*4)
+
+	var mux sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(nthreads)
+	for i := 0; i < nthreads; i++ {
+		go func() {
+			defer wg.Done()
+			for f := range chanF {
+				entries, err := rewriter.Rewrite(f)
+
+				mux.Lock()
+				for _, e := range entries {
+					fmt.Printf("%s\n", e)
+					if journal == nil {
+						continue
+					}
+					if err := journal.Write(e); err != nil {
+						log.Errorf("cannot write journal entry for %s: %s", e.Path, err)
+					}
+				}
+				mux.Unlock()
+
+				if err != nil {
+					log.Errorf("%s: %s", f.Path, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// rollback reverts the changes recorded in the journal file `jpath`. The entries
+// are reverted in the reverse order they were recorded.
+//
+// As the program runs with capabilities, the journal is only accepted if it is owned by
+// the caller and not writable by others.  Each entry should be a change of the principal
+// rewrite recorded in the journal header, on a path that resolves into a project
+// directory managed by the caller.
+func rollback(jpath string, caller *user.User) error {
+
+	f, err := os.Open(jpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := checkJournalFile(f, caller); err != nil {
+		return fmt.Errorf("untrusted journal %s: %s", jpath, err)
+	}
+
+	header, entries, err := acl.ReadJournal(f)
+	if err != nil {
+		return err
+	}
+
+	rewriter, err := acl.NewPrincipalRewriter(header.From, header.To, false)
+	if err != nil {
+		return err
+	}
+
+	// manager check is made once per project directory.
+	managerOf := make(map[string]bool)
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+
+		rpath, err := resolveEntryPath(e)
+		if err != nil {
+			log.Errorf("cannot resolve %s: %s", e.Path, err)
+			continue
+		}
+
+		ptop := projectDir(rpath)
+		if _, ok := managerOf[ptop]; !ok {
+			managerOf[ptop] = ptop != rpath && isManager(ptop, caller.Username)
+		}
+		if !managerOf[ptop] {
+			log.Errorf("permission denied: %s is not a manager of %s", caller.Username, rpath)
+			continue
+		}
+
+		e.Path = rpath
+		if err := rewriter.Rollback(e); err != nil {
+			log.Errorf("cannot rollback %s: %s", e, err)
+			continue
+		}
+		fmt.Printf("reverted: %s\n", e)
+	}
+
+	return nil
+}
+
+// checkJournalFile checks that the journal file `f` is a regular file owned by the
+// `caller` and not writable by the group or others.
+func checkJournalFile(f *os.File, caller *user.User) error {
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("not a regular file")
+	}
+
+	if fi.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("writable by group or others")
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || strconv.Itoa(int(st.Uid)) != caller.Uid {
+		return fmt.Errorf("not owned by %s", caller.Username)
+	}
+
+	return nil
+}
+
+// resolveEntryPath resolves the symbolic links in the path of the journal entry `e`.
+// The owner is changed on the path itself, so that only the parent directory is
+// resolved for the owner entries; the ACLs are set on the target of the path.
+func resolveEntryPath(e acl.JournalEntry) (string, error) {
+
+	if !filepath.IsAbs(e.Path) {
+		return "", fmt.Errorf("not an absolute path")
+	}
+
+	p := filepath.Clean(e.Path)
+
+	if e.Kind != acl.JournalOwner {
+		return filepath.EvalSymlinks(p)
+	}
+
+	dir, err := filepath.EvalSymlinks(filepath.Dir(p))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(p)), nil
+}
+
+// projectDir returns the project directory of `p`, i.e. the first two path elements
+// (e.g. `/project/3010000.01`).
+func projectDir(p string) string {
+	d := strings.Split(filepath.Clean(p), string(os.PathSeparator))
+	if len(d) < 3 {
+		return p
+	}
+	return strings.Join(d[:3], string(os.PathSeparator))
+}
@@ -43,6 +43,7 @@ var optsVerbose *bool
 var optsSilence *bool
 var optsFollowLink *bool
 var optsSkipFiles *bool
+var optsImport *string
 
 func init() {
 	optsManager = flag.String("m", "", "specify a comma-separated-list of users for the manager role")
@@ -58,6 +59,7 @@ func init() {
 	optsSilence = flag.Bool("s", false, "set to `silence` mode")
 	optsFollowLink = flag.Bool("l", false, "`follow` symlink to set roles on its first non-symlink referent")
 	optsSkipFiles = flag.Bool("k", false, "`skip` setting roles on existing files")
+	optsImport = flag.String("import", "", "import roles from a portable `file` created by \"prj_getacl -export\"")
 
 	flag.Usage = usage
 
@@ -89,6 +91,8 @@ func usage() {
 	fmt.Printf("\n  %s -m honlee -u edwger 3010000.01\n", os.Args[0])
 	fmt.Printf("\n%s\n", ustr.StringWrap("Adding or setting users 'honlee' and 'edwger' to the 'contributor' role on a specific path, and allowing the two users to traverse through the parent directories", 80))
 	fmt.Printf("\n  %s -c honlee,edwger /project/3010000.01/data_dir\n", os.Args[0])
+	fmt.Printf("\n%s\n", ustr.StringWrap("Importing roles from a portable file onto project 3010000.01, e.g. after migrating the project to another storage system", 80))
+	fmt.Printf("\n  %s -import 3010000.01.acl.json -d /project_cephfs 3010000.01\n", os.Args[0])
 	fmt.Printf("\n")
 }
 
@@ -123,9 +127,35 @@ func main() {
 		Nthreads:     *optsNthreads,
 	}
 
+	if *optsImport != "" {
+		if err := importRoles(runner, *optsImport); err != nil {
+			log.Fatalf("%s", err)
+		}
+		os.Exit(0)
+	}
+
 	exitcode, err := runner.SetRoles()
 	if err != nil {
 		log.Fatalf("%s", err)
 	}
 	os.Exit(exitcode)
 }
+
+// importRoles applies roles from the portable file `fpath` using the `runner`.
+func importRoles(runner acl.Runner, fpath string) error {
+
+	f, err := os.Open(fpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pacl, err := acl.ReadPortableACL(f)
+	if err != nil {
+		return fmt.Errorf("cannot read portable ACL %s: %s", fpath, err)
+	}
+
+	log.Infof("importing roles of %d paths exported from %s", len(pacl.Entries), pacl.Root)
+
+	return runner.ImportRoles(pacl)
+}
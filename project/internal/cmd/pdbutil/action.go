@@ -0,0 +1,409 @@
+package pdbutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	"github.com/dccn-tg/tg-toolset-golang/pkg/mailer"
+	"github.com/dccn-tg/tg-toolset-golang/pkg/store"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/alert"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/identity"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/journal"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
+	"github.com/spf13/cobra"
+)
+
+var (
+	actionLockPath     string        = "action.lock"
+	journalDbPath      string        = "action.db"
+	journalMaxAttempts int           = 5
+	journalBackoff     time.Duration = 10 * time.Minute
+	journalMaxBackoff  time.Duration = 12 * time.Hour
+	historyVerbose     bool          = false
+	actionFile         string
+	actionSkipNotify   bool = false
+
+	// roleChanges collects the role changes applied by the pending actions of a run, for
+	// notifying the members and the managers afterwards.  It is nil if the notifications
+	// are skipped.
+	roleChanges *alert.Membership
+)
+
+func init() {
+
+	projectActionCmd.PersistentFlags().StringVarP(&journalDbPath, "journal", "j", journalDbPath,
+		"`path` of the internal journal database of pending actions")
+
+	projectActionExecCmd.Flags().StringVarP(&actionLockPath, "lock", "", actionLockPath,
+		"`path` of the lock file guarding against concurrent executions of pending actions")
+
+	projectActionExecCmd.Flags().IntVarP(&journalMaxAttempts, "max-attempts", "", journalMaxAttempts,
+		"max. `number` of attempts before an action is moved into the dead-letter state")
+
+	projectActionExecCmd.Flags().DurationVarP(&journalBackoff, "backoff", "", journalBackoff,
+		"`duration` to wait before retrying a failed action, doubled after each failure")
+
+	projectActionExecCmd.Flags().DurationVarP(&journalMaxBackoff, "max-backoff", "", journalMaxBackoff,
+		"max. `duration` to wait before retrying a failed action")
+
+	projectActionExecCmd.Flags().StringVarP(&actionFile, "file", "f", actionFile,
+		"`path` of the JSON file with the actions to execute instead of the pending actions of the project database, e.g. the file written by \"check --emit-actions\"; the pending actions of the project database are left untouched")
+
+	projectActionExecCmd.Flags().BoolVarP(&actionSkipNotify, "skip-notify", "", actionSkipNotify,
+		"skip notifying the members and the managers of the role changes applied by the actions")
+
+	projectActionHistoryCmd.Flags().BoolVarP(&historyVerbose, "long", "l", historyVerbose,
+		"show also the details of the actions")
+
+	projectActionCmd.AddCommand(projectActionHistoryCmd, projectActionResetCmd)
+}
+
+// openJournal connects the journal database and returns the `journal.Journal`.  The
+// returned `store.KVStore` should be disconnected by the caller.
+func openJournal() (journal.Journal, *store.KVStore, error) {
+	s := &store.KVStore{Path: journalDbPath, Timeout: 10 * time.Second}
+	if err := s.Connect(); err != nil {
+		return journal.Journal{}, nil, err
+	}
+
+	j := journal.Journal{
+		Store:       s,
+		MaxAttempts: journalMaxAttempts,
+		Backoff:     journalBackoff,
+		MaxBackoff:  journalMaxBackoff,
+	}
+
+	if err := j.Init(); err != nil {
+		s.Disconnect()
+		return j, nil, err
+	}
+
+	return j, s, nil
+}
+
+// loadActions returns the pending actions from the project database, or from the JSON
+// file `actionFile` if it is given.
+func loadActions() (map[string]*pdb.DataProjectUpdate, error) {
+	if actionFile == "" {
+		return loadPdb().GetProjectPendingActions()
+	}
+
+	data, err := os.ReadFile(actionFile)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := make(map[string]*pdb.DataProjectUpdate)
+	if err := json.Unmarshal(data, &actions); err != nil {
+		return nil, fmt.Errorf("invalid actions in %s: %s", actionFile, err)
+	}
+	return actions, nil
+}
+
+// notifyRoleChanges sends the notifications of the role changes collected in `changes`,
+// one message per recipient, using the membership templates of the alerts configuration.
+// Failures are logged, and don't affect the applied actions.
+func notifyRoleChanges(changes *alert.Membership) {
+
+	ns := changes.Notifications()
+	if len(ns) == 0 {
+		return
+	}
+
+	conf := loadConfig()
+	ipdb := loadPdb()
+
+	policy, err := alert.NewPolicy(conf.Alerts)
+	if err != nil {
+		log.Errorf("cannot notify role changes, invalid alerts configuration: %s", err)
+		return
+	}
+
+	m, err := mailer.New(conf.Mailer, mailer.SMTP)
+	if err != nil {
+		log.Errorf("cannot initialize mailer to notify role changes: %s", err)
+		return
+	}
+
+	// users and projects are retrieved once for all the notifications.
+	users := make(map[string]*pdb.User)
+	user := func(uid string) *pdb.User {
+		u, ok := users[uid]
+		if !ok {
+			if u, err = ipdb.GetUser(uid); err != nil {
+				log.Errorf("cannot get user %s: %s", uid, err)
+			}
+			users[uid] = u
+		}
+		return u
+	}
+
+	titles := make(map[string]string)
+	title := func(pid string) string {
+		t, ok := titles[pid]
+		if !ok {
+			if p, err := ipdb.GetProject(pid); err != nil {
+				log.Errorf("[%s] cannot get project: %s", pid, err)
+			} else {
+				t = p.Name
+			}
+			titles[pid] = t
+		}
+		return t
+	}
+
+	sent := 0
+	for _, n := range ns {
+
+		r := user(n.Recipient)
+		if r == nil || r.Email == "" {
+			log.Warnf("skip notifying %s of role changes: unknown email", n.Recipient)
+			continue
+		}
+
+		data := mailer.ProjectMembershipTemplateData{
+			RecipientName: r.DisplayName(),
+			SenderName:    policy.Sender,
+		}
+		for _, c := range n.Changes {
+			c.ProjectTitle = title(c.ProjectID)
+			c.MemberName = c.MemberID
+			if u := user(c.MemberID); u != nil {
+				c.MemberName = u.DisplayName()
+			}
+			data.Changes = append(data.Changes, c)
+		}
+
+		tmpl := policy.Membership.Template
+		if n.Manager {
+			tmpl = policy.Membership.ManagerTemplate
+		}
+
+		subject, body, err := mailer.ComposeMessageFromTemplateFile(tmpl, data)
+		if err != nil {
+			log.Errorf("skip notifying %s of role changes: %s", n.Recipient, err)
+			continue
+		}
+
+		log.Debugf("notifying %s of %d role changes", r.Email, len(data.Changes))
+
+		if err := m.SendMail(policy.SenderEmail, subject, body, []string{r.Email}, policy.Membership.CarbonCopy...); err != nil {
+			log.Errorf("fail notifying %s of role changes: %s", r.Email, err)
+			continue
+		}
+		sent++
+	}
+
+	log.Infof("role change notifications sent: %d of %d", sent, len(ns))
+}
+
+// operator returns the identity of the one performing the actions, i.e. `user@host`.
+func operator() string {
+	uname := "unknown"
+	if u, err := user.Current(); err == nil {
+		uname = u.Username
+	}
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s@%s", uname, host)
+}
+
+// actionStats counts the outcomes of the pending actions processed by `runActions`.
+type actionStats struct {
+	Applied int
+	Failed  int
+	Skipped int
+}
+
+// splitActions splits the pending `actions` by the storage system on which they are
+// executed (see `actionStorageSystem`) into those on the storage `system` and the others.
+// The storage information of the projects is retrieved with `getProject`.  Actions of
+// which the storage system cannot be determined are put on the `system`, so that they
+// are never executed concurrently on it.
+func splitActions(actions map[string]*pdb.DataProjectUpdate, system string, getProject func(pid string) (*pdb.DataProjectInfo, error)) (on, others map[string]*pdb.DataProjectUpdate) {
+
+	on = make(map[string]*pdb.DataProjectUpdate)
+	others = make(map[string]*pdb.DataProjectUpdate)
+
+	for pid, act := range actions {
+		info, err := getProject(pid)
+		if err != nil {
+			info = nil
+		}
+		if s, err := actionStorageSystem(act, info); err != nil || s == system {
+			on[pid] = act
+		} else {
+			others[pid] = act
+		}
+	}
+	return
+}
+
+// runActions executes the pending `actions` with `nthreads` concurrent workers, and
+// bookkeeps the attempts in the journal `j`.  Members are validated against the identity
+// `resolver`, which is shared by the workers.  Actions not yet started are skipped when
+// the `ctx` is cancelled; the ongoing ones are completed.
+func runActions(ctx context.Context, j journal.Journal, actions map[string]*pdb.DataProjectUpdate, nthreads int, resolver identity.Resolver) actionStats {
+
+	var stats actionStats
+	var mutex sync.Mutex
+
+	count := func(n *int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		*n++
+	}
+
+	by := operator()
+
+	var wg sync.WaitGroup
+	pids := make(chan string, nthreads*2)
+	for w := 0; w < nthreads; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pid := range pids {
+				action := actions[pid]
+
+				e, due, err := j.Begin(pid, action, time.Now())
+				if err != nil {
+					log.Errorf("[%s] cannot register action in journal: %s", pid, err)
+					count(&stats.Failed)
+					continue
+				}
+
+				if !due {
+					switch e.Status {
+					case journal.StatusDead:
+						log.Warnf("[%s] action skipped, dead after %d attempts: %s", pid, e.Attempts, e.LastError)
+					default:
+						log.Infof("[%s] action skipped, next attempt at %s", pid, e.NextAttempt.Format(time.RFC3339))
+					}
+					count(&stats.Skipped)
+					continue
+				}
+
+				if err := actionExec(pid, action, resolver); err != nil {
+					log.Errorf("%s", err)
+					if err := j.Fail(e, err, by, time.Now()); err != nil {
+						log.Errorf("[%s] cannot record failure in journal: %s", pid, err)
+					}
+					count(&stats.Failed)
+					continue
+				}
+
+				if err := j.Succeed(e, by, time.Now()); err != nil {
+					log.Errorf("[%s] cannot record success in journal: %s", pid, err)
+				}
+				count(&stats.Applied)
+			}
+		}()
+	}
+
+dispatch:
+	for pid := range actions {
+		select {
+		case <-ctx.Done():
+			log.Infof("stop dispatching pending actions: %s", ctx.Err())
+			break dispatch
+		case pids <- pid:
+		}
+	}
+	close(pids)
+
+	wg.Wait()
+
+	return stats
+}
+
+// subcommand to show the history of executed pending actions.
+var projectActionHistoryCmd = &cobra.Command{
+	Use:   "history [projectID]",
+	Short: "Shows the history of executed pending actions",
+	Long: `Shows what was applied when and by whom, including the failed attempts.
+
+Actions still in process (i.e. failed and to be retried, or in the dead-letter state)
+are listed after the history.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		pid := ""
+		if len(args) == 1 {
+			pid = args[0]
+		}
+
+		j, s, err := openJournal()
+		if err != nil {
+			return err
+		}
+		defer s.Disconnect()
+
+		records, err := j.History(pid)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TIME\tPROJECT\tSTATUS\tATTEMPT\tBY\tERROR")
+		for _, r := range records {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n",
+				r.Time.Format(time.RFC3339), r.ProjectID, r.Status, r.Attempt, r.By, r.Error)
+			if historyVerbose {
+				data, _ := json.Marshal(r.Action)
+				fmt.Fprintf(tw, "\t\t%s\t\t\t\n", data)
+			}
+		}
+		tw.Flush()
+
+		entries, err := j.Entries()
+		if err != nil {
+			return err
+		}
+
+		inProcess := false
+		for _, e := range entries {
+			if (pid != "" && e.ProjectID != pid) || e.Attempts == 0 {
+				continue
+			}
+			if !inProcess {
+				fmt.Printf("\nactions in process:\n")
+				tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(tw, "PROJECT\tSTATUS\tATTEMPTS\tNEXT ATTEMPT\tLAST ERROR")
+				inProcess = true
+			}
+			next := "-"
+			if !e.NextAttempt.IsZero() {
+				next = e.NextAttempt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", e.ProjectID, e.Status, e.Attempts, next, e.LastError)
+		}
+		if inProcess {
+			tw.Flush()
+		}
+
+		return nil
+	},
+}
+
+// subcommand to reset a pending action in the journal.
+var projectActionResetCmd = &cobra.Command{
+	Use:   "reset [projectID]",
+	Short: "Resets a failed or dead-letter pending action to be retried at the next execution",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		j, s, err := openJournal()
+		if err != nil {
+			return err
+		}
+		defer s.Disconnect()
+
+		return j.Reset(args[0])
+	},
+}
@@ -0,0 +1,640 @@
+package pdbutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/config"
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	"github.com/dccn-tg/tg-toolset-golang/pkg/mailer"
+	"github.com/dccn-tg/tg-toolset-golang/pkg/store"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/alert"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/filergateway"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
+	"github.com/spf13/cobra"
+)
+
+var (
+	alertDigestModes  []string
+	alertHistorySince string
+	alertHistoryUntil string
+	alertHistoryTo    string
+	alertHistoryType  string
+	alertHistoryCSV   bool
+	alertResetTypes   []string
+
+	// alertHistory is the history in which the alerts sent are recorded; nothing is
+	// recorded if it is nil.
+	alertHistory *alert.History
+)
+
+func init() {
+
+	projectAlertDigestCmd.Flags().StringSliceVarP(&alertDigestModes, "modes", "m", alertDigestModes,
+		"comma-separated oot alert `modes` included in the digest; all modes in the alerts configuration if not specified")
+
+	projectAlertHistoryCmd.Flags().StringVarP(&alertHistorySince, "since", "", alertHistorySince,
+		"show only alerts sent on or after the `date` (YYYY-MM-DD)")
+
+	projectAlertHistoryCmd.Flags().StringVarP(&alertHistoryUntil, "until", "", alertHistoryUntil,
+		"show only alerts sent on or before the `date` (YYYY-MM-DD)")
+
+	projectAlertHistoryCmd.Flags().StringVarP(&alertHistoryTo, "recipient", "r", alertHistoryTo,
+		"show only alerts sent to the recipient `email`")
+
+	projectAlertHistoryCmd.Flags().StringVarP(&alertHistoryType, "type", "", alertHistoryType,
+		"show only alerts of the `type`, i.e. ooq or oot")
+
+	projectAlertHistoryCmd.Flags().BoolVarP(&alertHistoryCSV, "csv", "", alertHistoryCSV,
+		"export the alerts in CSV format")
+
+	projectAlertResetCmd.Flags().StringSliceVarP(&alertResetTypes, "type", "", alertResetTypes,
+		"comma-separated alert `types` to reset, i.e. ooq and/or oot; all types if not specified")
+
+	projectAlertCmd.AddCommand(projectAlertDigestCmd, projectAlertHistoryCmd, projectAlertResetCmd)
+}
+
+// alertDispatcher delivers the alert of a project to a recipient.
+type alertDispatcher interface {
+	// dispatch delivers the alert `data` to the recipient `u`, using the template file `tmpl`
+	// and the carbon copies `cc`.  An error is returned if the alert cannot be composed or
+	// sent, in which case the recipient is not recorded as alerted.
+	dispatch(u *pdb.User, tmpl string, data mailer.ProjectAlertTemplateData, cc ...string) error
+
+	// commit bookkeeps the dispatched alert once it is delivered, i.e. records `r` in the
+	// history and calls `save` to store the last alert of the project.  `save` is called
+	// right away if `r` is nil, i.e. no recipient is alerted.
+	commit(r *alert.Record, save func())
+}
+
+// mailDispatcher sends every alert immediately as an email.  In the dry-run mode, the
+// alerts are only logged.
+type mailDispatcher struct {
+	m    mailer.Mailer
+	from string
+}
+
+func (d mailDispatcher) dispatch(u *pdb.User, tmpl string, data mailer.ProjectAlertTemplateData, cc ...string) error {
+
+	data.RecipientName = u.DisplayName()
+
+	subject, body, err := mailer.ComposeMessageFromTemplateFile(tmpl, data)
+	if err != nil {
+		return err
+	}
+
+	if alertDryrun {
+		if data.AlertType == alert.TypeOoq {
+			log.Infof("[%s] alert %s on usage ratio: %d", data.ProjectID, u.Email, data.QuotaUsageRatio)
+		} else {
+			log.Infof("[%s] alert %s", data.ProjectID, u.Email)
+		}
+		return nil
+	}
+
+	if err := d.m.SendMail(d.from, subject, body, []string{u.Email}, cc...); err != nil {
+		return fmt.Errorf("fail to sent %s alert to %s: %s", data.AlertType, u.Email, err)
+	}
+
+	return nil
+}
+
+// commit records the alert right away, as it is sent by `dispatch`.
+func (d mailDispatcher) commit(r *alert.Record, save func()) {
+	if r != nil {
+		recordAlert(*r)
+	}
+	save()
+}
+
+// digestDispatcher collects the alerts into a digest, sent afterwards by `flush`.  The
+// per-alert template is not used.  The alerts are committed by `flush` after the digests
+// are sent.
+type digestDispatcher struct {
+	digest *alert.Digest
+
+	mutex   sync.Mutex
+	pending []pendingAlert
+}
+
+// pendingAlert is an alert in the digest to be committed after the digests are sent.
+type pendingAlert struct {
+	record *alert.Record
+	save   func()
+}
+
+func (d *digestDispatcher) dispatch(u *pdb.User, tmpl string, data mailer.ProjectAlertTemplateData, cc ...string) error {
+	log.Debugf("[%s] add %s alert for %s to digest", data.ProjectID, data.AlertType, u.Email)
+	d.digest.Add(u, data, cc...)
+	return nil
+}
+
+// commit defers the bookkeeping of the alert until the digests are sent by `flush`.
+func (d *digestDispatcher) commit(r *alert.Record, save func()) {
+	if r == nil {
+		save()
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.pending = append(d.pending, pendingAlert{record: r, save: save})
+}
+
+// flush sends one message per recipient with all the alerts collected in the digest,
+// composed from the digest template of the `policy`.  It returns the number of messages
+// sent.
+//
+// The alerts are then committed with the recipients to whom the digest is sent; alerts
+// of which no digest is sent are not committed, so that they are sent again at the next
+// run.
+func (d *digestDispatcher) flush(m mailer.Mailer, policy *alert.Policy) int {
+
+	// delivered alerts keyed by the recipient email, the project and the alert type.
+	delivered := make(map[string]bool)
+	deliveryKey := func(email, pid, atype string) string {
+		return strings.ToLower(email) + "/" + pid + "/" + atype
+	}
+
+	nsent := 0
+	for _, e := range d.digest.Entries() {
+
+		u := e.Recipient
+
+		subject, body, err := mailer.ComposeMessageFromTemplateFile(policy.Digest.Template, e.TemplateData(policy.Sender))
+		if err != nil {
+			log.Errorf("skip alert digest to %s due to failure generating digest: %s", u.Email, err)
+			continue
+		}
+
+		cc := append(append([]string{}, e.CarbonCopy...), policy.Digest.CarbonCopy...)
+
+		if alertDryrun {
+			log.Infof("alert digest %s with %d alerts, cc: %v", u.Email, len(e.Projects), cc)
+		} else if err := m.SendMail(policy.SenderEmail, subject, body, []string{u.Email}, cc...); err != nil {
+			log.Errorf("fail to sent alert digest to %s: %s", u.Email, err)
+			continue
+		}
+
+		for _, p := range e.Projects {
+			delivered[deliveryKey(u.Email, p.ProjectID, p.AlertType)] = true
+		}
+		nsent++
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, a := range d.pending {
+		r := *a.record
+
+		recipients := []string{}
+		for _, email := range r.Recipients {
+			if delivered[deliveryKey(email, r.ProjectID, r.Type)] {
+				recipients = append(recipients, email)
+			}
+		}
+
+		if len(recipients) == 0 {
+			log.Warnf("[%s] %s alert not delivered to any recipient, to be sent again", r.ProjectID, r.Type)
+			continue
+		}
+
+		r.Recipients = recipients
+		r.Digest = true
+		r.Template = policy.Digest.Template
+		recordAlert(r)
+		a.save()
+	}
+	d.pending = nil
+
+	return nsent
+}
+
+// submcommand to send all pending alerts grouped per recipient.
+var projectAlertDigestCmd = &cobra.Command{
+	Use:   "digest",
+	Short: "Sends all pending project alerts grouped into one message per recipient",
+	Long: `Sends all pending project alerts grouped into one message per recipient.
+
+The out-of-quota alerts and the out-of-time alerts of the given modes are checked
+as with the "ooq send" and "oot send" commands, and the last alert of each project
+is bookkept in the same way.  Instead of one message per project, all the alerts
+to a recipient are combined into one message composed from the digest template
+(alerts.digest.template in the configuration file).  The command fails if the
+digest template is not found.
+
+The last alert of a project and the alert history are updated only after the
+digest is sent; alerts of which no digest is delivered are sent again at the
+next run.
+
+The digest template is given the recipient name (.RecipientName), the sender name
+(.SenderName) and the list of project alerts (.Projects).  Each project alert has
+the same data as the template of the individual alert, and the type of the alert
+(.AlertType) being either "ooq" or "oot", e.g.
+
+    Alerts on your projects
+
+    Dear {{.RecipientName}},
+    {{range .Projects}}
+    {{- if eq .AlertType "ooq"}}
+      * {{.ProjectID}}: {{.QuotaUsageRatio}}% of the storage quota is used.
+    {{- else}}
+      * {{.ProjectID}}: the project ends on {{.ProjectEndDate}}.
+    {{- end}}
+    {{- end}}
+
+    Best regards, {{.SenderName}}`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		ipdb := loadPdb()
+		conf := loadConfig()
+		policy := loadAlertPolicy(cmd, conf)
+
+		// fail before any alert is bookkept rather than at sending the digests.
+		if _, err := os.Stat(policy.Digest.Template); err != nil {
+			return fmt.Errorf("digest template not found: %s", err)
+		}
+
+		names := alertDigestModes
+		if !cmd.Flags().Changed("modes") {
+			names = policy.OotModeNames()
+		}
+
+		modes := make([]config.OotAlertMode, 0, len(names))
+		for _, name := range names {
+			mode, ok := policy.OotMode(name)
+			if !ok {
+				return fmt.Errorf("unknown alert mode %s, supported modes: %s", name, strings.Join(policy.OotModeNames(), ","))
+			}
+			modes = append(modes, mode)
+		}
+
+		projects, err := ipdb.GetProjects(true)
+		if err != nil {
+			return err
+		}
+
+		// initialize filergateway client
+		fgw, err := filergateway.NewClient(conf)
+		if err != nil {
+			return err
+		}
+
+		// connect to internal database for last sent
+		kvstore := store.KVStore{
+			Path: alertDbPath,
+		}
+		if err := kvstore.Connect(); err != nil {
+			return err
+		}
+		defer kvstore.Disconnect()
+
+		alertHistory = &alert.History{Store: &kvstore}
+		if err := alertHistory.Init(); err != nil {
+			return err
+		}
+
+		m, err := mailer.New(conf.Mailer, mailer.SMTP)
+		if err != nil {
+			return err
+		}
+
+		d := &digestDispatcher{digest: alert.NewDigest()}
+
+		sendOoqAlerts(ipdb, fgw, &kvstore, projects, d, policy)
+		for _, mode := range modes {
+			sendOotAlerts(ipdb, fgw, &kvstore, projects, d, policy, mode)
+		}
+
+		log.Infof("%d alert digests sent", d.flush(m, policy))
+
+		return nil
+	},
+}
+
+// openAlertHistory connects to the internal alert database at `alertDbPath`, and returns
+// the alert history in it.  The caller is responsible for disconnecting the returned store.
+func openAlertHistory() (*alert.History, *store.KVStore, error) {
+
+	// check availability of the `alertDbPath`
+	if _, err := os.Stat(alertDbPath); os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("alert db not found: %s", alertDbPath)
+	}
+
+	kvstore := &store.KVStore{
+		Path: alertDbPath,
+	}
+	if err := kvstore.Connect(); err != nil {
+		return nil, nil, err
+	}
+
+	h := &alert.History{Store: kvstore}
+	if err := h.Init(); err != nil {
+		kvstore.Disconnect()
+		return nil, nil, err
+	}
+
+	return h, kvstore, nil
+}
+
+// subcommand to show the history of alerts sent.
+var projectAlertHistoryCmd = &cobra.Command{
+	Use:   "history [projectID]",
+	Short: "Shows the history of alerts sent",
+	Long: `Shows the alerts sent with the recipients, the mode (oot), the usage (ooq) and
+the template, in chronological order.
+
+The alerts can be selected by project, date range, recipient and type, and exported
+in CSV format with the --csv option.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		q := alert.Query{
+			Type:      alertHistoryType,
+			Recipient: alertHistoryTo,
+		}
+
+		if len(args) == 1 {
+			q.ProjectID = args[0]
+		}
+
+		if q.Type != "" && q.Type != alert.TypeOoq && q.Type != alert.TypeOot {
+			return fmt.Errorf("unknown alert type: %s", q.Type)
+		}
+
+		if alertHistorySince != "" {
+			t, err := time.ParseInLocation(dateLayout, alertHistorySince, time.Local)
+			if err != nil {
+				return fmt.Errorf("invalid date: %s", alertHistorySince)
+			}
+			q.From = t
+		}
+
+		if alertHistoryUntil != "" {
+			t, err := time.ParseInLocation(dateLayout, alertHistoryUntil, time.Local)
+			if err != nil {
+				return fmt.Errorf("invalid date: %s", alertHistoryUntil)
+			}
+			q.To = t.AddDate(0, 0, 1)
+		}
+
+		h, kvstore, err := openAlertHistory()
+		if err != nil {
+			return err
+		}
+		defer kvstore.Disconnect()
+
+		records, err := h.Records(q)
+		if err != nil {
+			return err
+		}
+
+		if alertHistoryCSV {
+			return alert.WriteCSV(os.Stdout, records)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TIME\tPROJECT\tTYPE\tMODE/USAGE\tTEMPLATE\tRECIPIENTS")
+		for _, r := range records {
+			detail := r.Mode
+			if r.Type == alert.TypeOoq {
+				detail = fmt.Sprintf("%d%%", r.UsagePercent)
+			}
+			tmpl := r.Template
+			if r.Digest {
+				tmpl = fmt.Sprintf("%s (digest)", tmpl)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				r.Time.Format(time.RFC3339), r.ProjectID, r.Type, detail, tmpl, strings.Join(r.Recipients, ","))
+		}
+		tw.Flush()
+
+		return nil
+	},
+}
+
+// subcommand to reset the alert state of projects.
+var projectAlertResetCmd = &cobra.Command{
+	Use:   "reset projectID [projectID...]",
+	Short: "Resets the last alerts of projects so that alerts are sent again",
+	Long: `Resets the last alerts of projects so that alerts are sent again at the next run,
+regardless of the alerts sent before.  The alert history is kept.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		h, kvstore, err := openAlertHistory()
+		if err != nil {
+			return err
+		}
+		defer kvstore.Disconnect()
+
+		for _, pid := range args {
+			if err := h.Reset(pid, alertResetTypes...); err != nil {
+				return err
+			}
+			log.Infof("[%s] alert state reset", pid)
+		}
+
+		return nil
+	},
+}
+
+// recordAlert records the alert `r` in the `alertHistory`.  Nothing is recorded in the
+// dry-run mode.
+func recordAlert(r alert.Record) {
+
+	if alertDryrun || alertHistory == nil {
+		return
+	}
+
+	if err := alertHistory.Add(r); err != nil {
+		log.Errorf("[%s] fail to record %s alert: %s", r.ProjectID, r.Type, err)
+	}
+}
+
+// sendOotAlerts checks the `projects` ending on the date selected by the alert `mode`,
+// and dispatches the out-of-time alerts via `d`.  The last alert of each project is
+// updated in `kvstore` once the alert is committed by `d`, except in the dry-run mode.
+func sendOotAlerts(ipdb pdb.PDB, fgw filergateway.Client, kvstore *store.KVStore, projects []*pdb.Project, d alertDispatcher, policy *alert.Policy, mode config.OotAlertMode) {
+
+	// perform pending actions with 4 concurrent workers,
+	// each works on a project.
+	var wg sync.WaitGroup
+	cprjs := make(chan *pdb.Project, execNthreads*2)
+	for w := 0; w < execNthreads; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for prj := range cprjs {
+
+				// perform test on a specific project with id specified via
+				// `ootAlertTestProjectID`.
+				if alertTestProjectID != "" && prj.ID != alertTestProjectID {
+					log.Infof("[%s] ignored oot alert in test mode", prj.ID)
+					continue
+				}
+
+				// get members from filer gateway
+				info, err := fgw.GetProject(prj.ID)
+				if err != nil {
+					log.Errorf("[%s] cannot get project storage info: %s", prj.ID, err)
+					continue
+				}
+				log.Debugf("[%s] project storage info: %+v", prj.ID, info)
+
+				// get last alert information from the local db
+				data, err := kvstore.Get(alert.BucketOotLastAlerts, []byte(prj.ID))
+				if err != nil {
+					log.Debugf("[%s] cannot get last oot alert data: %s", prj.ID, err)
+				}
+
+				// default lastAlert with timestamp (0001-01-01 00:00:00 +0000 UTC), uratio 0.
+				lastAlert := pdb.OotLastAlert{}
+				if data != nil {
+					if err := json.Unmarshal(data, &lastAlert); err != nil {
+						log.Debugf("[%s] cannot interpret last oot alert data: %s", prj.ID, err)
+					}
+				}
+				log.Debugf("[%s] last oot alert: %+v", prj.ID, lastAlert)
+
+				// check and send alert
+				lastAlert, record, err := ootAlert(ipdb, prj, info, lastAlert, d, policy, mode)
+
+				// do nothing to the db for dryrun
+				if alertDryrun {
+					continue
+				}
+
+				switch err.(type) {
+				case nil:
+					log.Debugf("[%s] last oot alert: %+v", prj.ID, lastAlert)
+					// alert sent, update store db with new last alert information once
+					// the alert is delivered.
+					data, _ := json.Marshal(&lastAlert)
+					pid := prj.ID
+					d.commit(record, func() {
+						kvstore.Set(alert.BucketOotLastAlerts, []byte(pid), data)
+					})
+				case *pdb.OpsIgnored:
+					// alert ignored
+					log.Debugf("[%s] %s", prj.ID, err)
+					log.Debugf("[%s] last oot alert: %+v", prj.ID, lastAlert)
+				default:
+					// something wrong
+					log.Errorf("[%s] fail to send alert for project out-of-time: +%v", prj.ID, err)
+				}
+			}
+		}()
+	}
+
+	// select projects matching the alerting mode criteria
+	endDate := alert.OotDate(mode, now).Format(dateLayout)
+	for _, project := range projects {
+		if project.End.Format(dateLayout) == endDate {
+			cprjs <- project
+		} else {
+			log.Debugf("[%s] skipped as the end time (%s) doesn't match alerting criteria for mode %s", project.ID, project.End, mode.Name)
+		}
+	}
+	close(cprjs)
+
+	wg.Wait()
+
+}
+
+// sendOoqAlerts checks the storage usage of the `projects`, and dispatches the out-of-quota
+// alerts via `d`.  The last alert of each project is updated in `kvstore` once the alert
+// is committed by `d`, except in the dry-run mode.
+func sendOoqAlerts(ipdb pdb.PDB, fgw filergateway.Client, kvstore *store.KVStore, projects []*pdb.Project, d alertDispatcher, policy *alert.Policy) {
+
+	// perform pending actions with 4 concurrent workers,
+	// each works on a project.
+	var wg sync.WaitGroup
+	cprjs := make(chan *pdb.Project, execNthreads*2)
+	for w := 0; w < execNthreads; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for prj := range cprjs {
+
+				// perform test on a specific project with id specified via
+				// `ooqAlertTestProjectID`.
+				if alertTestProjectID != "" && prj.ID != alertTestProjectID {
+					log.Infof("[%s] ignored ooq alert in test mode", prj.ID)
+					continue
+				}
+
+				// get members from filer gateway
+				info, err := fgw.GetProject(prj.ID)
+				if err != nil {
+					log.Errorf("[%s] cannot get project storage info: %s", prj.ID, err)
+					continue
+				}
+				log.Debugf("[%s] project storage info: %+v", prj.ID, info)
+
+				// get last alert information from the local db
+				data, err := kvstore.Get(alert.BucketOoqLastAlerts, []byte(prj.ID))
+				if err != nil {
+					log.Debugf("[%s] cannot get last ooq alert data: %s", prj.ID, err)
+				}
+
+				// default lastAlert with timestamp (0001-01-01 00:00:00 +0000 UTC), uratio 0.
+				lastAlert := pdb.OoqLastAlert{}
+				if data != nil {
+					if err := json.Unmarshal(data, &lastAlert); err != nil {
+						log.Debugf("[%s] cannot interpret last ooq alert data: %s", prj.ID, err)
+					}
+				}
+				log.Debugf("[%s] last ooq alert: %+v", prj.ID, lastAlert)
+
+				// check and send alert
+				lastAlert, record, err := ooqAlert(ipdb, prj, info, lastAlert, d, policy)
+
+				// do nothing to the db for dryrun
+				if alertDryrun {
+					continue
+				}
+
+				switch err.(type) {
+				case nil:
+					log.Debugf("[%s] last ooq alert: %+v", prj.ID, lastAlert)
+					// alert sent, update store db with new last alert information once
+					// the alert is delivered.
+					data, _ := json.Marshal(&lastAlert)
+					pid := prj.ID
+					d.commit(record, func() {
+						kvstore.Set(alert.BucketOoqLastAlerts, []byte(pid), data)
+					})
+				case *pdb.OpsIgnored:
+					// alert ignored
+					log.Debugf("[%s] %s", prj.ID, err)
+					log.Debugf("[%s] last ooq alert: %+v", prj.ID, lastAlert)
+					// alert ignored, still need to update the lastAlert to alert history db with
+					// the current project UsagePercent.
+					data, _ := json.Marshal(&lastAlert)
+					kvstore.Set(alert.BucketOoqLastAlerts, []byte(prj.ID), data)
+				default:
+					// something wrong
+					log.Errorf("[%s] fail to send alert for project out-of-quota: +%v", prj.ID, err)
+				}
+			}
+		}()
+	}
+
+	for _, project := range projects {
+		cprjs <- project
+	}
+	close(cprjs)
+
+	wg.Wait()
+
+}
@@ -0,0 +1,177 @@
+package pdbutil
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	"github.com/dccn-tg/tg-toolset-golang/pkg/store"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
+	"github.com/spf13/cobra"
+)
+
+var cacheExpiredOnly bool = false
+
+func init() {
+	cacheClearCmd.Flags().BoolVarP(&cacheExpiredOnly, "expired", "", cacheExpiredOnly,
+		"only remove the expired data")
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
+
+// cacheCmd is the command group for managing the cache of the project database.
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the cache of the project database",
+	Long:  ``,
+}
+
+// cacheClearCmd removes data from the persistent cache of the project database.
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear [key...]",
+	Short: "Remove data from the cache of the project database",
+	Long: `Remove data from the cache of the project database, e.g. after changes made in the
+project database that should be visible before the cached data expire.
+
+All cached data are removed if no key is given.  A key is composed of the name of the
+query and its arguments separated by ":", e.g. "GetUser:honlee" or "GetProject:3010000.01".
+With --expired, only the expired data are removed; they are otherwise kept until the same
+query is made again.
+
+Only the cache kept in the local database file (pdb.cache.path) can be cleared; the
+in-memory cache lives only within a single run.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		conf := loadConfig()
+
+		if conf.PDB.Cache.Path == "" {
+			log.Infof("no persistent pdb cache configured, nothing to clear")
+			return nil
+		}
+
+		// only the cache store is used for invalidation; no query is made to the
+		// project database.
+		cf := &cacheFile{path: conf.PDB.Cache.Path, timeout: 5 * time.Second}
+		defer cf.Close()
+
+		c, err := pdb.NewCached(nil, conf.PDB.Cache.TTL, cf)
+		if err != nil {
+			return fmt.Errorf("cannot open pdb cache %s: %s", conf.PDB.Cache.Path, err)
+		}
+
+		if cacheExpiredOnly {
+			n, err := c.Purge()
+			if err != nil {
+				return fmt.Errorf("cannot purge pdb cache: %s", err)
+			}
+			log.Infof("expired pdb cache entries removed: %d", n)
+			return nil
+		}
+
+		if err := c.Invalidate(args...); err != nil {
+			return fmt.Errorf("cannot clear pdb cache: %s", err)
+		}
+
+		if len(args) == 0 {
+			log.Infof("pdb cache cleared: %s", conf.PDB.Cache.Path)
+		} else {
+			log.Infof("pdb cache entries removed: %v", args)
+		}
+
+		return nil
+	},
+}
+
+// cacheFile implements the `pdb.CacheStore` interface on the local database file `path`.
+// The file is opened on the first operation and kept open for the subsequent ones, so
+// that concurrent workers share a single connection.  Call `Close` to release the lock
+// of the file, e.g. between two polls of the `serve` daemon; the file is re-opened by
+// the next operation.
+type cacheFile struct {
+	path    string
+	timeout time.Duration
+
+	s     *store.KVStore
+	mutex sync.RWMutex
+}
+
+// open connects the database file if it is not yet connected.
+func (c *cacheFile) open() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.s != nil {
+		return nil
+	}
+
+	s := &store.KVStore{Path: c.path, Timeout: c.timeout}
+	if err := s.Connect(); err != nil {
+		return err
+	}
+	c.s = s
+	return nil
+}
+
+// Close disconnects the database file.
+func (c *cacheFile) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.s == nil {
+		return nil
+	}
+	err := c.s.Disconnect()
+	c.s = nil
+	return err
+}
+
+// do runs `f` on the connected database file.
+func (c *cacheFile) do(f func(s *store.KVStore) error) error {
+	for {
+		c.mutex.RLock()
+		if c.s != nil {
+			defer c.mutex.RUnlock()
+			return f(c.s)
+		}
+		c.mutex.RUnlock()
+
+		if err := c.open(); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *cacheFile) Init(buckets []string) error {
+	return c.do(func(s *store.KVStore) error { return s.Init(buckets) })
+}
+
+func (c *cacheFile) Get(bucket string, key []byte) (v []byte, err error) {
+	err = c.do(func(s *store.KVStore) error {
+		d, err := s.Get(bucket, key)
+		// copy the value as it refers to the memory of the database file.
+		v = append([]byte(nil), d...)
+		return err
+	})
+	return
+}
+
+func (c *cacheFile) ForEach(bucket string, fn func(key, value []byte) error) error {
+	return c.do(func(s *store.KVStore) error { return s.ForEach(bucket, fn) })
+}
+
+func (c *cacheFile) Set(bucket string, key []byte, value []byte) error {
+	return c.do(func(s *store.KVStore) error { return s.Set(bucket, key, value) })
+}
+
+func (c *cacheFile) Delete(bucket string, key []byte) error {
+	return c.do(func(s *store.KVStore) error { return s.Delete(bucket, key) })
+}
+
+func (c *cacheFile) DeleteRange(bucket string, min, max []byte) error {
+	return c.do(func(s *store.KVStore) error { return s.DeleteRange(bucket, min, max) })
+}
+
+func (c *cacheFile) Clear(bucket string) error {
+	return c.do(func(s *store.KVStore) error { return s.Clear(bucket) })
+}
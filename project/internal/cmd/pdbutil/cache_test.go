@@ -0,0 +1,61 @@
+package pdbutil
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/store"
+)
+
+func TestCacheFile(t *testing.T) {
+
+	c := &cacheFile{path: filepath.Join(t.TempDir(), "cache.db"), timeout: 100 * time.Millisecond}
+	defer c.Close()
+
+	if err := c.Init([]string{"test"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// concurrent operations share the connection to the database file.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Set("test", []byte("k"), []byte("v")); err != nil {
+				t.Errorf("%s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the database file is locked until the cache file is closed.
+	s := &store.KVStore{Path: c.path, Timeout: 100 * time.Millisecond}
+	if err := s.Connect(); err == nil {
+		s.Disconnect()
+		t.Fatalf("database file not locked while in use")
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := s.Connect(); err != nil {
+		t.Fatalf("database file locked after close: %s", err)
+	}
+	s.Disconnect()
+
+	// the database file is re-opened by the next operation.
+	if v, err := c.Get("test", []byte("k")); err != nil || string(v) != "v" {
+		t.Errorf("unexpected value: %s, %v", v, err)
+	}
+
+	if err := c.Clear("test"); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if _, err := c.Get("test", []byte("k")); err == nil {
+		t.Errorf("expect error on cleared key")
+	}
+}
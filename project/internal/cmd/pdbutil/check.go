@@ -0,0 +1,289 @@
+package pdbutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/filergateway"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
+	"github.com/spf13/cobra"
+)
+
+// Kinds of the inconsistencies between the project database and the storage.
+const (
+	findingNoStorage      = "no-storage"
+	findingNoPdbRecord    = "no-pdb-record"
+	findingQuotaMismatch  = "quota-mismatch"
+	findingInactiveMember = "inactive-member"
+)
+
+// projectDirPattern is the pattern of the project directory names, i.e. the project ids.
+var projectDirPattern = regexp.MustCompile(`^[0-9]{7}\.[0-9]{2}$`)
+
+var (
+	checkEmitActions string
+	checkJSON        bool = false
+)
+
+func init() {
+
+	checkCmd.Flags().StringVarP(&storSystem, "sys", "s", storSystem,
+		"storage `system` of which the project directories are checked against the project database")
+
+	checkCmd.Flags().StringVarP(&checkEmitActions, "emit-actions", "", checkEmitActions,
+		"`path` of the JSON file to which the pending actions fixing the findings are written.  The actions can be executed with \"project action exec --file\".")
+
+	checkCmd.Flags().BoolVarP(&checkJSON, "json", "", checkJSON,
+		"print the findings in JSON format")
+
+	checkCmd.Flags().IntVarP(&execNthreads, "nthreads", "n", execNthreads,
+		"`number` of concurrent worker threads retrieving the project storage information.")
+
+	rootCmd.AddCommand(checkCmd)
+}
+
+// checkFinding is an inconsistency between the project database and the storage.
+type checkFinding struct {
+	ProjectID string `json:"projectID"`
+	Kind      string `json:"kind"`
+	Detail    string `json:"detail"`
+}
+
+// checkCmd cross-checks the project database against the storage.
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Checks consistency between the project database and the storage",
+	Long: `Cross-checks the project database against the storage, and reports:
+
+    no-storage       active projects without storage on the filer gateway
+    no-pdb-record    project directories on the storage system without a record in the project database
+    quota-mismatch   projects of which the storage quota differs from the quota in the project database
+    inactive-member  members who are not active in the project database but still have a role in the project
+
+With the --emit-actions option, the pending actions fixing the findings are written to
+a file, so that they can be reviewed and executed with "project action exec --file".
+The storage is created, or the quota is set, according to the project database, and
+the roles of the inactive members are removed.  The projects without a record in the
+project database, and the quota mismatches of which the quota in the project database
+is below the current usage, are only reported.`,
+	Args: cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if _, ok := projectRoots[storSystem]; !ok {
+			log.Fatalf("unsupported storage system: %s", storSystem)
+		}
+		if execNthreads < 1 {
+			execNthreads = 1
+		}
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		ipdb := loadPdb()
+		conf := loadConfig()
+
+		// users not active in the project database; the check on the inactive members is
+		// skipped if the users cannot be retrieved.
+		inactive := make(map[string]*pdb.User)
+		if users, err := ipdb.GetUsers(false); err != nil {
+			log.Warnf("skip checking inactive members, cannot get users: %s", err)
+		} else {
+			for _, u := range users {
+				if !u.Status.IsActive() {
+					inactive[u.ID] = u
+				}
+			}
+		}
+
+		fgw, err := filergateway.NewClient(conf)
+		if err != nil {
+			return err
+		}
+
+		var mutex sync.Mutex
+		findings := []checkFinding{}
+		actions := make(map[string]*pdb.DataProjectUpdate)
+
+		var wg sync.WaitGroup
+		cprjs := make(chan *pdb.Project, execNthreads*2)
+		for w := 0; w < execNthreads; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for prj := range cprjs {
+
+					info, err := fgw.GetProject(prj.ID)
+					if err != nil && !filergateway.IsNotFound(err) {
+						log.Errorf("[%s] cannot get project storage info: %s", prj.ID, err)
+						continue
+					}
+
+					fs, act := checkProject(prj, info, inactive)
+
+					mutex.Lock()
+					findings = append(findings, fs...)
+					if act != nil {
+						actions[prj.ID] = act
+					}
+					mutex.Unlock()
+				}
+			}()
+		}
+
+		// projects are streamed from the project database; only the ids are kept for
+		// finding the directories without a project database record.
+		pids := make(map[string]bool)
+		err = pdb.WalkProjects(context.Background(), ipdb, pdb.ProjectFilter{}, func(prj *pdb.Project) error {
+			pids[prj.ID] = true
+			if prj.Status == pdb.ProjectStatusActive {
+				cprjs <- prj
+			}
+			return nil
+		})
+		close(cprjs)
+
+		wg.Wait()
+
+		if err != nil {
+			return err
+		}
+
+		// project directories without a record in the project database.
+		root := projectRoots[storSystem]
+		if entries, err := os.ReadDir(root); err != nil {
+			log.Errorf("cannot list project directories in %s: %s", root, err)
+		} else {
+			for _, e := range entries {
+				if !e.IsDir() || !projectDirPattern.MatchString(e.Name()) || pids[e.Name()] {
+					continue
+				}
+				findings = append(findings, checkFinding{
+					ProjectID: e.Name(),
+					Kind:      findingNoPdbRecord,
+					Detail:    fmt.Sprintf("directory on %s without project database record", storSystem),
+				})
+			}
+		}
+
+		sort.SliceStable(findings, func(i, j int) bool {
+			if findings[i].ProjectID != findings[j].ProjectID {
+				return findings[i].ProjectID < findings[j].ProjectID
+			}
+			return findings[i].Kind < findings[j].Kind
+		})
+
+		if checkJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(findings); err != nil {
+				return err
+			}
+		} else {
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "PROJECT\tFINDING\tDETAIL")
+			for _, f := range findings {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", f.ProjectID, f.Kind, f.Detail)
+			}
+			tw.Flush()
+		}
+
+		log.Infof("projects checked: %d, findings: %d, fixing actions: %d", len(pids), len(findings), len(actions))
+
+		if checkEmitActions != "" {
+			data, err := json.MarshalIndent(actions, "", "  ")
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(checkEmitActions, data, 0644); err != nil {
+				return err
+			}
+			log.Infof("pending actions written to %s", checkEmitActions)
+		}
+
+		return nil
+	},
+}
+
+// checkProject checks the project `prj` against its storage information `info`, and
+// returns the findings together with the pending action fixing them.  The `info` is nil
+// if the project has no storage.  The action is nil if there is nothing to be fixed.
+func checkProject(prj *pdb.Project, info *pdb.DataProjectInfo, inactive map[string]*pdb.User) ([]checkFinding, *pdb.DataProjectUpdate) {
+
+	if info == nil {
+		f := checkFinding{
+			ProjectID: prj.ID,
+			Kind:      findingNoStorage,
+			Detail:    fmt.Sprintf("no storage, quota %d GB in project database", prj.QuotaGb),
+		}
+
+		// storage without quota is not created, as for the pending actions from the
+		// project database.
+		if prj.QuotaGb <= 0 {
+			return []checkFinding{f}, nil
+		}
+
+		return []checkFinding{f}, &pdb.DataProjectUpdate{
+			Members: []pdb.Member{{UserID: prj.Owner, Role: "manager"}},
+			Storage: pdb.Storage{
+				QuotaGb: prj.QuotaGb,
+				System:  storSystem,
+			},
+		}
+	}
+
+	findings := []checkFinding{}
+	fix := false
+
+	// the action keeps the current quota unless it is fixed.
+	act := &pdb.DataProjectUpdate{
+		Members: []pdb.Member{},
+		Storage: pdb.Storage{
+			QuotaGb: info.Storage.QuotaGb,
+			System:  info.Storage.System,
+		},
+	}
+
+	if info.Storage.QuotaGb != prj.QuotaGb {
+		f := checkFinding{
+			ProjectID: prj.ID,
+			Kind:      findingQuotaMismatch,
+			Detail:    fmt.Sprintf("quota %d GB on %s, %d GB in project database", info.Storage.QuotaGb, info.Storage.System, prj.QuotaGb),
+		}
+		// the quota is never removed from the storage, nor reduced below the usage.
+		switch usageGb := storageUsageGb(info); {
+		case prj.QuotaGb <= 0:
+		case prj.QuotaGb < usageGb:
+			f.Detail += fmt.Sprintf(", below usage of %d GB", usageGb)
+		default:
+			act.Storage.QuotaGb = prj.QuotaGb
+			fix = true
+		}
+		findings = append(findings, f)
+	}
+
+	for _, m := range info.Members {
+		u, ok := inactive[m.UserID]
+		if !ok {
+			continue
+		}
+		findings = append(findings, checkFinding{
+			ProjectID: prj.ID,
+			Kind:      findingInactiveMember,
+			Detail:    fmt.Sprintf("%s (%s) has role %s", m.UserID, u.Status, m.Role),
+		})
+		act.Members = append(act.Members, pdb.Member{UserID: m.UserID, Role: "none"})
+		fix = true
+	}
+
+	if !fix {
+		return findings, nil
+	}
+
+	return findings, act
+}
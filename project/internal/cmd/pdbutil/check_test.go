@@ -0,0 +1,59 @@
+package pdbutil
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
+)
+
+func TestCheckProjectQuota(t *testing.T) {
+
+	info := func(quotaGb, usageMb int) *pdb.DataProjectInfo {
+		return &pdb.DataProjectInfo{
+			ProjectID: "3010000.01",
+			Members:   []pdb.Member{},
+			Storage:   pdb.StorageInfo{System: "cephfs", QuotaGb: quotaGb, UsageMb: usageMb},
+		}
+	}
+
+	cases := []struct {
+		name     string
+		quotaGb  int
+		info     *pdb.DataProjectInfo
+		findings []string
+		quota    int // quota of the emitted action; 0 if no action is emitted.
+	}{
+		{"match", 100, info(100, 50*1024), []string{}, 0},
+		{"raise", 200, info(100, 50*1024), []string{findingQuotaMismatch}, 200},
+		{"reduce above usage", 60, info(100, 50*1024), []string{findingQuotaMismatch}, 60},
+		{"reduce to usage", 50, info(100, 50*1024), []string{findingQuotaMismatch}, 50},
+		{"reduce below usage", 40, info(100, 50*1024), []string{findingQuotaMismatch}, 0},
+		{"reduce below partial GB of usage", 50, info(100, 50*1024+1), []string{findingQuotaMismatch}, 0},
+		{"no quota in pdb", 0, info(100, 50*1024), []string{findingQuotaMismatch}, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			prj := &pdb.Project{ID: "3010000.01", QuotaGb: c.quotaGb}
+			findings, act := checkProject(prj, c.info, nil)
+
+			kinds := []string{}
+			for _, f := range findings {
+				kinds = append(kinds, f.Kind)
+			}
+			if !reflect.DeepEqual(kinds, c.findings) {
+				t.Errorf("unexpected findings: %+v", findings)
+			}
+
+			switch {
+			case c.quota == 0 && act != nil:
+				t.Errorf("unexpected action: %+v", act)
+			case c.quota != 0 && act == nil:
+				t.Errorf("expect action with quota %d GB", c.quota)
+			case act != nil && act.Storage.QuotaGb != c.quota:
+				t.Errorf("unexpected quota %d GB, expected %d GB", act.Storage.QuotaGb, c.quota)
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package pdbutil
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	"github.com/dccn-tg/tg-toolset-golang/pkg/store"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/acl"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/filergateway"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/identity"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
+	"github.com/spf13/cobra"
+)
+
+var (
+	expireGraceDays   int    = 60
+	expireDbPath      string = "expire.db"
+	expireDryrun      bool   = false
+	expireReduceQuota bool   = false
+	expireSkip        []string
+	expireOverride    string
+)
+
+// expireBucket is the bucket of the internal database in which the expired projects
+// are bookkept.
+const expireBucket = "expiredProjects"
+
+func init() {
+
+	supportedStorSystems := make([]string, 0, len(projectRoots))
+	for sys := range projectRoots {
+		supportedStorSystems = append(supportedStorSystems, sys)
+	}
+
+	projectExpireCmd.Flags().IntVarP(&expireGraceDays, "grace", "g", expireGraceDays,
+		"`number` of days after the project end date before the project storage is made read-only")
+
+	projectExpireCmd.Flags().StringVarP(&expireDbPath, "dbpath", "", expireDbPath,
+		"`path` of the internal database of expired projects")
+
+	projectExpireCmd.Flags().BoolVarP(&expireDryrun, "dryrun", "", expireDryrun,
+		"print out the projects to expire and the changes without really applying them")
+
+	projectExpireCmd.Flags().BoolVarP(&expireReduceQuota, "reduce-quota", "", expireReduceQuota,
+		"reduce the storage quota of the expired project to its current usage")
+
+	projectExpireCmd.Flags().StringSliceVarP(&expireSkip, "skip", "", expireSkip,
+		"specify a list of comma-separated projects never to expire")
+
+	projectExpireCmd.Flags().StringVarP(&expireOverride, "override", "", expireOverride,
+		"`path` of the file with per-project overrides; one project per line followed optionally by the date (YYYY-MM-DD) until which the project is not expired")
+
+	projectExpireCmd.Flags().StringVarP(&storSystem, "sys", "s", storSystem,
+		fmt.Sprintf("storage `system` of projects for which the filer-gateway reports no system.  Supported systems: %s", strings.Join(supportedStorSystems, ",")))
+
+	projectExpireCmd.Flags().IntVarP(&execNthreads, "nthreads", "n", execNthreads,
+		"`number` of concurrent worker threads.")
+
+	projectCmd.AddCommand(projectExpireCmd)
+}
+
+// expireState is the bookkeeping data of an expired project.  The members and the quota
+// before the expiry are kept for the eventual recovery of the project storage.
+type expireState struct {
+	ProjectID     string       `json:"projectID"`
+	End           time.Time    `json:"end"`
+	Expired       time.Time    `json:"expired"`
+	By            string       `json:"by"`
+	Members       []pdb.Member `json:"members"`
+	QuotaGbBefore int          `json:"quotaGbBefore"`
+	QuotaGb       int          `json:"quotaGb"`
+}
+
+// readExpireOverrides reads the per-project overrides from the file `fpath`.  The returned
+// map contains the date until which the project is not expired; the zero time means never.
+func readExpireOverrides(fpath string) (map[string]time.Time, error) {
+
+	overrides := make(map[string]time.Time)
+
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for ln := 1; scanner.Scan(); ln++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 1:
+			overrides[fields[0]] = time.Time{}
+		case 2:
+			t, err := time.ParseInLocation(dateLayout, fields[1], time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid date: %s", fpath, ln, fields[1])
+			}
+			overrides[fields[0]] = t
+		default:
+			return nil, fmt.Errorf("%s:%d: invalid override: %s", fpath, ln, line)
+		}
+	}
+
+	return overrides, scanner.Err()
+}
+
+// subcommand to expire projects past the grace period.
+var projectExpireCmd = &cobra.Command{
+	Use:   "expire [projectID...]",
+	Short: "Makes storage of projects past the grace period read-only",
+	Long: `Makes storage of projects past the grace period after the end date read-only.
+
+The managers and contributors of the expired project are converted into viewers.
+Optionally, the storage quota is reduced to the current usage.  The members and the
+quota before the expiry are kept in the internal database; projects already expired
+are skipped.
+
+Projects can be excluded with the --skip option or the override file.  Each line of
+the override file contains a project id, optionally followed by the date until which
+the project is not expired, e.g.
+
+    # never expire
+    3010000.01
+    # extended until the end of the year
+    3010000.02 2024-12-31
+
+If project ids are given as arguments, only those projects are considered.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if _, ok := projectRoots[storSystem]; !ok {
+			log.Fatalf("unsupported storage system: %s", storSystem)
+		}
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		ipdb := loadPdb()
+		conf := loadConfig()
+
+		// per-project overrides
+		overrides := make(map[string]time.Time)
+		if expireOverride != "" {
+			var err error
+			if overrides, err = readExpireOverrides(expireOverride); err != nil {
+				return err
+			}
+		}
+		for _, pid := range expireSkip {
+			overrides[pid] = time.Time{}
+		}
+
+		only := make(map[string]bool)
+		for _, pid := range args {
+			only[pid] = true
+		}
+
+		fgw, err := filergateway.NewClient(conf)
+		if err != nil {
+			return err
+		}
+
+		resolver, err := newIdentityResolver(conf)
+		if err != nil {
+			return err
+		}
+		defer closeIdentityResolver(resolver)
+
+		// connect to internal database of expired projects
+		kvstore := store.KVStore{
+			Path: expireDbPath,
+		}
+		if err := kvstore.Connect(); err != nil {
+			return err
+		}
+		defer kvstore.Disconnect()
+
+		if err := kvstore.Init([]string{expireBucket}); err != nil {
+			return err
+		}
+
+		deadline := now.AddDate(0, 0, -expireGraceDays)
+
+		// projects ended before the deadline are streamed from the project database.
+		f := pdb.ProjectFilter{EndBefore: deadline}
+		return pdb.WalkProjects(context.Background(), ipdb, f, func(prj *pdb.Project) error {
+			if len(only) > 0 && !only[prj.ID] {
+				return nil
+			}
+
+			if prj.End.IsZero() || !prj.End.Before(deadline) {
+				return nil
+			}
+
+			if until, ok := overrides[prj.ID]; ok && (until.IsZero() || now.Before(until)) {
+				log.Infof("[%s] expiry skipped by override", prj.ID)
+				return nil
+			}
+
+			// the record of an expired project keeps the state before the expiry; it must
+			// not be overwritten by expiring the project again.
+			switch _, err := kvstore.Get(expireBucket, []byte(prj.ID)); {
+			case err == nil:
+				log.Debugf("[%s] already expired", prj.ID)
+				return nil
+			case !errors.Is(err, store.ErrKeyNotFound):
+				log.Errorf("[%s] fail reading expiry record: %s", prj.ID, err)
+				return nil
+			}
+
+			state, err := expireProject(ipdb, fgw, resolver, prj)
+			if err != nil {
+				log.Errorf("[%s] fail expiring project: %s", prj.ID, err)
+				return nil
+			}
+
+			if expireDryrun {
+				return nil
+			}
+
+			data, err := json.Marshal(state)
+			if err != nil {
+				return fmt.Errorf("[%s] fail recording expiry: %s", prj.ID, err)
+			}
+			// the state is included in the error as it is needed to restore the project.
+			if err := kvstore.Set(expireBucket, []byte(prj.ID), data); err != nil {
+				return fmt.Errorf("[%s] fail recording expiry, state before expiry %s: %s", prj.ID, data, err)
+			}
+			return nil
+		})
+	},
+}
+
+// expireProject converts the managers and contributors of the project `prj` into viewers,
+// and optionally reduces the storage quota to the current usage.  The users are validated
+// with the `resolver`.  Nothing is changed in the dry-run mode.
+func expireProject(ipdb pdb.PDB, fgw filergateway.Client, resolver identity.Resolver, prj *pdb.Project) (*expireState, error) {
+
+	info, err := fgw.GetProject(prj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get project storage info: %s", err)
+	}
+
+	system := info.Storage.System
+	if system == "" {
+		system = storSystem
+	}
+	root, ok := projectRoots[system]
+	if !ok {
+		return nil, fmt.Errorf("unsupported storage system: %s", system)
+	}
+
+	state := &expireState{
+		ProjectID:     prj.ID,
+		End:           prj.End,
+		Expired:       time.Now(),
+		By:            operator(),
+		Members:       info.Members,
+		QuotaGbBefore: info.Storage.QuotaGb,
+		QuotaGb:       info.Storage.QuotaGb,
+	}
+
+	// users who no longer exist or are inactive cannot be granted with the viewer role;
+	// they only lose their write permission when their ACL entries are removed by the
+	// system.
+	viewers := []string{}
+	for _, m := range info.Members {
+		if m.Role != acl.Manager.String() && m.Role != acl.Contributor.String() {
+			continue
+		}
+		if err := identity.Validate(resolver, []string{m.UserID}); err != nil {
+			log.Warnf("[%s] skip converting role of %s: %s", prj.ID, m.UserID, err)
+			continue
+		}
+		viewers = append(viewers, m.UserID)
+	}
+
+	if expireReduceQuota {
+		// usage in GiB, rounded up; the quota is never increased.
+		usage := (info.Storage.UsageMb + 1023) / 1024
+		if usage < 1 {
+			usage = 1
+		}
+		if usage < state.QuotaGb {
+			state.QuotaGb = usage
+		}
+	}
+
+	log.Infof("[%s] expired on %s, converting to viewers: %s, quota: %d -> %d GB",
+		prj.ID, prj.End.Format(dateLayout), strings.Join(viewers, ","), state.QuotaGbBefore, state.QuotaGb)
+
+	if expireDryrun {
+		return state, nil
+	}
+
+	if len(viewers) > 0 {
+		runner := acl.Runner{
+			RootPath:   filepath.Join(root, prj.ID),
+			Viewers:    strings.Join(viewers, ","),
+			FollowLink: false,
+			SkipFiles:  false,
+			Nthreads:   execNthreads,
+			Silence:    true,
+			Traverse:   false,
+			Force:      false,
+			Resolver:   resolver,
+		}
+
+		if ec, err := runner.SetRoles(); err != nil {
+			return nil, fmt.Errorf("fail setting viewer role (ec=%d): %s", ec, err)
+		}
+	}
+
+	if state.QuotaGb != state.QuotaGbBefore {
+		act := &pdb.DataProjectUpdate{
+			Storage: pdb.Storage{
+				QuotaGb: state.QuotaGb,
+				System:  system,
+			},
+		}
+		if _, err := fgw.SyncUpdateProject(prj.ID, act, time.Second); err != nil {
+			return nil, fmt.Errorf("fail reducing quota: %s", err)
+		}
+		if err := ipdb.UpdateProjectStorageQuota(prj.ID, state.QuotaGb, info.Storage.UsageMb/1024); err != nil {
+			log.Errorf("[%s] fail updating quota in PDB: %s", prj.ID, err)
+		}
+	}
+
+	// update project database with the up-to-date members.
+	if pdata, err := fgw.GetProject(prj.ID); err != nil {
+		log.Errorf("[%s] fail getting acl: %s", prj.ID, err)
+	} else if err := ipdb.UpdateProjectMembers(prj.ID, pdata.Members); err != nil {
+		log.Errorf("[%s] fail updating acl in PDB: %s", prj.ID, err)
+	}
+
+	return state, nil
+}
@@ -0,0 +1,96 @@
+package pdbutil
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadExpireOverrides(t *testing.T) {
+
+	date := func(s string) time.Time {
+		d, err := time.ParseInLocation(dateLayout, s, time.Local)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		return d
+	}
+
+	cases := []struct {
+		name     string
+		content  string
+		expected map[string]time.Time
+		err      bool
+	}{
+		{
+			name:     "empty file",
+			content:  "",
+			expected: map[string]time.Time{},
+		},
+		{
+			name:    "comments and blank lines",
+			content: "# never expire\n3010000.01\n\n   \n  # extended\n3010000.02 2024-12-31\n",
+			expected: map[string]time.Time{
+				"3010000.01": {},
+				"3010000.02": date("2024-12-31"),
+			},
+		},
+		{
+			name:    "surrounding white spaces",
+			content: "\t3010000.01   2024-06-30  \n",
+			expected: map[string]time.Time{
+				"3010000.01": date("2024-06-30"),
+			},
+		},
+		{
+			name:    "later line overrides earlier one",
+			content: "3010000.01 2024-06-30\n3010000.01\n",
+			expected: map[string]time.Time{
+				"3010000.01": {},
+			},
+		},
+		{
+			name:    "bad date",
+			content: "3010000.01 2024-13-01\n",
+			err:     true,
+		},
+		{
+			name:    "bad date format",
+			content: "3010000.01 31-12-2024\n",
+			err:     true,
+		},
+		{
+			name:    "extra fields",
+			content: "3010000.01 2024-12-31 forever\n",
+			err:     true,
+		},
+	}
+
+	for _, c := range cases {
+		fpath := filepath.Join(t.TempDir(), "overrides.txt")
+		if err := os.WriteFile(fpath, []byte(c.content), 0644); err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		overrides, err := readExpireOverrides(fpath)
+		if c.err {
+			if err == nil {
+				t.Errorf("%s: expect error, got %v", c.name, overrides)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(overrides, c.expected) {
+			t.Errorf("%s: unexpected overrides: %v", c.name, overrides)
+		}
+	}
+
+	if _, err := readExpireOverrides(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Errorf("expect error on missing file")
+	}
+}
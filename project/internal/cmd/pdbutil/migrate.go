@@ -0,0 +1,277 @@
+package pdbutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/config"
+	ufp "github.com/dccn-tg/tg-toolset-golang/pkg/filepath"
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	"github.com/dccn-tg/tg-toolset-golang/pkg/store"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/acl"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/filergateway"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/migrate"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/vol"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateFrom     string = "netapp"
+	migrateTo       string = "cephfs"
+	migrateDbPath   string = "migrate.db"
+	migrateLink     string
+	migrateNthreads int = 4
+)
+
+func init() {
+
+	supportedStorSystems := make([]string, 0, len(projectRoots))
+	for sys := range projectRoots {
+		supportedStorSystems = append(supportedStorSystems, sys)
+	}
+
+	projectMigrateCmd.Flags().StringVarP(&migrateFrom, "from", "", migrateFrom,
+		fmt.Sprintf("source storage `system`.  Supported systems: %s", strings.Join(supportedStorSystems, ",")))
+
+	projectMigrateCmd.Flags().StringVarP(&migrateTo, "to", "", migrateTo,
+		fmt.Sprintf("target storage `system`.  Supported systems: %s", strings.Join(supportedStorSystems, ",")))
+
+	projectMigrateCmd.Flags().StringVarP(&migrateDbPath, "dbpath", "", migrateDbPath,
+		"`path` of the internal migration database for resuming the migration")
+
+	projectMigrateCmd.Flags().StringVarP(&migrateLink, "link", "", migrateLink,
+		"`path` to be switched into a symlink to the target (default: the project path on the source system)")
+
+	projectMigrateCmd.Flags().IntVarP(&migrateNthreads, "nthreads", "n", migrateNthreads,
+		"`number` of concurrent copy threads.")
+
+	projectCmd.AddCommand(projectMigrateCmd)
+}
+
+// subcommand to migrate project storage between storage systems.
+var projectMigrateCmd = &cobra.Command{
+	Use:   "migrate [projectID]",
+	Short: "Migrates project storage to another storage system",
+	Long: `Migrates project storage to another storage system in the following stages:
+
+1. provisions the project storage on the target system with the same quota,
+2. copies data to the target with checksum verification,
+3. translates and applies roles on the target,
+4. switches the project path into a symlink referring to the target.
+
+The migration is resumable; the progress is kept in the migration database. When
+re-running an interrupted migration, completed stages are skipped and files already
+copied and verified are not copied again.`,
+	Args: cobra.ExactArgs(1),
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if _, ok := projectRoots[migrateFrom]; !ok {
+			log.Fatalf("unsupported storage system: %s", migrateFrom)
+		}
+		if _, ok := projectRoots[migrateTo]; !ok {
+			log.Fatalf("unsupported storage system: %s", migrateTo)
+		}
+		if migrateFrom == migrateTo {
+			log.Fatalf("source and target storage systems are identical: %s", migrateTo)
+		}
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		pid := args[0]
+		conf := loadConfig()
+
+		// avoid concurrent migration of the same project
+		flock := fmt.Sprintf("%s.%s.lock", migrateDbPath, pid)
+		lock, err := ufp.TryLock(flock)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+
+		// connect to internal database for the migration state
+		store := store.KVStore{
+			Path: migrateDbPath,
+		}
+		if err := store.Connect(); err != nil {
+			return err
+		}
+		defer store.Disconnect()
+
+		state, err := migrate.LoadState(&store, pid)
+		if err != nil {
+			return err
+		}
+
+		source := filepath.Join(projectRoots[migrateFrom], pid)
+		target := filepath.Join(projectRoots[migrateTo], pid)
+
+		if state.Stage != migrate.StageNew && (state.Source != source || state.Target != target) {
+			return fmt.Errorf("[%s] ongoing migration from %s to %s", pid, state.Source, state.Target)
+		}
+		state.Source = source
+		state.Target = target
+
+		link := migrateLink
+		if link == "" {
+			link = source
+		}
+
+		// the source data has been moved aside by an interrupted link switch.
+		if state.Backup != "" {
+			source = state.Backup
+		}
+
+		log.Infof("[%s] migrating from %s to %s, stage: %s", pid, source, target, state.Stage)
+
+		// once the link is switched, the source path refers to the target.
+		if state.Stage == migrate.StageSwitched {
+			log.Infof("[%s] migration already completed", pid)
+			return nil
+		}
+
+		// stage 1: provision project storage on the target.
+		if state.Stage == migrate.StageNew {
+
+			fgw, err := filergateway.NewClient(conf)
+			if err != nil {
+				return err
+			}
+
+			info, err := fgw.GetProject(pid)
+			if err != nil {
+				return fmt.Errorf("[%s] cannot get project storage info: %s", pid, err)
+			}
+
+			if err := migrateProvision(conf.VolumeManager, fgw, pid, info.Storage.QuotaGb, target); err != nil {
+				return err
+			}
+
+			state.Stage = migrate.StageProvisioned
+			if err := migrate.SaveState(&store, state); err != nil {
+				return err
+			}
+		}
+
+		// stage 2: copy data.  The copy is performed until the link is switched so that
+		// changes made on the source in between are synchronized; paths deleted from the
+		// source in between are removed from the target.
+		copier := migrate.Copier{
+			ProjectID: pid,
+			Source:    source,
+			Target:    target,
+			Store:     &store,
+			Nthreads:  migrateNthreads,
+			Prune:     true,
+		}
+
+		res, err := copier.Copy()
+		log.Infof("[%s] copy result: %d dirs, %d files (%d bytes), %d links, %d skipped, %d deleted, %d failed",
+			pid, res.Dirs, res.Files, res.Bytes, res.Links, res.Skipped, res.Deleted, res.Failed)
+		if err != nil {
+			return fmt.Errorf("[%s] %s", pid, err)
+		}
+
+		if state.Stage == migrate.StageProvisioned {
+			state.Stage = migrate.StageCopied
+			if err := migrate.SaveState(&store, state); err != nil {
+				return err
+			}
+		}
+
+		// stage 3: translate roles of the source into the target.
+		runner := acl.Runner{
+			RootPath:   source,
+			FollowLink: false,
+			SkipFiles:  false,
+			Nthreads:   migrateNthreads,
+			Silence:    true,
+		}
+
+		roles, err := runner.ExportRoles(true)
+		if err != nil {
+			return fmt.Errorf("[%s] cannot export roles: %s", pid, err)
+		}
+
+		runner.RootPath = target
+		if err := runner.ImportRoles(roles); err != nil {
+			return fmt.Errorf("[%s] cannot import roles: %s", pid, err)
+		}
+
+		state.Stage = migrate.StageRolesApplied
+		if err := migrate.SaveState(&store, state); err != nil {
+			return err
+		}
+
+		// stage 4: switch the project path into a symlink to the target.
+		// the backup path is saved before the source data is moved aside, so that an
+		// interrupted switch is resumed with the data copied from the backup path.
+		backup, err := migrate.SwitchLink(link, target, func(backup string) error {
+			state.Backup = backup
+			return migrate.SaveState(&store, state)
+		})
+		if backup != "" {
+			log.Infof("[%s] original data moved to %s", pid, backup)
+		}
+		if err != nil {
+			return fmt.Errorf("[%s] cannot switch %s to %s: %s", pid, link, target, err)
+		}
+
+		state.Stage = migrate.StageSwitched
+		if err := migrate.SaveState(&store, state); err != nil {
+			return err
+		}
+
+		log.Infof("[%s] migration completed: %s -> %s", pid, link, target)
+
+		return nil
+	},
+}
+
+// migrateProvision provisions the project storage on the `target` path with the
+// given quota, using the volume manager of the target storage if available, or
+// the filer gateway otherwise.  It waits until the `target` path appears.
+func migrateProvision(vmConf config.VolumeManagerConfiguration, fgw filergateway.Client, pid string, quotaGb int, target string) error {
+
+	if _, err := os.Stat(target); err == nil {
+		log.Infof("[%s] project path already exists: %s", pid, target)
+		return nil
+	}
+
+	log.Infof("[%s] provisioning %s with quota %d GB", pid, target, quotaGb)
+
+	if vm, ok := vol.VolumeManagerMap[filepath.Dir(target)]; ok {
+		if err := vm.Config(vmConf); err != nil {
+			return fmt.Errorf("[%s] cannot configure volume manager: %s", pid, err)
+		}
+		if err := vm.Create(pid, quotaGb); err != nil {
+			return fmt.Errorf("[%s] fail creating project volume: %s", pid, err)
+		}
+	} else {
+		data := pdb.DataProjectUpdate{
+			Storage: pdb.Storage{
+				System:  migrateTo,
+				QuotaGb: quotaGb,
+			},
+		}
+		if _, err := fgw.SyncCreateProject(pid, &data, time.Second); err != nil {
+			return fmt.Errorf("[%s] fail creating project: %s", pid, err)
+		}
+	}
+
+	t1 := time.Now()
+	// check until the project directory appears
+	for {
+		if _, err := os.Stat(target); !os.IsNotExist(err) {
+			return nil
+		}
+		// timeout after 5 minutes
+		if time.Since(t1) > 5*time.Minute {
+			return fmt.Errorf("[%s] timeout waiting for %s to appear", pid, target)
+		}
+		// wait for 100 millisecond for the next check.
+		time.Sleep(100 * time.Millisecond)
+	}
+}
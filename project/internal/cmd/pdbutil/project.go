@@ -1,6 +1,7 @@
 package pdbutil
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -12,11 +13,14 @@ import (
 	"time"
 
 	"github.com/dccn-tg/tg-toolset-golang/pkg/config"
+	ufp "github.com/dccn-tg/tg-toolset-golang/pkg/filepath"
 	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
 	"github.com/dccn-tg/tg-toolset-golang/pkg/mailer"
 	"github.com/dccn-tg/tg-toolset-golang/pkg/store"
 	"github.com/dccn-tg/tg-toolset-golang/project/pkg/acl"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/alert"
 	"github.com/dccn-tg/tg-toolset-golang/project/pkg/filergateway"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/identity"
 	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
 	"github.com/spf13/cobra"
 )
@@ -47,45 +51,8 @@ var (
 	alertMailerTemplate  string   = "template.txt"
 
 	skipContributorPmap map[string]bool = make(map[string]bool)
-
-	// ootAlertDate calculates the project expiry alerting dates for:
-	// - "p4w": 28-day in advance
-	// - "p2w": 14-day in advance
-	// - "now": on the expiration date
-	// - "g2m": 60-day gracen time
-	ootAlertDate map[string]string = map[string]string{
-		"p4w": now.AddDate(0, 0, 28).Format(dateLayout),
-		"p2w": now.AddDate(0, 0, 14).Format(dateLayout),
-		"p1w": now.AddDate(0, 0, 7).Format(dateLayout),
-		"now": now.Format(dateLayout),
-		"g2m": now.AddDate(0, 0, -60).Format(dateLayout),
-	}
 )
 
-// ooqAlertFrequency determines the max ooq alert frequency
-// in terms of the duration between two subsequent alerts, based on
-// the current storage usage in percentage.
-//
-// if the return duration is 0, it means "never".
-//
-// TODO: make the usage range and the frequency configurable
-func ooqAlertFrequency(usage int) time.Duration {
-
-	if usage >= 90 && usage < 95 {
-		return time.Hour * 24 * 14 // every 14 days
-	}
-
-	if usage >= 95 && usage < 99 {
-		return time.Hour * 24 * 7 // every 7 days
-	}
-
-	if usage >= 99 {
-		return time.Hour * 24 * 2 // every 2 days
-	}
-
-	return 0
-}
-
 func init() {
 
 	// get supported storage systems from the `projectRoots`.
@@ -96,14 +63,6 @@ func init() {
 		i++
 	}
 
-	// get supported storage systems from the `ootAlertDate`.
-	supportedAlertModes := make([]string, len(ootAlertDate))
-	i = 0
-	for mode := range ootAlertDate {
-		supportedAlertModes[i] = mode
-		i++
-	}
-
 	projectActionExecCmd.Flags().IntVarP(&execNthreads, "nthreads", "n", execNthreads,
 		"`number` of concurrent worker threads.")
 
@@ -111,7 +70,7 @@ func init() {
 		"use NetApp ONTAP CLI to apply changes on the NetApp filer. Only applicable for the netapp storage system.")
 
 	projectActionExecCmd.Flags().StringVarP(&storSystem, "sys", "s", storSystem,
-		fmt.Sprintf("storage `system`.  Supported systems: %s", strings.Join(supportedStorSystems, ",")))
+		fmt.Sprintf("storage `system` of new projects; existing projects stay on the system reported by the filer-gateway.  Supported systems: %s", strings.Join(supportedStorSystems, ",")))
 
 	projectCreateCmd.Flags().StringVarP(&storSystem, "sys", "s", storSystem,
 		fmt.Sprintf("storage `system`.  Supported systems: %s", strings.Join(supportedStorSystems, ",")))
@@ -126,10 +85,10 @@ func init() {
 		"`id` of project for testing ooq alert")
 
 	projectAlertCmd.PersistentFlags().StringVarP(&alertSender, "sender", "", alertSender,
-		"`name` of the alert sender")
+		"`name` of the alert sender, overriding the alerts configuration")
 
 	projectAlertCmd.PersistentFlags().StringVarP(&alertSenderEmail, "from", "f", alertSenderEmail,
-		"`email` of the alert sender")
+		"`email` of the alert sender, overriding the alerts configuration")
 
 	projectAlertCmd.PersistentFlags().BoolVarP(&alertSkipPI, "skip-pi", "", alertSkipPI,
 		"set to skip sending alert to PIs")
@@ -138,16 +97,16 @@ func init() {
 		"specify a list of comma-separated projects of which the contributors are skipped for alert")
 
 	projectAlertCmd.PersistentFlags().StringVarP(&alertCarbonCopy, "cc", "", alertCarbonCopy,
-		"alert carbon copy `email`")
+		"alert carbon copy `email`, overriding the alerts configuration")
 
 	projectAlertCmd.PersistentFlags().StringVarP(&alertMailerTemplate, "template", "", alertMailerTemplate,
-		"`path` of the mail template file")
+		"`path` of the mail template file, overriding the templates in the alerts configuration")
 
 	projectAlertCmd.PersistentFlags().BoolVarP(&alertDryrun, "dryrun", "", alertDryrun,
 		"print out alerts and recipients without really sent them")
 
 	projectAlertOotCmd.PersistentFlags().StringVarP(&alertMode, "mode", "m", alertMode,
-		fmt.Sprintf("alert `mode`. Supported modes: %s", strings.Join(supportedAlertModes, ",")))
+		fmt.Sprintf("alert `mode` defined in the alerts configuration. Default modes: %s", strings.Join(alert.Policy{AlertsConfiguration: alert.Default()}.OotModeNames(), ",")))
 
 	projectActionCmd.AddCommand(projectActionListCmd, projectActionExecCmd)
 
@@ -191,7 +150,13 @@ var projectCreateCmd = &cobra.Command{
 			},
 		}
 
-		return actionExec(args[0], &data)
+		resolver, err := newIdentityResolver(loadConfig())
+		if err != nil {
+			return err
+		}
+		defer closeIdentityResolver(resolver)
+
+		return actionExec(args[0], &data, resolver)
 	},
 }
 
@@ -261,20 +226,16 @@ var projectUpdateCmd = &cobra.Command{
 					}
 					log.Debugf("[%s] project storage info: %+v", pid, info)
 
-					// update project database only for pdb V1.
-					if v1, ok := ipdb.(pdb.V1); ok {
-						if _, x := opts["members"]; x {
-							if err := v1.UpdateProjectMembers(pid, info.Members); err != nil {
-								log.Errorf("[%s] cannot update members in pdb: %s", pid, err)
-							}
+					// update project database.
+					if _, x := opts["members"]; x {
+						if err := ipdb.UpdateProjectMembers(pid, info.Members); err != nil {
+							log.Errorf("[%s] cannot update members in pdb: %s", pid, err)
 						}
-						if _, x := opts["quota"]; x {
-							if err := v1.UpdateProjectStorageQuota(pid, info.Storage.QuotaGb, info.Storage.UsageMb>>10); err != nil {
-								log.Errorf("[%s] cannot update storage usage in pdb: %s", pid, err)
-							}
+					}
+					if _, x := opts["quota"]; x {
+						if err := ipdb.UpdateProjectStorageQuota(pid, info.Storage.QuotaGb, info.Storage.UsageMb>>10); err != nil {
+							log.Errorf("[%s] cannot update storage usage in pdb: %s", pid, err)
 						}
-					} else {
-						log.Warnf("[%s] not pdb V1: skip update.", pid)
 					}
 				}
 			}()
@@ -341,19 +302,44 @@ var projectActionExecCmd = &cobra.Command{
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 
-		// list pending pdb actions
+		// list pending pdb actions, or read them from the file.
 		log.Debugf("list pending actions")
-		actions, err := loadPdb().GetProjectPendingActions()
+		actions, err := loadActions()
+		if err != nil {
+			return err
+		}
+
+		// guard against concurrent executions, e.g. the `serve` daemon.
+		lock, err := ufp.TryLock(actionLockPath)
 		if err != nil {
 			return err
 		}
+		defer lock.Unlock()
+
+		// open the journal for bookkeeping the attempts on the actions.
+		j, s, err := openJournal()
+		if err != nil {
+			return err
+		}
+		defer s.Disconnect()
+
+		resolver, err := newIdentityResolver(loadConfig())
+		if err != nil {
+			return err
+		}
+		defer closeIdentityResolver(resolver)
+
+		if !actionSkipNotify {
+			roleChanges = alert.NewMembership()
+		}
 
 		// perform pending actions sequencially as the NetApp API
 		// doesn't seem to be able to handle it concurrently.
-		for pid, action := range actions {
-			if err := actionExec(pid, action); err != nil {
-				log.Errorf("%s", err)
-			}
+		stats := runActions(context.Background(), j, actions, 1, resolver)
+		log.Infof("pending actions applied: %d, failed: %d, skipped: %d", stats.Applied, stats.Failed, stats.Skipped)
+
+		if roleChanges != nil {
+			notifyRoleChanges(roleChanges)
 		}
 
 		return nil
@@ -377,6 +363,44 @@ var projectAlertCmd = &cobra.Command{
 	},
 }
 
+// loadAlertPolicy returns the alert policy from the alerts configuration, with the
+// settings overridden by the command-line flags given explicitly.
+// This function fatals out if the policy is invalid.
+func loadAlertPolicy(cmd *cobra.Command, conf config.Configuration) *alert.Policy {
+
+	c := conf.Alerts
+
+	if cmd.Flags().Changed("sender") {
+		c.Sender = alertSender
+	}
+	if cmd.Flags().Changed("from") {
+		c.SenderEmail = alertSenderEmail
+	}
+	if cmd.Flags().Changed("cc") {
+		c.Oot.CarbonCopy = []string{alertCarbonCopy}
+	}
+
+	policy, err := alert.NewPolicy(c)
+	if err != nil {
+		log.Fatalf("invalid alerts configuration: %s", err)
+	}
+
+	// the template given explicitly applies to all alerts.
+	if cmd.Flags().Changed("template") {
+		policy.Ooq.Template = alertMailerTemplate
+		for i := range policy.Ooq.Bands {
+			policy.Ooq.Bands[i].Template = ""
+		}
+		policy.Oot.Template = alertMailerTemplate
+		for i := range policy.Oot.Modes {
+			policy.Oot.Modes[i].Template = ""
+		}
+		policy.Digest.Template = alertMailerTemplate
+	}
+
+	return policy
+}
+
 var projectAlertOotCmd = &cobra.Command{
 	Use:   "oot",
 	Short: "Utility for expiring or overdue (i.e. out-of-time) project alerts",
@@ -406,7 +430,7 @@ var projectAlertOotInfo = &cobra.Command{
 		defer store.Disconnect()
 
 		// initialize kvstore with bucket "ootLastAlerts"
-		dbBucket := "ootLastAlerts"
+		dbBucket := alert.BucketOotLastAlerts
 		err = store.Init([]string{dbBucket})
 		if err != nil {
 			return err
@@ -444,6 +468,12 @@ var projectAlertOotSend = &cobra.Command{
 
 		ipdb := loadPdb()
 		conf := loadConfig()
+		policy := loadAlertPolicy(cmd, conf)
+
+		mode, ok := policy.OotMode(alertMode)
+		if !ok {
+			return fmt.Errorf("unknown alert mode %s, supported modes: %s", alertMode, strings.Join(policy.OotModeNames(), ","))
+		}
 
 		projects, err := ipdb.GetProjects(true)
 		if err != nil {
@@ -459,100 +489,28 @@ var projectAlertOotSend = &cobra.Command{
 		}
 
 		// connect to internal database for last sent
-		store := store.KVStore{
+		kvstore := store.KVStore{
 			Path: alertDbPath,
 		}
-		err = store.Connect()
+		err = kvstore.Connect()
 		if err != nil {
 			return err
 		}
-		defer store.Disconnect()
+		defer kvstore.Disconnect()
 
-		// initialize kvstore with bucket "ootLastAlerts"
-		dbBucket := "ootLastAlerts"
-		err = store.Init([]string{dbBucket})
+		// initialize kvstore with bucket "ootLastAlerts" and the alert history
+		alertHistory = &alert.History{Store: &kvstore}
+		err = alertHistory.Init()
 		if err != nil {
 			return err
 		}
 
-		// perform pending actions with 4 concurrent workers,
-		// each works on a project.
-		var wg sync.WaitGroup
-		cprjs := make(chan *pdb.Project, execNthreads*2)
-		for w := 0; w < execNthreads; w++ {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-
-				for prj := range cprjs {
-
-					// perform test on a specific project with id specified via
-					// `ootAlertTestProjectID`.
-					if alertTestProjectID != "" && prj.ID != alertTestProjectID {
-						log.Infof("[%s] ignored oot alert in test mode", prj.ID)
-						continue
-					}
-
-					// get members from filer gateway
-					info, err := fgw.GetProject(prj.ID)
-					if err != nil {
-						log.Errorf("[%s] cannot get project storage info: %s", prj.ID, err)
-						continue
-					}
-					log.Debugf("[%s] project storage info: %+v", prj.ID, info)
-
-					// get last alert information from the local db
-					data, err := store.Get(dbBucket, []byte(prj.ID))
-					if err != nil {
-						log.Debugf("[%s] cannot get last oot alert data: %s", prj.ID, err)
-					}
-
-					// default lastAlert with timestamp (0001-01-01 00:00:00 +0000 UTC), uratio 0.
-					lastAlert := pdb.OotLastAlert{}
-					if data != nil {
-						if err := json.Unmarshal(data, &lastAlert); err != nil {
-							log.Debugf("[%s] cannot interpret last oot alert data: %s", prj.ID, err)
-						}
-					}
-					log.Debugf("[%s] last oot alert: %+v", prj.ID, lastAlert)
-
-					// check and send alert
-					lastAlert, err = ootAlert(ipdb, prj, info, lastAlert, conf.Mailer)
-
-					// do nothing to the db for dryrun
-					if alertDryrun {
-						continue
-					}
-
-					switch err.(type) {
-					case nil:
-						log.Debugf("[%s] last oot alert: %+v", prj.ID, lastAlert)
-						// alert sent, update store db with new last alert information
-						data, _ := json.Marshal(&lastAlert)
-						store.Set(dbBucket, []byte(prj.ID), data)
-					case *pdb.OpsIgnored:
-						// alert ignored
-						log.Debugf("[%s] %s", prj.ID, err)
-						log.Debugf("[%s] last oot alert: %+v", prj.ID, lastAlert)
-					default:
-						// something wrong
-						log.Errorf("[%s] fail to send alert for project out-of-time: +%v", prj.ID, err)
-					}
-				}
-			}()
-		}
-
-		// select projects matching the alerting mode criteria
-		for _, project := range projects {
-			if project.End.Format(dateLayout) == ootAlertDate[alertMode] {
-				cprjs <- project
-			} else {
-				log.Debugf("[%s] skipped as the end time (%s) doesn't match alerting criteria for mode %s", project.ID, project.End, alertMode)
-			}
+		m, err := mailer.New(conf.Mailer, mailer.SMTP)
+		if err != nil {
+			return err
 		}
-		close(cprjs)
 
-		wg.Wait()
+		sendOotAlerts(ipdb, fgw, &kvstore, projects, mailDispatcher{m: m, from: policy.SenderEmail}, policy, mode)
 
 		return nil
 	},
@@ -587,7 +545,7 @@ var projectAlertOoqInfo = &cobra.Command{
 		defer store.Disconnect()
 
 		// initialize kvstore with bucket "ooqLastAlerts"
-		dbBucket := "ooqLastAlerts"
+		dbBucket := alert.BucketOoqLastAlerts
 		err = store.Init([]string{dbBucket})
 		if err != nil {
 			return err
@@ -625,6 +583,7 @@ var projectAlertOoqSend = &cobra.Command{
 
 		ipdb := loadPdb()
 		conf := loadConfig()
+		policy := loadAlertPolicy(cmd, conf)
 
 		projects, err := ipdb.GetProjects(true)
 		if err != nil {
@@ -640,99 +599,28 @@ var projectAlertOoqSend = &cobra.Command{
 		}
 
 		// connect to internal database for last sent
-		store := store.KVStore{
+		kvstore := store.KVStore{
 			Path: alertDbPath,
 		}
-		err = store.Connect()
+		err = kvstore.Connect()
 		if err != nil {
 			return err
 		}
-		defer store.Disconnect()
+		defer kvstore.Disconnect()
 
-		// initialize kvstore with bucket "ooqLastAlerts"
-		dbBucket := "ooqLastAlerts"
-		err = store.Init([]string{dbBucket})
+		// initialize kvstore with bucket "ooqLastAlerts" and the alert history
+		alertHistory = &alert.History{Store: &kvstore}
+		err = alertHistory.Init()
 		if err != nil {
 			return err
 		}
 
-		// perform pending actions with 4 concurrent workers,
-		// each works on a project.
-		var wg sync.WaitGroup
-		cprjs := make(chan *pdb.Project, execNthreads*2)
-		for w := 0; w < execNthreads; w++ {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-
-				for prj := range cprjs {
-
-					// perform test on a specific project with id specified via
-					// `ooqAlertTestProjectID`.
-					if alertTestProjectID != "" && prj.ID != alertTestProjectID {
-						log.Infof("[%s] ignored ooq alert in test mode", prj.ID)
-						continue
-					}
-
-					// get members from filer gateway
-					info, err := fgw.GetProject(prj.ID)
-					if err != nil {
-						log.Errorf("[%s] cannot get project storage info: %s", prj.ID, err)
-						continue
-					}
-					log.Debugf("[%s] project storage info: %+v", prj.ID, info)
-
-					// get last alert information from the local db
-					data, err := store.Get(dbBucket, []byte(prj.ID))
-					if err != nil {
-						log.Debugf("[%s] cannot get last ooq alert data: %s", prj.ID, err)
-					}
-
-					// default lastAlert with timestamp (0001-01-01 00:00:00 +0000 UTC), uratio 0.
-					lastAlert := pdb.OoqLastAlert{}
-					if data != nil {
-						if err := json.Unmarshal(data, &lastAlert); err != nil {
-							log.Debugf("[%s] cannot interpret last ooq alert data: %s", prj.ID, err)
-						}
-					}
-					log.Debugf("[%s] last ooq alert: %+v", prj.ID, lastAlert)
-
-					// check and send alert
-					lastAlert, err = ooqAlert(ipdb, prj, info, lastAlert, conf.Mailer)
-
-					// do nothing to the db for dryrun
-					if alertDryrun {
-						continue
-					}
-
-					switch err.(type) {
-					case nil:
-						log.Debugf("[%s] last ooq alert: %+v", prj.ID, lastAlert)
-						// alert sent, update store db with new last alert information
-						data, _ := json.Marshal(&lastAlert)
-						store.Set(dbBucket, []byte(prj.ID), data)
-					case *pdb.OpsIgnored:
-						// alert ignored
-						log.Debugf("[%s] %s", prj.ID, err)
-						log.Debugf("[%s] last ooq alert: %+v", prj.ID, lastAlert)
-						// alert ignored, still need to update the lastAlert to alert history db with
-						// the current project UsagePercent.
-						data, _ := json.Marshal(&lastAlert)
-						store.Set(dbBucket, []byte(prj.ID), data)
-					default:
-						// something wrong
-						log.Errorf("[%s] fail to send alert for project out-of-quota: +%v", prj.ID, err)
-					}
-				}
-			}()
-		}
-
-		for _, project := range projects {
-			cprjs <- project
+		m, err := mailer.New(conf.Mailer, mailer.SMTP)
+		if err != nil {
+			return err
 		}
-		close(cprjs)
 
-		wg.Wait()
+		sendOoqAlerts(ipdb, fgw, &kvstore, projects, mailDispatcher{m: m, from: policy.SenderEmail}, policy)
 
 		return nil
 	},
@@ -741,10 +629,11 @@ var projectAlertOoqSend = &cobra.Command{
 // ootAlert sents out alert email concerning project going to expire at maximum frequency of
 // once per week.
 //
-// If the alert email is sent, it returns the time at which the email were sent.
+// If the alert email is sent, it returns the time at which the email were sent, and the
+// history record of the alert (nil if no recipient is alerted).
 //
 // If the alert sending is ignored, the returned error is `OpsIgnored`.
-func ootAlert(ipdb pdb.PDB, prj *pdb.Project, info *pdb.DataProjectInfo, lastAlert pdb.OotLastAlert, mailerConfig config.MailerConfiguration) (pdb.OotLastAlert, error) {
+func ootAlert(ipdb pdb.PDB, prj *pdb.Project, info *pdb.DataProjectInfo, lastAlert pdb.OotLastAlert, d alertDispatcher, policy *alert.Policy, mode config.OotAlertMode) (pdb.OotLastAlert, *alert.Record, error) {
 
 	now := time.Now()
 	next := lastAlert.Timestamp.AddDate(0, 0, 3)
@@ -753,61 +642,29 @@ func ootAlert(ipdb pdb.PDB, prj *pdb.Project, info *pdb.DataProjectInfo, lastAle
 	// NOTE: this is a redundent protection given that the ootAlert implements `mode` to
 	//       send out alert on an exact dates based on the project end time (i.e. 28/14/7/0 days in advance)
 	if now.After(next) {
-		// send the email
-		// initializing mailer
-		m, err := mailer.New(mailerConfig, mailer.SMTP)
-		if err != nil {
-			return lastAlert, err
-		}
-
-		// gather user information of project owner
-		recipients := make(map[string]*pdb.User)
-		u, err := ipdb.GetUser(prj.Owner)
-		switch {
-		case err != nil:
-			log.Errorf("[%s] cannot get recipient info from project database: %s", info.ProjectID, prj.Owner)
-		case u.Status != pdb.UserStatusCheckedIn && u.Status != pdb.UserStatusCheckedOutExtended:
-			log.Debugf("[%s] skip alert %s due to user state %s", info.ProjectID, u.ID, u.Status)
-		default:
-			recipients[prj.Owner] = u
-		}
-
-		// gather user information of project members
-		for _, m := range info.Members {
-
-			if m.Role != acl.Manager.String() && m.Role != acl.Contributor.String() {
-				log.Debugf("[%s] skip alert %s due to user role %s", info.ProjectID, m.UserID, m.Role)
-				continue
-			}
-
-			_, skipc := skipContributorPmap[info.ProjectID]
-			if m.Role == acl.Contributor.String() && skipc {
-				log.Debugf("[%s] skip alert to contributor %s", info.ProjectID, m.UserID)
-				continue
-			}
-
-			u, err := ipdb.GetUser(m.UserID)
-			switch {
-			case err != nil:
-				log.Errorf("[%s] cannot get recipient info from project database: %s", info.ProjectID, m.UserID)
-			case u.Status != pdb.UserStatusCheckedIn && u.Status != pdb.UserStatusCheckedOutExtended:
-				log.Debugf("[%s] skip alert %s due to user state %s", info.ProjectID, u.ID, u.Status)
-			default:
-				recipients[m.UserID] = u
-			}
-		}
+		// gather user information of the recipients
+		recipients := alertRecipients(ipdb, prj, info, policy.Oot.Recipients)
 
 		// sending alerts to recipients
 		nsent := 0
+		sent := []string{}
 		data := mailer.ProjectAlertTemplateData{
 			ProjectID:      info.ProjectID,
 			ProjectTitle:   prj.Name,
 			ProjectEndDate: prj.End.Format(dateLayout),
-			SenderName:     alertSender,
+			SenderName:     policy.Sender,
+			AlertType:      alert.TypeOot,
+		}
+
+		// the number of days (or months) before the project's end date
+		if mode.Months != 0 {
+			data.ExpiringInMonths = mode.Months
+		} else {
+			data.ExpiringInDays = mode.Days
 		}
 
 		// add condituional carbon-copies for every email send to a recipient
-		cclist := []string{alertCarbonCopy}
+		cclist := append([]string{}, policy.Oot.CarbonCopy...)
 
 		if strings.HasPrefix(info.ProjectID, "24") {
 			cclist = append(cclist, "DCC-DataOfficer@donders.ru.nl")
@@ -823,75 +680,101 @@ func ootAlert(ipdb pdb.PDB, prj *pdb.Project, info *pdb.DataProjectInfo, lastAle
 				continue
 			}
 
-			data.RecipientName = u.DisplayName()
-
-			// compose alert subject and body
-			switch alertMode {
-			case "p4w":
-				data.ExpiringInDays = 28
-			case "p2w":
-				data.ExpiringInDays = 14
-			case "p1w":
-				data.ExpiringInDays = 7
-			case "now":
-				data.ExpiringInDays = 0
-			case "g2m":
-				data.ExpiringInMonths = -2
-			default:
-				// ignore operation if alertMode is not a defined mode
-				msg := fmt.Sprintf("ignore unknown alert mode %s", alertMode)
-				return lastAlert, &pdb.OpsIgnored{Message: msg}
-			}
-
-			subject, body, err := mailer.ComposeMessageFromTemplateFile(alertMailerTemplate, data)
-
-			if err != nil {
-				log.Debugf("[%s] skip alert %s due to failure generating alert: %s", info.ProjectID, u.ID, err)
+			if err := d.dispatch(u, policy.OotTemplate(mode), data, cclist...); err != nil {
+				log.Errorf("[%s] skip alert %s due to failure dispatching alert: %s", info.ProjectID, u.ID, err)
 				continue
 			}
 
-			if alertDryrun {
-				log.Infof("[%s] alert %s", info.ProjectID, u.Email)
-			} else if err := m.SendMail(alertSenderEmail, subject, body, []string{u.Email}, cclist...); err != nil {
-				log.Errorf("[%s] fail to sent oot alert to %s: %s", info.ProjectID, u.Email, err)
-			}
-
+			sent = append(sent, u.Email)
 			nsent++
 		}
 
+		var record *alert.Record
+		if nsent > 0 {
+			record = &alert.Record{
+				ProjectID:  info.ProjectID,
+				Time:       now,
+				Type:       alert.TypeOot,
+				Mode:       mode.Name,
+				Template:   policy.OotTemplate(mode),
+				Recipients: sent,
+				CarbonCopy: cclist,
+			}
+		}
+
 		return pdb.OotLastAlert{
 			Timestamp: now,
-		}, nil
+		}, record, nil
 	}
 	msg := fmt.Sprintf("last alert has been sent on %s", lastAlert.Timestamp.Format(dateLayout))
-	return lastAlert, &pdb.OpsIgnored{Message: msg}
+	return lastAlert, nil, &pdb.OpsIgnored{Message: msg}
+}
+
+// alertRecipients returns the users of the project `prj` having one of the recipient `roles`,
+// i.e. the project owner and the members with the roles in `info`.  Users not checked-in
+// (or with an extended check-out) are excluded.
+func alertRecipients(ipdb pdb.PDB, prj *pdb.Project, info *pdb.DataProjectInfo, roles []string) map[string]*pdb.User {
+
+	recipients := make(map[string]*pdb.User)
+
+	// gather user information of project owner
+	if alert.IsRecipient(roles, alert.RoleOwner) {
+		u, err := ipdb.GetUser(prj.Owner)
+		switch {
+		case err != nil:
+			log.Errorf("[%s] cannot get recipient info from project database: %s", info.ProjectID, prj.Owner)
+		case u.Status != pdb.UserStatusCheckedIn && u.Status != pdb.UserStatusCheckedOutExtended:
+			log.Debugf("[%s] skip alert %s due to user state %s", info.ProjectID, u.ID, u.Status)
+		default:
+			recipients[prj.Owner] = u
+		}
+	}
+
+	// gather user information of project members
+	for _, m := range info.Members {
+
+		if !alert.IsRecipient(roles, m.Role) {
+			log.Debugf("[%s] skip alert %s due to user role %s", info.ProjectID, m.UserID, m.Role)
+			continue
+		}
+
+		_, skipc := skipContributorPmap[info.ProjectID]
+		if m.Role == acl.Contributor.String() && skipc {
+			log.Debugf("[%s] skip alert to contributor %s", info.ProjectID, m.UserID)
+			continue
+		}
+
+		u, err := ipdb.GetUser(m.UserID)
+		switch {
+		case err != nil:
+			log.Errorf("[%s] cannot get recipient info from project database: %s", info.ProjectID, m.UserID)
+		case u.Status != pdb.UserStatusCheckedIn && u.Status != pdb.UserStatusCheckedOutExtended:
+			log.Debugf("[%s] skip alert %s due to user state %s", info.ProjectID, u.ID, u.Status)
+		default:
+			recipients[m.UserID] = u
+		}
+	}
+
+	return recipients
 }
 
 // ooqAlert checks whether alert concerning project storage out-of-quota
 // is to be sent based on the project storage information `info`.
 //
-// If the alert email is sent, it returns the time at which the emails were sent.
+// If the alert email is sent, it returns the time at which the emails were sent, and the
+// history record of the alert.
 //
 // If the alert sending is ignored by design, the returned error is `OpsIgnored`.
-func ooqAlert(ipdb pdb.PDB, prj *pdb.Project, info *pdb.DataProjectInfo, lastAlert pdb.OoqLastAlert, mailerConfig config.MailerConfiguration) (pdb.OoqLastAlert, error) {
+func ooqAlert(ipdb pdb.PDB, prj *pdb.Project, info *pdb.DataProjectInfo, lastAlert pdb.OoqLastAlert, d alertDispatcher, policy *alert.Policy) (pdb.OoqLastAlert, *alert.Record, error) {
 
-	var uratio int
-
-	switch {
-	case info.Storage.QuotaGb == 0 && info.Storage.UsageMb > 0:
-		uratio = 100
-	case info.Storage.QuotaGb == 0 && info.Storage.UsageMb == 0:
-		uratio = 0
-	default:
-		uratio = 100 * info.Storage.UsageMb / (info.Storage.QuotaGb << 10)
-	}
+	uratio := usagePercent(info)
 
 	// check if the usage is above the alert threshold.
-	duration := ooqAlertFrequency(uratio)
-	if duration == 0 {
+	band, ok := policy.OoqBand(uratio)
+	if !ok {
 		msg := fmt.Sprintf("usage (%d%%) below the ooq threshold.", uratio)
 		lastAlert.UsagePercentLastCheck = uratio
-		return lastAlert, &pdb.OpsIgnored{Message: msg}
+		return lastAlert, nil, &pdb.OpsIgnored{Message: msg}
 	}
 
 	// check if current usage ratio is higher than the usage ratio at the time the last alert was sent.
@@ -902,69 +785,30 @@ func ooqAlert(ipdb pdb.PDB, prj *pdb.Project, info *pdb.DataProjectInfo, lastAle
 	if uratio < minUsageLastAert {
 		msg := fmt.Sprintf("usage (%d%%) below the usage (%d%%) at the last alert/check.", uratio, minUsageLastAert)
 		lastAlert.UsagePercentLastCheck = uratio
-		return lastAlert, &pdb.OpsIgnored{Message: msg}
+		return lastAlert, nil, &pdb.OpsIgnored{Message: msg}
 	}
 
 	// check if a new alert should be sent according to the alert frequency.
 	now := time.Now()
-	next := lastAlert.Timestamp.Add(duration)
+	next := lastAlert.Timestamp.Add(band.Interval)
 	if now.Before(next) { // current time is in between
 		msg := fmt.Sprintf("%s not reaching next alert %s.", now, next)
 		lastAlert.UsagePercentLastCheck = uratio
-		return lastAlert, &pdb.OpsIgnored{Message: msg}
-	}
-
-	// initializing mailer
-	m, err := mailer.New(mailerConfig, mailer.SMTP)
-	if err != nil {
-		return lastAlert, err
+		return lastAlert, nil, &pdb.OpsIgnored{Message: msg}
 	}
 
-	// gather user information of project owner
-	recipients := make(map[string]*pdb.User)
-
-	u, err := ipdb.GetUser(prj.Owner)
-	switch {
-	case err != nil:
-		log.Errorf("[%s] cannot get recipient info from project database: %s", info.ProjectID, prj.Owner)
-	case u.Status != pdb.UserStatusCheckedIn && u.Status != pdb.UserStatusCheckedOutExtended:
-		log.Debugf("[%s] skip alert %s due to user state %s", info.ProjectID, u.ID, u.Status)
-	default:
-		recipients[prj.Owner] = u
-	}
-
-	// gather user information of project members
-	for _, m := range info.Members {
-
-		if m.Role != acl.Manager.String() && m.Role != acl.Contributor.String() {
-			log.Debugf("[%s] skip alert %s due to user role %s", info.ProjectID, m.UserID, m.Role)
-			continue
-		}
-
-		_, skipc := skipContributorPmap[info.ProjectID]
-		if m.Role == acl.Contributor.String() && skipc {
-			log.Debugf("[%s] skip alert to contributor %s", info.ProjectID, m.UserID)
-			continue
-		}
-
-		u, err := ipdb.GetUser(m.UserID)
-		switch {
-		case err != nil:
-			log.Errorf("[%s] cannot get recipient info from project database: %s", info.ProjectID, m.UserID)
-		case u.Status != pdb.UserStatusCheckedIn && u.Status != pdb.UserStatusCheckedOutExtended:
-			log.Debugf("[%s] skip alert %s due to user state %s", info.ProjectID, u.ID, u.Status)
-		default:
-			recipients[m.UserID] = u
-		}
-	}
+	// gather user information of the recipients
+	recipients := alertRecipients(ipdb, prj, info, policy.Ooq.Recipients)
 
 	// sending alerts to recipients
 	nsent := 0
+	sent := []string{}
 	data := mailer.ProjectAlertTemplateData{
 		ProjectID:       info.ProjectID,
 		ProjectTitle:    prj.Name,
 		QuotaUsageRatio: uratio,
-		SenderName:      alertSender,
+		SenderName:      policy.Sender,
+		AlertType:       alert.TypeOoq,
 	}
 	for _, u := range recipients {
 
@@ -974,39 +818,61 @@ func ooqAlert(ipdb pdb.PDB, prj *pdb.Project, info *pdb.DataProjectInfo, lastAle
 			continue
 		}
 
-		data.RecipientName = u.DisplayName()
-
-		subject, body, err := mailer.ComposeMessageFromTemplateFile(alertMailerTemplate, data)
-
-		if err != nil {
-			log.Debugf("[%s] skip alert %s due to failure generating alert: %s", info.ProjectID, u.ID, err)
+		if err := d.dispatch(u, policy.OoqTemplate(band), data, policy.Ooq.CarbonCopy...); err != nil {
+			log.Errorf("[%s] skip alert %s due to failure dispatching alert: %s", info.ProjectID, u.ID, err)
 			continue
 		}
 
-		if alertDryrun {
-			log.Infof("[%s] alert %s on usage ratio: %d", info.ProjectID, u.Email, uratio)
-		} else if err := m.SendMail(alertSenderEmail, subject, body, []string{u.Email}); err != nil {
-			log.Errorf("[%s] fail to sent ooq alert to %s: %s", info.ProjectID, u.Email, err)
-		}
-
+		sent = append(sent, u.Email)
 		nsent++
 	}
 
 	// return `pdb.OpsIgnored` if no alert was (successfully) sent.
 	if nsent == 0 {
 		lastAlert.UsagePercentLastCheck = uratio
-		return lastAlert, &pdb.OpsIgnored{Message: "no alert was sent"}
+		return lastAlert, nil, &pdb.OpsIgnored{Message: "no alert was sent"}
+	}
+
+	record := &alert.Record{
+		ProjectID:    info.ProjectID,
+		Time:         now,
+		Type:         alert.TypeOoq,
+		UsagePercent: uratio,
+		Template:     policy.OoqTemplate(band),
+		Recipients:   sent,
+		CarbonCopy:   policy.Ooq.CarbonCopy,
 	}
 
 	return pdb.OoqLastAlert{
 		Timestamp:             now,
 		UsagePercent:          uratio,
 		UsagePercentLastCheck: uratio,
-	}, nil
+	}, record, nil
+}
+
+// actionStorageSystem determines the storage system on which the action `act` is
+// executed: the system of the existing project reported by the filer-gateway (`info`), the
+// system given in the action (e.g. read from a file), or the `storSystem` for a new project.
+// An error is returned if the system is not supported.
+func actionStorageSystem(act *pdb.DataProjectUpdate, info *pdb.DataProjectInfo) (string, error) {
+
+	system := storSystem
+	switch {
+	case info != nil && info.Storage.System != "":
+		system = info.Storage.System
+	case act.Storage.System != "":
+		system = act.Storage.System
+	}
+
+	if _, ok := projectRoots[system]; !ok {
+		return "", fmt.Errorf("unknown storage system: %q", system)
+	}
+	return system, nil
 }
 
 // actionExec implements the logic of executing the pending actions concerning a project.
-func actionExec(pid string, act *pdb.DataProjectUpdate) error {
+// Members to be granted with roles are validated against the identity `resolver`.
+func actionExec(pid string, act *pdb.DataProjectUpdate, resolver identity.Resolver) error {
 
 	// load project database interface
 	ipdb := loadPdb()
@@ -1036,6 +902,12 @@ func actionExec(pid string, act *pdb.DataProjectUpdate) error {
 		}
 	}
 
+	// validate members to be granted with roles before any ACL is written.
+	members := append(append(append([]string{}, managers...), contributors...), viewers...)
+	if err := identity.Validate(resolver, members); err != nil {
+		return fmt.Errorf("[%s] %s", pid, err)
+	}
+
 	// check if it is about an existing project
 	fgw, err := filergateway.NewClient(conf)
 	if err != nil {
@@ -1043,11 +915,22 @@ func actionExec(pid string, act *pdb.DataProjectUpdate) error {
 	}
 
 	newProject := true
-	if _, err = fgw.GetProject(pid); err == nil {
+	var before []pdb.Member
+	info, err := fgw.GetProject(pid)
+	if err == nil {
 		newProject = false
+		before = info.Members
+	} else {
+		info = nil
 	}
 
-	if useNetappCLI && storSystem == "netapp" {
+	system, err := actionStorageSystem(act, info)
+	if err != nil {
+		return fmt.Errorf("[%s] %s", pid, err)
+	}
+	act.Storage.System = system
+
+	if useNetappCLI && system == "netapp" {
 		// use NetappCLI + SSH to perform pending actions.
 		cli := filergateway.NetAppCLI{Config: conf.NetAppCLI}
 		if !newProject {
@@ -1095,6 +978,7 @@ func actionExec(pid string, act *pdb.DataProjectUpdate) error {
 			Silence:      false,
 			Traverse:     false,
 			Force:        false,
+			Resolver:     resolver,
 		}
 
 		if ec, err := runner.SetRoles(); err != nil {
@@ -1168,40 +1052,58 @@ func actionExec(pid string, act *pdb.DataProjectUpdate) error {
 		}
 	}
 
-	// For PDBv1, get ACL from the filer gateway and update database accordingly.
-	if v1, ok := ipdb.(pdb.V1); ok {
+	// get ACL from the filer gateway and update database accordingly.
+	// wait for 5 seconds to give the filer-gateway time to refresh the cache
+	// upon project update.
+	time.Sleep(5 * time.Second)
 
-		// wait for 5 seconds to give the filer-gateway time to refresh the cache
-		// upon project update.
-		time.Sleep(5 * time.Second)
+	pdata, err := fgw.GetProject(pid)
 
-		pdata, err := fgw.GetProject(pid)
+	if err != nil {
+		return fmt.Errorf("[%s] fail getting acl: %s", pid, err)
+	}
 
-		if err != nil {
-			return fmt.Errorf("[%s] fail getting acl: %s", pid, err)
-		}
+	log.Debugf("[%s] retrieved acl from filergateway: %+v", pid, pdata.Members)
 
-		// construct data structure for updating PDB v1 database.
-		members := pdata.Members
+	// update project database with the up-to-date active members.
+	if err := ipdb.UpdateProjectMembers(pid, pdata.Members); err != nil {
+		return fmt.Errorf("[%s] fail updating acl in PDB: %s", pid, err)
+	}
 
-		log.Debugf("[%s] retrieved acl from filergateway: %+v", pid, members)
+	// collect the role changes for notifying the members and the managers.
+	if roleChanges != nil {
+		changes := []mailer.ProjectRoleChangeTemplateData{}
+		for _, c := range alert.RoleChanges(pid, before, pdata.Members) {
+			// managers of a new project are notified of the project storage below.
+			if newProject && c.NewRole == acl.Manager.String() {
+				continue
+			}
+			changes = append(changes, c)
+		}
 
-		// update PDB v1 database with the up-to-date active members.
-		if err := v1.UpdateProjectMembers(pid, members); err != nil {
-			return fmt.Errorf("[%s] fail updating acl in PDB: %s", pid, err)
+		mgrs := []string{}
+		for _, m := range pdata.Members {
+			if m.Role == acl.Manager.String() {
+				mgrs = append(mgrs, m.UserID)
+			}
 		}
-	}
 
-	// put successfully performed action to actionsOK map
-	// initialize map for successfully performed actions.
-	actionsOK := map[string]*pdb.DataProjectUpdate{
-		pid: act,
+		roleChanges.Add(pid, changes, mgrs)
 	}
 
-	// clean up PDB pending actions that has been successfully performed.
-	// Note that it doesn't fail the process.
-	if err := ipdb.DelProjectPendingActions(actionsOK); err != nil {
-		log.Errorf("[%s] fail cleaning up pending actions: %s", pid, err)
+	// clean up PDB pending actions that has been successfully performed.  Actions read
+	// from the `actionFile` are not pending actions of the project database, and are
+	// left out.  Note that it doesn't fail the process.
+	if actionFile == "" {
+		// put successfully performed action to actionsOK map
+		// initialize map for successfully performed actions.
+		actionsOK := map[string]*pdb.DataProjectUpdate{
+			pid: act,
+		}
+
+		if err := ipdb.DelProjectPendingActions(actionsOK); err != nil {
+			log.Errorf("[%s] fail cleaning up pending actions: %s", pid, err)
+		}
 	}
 
 	// sendout email notifying managers the new project storage is ready to use.
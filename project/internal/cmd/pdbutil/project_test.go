@@ -0,0 +1,100 @@
+package pdbutil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
+)
+
+func TestActionStorageSystem(t *testing.T) {
+
+	defer func(sys string) { storSystem = sys }(storSystem)
+	storSystem = "cephfs"
+
+	info := func(sys string) *pdb.DataProjectInfo {
+		i := &pdb.DataProjectInfo{}
+		i.Storage.System = sys
+		return i
+	}
+
+	cases := []struct {
+		name     string
+		act      string
+		info     *pdb.DataProjectInfo
+		expected string
+		err      bool
+	}{
+		{name: "new project", expected: "cephfs"},
+		{name: "new project from file", act: "freenas", expected: "freenas"},
+		{name: "existing project", act: "freenas", info: info("netapp"), expected: "netapp"},
+		{name: "existing project without system", info: info(""), expected: "cephfs"},
+		{name: "unknown system", act: "nfs", err: true},
+	}
+
+	for _, c := range cases {
+		act := &pdb.DataProjectUpdate{Storage: pdb.Storage{System: c.act}}
+		sys, err := actionStorageSystem(act, c.info)
+		if c.err {
+			if err == nil {
+				t.Errorf("%s: expect error, got %s", c.name, sys)
+			}
+			continue
+		}
+		if err != nil || sys != c.expected {
+			t.Errorf("%s: unexpected system %q (%v), expected %q", c.name, sys, err, c.expected)
+		}
+	}
+}
+
+func TestSplitActions(t *testing.T) {
+
+	defer func(sys string) { storSystem = sys }(storSystem)
+	storSystem = "cephfs"
+
+	systems := map[string]string{
+		"3010000.01": "netapp",
+		"3010000.02": "cephfs",
+	}
+
+	getProject := func(pid string) (*pdb.DataProjectInfo, error) {
+		sys, ok := systems[pid]
+		if !ok {
+			return nil, fmt.Errorf("project not found: %s", pid)
+		}
+		i := &pdb.DataProjectInfo{ProjectID: pid}
+		i.Storage.System = sys
+		return i, nil
+	}
+
+	actions := map[string]*pdb.DataProjectUpdate{
+		// existing projects stay on their storage system.
+		"3010000.01": {},
+		"3010000.02": {Storage: pdb.Storage{System: "netapp"}},
+		// new projects are created on the given or the default storage system.
+		"3010000.03": {},
+		"3010000.04": {Storage: pdb.Storage{System: "netapp"}},
+		// actions on an unknown storage system are kept on netapp.
+		"3010000.05": {Storage: pdb.Storage{System: "nfs"}},
+	}
+
+	netapp, others := splitActions(actions, "netapp", getProject)
+
+	keys := func(m map[string]*pdb.DataProjectUpdate) []string {
+		ks := []string{}
+		for k := range m {
+			ks = append(ks, k)
+		}
+		sort.Strings(ks)
+		return ks
+	}
+
+	if ks := keys(netapp); !reflect.DeepEqual(ks, []string{"3010000.01", "3010000.04", "3010000.05"}) {
+		t.Errorf("unexpected actions on netapp: %v", ks)
+	}
+	if ks := keys(others); !reflect.DeepEqual(ks, []string{"3010000.02", "3010000.03"}) {
+		t.Errorf("unexpected actions on other systems: %v", ks)
+	}
+}
@@ -0,0 +1,346 @@
+package pdbutil
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/filergateway"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
+	"github.com/spf13/cobra"
+)
+
+var (
+	quotaFile        string
+	quotaDryrun      bool = false
+	quotaSkipInvalid bool = false
+)
+
+func init() {
+
+	projectQuotaApplyCmd.Flags().StringVarP(&quotaFile, "file", "f", quotaFile,
+		"`path` of the CSV file with the quota changes; one project per line with the project id and the new quota in GB")
+
+	projectQuotaApplyCmd.Flags().BoolVarP(&quotaDryrun, "dryrun", "", quotaDryrun,
+		"validate the quota changes without really applying them")
+
+	projectQuotaApplyCmd.Flags().BoolVarP(&quotaSkipInvalid, "skip-invalid", "", quotaSkipInvalid,
+		"apply the valid quota changes even if some changes are invalid")
+
+	projectQuotaApplyCmd.Flags().BoolVarP(&useNetappCLI, "netapp-cli", "", useNetappCLI,
+		"use NetApp ONTAP CLI to apply changes on the NetApp filer. Only applicable for projects on the netapp storage system.")
+
+	projectQuotaApplyCmd.Flags().IntVarP(&execNthreads, "nthreads", "n", execNthreads,
+		"`number` of concurrent worker threads.  Changes on the netapp storage system are applied by a single worker, as the filer doesn't handle concurrent changes.")
+
+	projectQuotaApplyCmd.MarkFlagRequired("file")
+
+	projectQuotaCmd.AddCommand(projectQuotaApplyCmd)
+	projectCmd.AddCommand(projectQuotaCmd)
+}
+
+// quotaChange is a quota change of a project from the CSV file, with the result of the
+// validation and the application of the change.
+type quotaChange struct {
+	Line      int
+	ProjectID string
+	QuotaGb   int
+	// storage information before the change, from the filer gateway.
+	System        string
+	UsageGb       int
+	QuotaGbBefore int
+	Result        string
+	Err           error
+}
+
+// readQuotaChanges reads the quota changes from the CSV file `fpath`.  Empty lines, lines
+// starting with "#" and a header line (i.e. the first line with a non-numeric quota) are
+// ignored.
+func readQuotaChanges(fpath string) ([]*quotaChange, error) {
+
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	changes := []*quotaChange{}
+	for first := true; ; first = false {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		ln, _ := r.FieldPos(0)
+
+		if len(rec) != 2 {
+			return nil, fmt.Errorf("%s:%d: expect 2 fields, got %d", fpath, ln, len(rec))
+		}
+
+		pid := strings.TrimSpace(rec[0])
+		quota, err := strconv.Atoi(strings.TrimSpace(rec[1]))
+		if err != nil {
+			if first {
+				// header line
+				continue
+			}
+			return nil, fmt.Errorf("%s:%d: invalid quota: %s", fpath, ln, rec[1])
+		}
+
+		changes = append(changes, &quotaChange{Line: ln, ProjectID: pid, QuotaGb: quota})
+	}
+
+	return changes, nil
+}
+
+var projectQuotaCmd = &cobra.Command{
+	Use:   "quota",
+	Short: "Utility for project storage quota",
+	Long:  ``,
+}
+
+// subcommand to apply quota changes of multiple projects from a CSV file.
+var projectQuotaApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Applies quota changes of projects from a CSV file",
+	Long: `Applies quota changes of projects from a CSV file, e.g.
+
+    project,quotaGb
+    3010000.01,500
+    3010000.02,250
+
+Every change is validated against the current usage retrieved from the filer gateway;
+the quota is never reduced below the usage.  If any change is invalid, nothing is
+applied unless the --skip-invalid option is given.
+
+The valid changes are applied concurrently on the storage and updated in the project
+database; changes on the netapp storage system are applied one at a time.  The results
+are printed as a table.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if execNthreads < 1 {
+			return fmt.Errorf("invalid number of worker threads: %d", execNthreads)
+		}
+
+		ipdb := loadPdb()
+		conf := loadConfig()
+
+		changes, err := readQuotaChanges(quotaFile)
+		if err != nil {
+			return err
+		}
+
+		fgw, err := filergateway.NewClient(conf)
+		if err != nil {
+			return err
+		}
+
+		// validate the changes
+		markDuplicateQuotaChanges(changes)
+
+		runQuotaChanges(changes, execNthreads, func(c *quotaChange) {
+			if c.Err != nil {
+				return
+			}
+			c.Err = validateQuotaChange(fgw, c)
+		})
+
+		invalid := 0
+		for _, c := range changes {
+			if c.Err != nil {
+				c.Result = fmt.Sprintf("invalid: %s", c.Err)
+				invalid++
+			}
+		}
+
+		if invalid > 0 && !quotaSkipInvalid {
+			printQuotaChanges(changes)
+			return fmt.Errorf("%d invalid quota changes, nothing applied", invalid)
+		}
+
+		cli := filergateway.NetAppCLI{Config: conf.NetAppCLI}
+
+		apply := func(c *quotaChange) {
+			if c.Err != nil {
+				return
+			}
+			if c.QuotaGb == c.QuotaGbBefore {
+				c.Result = "unchanged"
+				return
+			}
+			if quotaDryrun {
+				c.Result = "dryrun"
+				return
+			}
+			if err := applyQuotaChange(ipdb, fgw, cli, c); err != nil {
+				c.Err = err
+				c.Result = fmt.Sprintf("failed: %s", err)
+				return
+			}
+			c.Result = "applied"
+		}
+
+		// the NetApp filer (either via the filer-gateway or the ONTAP CLI) doesn't handle
+		// concurrent changes; they are applied one at a time.
+		netapp, others := splitQuotaChanges(changes, "netapp")
+		runQuotaChanges(others, execNthreads, apply)
+		runQuotaChanges(netapp, 1, apply)
+
+		printQuotaChanges(changes)
+
+		for _, c := range changes {
+			if c.Err != nil {
+				return fmt.Errorf("not all quota changes are applied")
+			}
+		}
+
+		return nil
+	},
+}
+
+// runQuotaChanges runs `fn` on the `changes` concurrently with `nthreads` workers.
+func runQuotaChanges(changes []*quotaChange, nthreads int, fn func(c *quotaChange)) {
+
+	var wg sync.WaitGroup
+	cchanges := make(chan *quotaChange, nthreads*2)
+	for w := 0; w < nthreads; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range cchanges {
+				fn(c)
+			}
+		}()
+	}
+
+	for _, c := range changes {
+		cchanges <- c
+	}
+	close(cchanges)
+
+	wg.Wait()
+}
+
+// splitQuotaChanges splits the `changes` into those on the storage `system` and the others.
+func splitQuotaChanges(changes []*quotaChange, system string) (on, others []*quotaChange) {
+	for _, c := range changes {
+		if c.System == system {
+			on = append(on, c)
+		} else {
+			others = append(others, c)
+		}
+	}
+	return
+}
+
+// markDuplicateQuotaChanges sets the error on the changes of a project already changed
+// in a previous line.
+func markDuplicateQuotaChanges(changes []*quotaChange) {
+	seen := make(map[string]int)
+	for _, c := range changes {
+		if l, ok := seen[c.ProjectID]; ok {
+			c.Err = fmt.Errorf("duplicated with line %d", l)
+			continue
+		}
+		seen[c.ProjectID] = c.Line
+	}
+}
+
+// validateQuotaChange retrieves the current storage information of the project, and
+// checks that the new quota is not below the usage.
+func validateQuotaChange(fgw filergateway.Client, c *quotaChange) error {
+
+	if c.QuotaGb <= 0 {
+		return fmt.Errorf("quota must be positive")
+	}
+
+	info, err := fgw.GetProject(c.ProjectID)
+	if err != nil {
+		return fmt.Errorf("cannot get project storage info: %s", err)
+	}
+
+	return checkQuotaChange(c, info)
+}
+
+// checkQuotaChange sets the storage information `info` before the change `c`, and checks
+// that the new quota is not below the usage.
+func checkQuotaChange(c *quotaChange, info *pdb.DataProjectInfo) error {
+
+	c.System = info.Storage.System
+	c.QuotaGbBefore = info.Storage.QuotaGb
+	c.UsageGb = storageUsageGb(info)
+
+	if c.QuotaGb < c.UsageGb {
+		return fmt.Errorf("quota below usage of %d GB", c.UsageGb)
+	}
+
+	return nil
+}
+
+// storageUsageGb returns the storage usage of the project `info` in GiB, rounded up.
+func storageUsageGb(info *pdb.DataProjectInfo) int {
+	return (info.Storage.UsageMb + 1023) / 1024
+}
+
+// applyQuotaChange applies the quota change `c` on the storage, and updates the quota in
+// the project database.  The NetApp ONTAP CLI `cli` is used for projects on the netapp
+// storage system if the `useNetappCLI` option is set.
+func applyQuotaChange(ipdb pdb.PDB, fgw filergateway.Client, cli filergateway.NetAppCLI, c *quotaChange) error {
+
+	act := &pdb.DataProjectUpdate{
+		Storage: pdb.Storage{
+			QuotaGb: c.QuotaGb,
+			System:  c.System,
+		},
+	}
+
+	if useNetappCLI && c.System == "netapp" {
+		if err := cli.UpdateProjectQuota(c.ProjectID, act); err != nil {
+			return err
+		}
+	} else if _, err := fgw.SyncUpdateProject(c.ProjectID, act, time.Second); err != nil {
+		return err
+	}
+
+	log.Infof("[%s] quota updated: %d -> %d GB", c.ProjectID, c.QuotaGbBefore, c.QuotaGb)
+
+	if err := ipdb.UpdateProjectStorageQuota(c.ProjectID, c.QuotaGb, c.UsageGb); err != nil {
+		return fmt.Errorf("quota applied but not updated in PDB: %s", err)
+	}
+
+	return nil
+}
+
+// printQuotaChanges prints the results of the quota `changes` as a table.
+func printQuotaChanges(changes []*quotaChange) {
+
+	sorted := append([]*quotaChange{}, changes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Line < sorted[j].Line
+	})
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINE\tPROJECT\tSYSTEM\tUSAGE (GB)\tQUOTA (GB)\tNEW QUOTA (GB)\tRESULT")
+	for _, c := range sorted {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%d\t%d\t%s\n",
+			c.Line, c.ProjectID, c.System, c.UsageGb, c.QuotaGbBefore, c.QuotaGb, c.Result)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,203 @@
+package pdbutil
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/filergateway"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
+)
+
+func TestReadQuotaChanges(t *testing.T) {
+
+	cases := []struct {
+		name     string
+		content  string
+		expected []*quotaChange
+		err      bool
+	}{
+		{
+			name:     "empty file",
+			content:  "",
+			expected: []*quotaChange{},
+		},
+		{
+			name:     "header only",
+			content:  "project,quotaGb\n",
+			expected: []*quotaChange{},
+		},
+		{
+			name:    "header line",
+			content: "project,quotaGb\n3010000.01,500\n3010000.02,250\n",
+			expected: []*quotaChange{
+				{Line: 2, ProjectID: "3010000.01", QuotaGb: 500},
+				{Line: 3, ProjectID: "3010000.02", QuotaGb: 250},
+			},
+		},
+		{
+			name:    "no header line",
+			content: "3010000.01,500\n3010000.02,250\n",
+			expected: []*quotaChange{
+				{Line: 1, ProjectID: "3010000.01", QuotaGb: 500},
+				{Line: 2, ProjectID: "3010000.02", QuotaGb: 250},
+			},
+		},
+		{
+			name:    "header after comments and blank lines",
+			content: "# quota changes\n\nproject, quotaGb\n 3010000.01 , 500 \n",
+			expected: []*quotaChange{
+				{Line: 4, ProjectID: "3010000.01", QuotaGb: 500},
+			},
+		},
+		{
+			// duplicated projects are detected at validating the changes.
+			name:    "duplicated projects",
+			content: "3010000.01,500\n3010000.01,250\n",
+			expected: []*quotaChange{
+				{Line: 1, ProjectID: "3010000.01", QuotaGb: 500},
+				{Line: 2, ProjectID: "3010000.01", QuotaGb: 250},
+			},
+		},
+		{
+			name:    "non-numeric quota after the first line",
+			content: "3010000.01,500\n3010000.02,lots\n",
+			err:     true,
+		},
+		{
+			name:    "second header line",
+			content: "project,quotaGb\nproject,quotaGb\n",
+			err:     true,
+		},
+		{
+			name:    "missing field",
+			content: "3010000.01\n",
+			err:     true,
+		},
+		{
+			name:    "extra field",
+			content: "3010000.01,500,1000\n",
+			err:     true,
+		},
+	}
+
+	for _, c := range cases {
+		fpath := filepath.Join(t.TempDir(), "quota.csv")
+		if err := os.WriteFile(fpath, []byte(c.content), 0644); err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		changes, err := readQuotaChanges(fpath)
+		if c.err {
+			if err == nil {
+				t.Errorf("%s: expect error, got %v", c.name, changes)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(changes, c.expected) {
+			t.Errorf("%s: unexpected changes: %+v", c.name, changes)
+		}
+	}
+
+	if _, err := readQuotaChanges(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Errorf("expect error on missing file")
+	}
+}
+
+func TestMarkDuplicateQuotaChanges(t *testing.T) {
+
+	changes := []*quotaChange{
+		{Line: 2, ProjectID: "3010000.01", QuotaGb: 500},
+		{Line: 3, ProjectID: "3010000.02", QuotaGb: 250},
+		{Line: 4, ProjectID: "3010000.01", QuotaGb: 250},
+		{Line: 5, ProjectID: "3010000.01", QuotaGb: 100},
+	}
+
+	markDuplicateQuotaChanges(changes)
+
+	// the first change of a project is kept; the later ones refer to it.
+	expected := []string{"", "", "duplicated with line 2", "duplicated with line 2"}
+	for i, c := range changes {
+		msg := ""
+		if c.Err != nil {
+			msg = c.Err.Error()
+		}
+		if msg != expected[i] {
+			t.Errorf("line %d: unexpected error %q, expected %q", c.Line, msg, expected[i])
+		}
+	}
+}
+
+func TestCheckQuotaChange(t *testing.T) {
+
+	cases := []struct {
+		name    string
+		quotaGb int
+		usageMb int
+		usageGb int
+		err     bool
+	}{
+		{name: "grow", quotaGb: 200, usageMb: 50 * 1024, usageGb: 50},
+		{name: "shrink above usage", quotaGb: 60, usageMb: 50 * 1024, usageGb: 50},
+		{name: "shrink to usage", quotaGb: 50, usageMb: 50 * 1024, usageGb: 50},
+		{name: "shrink below usage", quotaGb: 40, usageMb: 50 * 1024, usageGb: 50, err: true},
+		// the usage is rounded up to the next GB.
+		{name: "shrink below rounded usage", quotaGb: 50, usageMb: 50*1024 + 1, usageGb: 51, err: true},
+		{name: "empty project", quotaGb: 1, usageMb: 0, usageGb: 0},
+	}
+
+	for _, c := range cases {
+		qc := &quotaChange{ProjectID: "3010000.01", QuotaGb: c.quotaGb}
+		info := &pdb.DataProjectInfo{
+			ProjectID: "3010000.01",
+			Storage:   pdb.StorageInfo{QuotaGb: 100, UsageMb: c.usageMb, System: "netapp"},
+		}
+
+		err := checkQuotaChange(qc, info)
+		if c.err != (err != nil) {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+		}
+		if qc.UsageGb != c.usageGb || qc.QuotaGbBefore != 100 || qc.System != "netapp" {
+			t.Errorf("%s: unexpected storage information: %+v", c.name, qc)
+		}
+	}
+
+	// non-positive quota is rejected before the storage is queried.
+	for _, q := range []int{0, -1} {
+		if err := validateQuotaChange(filergateway.Client{}, &quotaChange{ProjectID: "3010000.01", QuotaGb: q}); err == nil {
+			t.Errorf("expect error on quota %d", q)
+		}
+	}
+}
+
+func TestSplitQuotaChanges(t *testing.T) {
+
+	changes := []*quotaChange{
+		{Line: 2, ProjectID: "3010000.01", System: "netapp"},
+		{Line: 3, ProjectID: "3010000.02", System: "cephfs"},
+		{Line: 4, ProjectID: "3010000.03", System: "netapp"},
+		{Line: 5, ProjectID: "3010000.04"},
+	}
+
+	netapp, others := splitQuotaChanges(changes, "netapp")
+
+	lines := func(cs []*quotaChange) []int {
+		l := []int{}
+		for _, c := range cs {
+			l = append(l, c.Line)
+		}
+		return l
+	}
+
+	if l := lines(netapp); !reflect.DeepEqual(l, []int{2, 4}) {
+		t.Errorf("unexpected netapp changes: %v", l)
+	}
+	if l := lines(others); !reflect.DeepEqual(l, []int{3, 5}) {
+		t.Errorf("unexpected other changes: %v", l)
+	}
+}
@@ -0,0 +1,336 @@
+package pdbutil
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/acl"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/filergateway"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
+	"github.com/spf13/cobra"
+	"github.com/xuri/excelize/v2"
+)
+
+var (
+	reportStatus   string
+	reportKind     string
+	reportSystem   string
+	reportMinUsage int    = 0
+	reportFormat   string = "table"
+	reportOutput   string
+)
+
+func init() {
+
+	projectReportCmd.Flags().StringVarP(&reportStatus, "status", "", reportStatus,
+		"include only projects of the `status`, i.e. active or inactive")
+
+	projectReportCmd.Flags().StringVarP(&reportKind, "kind", "", reportKind,
+		"include only projects of the `kind`, i.e. research or dataset")
+
+	projectReportCmd.Flags().StringVarP(&reportSystem, "sys", "s", reportSystem,
+		"include only projects on the storage `system`")
+
+	projectReportCmd.Flags().IntVarP(&reportMinUsage, "min-usage", "u", reportMinUsage,
+		"include only projects with the storage quota usage of at least the `percent`")
+
+	projectReportCmd.Flags().StringVarP(&reportFormat, "format", "f", reportFormat,
+		"output `format`, i.e. table, csv, json or xlsx")
+
+	projectReportCmd.Flags().StringVarP(&reportOutput, "output", "o", reportOutput,
+		"`path` of the output file; the report is printed to stdout if not specified, except for the xlsx format")
+
+	projectReportCmd.Flags().IntVarP(&execNthreads, "nthreads", "n", execNthreads,
+		"`number` of concurrent worker threads retrieving the project storage information.")
+
+	projectCmd.AddCommand(projectReportCmd)
+}
+
+// projectReport is a row of the project report.
+type projectReport struct {
+	ProjectID    string `json:"projectID"`
+	Name         string `json:"projectName"`
+	Owner        string `json:"owner"`
+	Status       string `json:"status"`
+	Kind         string `json:"kind"`
+	End          string `json:"end"`
+	System       string `json:"system"`
+	QuotaGb      int    `json:"quotaGb"`
+	UsageMb      int    `json:"usageMb"`
+	UsagePercent int    `json:"usagePercent"`
+	Managers     int    `json:"managers"`
+	Contributors int    `json:"contributors"`
+	Viewers      int    `json:"viewers"`
+}
+
+// reportHeader is the header of the tabular formats of the project report.
+var reportHeader = []string{
+	"project", "name", "owner", "status", "kind", "end", "system",
+	"quotaGb", "usageMb", "usage%", "managers", "contributors", "viewers",
+}
+
+// values returns the values of the report row in the order of the `reportHeader`.
+func (r projectReport) values() []interface{} {
+	return []interface{}{
+		r.ProjectID, r.Name, r.Owner, r.Status, r.Kind, r.End, r.System,
+		r.QuotaGb, r.UsageMb, r.UsagePercent, r.Managers, r.Contributors, r.Viewers,
+	}
+}
+
+// usagePercent returns the storage quota usage of the project in percent.  A project
+// without quota has usage of 100 percent if it uses any storage.
+func usagePercent(info *pdb.DataProjectInfo) int {
+	switch {
+	case info.Storage.QuotaGb == 0 && info.Storage.UsageMb > 0:
+		return 100
+	case info.Storage.QuotaGb == 0 && info.Storage.UsageMb == 0:
+		return 0
+	default:
+		return 100 * info.Storage.UsageMb / (info.Storage.QuotaGb << 10)
+	}
+}
+
+// subcommand to report storage and members of all projects.
+var projectReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Reports storage and members of projects",
+	Long: `Reports the owner, status, kind, end date, storage quota and usage, and the number
+of members per role of projects, combining the project database with the storage
+information from the filer gateway.
+
+The report is given as a table, or in the CSV, JSON or XLSX format.  The XLSX report
+requires the output file to be specified.`,
+	Args: cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		switch reportFormat {
+		case "table", "csv", "json":
+		case "xlsx":
+			if reportOutput == "" {
+				log.Fatalf("output file is required for the xlsx format")
+			}
+		default:
+			log.Fatalf("unsupported format: %s", reportFormat)
+		}
+
+		switch strings.ToLower(reportStatus) {
+		case "", "active", "inactive":
+		default:
+			log.Fatalf("unknown project status: %s", reportStatus)
+		}
+
+		switch strings.ToLower(reportKind) {
+		case "", "research", "dataset":
+		default:
+			log.Fatalf("unknown project kind: %s", reportKind)
+		}
+
+		if execNthreads < 1 {
+			execNthreads = 1
+		}
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		ipdb := loadPdb()
+		conf := loadConfig()
+
+		fgw, err := filergateway.NewClient(conf)
+		if err != nil {
+			return err
+		}
+
+		var mutex sync.Mutex
+		reports := make([]projectReport, 0)
+
+		var wg sync.WaitGroup
+		cprjs := make(chan *pdb.Project, execNthreads*2)
+		for w := 0; w < execNthreads; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for prj := range cprjs {
+
+					info, err := fgw.GetProject(prj.ID)
+					if err != nil {
+						log.Errorf("[%s] cannot get project storage info: %s", prj.ID, err)
+						continue
+					}
+
+					r := newProjectReport(prj, info)
+
+					if reportSystem != "" && r.System != reportSystem {
+						continue
+					}
+
+					if r.UsagePercent < reportMinUsage {
+						continue
+					}
+
+					mutex.Lock()
+					reports = append(reports, r)
+					mutex.Unlock()
+				}
+			}()
+		}
+
+		// projects are streamed from the project database, and filtered by status on the server side.
+		f := pdb.ProjectFilter{}
+		switch strings.ToLower(reportStatus) {
+		case "active":
+			f.Status = []pdb.ProjectStatus{pdb.ProjectStatusActive}
+		case "inactive":
+			f.Status = []pdb.ProjectStatus{pdb.ProjectStatusInactive}
+		}
+
+		err = pdb.WalkProjects(context.Background(), ipdb, f, func(prj *pdb.Project) error {
+			if reportKind != "" && !strings.EqualFold(prj.Kind.String(), reportKind) {
+				return nil
+			}
+			cprjs <- prj
+			return nil
+		})
+		close(cprjs)
+
+		wg.Wait()
+
+		if err != nil {
+			return err
+		}
+
+		sort.Slice(reports, func(i, j int) bool {
+			return reports[i].ProjectID < reports[j].ProjectID
+		})
+
+		if reportFormat == "xlsx" {
+			return writeReportXLSX(reportOutput, reports)
+		}
+
+		w := io.Writer(os.Stdout)
+		if reportOutput != "" {
+			f, err := os.Create(reportOutput)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+
+		switch reportFormat {
+		case "csv":
+			return writeReportCSV(w, reports)
+		case "json":
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(reports)
+		default:
+			return writeReportTable(w, reports)
+		}
+	},
+}
+
+// newProjectReport returns the report row of the project `prj` with the storage
+// information `info`.
+func newProjectReport(prj *pdb.Project, info *pdb.DataProjectInfo) projectReport {
+
+	r := projectReport{
+		ProjectID:    prj.ID,
+		Name:         prj.Name,
+		Owner:        prj.Owner,
+		Status:       prj.Status.String(),
+		Kind:         prj.Kind.String(),
+		System:       info.Storage.System,
+		QuotaGb:      info.Storage.QuotaGb,
+		UsageMb:      info.Storage.UsageMb,
+		UsagePercent: usagePercent(info),
+	}
+
+	if !prj.End.IsZero() {
+		r.End = prj.End.Format(dateLayout)
+	}
+
+	for _, m := range info.Members {
+		switch m.Role {
+		case acl.Manager.String():
+			r.Managers++
+		case acl.Contributor.String():
+			r.Contributors++
+		case acl.Viewer.String():
+			r.Viewers++
+		}
+	}
+
+	return r
+}
+
+// writeReportTable writes the `reports` to `w` as a table.
+func writeReportTable(w io.Writer, reports []projectReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(reportHeader, "\t")))
+	for _, r := range reports {
+		vals := r.values()
+		cols := make([]string, len(vals))
+		for i, v := range vals {
+			cols[i] = fmt.Sprint(v)
+		}
+		fmt.Fprintln(tw, strings.Join(cols, "\t"))
+	}
+	return tw.Flush()
+}
+
+// writeReportCSV writes the `reports` to `w` in CSV format, with a header line.
+func writeReportCSV(w io.Writer, reports []projectReport) error {
+	cw := csv.NewWriter(w)
+	cw.Write(reportHeader)
+	for _, r := range reports {
+		vals := r.values()
+		cols := make([]string, len(vals))
+		for i, v := range vals {
+			cols[i] = fmt.Sprint(v)
+		}
+		cw.Write(cols)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeReportXLSX writes the `reports` into the XLSX file `path`.
+func writeReportXLSX(path string, reports []projectReport) error {
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := "Projects"
+	if err := f.SetSheetName(f.GetSheetName(0), sheet); err != nil {
+		return err
+	}
+
+	header := make([]interface{}, len(reportHeader))
+	for i, h := range reportHeader {
+		header[i] = h
+	}
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		return err
+	}
+
+	for i, r := range reports {
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return err
+		}
+		vals := r.values()
+		if err := f.SetSheetRow(sheet, cell, &vals); err != nil {
+			return err
+		}
+	}
+
+	return f.SaveAs(path)
+}
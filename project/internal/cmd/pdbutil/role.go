@@ -190,6 +190,12 @@ var roleSetCmd = &cobra.Command{
 			ppathSym, _ = filepath.Abs(ppathSym)
 		}
 
+		resolver, err := newIdentityResolver(loadConfig())
+		if err != nil {
+			return err
+		}
+		defer closeIdentityResolver(resolver)
+
 		runner := acl.Runner{
 			RootPath:     ppathSym,
 			Managers:     uidsManager,
@@ -201,9 +207,10 @@ var roleSetCmd = &cobra.Command{
 			Silence:      silenceFlag,
 			Traverse:     true,
 			Force:        forceFlag,
+			Resolver:     resolver,
 		}
 
-		_, err := runner.SetRoles()
+		_, err = runner.SetRoles()
 		return err
 	},
 }
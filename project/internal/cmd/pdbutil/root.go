@@ -1,21 +1,35 @@
 package pdbutil
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/dccn-tg/tg-toolset-golang/pkg/config"
 	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/identity"
 	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
 	"github.com/spf13/cobra"
 )
 
 var verbose bool
 var configFile string
+var noCache bool
+
+// ipdbLoaded is the PDB interface initialized by `loadPdb`.
+var ipdbLoaded pdb.PDB
+var ipdbMutex sync.Mutex
+
+// ipdbCache is the local database file of the cache of `ipdbLoaded`, if it is used.
+var ipdbCache *cacheFile
 var cfg log.Configuration
 
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "config.yml", "`path` of the configuration YAML file.")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	rootCmd.PersistentFlags().BoolVarP(&noCache, "no-cache", "", false, "bypass the cache of the project database")
 
 	// initiate default logger
 	cfg = log.Configuration{
@@ -36,9 +50,49 @@ func loadConfig() config.Configuration {
 	return conf
 }
 
+// newIdentityResolver returns the `identity.Resolver` of the identity source specified
+// by the configuration `conf`.  The local passwd database is used if the identity source
+// is not specified.  The resolver should be released with `closeIdentityResolver` after
+// use.
+func newIdentityResolver(conf config.Configuration) (identity.Resolver, error) {
+	switch conf.Identity.Source {
+	case "", "passwd":
+		return identity.Passwd{}, nil
+	case "ldap":
+		return &identity.LDAP{Config: conf.Identity.LDAP}, nil
+	case "pdb":
+		return pdb.IdentityResolver{PDB: loadPdb()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported identity source: %s", conf.Identity.Source)
+	}
+}
+
+// closeIdentityResolver releases the connection held by the identity `resolver`, e.g.
+// the connection to the LDAP server.
+func closeIdentityResolver(resolver identity.Resolver) {
+	if c, ok := resolver.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			log.Warnf("cannot close identity resolver: %s", err)
+		}
+	}
+}
+
 // loadPdb initializes the PDB interface package using the configuration YAML file.
+// The PDB interface is initialized once and reused by subsequent calls, so that the
+// cache is shared within the process.
 // This function fatals out if there is an error.
 func loadPdb() pdb.PDB {
+	ipdbMutex.Lock()
+	defer ipdbMutex.Unlock()
+
+	if ipdbLoaded == nil {
+		ipdbLoaded = newPdb()
+	}
+	return ipdbLoaded
+}
+
+// newPdb initializes the PDB interface, with the caching decorator if it is enabled.
+func newPdb() pdb.PDB {
 	// initialize pdb interface
 	conf := loadConfig()
 	ipdb, err := pdb.New(conf.PDB)
@@ -46,7 +100,43 @@ func loadPdb() pdb.PDB {
 		log.Fatalf("%s", err)
 	}
 
-	return ipdb
+	if noCache || conf.PDB.Cache.TTL <= 0 {
+		return ipdb
+	}
+
+	// wrap the pdb with the caching decorator, using the local database file if configured.
+	// It falls back to the in-memory cache if the file cannot be initialized.
+	if conf.PDB.Cache.Path != "" {
+		cf := &cacheFile{path: conf.PDB.Cache.Path, timeout: 5 * time.Second}
+		cpdb, err := pdb.NewCached(ipdb, conf.PDB.Cache.TTL, cf)
+		if err == nil {
+			ipdbCache = cf
+			return cpdb
+		}
+		cf.Close()
+		log.Warnf("cannot use pdb cache %s, cache in memory: %s", conf.PDB.Cache.Path, err)
+	}
+
+	cpdb, err := pdb.NewCached(ipdb, conf.PDB.Cache.TTL, nil)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	return cpdb
+}
+
+// releasePdb releases the local database file of the cache of the PDB interface, so that
+// it can be used by other processes.  The file is re-opened when the cache is used again.
+func releasePdb() {
+	ipdbMutex.Lock()
+	defer ipdbMutex.Unlock()
+
+	if ipdbCache == nil {
+		return
+	}
+	if err := ipdbCache.Close(); err != nil {
+		log.Warnf("cannot close pdb cache: %s", err)
+	}
 }
 
 var rootCmd = &cobra.Command{
@@ -64,7 +154,9 @@ var rootCmd = &cobra.Command{
 
 // Execute is the main entry point of the cluster command.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	releasePdb()
+	if err != nil {
 		log.Errorf("%s", err)
 		os.Exit(1)
 	}
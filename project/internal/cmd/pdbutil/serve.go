@@ -0,0 +1,305 @@
+package pdbutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	ufp "github.com/dccn-tg/tg-toolset-golang/pkg/filepath"
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/alert"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/filergateway"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveInterval time.Duration = time.Minute
+	serveListen   string        = ":8080"
+	serveNthreads int           = 1
+)
+
+func init() {
+
+	supportedStorSystems := make([]string, 0, len(projectRoots))
+	for sys := range projectRoots {
+		supportedStorSystems = append(supportedStorSystems, sys)
+	}
+
+	serveCmd.Flags().DurationVarP(&serveInterval, "interval", "i", serveInterval,
+		"`duration` between two polls on the project database for pending actions")
+
+	serveCmd.Flags().StringVarP(&serveListen, "listen", "l", serveListen,
+		"`address` on which the health and metrics endpoints are served")
+
+	serveCmd.Flags().IntVarP(&serveNthreads, "nthreads", "n", serveNthreads,
+		"`number` of concurrent workers executing pending actions.  Actions on the netapp storage system are executed by 1 worker, as the filer doesn't handle concurrent changes.")
+
+	serveCmd.Flags().StringVarP(&storSystem, "sys", "s", storSystem,
+		fmt.Sprintf("storage `system` of new projects; existing projects stay on the system reported by the filer-gateway.  Supported systems: %s", strings.Join(supportedStorSystems, ",")))
+
+	serveCmd.Flags().BoolVarP(&useNetappCLI, "netapp-cli", "", useNetappCLI,
+		"use NetApp ONTAP CLI to apply changes on the NetApp filer. Only applicable for the netapp storage system.")
+
+	serveCmd.Flags().StringVarP(&actionLockPath, "lock", "", actionLockPath,
+		"`path` of the lock file guarding against concurrent executions of pending actions")
+
+	serveCmd.Flags().StringVarP(&journalDbPath, "journal", "j", journalDbPath,
+		"`path` of the internal journal database of pending actions")
+
+	serveCmd.Flags().IntVarP(&journalMaxAttempts, "max-attempts", "", journalMaxAttempts,
+		"max. `number` of attempts before an action is moved into the dead-letter state")
+
+	serveCmd.Flags().DurationVarP(&journalBackoff, "backoff", "", journalBackoff,
+		"`duration` to wait before retrying a failed action, doubled after each failure")
+
+	serveCmd.Flags().DurationVarP(&journalMaxBackoff, "max-backoff", "", journalMaxBackoff,
+		"max. `duration` to wait before retrying a failed action")
+
+	serveCmd.Flags().BoolVarP(&actionSkipNotify, "skip-notify", "", actionSkipNotify,
+		"skip notifying the members and the managers of the role changes applied by the actions")
+
+	rootCmd.AddCommand(serveCmd)
+}
+
+// serveStatus keeps the status and the counters of the `serve` daemon, exposed by the
+// health and metrics endpoints.
+type serveStatus struct {
+	mutex      sync.Mutex
+	started    time.Time
+	lastPoll   time.Time
+	lastError  string
+	polls      int
+	pollErrors int
+	busy       bool
+	stats      actionStats
+}
+
+// healthy returns whether the last poll succeeded within three poll intervals.
+func (s *serveStatus) healthy(interval time.Duration) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.lastError != "" {
+		return false
+	}
+
+	last := s.lastPoll
+	if last.IsZero() {
+		last = s.started
+	}
+	return time.Since(last) < 3*interval || s.busy
+}
+
+// ServeHTTP implements the health (`/healthz`) and metrics (`/metrics`) endpoints.
+func (s *serveStatus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	switch r.URL.Path {
+	case "/healthz":
+		status := http.StatusOK
+		if !s.healthy(serveInterval) {
+			status = http.StatusServiceUnavailable
+		}
+
+		s.mutex.Lock()
+		data, _ := json.Marshal(map[string]interface{}{
+			"healthy":   status == http.StatusOK,
+			"busy":      s.busy,
+			"lastPoll":  s.lastPoll,
+			"lastError": s.lastError,
+		})
+		s.mutex.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(data)
+
+	case "/metrics":
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		busy := 0
+		if s.busy {
+			busy = 1
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprintf(w, "# HELP pdbutil_polls_total Number of polls on the project database for pending actions.\n")
+		fmt.Fprintf(w, "# TYPE pdbutil_polls_total counter\n")
+		fmt.Fprintf(w, "pdbutil_polls_total %d\n", s.polls)
+		fmt.Fprintf(w, "# HELP pdbutil_poll_errors_total Number of failed polls on the project database.\n")
+		fmt.Fprintf(w, "# TYPE pdbutil_poll_errors_total counter\n")
+		fmt.Fprintf(w, "pdbutil_poll_errors_total %d\n", s.pollErrors)
+		fmt.Fprintf(w, "# HELP pdbutil_actions_total Number of processed pending actions by result.\n")
+		fmt.Fprintf(w, "# TYPE pdbutil_actions_total counter\n")
+		fmt.Fprintf(w, "pdbutil_actions_total{result=\"applied\"} %d\n", s.stats.Applied)
+		fmt.Fprintf(w, "pdbutil_actions_total{result=\"failed\"} %d\n", s.stats.Failed)
+		fmt.Fprintf(w, "pdbutil_actions_total{result=\"skipped\"} %d\n", s.stats.Skipped)
+		fmt.Fprintf(w, "# HELP pdbutil_busy Whether pending actions are being processed.\n")
+		fmt.Fprintf(w, "# TYPE pdbutil_busy gauge\n")
+		fmt.Fprintf(w, "pdbutil_busy %d\n", busy)
+		fmt.Fprintf(w, "# HELP pdbutil_last_poll_timestamp_seconds Time of the last successful poll.\n")
+		fmt.Fprintf(w, "# TYPE pdbutil_last_poll_timestamp_seconds gauge\n")
+		fmt.Fprintf(w, "pdbutil_last_poll_timestamp_seconds %d\n", s.lastPoll.Unix())
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// poll retrieves the pending actions from the project database and processes them.
+func (s *serveStatus) poll(ctx context.Context, run func(context.Context) (actionStats, error)) {
+
+	s.mutex.Lock()
+	s.busy = true
+	s.mutex.Unlock()
+
+	stats, err := run(ctx)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.busy = false
+	s.polls++
+	if err != nil {
+		log.Errorf("fail polling pending actions: %s", err)
+		s.pollErrors++
+		s.lastError = err.Error()
+		return
+	}
+
+	s.lastPoll = time.Now()
+	s.lastError = ""
+	s.stats.Applied += stats.Applied
+	s.stats.Failed += stats.Failed
+	s.stats.Skipped += stats.Skipped
+}
+
+// serveCmd runs pdbutil as a daemon processing pending actions continuously.
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Runs as a daemon processing pending project storage actions continuously",
+	Long: `Runs as a daemon processing pending project storage actions continuously.
+
+The daemon polls the project database for pending actions every given interval, and
+executes them with the given number of concurrent workers; on the netapp storage system,
+actions are executed by a single worker.  The attempts are bookkept
+in the same journal as the "project action exec" command, and the same lock file guards
+against concurrent executions.  The journal and the local pdb cache file are only
+opened while the actions of a poll are processed.
+
+The health and metrics (Prometheus text format) endpoints are served on "/healthz"
+and "/metrics", respectively.
+
+On SIGINT or SIGTERM, the daemon stops dispatching pending actions, and shuts down
+after the ongoing actions are completed.`,
+	Args: cobra.NoArgs,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if _, ok := projectRoots[storSystem]; !ok {
+			log.Fatalf("unsupported storage system: %s", storSystem)
+		}
+		if serveInterval <= 0 {
+			log.Fatalf("invalid poll interval: %s", serveInterval)
+		}
+		if serveNthreads < 1 {
+			serveNthreads = 1
+		}
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		// guard against concurrent instances and executions.
+		lock, err := ufp.TryLock(actionLockPath)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+
+		ipdb := loadPdb()
+
+		status := &serveStatus{started: time.Now()}
+
+		// serve health and metrics endpoints
+		srv := &http.Server{Addr: serveListen, Handler: status}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Errorf("fail serving health and metrics endpoints: %s", err)
+				stop()
+			}
+		}()
+
+		run := func(ctx context.Context) (actionStats, error) {
+			log.Debugf("list pending actions")
+			actions, err := ipdb.GetProjectPendingActions()
+			if err != nil {
+				return actionStats{}, err
+			}
+			log.Debugf("%d pending actions", len(actions))
+
+			if !actionSkipNotify {
+				roleChanges = alert.NewMembership()
+				defer notifyRoleChanges(roleChanges)
+			}
+
+			// the journal is opened for each poll, so that the "project action history"
+			// and "project action reset" commands can access it between two polls.
+			j, kvs, err := openJournal()
+			if err != nil {
+				return actionStats{}, err
+			}
+			defer kvs.Disconnect()
+
+			// the identity resolver is created for each poll and shared by its actions.
+			resolver, err := newIdentityResolver(loadConfig())
+			if err != nil {
+				return actionStats{}, err
+			}
+			defer closeIdentityResolver(resolver)
+
+			fgw, err := filergateway.NewClient(loadConfig())
+			if err != nil {
+				return actionStats{}, err
+			}
+
+			// the NetApp filer (either via the filer-gateway or the ONTAP CLI) doesn't handle
+			// concurrent changes; actions on it are executed one at a time.
+			netapp, others := splitActions(actions, "netapp", fgw.GetProject)
+			stats := runActions(ctx, j, others, serveNthreads, resolver)
+			sn := runActions(ctx, j, netapp, 1, resolver)
+			stats.Applied += sn.Applied
+			stats.Failed += sn.Failed
+			stats.Skipped += sn.Skipped
+
+			return stats, nil
+		}
+
+		log.Infof("start processing pending actions every %s, endpoints on %s", serveInterval, serveListen)
+
+		ticker := time.NewTicker(serveInterval)
+		defer ticker.Stop()
+
+		for {
+			status.poll(ctx, run)
+
+			// release the pdb cache between polls, so that it can be cleared.
+			releasePdb()
+
+			select {
+			case <-ctx.Done():
+				log.Infof("stopping service...")
+				sctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				return srv.Shutdown(sctx)
+			case <-ticker.C:
+			}
+		}
+	},
+}
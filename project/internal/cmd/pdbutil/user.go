@@ -3,17 +3,66 @@ package pdbutil
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/alert"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/filergateway"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
 	"github.com/spf13/cobra"
 )
 
 //var findByEmail bool
 
+var (
+	overviewAllProjects bool   = false
+	overviewFrom        string = time.Now().Format(dateLayout)
+	overviewDays        int    = 30
+	overviewJSON        bool   = false
+
+	searchStatus   []string
+	searchFunction []string
+	searchLimit    int  = 10
+	searchJSON     bool = false
+)
+
 func init() {
 
 	//userFindCmd.Flags().BoolVarP(&findByEmail, "email", "e", true, "find user with the given email address.")
 
-	userCmd.AddCommand(userInfoCmd, userFindCmd)
+	userOverviewCmd.Flags().BoolVarP(&overviewAllProjects, "all", "a", overviewAllProjects,
+		"include also the inactive projects")
+
+	userOverviewCmd.Flags().StringVarP(&overviewFrom, "from", "", overviewFrom,
+		"start `date` (YYYY-MM-DD) of the lab bookings")
+
+	userOverviewCmd.Flags().IntVarP(&overviewDays, "days", "d", overviewDays,
+		"`number` of days of the lab bookings from the start date")
+
+	userOverviewCmd.Flags().BoolVarP(&overviewJSON, "json", "", overviewJSON,
+		"print the overview in JSON format")
+
+	userOverviewCmd.Flags().IntVarP(&execNthreads, "nthreads", "n", execNthreads,
+		"`number` of concurrent worker threads retrieving the project members.")
+
+	userSearchCmd.Flags().StringSliceVarP(&searchStatus, "status", "", searchStatus,
+		"comma-separated user `statuses` to include, e.g. CheckedIn,CheckedOutExtended")
+
+	userSearchCmd.Flags().StringSliceVarP(&searchFunction, "function", "", searchFunction,
+		"comma-separated user `functions` to include, e.g. PrincipalInvestigator,Postdoc")
+
+	userSearchCmd.Flags().IntVarP(&searchLimit, "limit", "l", searchLimit,
+		"max. `number` of users to show; 0 for no limit")
+
+	userSearchCmd.Flags().BoolVarP(&searchJSON, "json", "", searchJSON,
+		"print the matched users in JSON format")
+
+	userCmd.AddCommand(userInfoCmd, userFindCmd, userOverviewCmd, userSearchCmd)
 	rootCmd.AddCommand(userCmd)
 }
 
@@ -66,3 +115,243 @@ var userFindCmd = &cobra.Command{
 		return nil
 	},
 }
+
+var userSearchCmd = &cobra.Command{
+	Use:   "search [query...]",
+	Short: "Search users by partial name, email or user id",
+	Long: `Search users by partial name, email or user id.
+
+Every word of the query has to match the first name, last name, display name, email
+or the user id of the user, tolerating partial words and typos.  The users are listed
+in the order of the best match.  Without query, all users selected by the status and
+function filters are listed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		filter := pdb.UserFilter{}
+		for _, n := range searchStatus {
+			s, err := pdb.ParseUserStatus(n)
+			if err != nil {
+				return err
+			}
+			filter.Status = append(filter.Status, s)
+		}
+		for _, n := range searchFunction {
+			f, err := pdb.ParseUserFunction(n)
+			if err != nil {
+				return err
+			}
+			filter.Function = append(filter.Function, f)
+		}
+
+		ipdb := loadPdb()
+		users, err := ipdb.GetUsers(false)
+		if err != nil {
+			return err
+		}
+
+		matches := pdb.SearchUsers(users, strings.Join(args, " "), filter)
+		if searchLimit > 0 && len(matches) > searchLimit {
+			matches = matches[:searchLimit]
+		}
+
+		if searchJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(matches)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "USER\tNAME\tEMAIL\tSTATUS\tFUNCTION\tSCORE")
+		for _, m := range matches {
+			u := m.User
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\n", u.ID, u.DisplayName(), u.Email, u.Status, u.Function, m.Score)
+		}
+		return tw.Flush()
+	},
+}
+
+// userOverview is the overview of what a user has, i.e. the projects with the user's
+// roles, and the upcoming lab bookings operated by the user.
+type userOverview struct {
+	User     *pdb.User         `json:"user"`
+	Status   string            `json:"status"`
+	Projects []*userProject    `json:"projects"`
+	Bookings []*pdb.LabBooking `json:"bookings"`
+}
+
+// userProject is a project of the user in the `userOverview`.
+type userProject struct {
+	ProjectID string `json:"projectID"`
+	Name      string `json:"projectName"`
+	Status    string `json:"status"`
+	End       string `json:"end"`
+	// PdbRoles are the roles of the user in the project database, i.e. "owner" and the
+	// role of the project member.
+	PdbRoles []string `json:"pdbRoles"`
+	// StorageRoles are the roles of the user in the project storage.
+	StorageRoles []string `json:"storageRoles"`
+}
+
+var userOverviewCmd = &cobra.Command{
+	Use:   "overview [userID]",
+	Short: "Get an overview of projects, roles and lab bookings of a user",
+	Long: `Get an overview of projects, roles and lab bookings of a user.
+
+The projects are those owned by the user or in which the user has a role according
+to the project database.  The roles of the user in the storage of these projects are
+retrieved from the filer gateway.  The lab bookings are those operated by the user within the given number
+of days from the start date (default today).`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		ipdb := loadPdb()
+		conf := loadConfig()
+
+		from, err := time.ParseInLocation(dateLayout, overviewFrom, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid date: %s", overviewFrom)
+		}
+
+		u, err := ipdb.GetUser(args[0])
+		if err != nil {
+			return err
+		}
+
+		overview := userOverview{
+			User:     u,
+			Status:   u.Status.String(),
+			Projects: make([]*userProject, 0),
+			Bookings: make([]*pdb.LabBooking, 0),
+		}
+
+		projects, err := ipdb.GetProjects(!overviewAllProjects)
+		if err != nil {
+			return err
+		}
+
+		prjRoles, err := ipdb.GetUserProjectRoles(u.ID)
+		if err != nil {
+			return err
+		}
+
+		// roles of the user in the project database, per project.
+		pdbRoles := make(map[string][]string)
+		for _, prj := range projects {
+			if prj.Owner == u.ID {
+				pdbRoles[prj.ID] = append(pdbRoles[prj.ID], alert.RoleOwner)
+			}
+		}
+		for _, r := range prjRoles {
+			pdbRoles[r.ProjectID] = append(pdbRoles[r.ProjectID], r.Role)
+		}
+
+		fgw, err := filergateway.NewClient(conf)
+		if err != nil {
+			return err
+		}
+
+		if execNthreads < 1 {
+			execNthreads = 1
+		}
+
+		// only the projects of the user are looked up in the filer gateway.
+		var mutex sync.Mutex
+		var wg sync.WaitGroup
+		cprjs := make(chan *pdb.Project, execNthreads*2)
+		for w := 0; w < execNthreads; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for prj := range cprjs {
+
+					storageRoles := []string{}
+					info, err := fgw.GetProject(prj.ID)
+					if err != nil {
+						log.Errorf("[%s] cannot get project storage info: %s", prj.ID, err)
+					} else {
+						for _, m := range info.Members {
+							if m.UserID == u.ID {
+								storageRoles = append(storageRoles, m.Role)
+							}
+						}
+					}
+
+					up := &userProject{
+						ProjectID:    prj.ID,
+						Name:         prj.Name,
+						Status:       prj.Status.String(),
+						PdbRoles:     pdbRoles[prj.ID],
+						StorageRoles: storageRoles,
+					}
+					if !prj.End.IsZero() {
+						up.End = prj.End.Format(dateLayout)
+					}
+
+					mutex.Lock()
+					overview.Projects = append(overview.Projects, up)
+					mutex.Unlock()
+				}
+			}()
+		}
+
+		for _, prj := range projects {
+			if _, ok := pdbRoles[prj.ID]; ok {
+				cprjs <- prj
+			}
+		}
+		close(cprjs)
+
+		wg.Wait()
+
+		sort.Slice(overview.Projects, func(i, j int) bool {
+			return overview.Projects[i].ProjectID < overview.Projects[j].ProjectID
+		})
+
+		// bookings are not available from all project database versions; the overview
+		// is still given without them.
+		to := from.AddDate(0, 0, overviewDays)
+		bookings, err := ipdb.GetLabBookingsForReport(pdb.ALL, from.Format(dateLayout), to.Format(dateLayout))
+		if err != nil {
+			log.Warnf("cannot get lab bookings: %s", err)
+		}
+		for _, b := range bookings {
+			if b.Operator.ID == u.ID {
+				overview.Bookings = append(overview.Bookings, b)
+			}
+		}
+
+		if overviewJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(overview)
+		}
+
+		printUserOverview(overview)
+		return nil
+	},
+}
+
+// printUserOverview prints the overview `o` in human-readable format.
+func printUserOverview(o userOverview) {
+
+	fmt.Printf("%s (%s) <%s>, status: %s\n", o.User.DisplayName(), o.User.ID, o.User.Email, o.Status)
+
+	fmt.Printf("\nprojects:\n")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tSTATUS\tEND\tPDB ROLES\tSTORAGE ROLES\tTITLE")
+	for _, p := range o.Projects {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			p.ProjectID, p.Status, p.End, strings.Join(p.PdbRoles, ","), strings.Join(p.StorageRoles, ","), p.Name)
+	}
+	tw.Flush()
+
+	fmt.Printf("\nlab bookings:\n")
+	tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "START\tEND\tLAB\tPROJECT\tSUBJECT\tSESSION\tSTATUS")
+	for _, b := range o.Bookings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			b.StartTime.Format("2006-01-02 15:04"), b.EndTime.Format("2006-01-02 15:04"), b.Lab, b.Project, b.Subject, b.Session, b.Status)
+	}
+	tw.Flush()
+}
@@ -22,6 +22,15 @@ const (
 	BookingEventStatusCancelednotintime BookingEventStatus = "CanceledNotInTime"
 )
 
+type PendingProjectMemberUpdate string
+
+const (
+	PendingProjectMemberUpdateChangetomanager     PendingProjectMemberUpdate = "ChangeToManager"
+	PendingProjectMemberUpdateChangetocontributor PendingProjectMemberUpdate = "ChangeToContributor"
+	PendingProjectMemberUpdateChangetoviewer      PendingProjectMemberUpdate = "ChangeToViewer"
+	PendingProjectMemberUpdateRemove              PendingProjectMemberUpdate = "Remove"
+)
+
 type ProjectKind string
 
 const (
@@ -29,6 +38,15 @@ const (
 	ProjectKindDataset  ProjectKind = "Dataset"
 )
 
+type ProjectMemberRole string
+
+const (
+	ProjectMemberRoleManager     ProjectMemberRole = "Manager"
+	ProjectMemberRoleContributor ProjectMemberRole = "Contributor"
+	ProjectMemberRoleViewer      ProjectMemberRole = "Viewer"
+	ProjectMemberRoleTraverse    ProjectMemberRole = "Traverse"
+)
+
 type ProjectStatus string
 
 const (
@@ -63,6 +81,22 @@ const (
 	UserStatusCheckedoutextended UserStatus = "CheckedOutExtended"
 )
 
+// __addUserToProjectInput is used internally by genqlient
+type __addUserToProjectInput struct {
+	Number   string            `json:"number"`
+	Username string            `json:"username"`
+	Role     ProjectMemberRole `json:"role"`
+}
+
+// GetNumber returns __addUserToProjectInput.Number, and is useful for accessing the field via an interface.
+func (v *__addUserToProjectInput) GetNumber() string { return v.Number }
+
+// GetUsername returns __addUserToProjectInput.Username, and is useful for accessing the field via an interface.
+func (v *__addUserToProjectInput) GetUsername() string { return v.Username }
+
+// GetRole returns __addUserToProjectInput.Role, and is useful for accessing the field via an interface.
+func (v *__addUserToProjectInput) GetRole() ProjectMemberRole { return v.Role }
+
 // __getBookingEventsInput is used internally by genqlient
 type __getBookingEventsInput struct {
 	Start     time.Time `json:"start"`
@@ -87,6 +121,14 @@ type __getProjectInput struct {
 // GetNumber returns __getProjectInput.Number, and is useful for accessing the field via an interface.
 func (v *__getProjectInput) GetNumber() string { return v.Number }
 
+// __getProjectMembersInput is used internally by genqlient
+type __getProjectMembersInput struct {
+	Number string `json:"number"`
+}
+
+// GetNumber returns __getProjectMembersInput.Number, and is useful for accessing the field via an interface.
+func (v *__getProjectMembersInput) GetNumber() string { return v.Number }
+
 // __getProjectQuotaInput is used internally by genqlient
 type __getProjectQuotaInput struct {
 	Number string `json:"number"`
@@ -95,6 +137,34 @@ type __getProjectQuotaInput struct {
 // GetNumber returns __getProjectQuotaInput.Number, and is useful for accessing the field via an interface.
 func (v *__getProjectQuotaInput) GetNumber() string { return v.Number }
 
+// __getProjectsPageInput is used internally by genqlient
+type __getProjectsPageInput struct {
+	First     int             `json:"first"`
+	After     string          `json:"after,omitempty"`
+	Status    []ProjectStatus `json:"status,omitempty"`
+	Owner     string          `json:"owner,omitempty"`
+	EndAfter  *time.Time      `json:"endAfter,omitempty"`
+	EndBefore *time.Time      `json:"endBefore,omitempty"`
+}
+
+// GetFirst returns __getProjectsPageInput.First, and is useful for accessing the field via an interface.
+func (v *__getProjectsPageInput) GetFirst() int { return v.First }
+
+// GetAfter returns __getProjectsPageInput.After, and is useful for accessing the field via an interface.
+func (v *__getProjectsPageInput) GetAfter() string { return v.After }
+
+// GetStatus returns __getProjectsPageInput.Status, and is useful for accessing the field via an interface.
+func (v *__getProjectsPageInput) GetStatus() []ProjectStatus { return v.Status }
+
+// GetOwner returns __getProjectsPageInput.Owner, and is useful for accessing the field via an interface.
+func (v *__getProjectsPageInput) GetOwner() string { return v.Owner }
+
+// GetEndAfter returns __getProjectsPageInput.EndAfter, and is useful for accessing the field via an interface.
+func (v *__getProjectsPageInput) GetEndAfter() *time.Time { return v.EndAfter }
+
+// GetEndBefore returns __getProjectsPageInput.EndBefore, and is useful for accessing the field via an interface.
+func (v *__getProjectsPageInput) GetEndBefore() *time.Time { return v.EndBefore }
+
 // __getUserByEmailInput is used internally by genqlient
 type __getUserByEmailInput struct {
 	Email string `json:"email"`
@@ -111,6 +181,82 @@ type __getUserInput struct {
 // GetUsername returns __getUserInput.Username, and is useful for accessing the field via an interface.
 func (v *__getUserInput) GetUsername() string { return v.Username }
 
+// __getUserProjectsInput is used internally by genqlient
+type __getUserProjectsInput struct {
+	Username string `json:"username"`
+}
+
+// GetUsername returns __getUserProjectsInput.Username, and is useful for accessing the field via an interface.
+func (v *__getUserProjectsInput) GetUsername() string { return v.Username }
+
+// __getUsersPageInput is used internally by genqlient
+type __getUsersPageInput struct {
+	First    int            `json:"first"`
+	After    string         `json:"after,omitempty"`
+	Status   []UserStatus   `json:"status,omitempty"`
+	Function []UserFunction `json:"function,omitempty"`
+}
+
+// GetFirst returns __getUsersPageInput.First, and is useful for accessing the field via an interface.
+func (v *__getUsersPageInput) GetFirst() int { return v.First }
+
+// GetAfter returns __getUsersPageInput.After, and is useful for accessing the field via an interface.
+func (v *__getUsersPageInput) GetAfter() string { return v.After }
+
+// GetStatus returns __getUsersPageInput.Status, and is useful for accessing the field via an interface.
+func (v *__getUsersPageInput) GetStatus() []UserStatus { return v.Status }
+
+// GetFunction returns __getUsersPageInput.Function, and is useful for accessing the field via an interface.
+func (v *__getUsersPageInput) GetFunction() []UserFunction { return v.Function }
+
+// __removeUserFromProjectInput is used internally by genqlient
+type __removeUserFromProjectInput struct {
+	Number   string `json:"number"`
+	Username string `json:"username"`
+}
+
+// GetNumber returns __removeUserFromProjectInput.Number, and is useful for accessing the field via an interface.
+func (v *__removeUserFromProjectInput) GetNumber() string { return v.Number }
+
+// GetUsername returns __removeUserFromProjectInput.Username, and is useful for accessing the field via an interface.
+func (v *__removeUserFromProjectInput) GetUsername() string { return v.Username }
+
+// __updateProjectQuotaInput is used internally by genqlient
+type __updateProjectQuotaInput struct {
+	Number   string `json:"number"`
+	QuotaGiB int    `json:"quotaGiB"`
+}
+
+// GetNumber returns __updateProjectQuotaInput.Number, and is useful for accessing the field via an interface.
+func (v *__updateProjectQuotaInput) GetNumber() string { return v.Number }
+
+// GetQuotaGiB returns __updateProjectQuotaInput.QuotaGiB, and is useful for accessing the field via an interface.
+func (v *__updateProjectQuotaInput) GetQuotaGiB() int { return v.QuotaGiB }
+
+// addUserToProjectAddUserToProjectProjectMember includes the requested fields of the GraphQL type ProjectMember.
+type addUserToProjectAddUserToProjectProjectMember struct {
+	Role          ProjectMemberRole          `json:"role"`
+	PendingUpdate PendingProjectMemberUpdate `json:"pendingUpdate"`
+}
+
+// GetRole returns addUserToProjectAddUserToProjectProjectMember.Role, and is useful for accessing the field via an interface.
+func (v *addUserToProjectAddUserToProjectProjectMember) GetRole() ProjectMemberRole { return v.Role }
+
+// GetPendingUpdate returns addUserToProjectAddUserToProjectProjectMember.PendingUpdate, and is useful for accessing the field via an interface.
+func (v *addUserToProjectAddUserToProjectProjectMember) GetPendingUpdate() PendingProjectMemberUpdate {
+	return v.PendingUpdate
+}
+
+// addUserToProjectResponse is returned by addUserToProject on success.
+type addUserToProjectResponse struct {
+	AddUserToProject addUserToProjectAddUserToProjectProjectMember `json:"addUserToProject"`
+}
+
+// GetAddUserToProject returns addUserToProjectResponse.AddUserToProject, and is useful for accessing the field via an interface.
+func (v *addUserToProjectResponse) GetAddUserToProject() addUserToProjectAddUserToProjectProjectMember {
+	return v.AddUserToProject
+}
+
 // getBookingEventsBookingEventsBookingEvent includes the requested fields of the GraphQL type BookingEvent.
 type getBookingEventsBookingEventsBookingEvent struct {
 	Start    time.Time                                         `json:"start"`
@@ -571,15 +717,67 @@ type getLabsResponse struct {
 // GetLabs returns getLabsResponse.Labs, and is useful for accessing the field via an interface.
 func (v *getLabsResponse) GetLabs() []getLabsLabsLab { return v.Labs }
 
+// getProjectMembersProject includes the requested fields of the GraphQL type Project.
+type getProjectMembersProject struct {
+	Number  string                                         `json:"number"`
+	Members []getProjectMembersProjectMembersProjectMember `json:"members"`
+}
+
+// GetNumber returns getProjectMembersProject.Number, and is useful for accessing the field via an interface.
+func (v *getProjectMembersProject) GetNumber() string { return v.Number }
+
+// GetMembers returns getProjectMembersProject.Members, and is useful for accessing the field via an interface.
+func (v *getProjectMembersProject) GetMembers() []getProjectMembersProjectMembersProjectMember {
+	return v.Members
+}
+
+// getProjectMembersProjectMembersProjectMember includes the requested fields of the GraphQL type ProjectMember.
+type getProjectMembersProjectMembersProjectMember struct {
+	User          getProjectMembersProjectMembersProjectMemberUser `json:"user"`
+	Role          ProjectMemberRole                                `json:"role"`
+	PendingUpdate PendingProjectMemberUpdate                       `json:"pendingUpdate"`
+}
+
+// GetUser returns getProjectMembersProjectMembersProjectMember.User, and is useful for accessing the field via an interface.
+func (v *getProjectMembersProjectMembersProjectMember) GetUser() getProjectMembersProjectMembersProjectMemberUser {
+	return v.User
+}
+
+// GetRole returns getProjectMembersProjectMembersProjectMember.Role, and is useful for accessing the field via an interface.
+func (v *getProjectMembersProjectMembersProjectMember) GetRole() ProjectMemberRole { return v.Role }
+
+// GetPendingUpdate returns getProjectMembersProjectMembersProjectMember.PendingUpdate, and is useful for accessing the field via an interface.
+func (v *getProjectMembersProjectMembersProjectMember) GetPendingUpdate() PendingProjectMemberUpdate {
+	return v.PendingUpdate
+}
+
+// getProjectMembersProjectMembersProjectMemberUser includes the requested fields of the GraphQL type User.
+type getProjectMembersProjectMembersProjectMemberUser struct {
+	Username string `json:"username"`
+}
+
+// GetUsername returns getProjectMembersProjectMembersProjectMemberUser.Username, and is useful for accessing the field via an interface.
+func (v *getProjectMembersProjectMembersProjectMemberUser) GetUsername() string { return v.Username }
+
+// getProjectMembersResponse is returned by getProjectMembers on success.
+type getProjectMembersResponse struct {
+	Project getProjectMembersProject `json:"project"`
+}
+
+// GetProject returns getProjectMembersResponse.Project, and is useful for accessing the field via an interface.
+func (v *getProjectMembersResponse) GetProject() getProjectMembersProject { return v.Project }
+
 // getProjectProject includes the requested fields of the GraphQL type Project.
 type getProjectProject struct {
-	Number string                     `json:"number"`
-	Title  string                     `json:"title"`
-	Kind   ProjectKind                `json:"kind"`
-	Owner  getProjectProjectOwnerUser `json:"owner"`
-	Status ProjectStatus              `json:"status"`
-	Start  time.Time                  `json:"start"`
-	End    time.Time                  `json:"end"`
+	Number             string                     `json:"number"`
+	Title              string                     `json:"title"`
+	Kind               ProjectKind                `json:"kind"`
+	Owner              getProjectProjectOwnerUser `json:"owner"`
+	Status             ProjectStatus              `json:"status"`
+	Start              time.Time                  `json:"start"`
+	End                time.Time                  `json:"end"`
+	OverrulingQuotaGiB int                        `json:"overrulingQuotaGiB"`
+	Storage            getProjectProjectStorage   `json:"storage"`
 }
 
 // GetNumber returns getProjectProject.Number, and is useful for accessing the field via an interface.
@@ -603,6 +801,12 @@ func (v *getProjectProject) GetStart() time.Time { return v.Start }
 // GetEnd returns getProjectProject.End, and is useful for accessing the field via an interface.
 func (v *getProjectProject) GetEnd() time.Time { return v.End }
 
+// GetOverrulingQuotaGiB returns getProjectProject.OverrulingQuotaGiB, and is useful for accessing the field via an interface.
+func (v *getProjectProject) GetOverrulingQuotaGiB() int { return v.OverrulingQuotaGiB }
+
+// GetStorage returns getProjectProject.Storage, and is useful for accessing the field via an interface.
+func (v *getProjectProject) GetStorage() getProjectProjectStorage { return v.Storage }
+
 // getProjectProjectOwnerUser includes the requested fields of the GraphQL type User.
 type getProjectProjectOwnerUser struct {
 	Username    string `json:"username"`
@@ -619,6 +823,14 @@ func (v *getProjectProjectOwnerUser) GetDisplayName() string { return v.DisplayN
 // GetEmail returns getProjectProjectOwnerUser.Email, and is useful for accessing the field via an interface.
 func (v *getProjectProjectOwnerUser) GetEmail() string { return v.Email }
 
+// getProjectProjectStorage includes the requested fields of the GraphQL type Storage.
+type getProjectProjectStorage struct {
+	QuotaGiB int `json:"quotaGiB"`
+}
+
+// GetQuotaGiB returns getProjectProjectStorage.QuotaGiB, and is useful for accessing the field via an interface.
+func (v *getProjectProjectStorage) GetQuotaGiB() int { return v.QuotaGiB }
+
 // getProjectQuotaProject includes the requested fields of the GraphQL type Project.
 type getProjectQuotaProject struct {
 	OverrulingQuotaGiB int                           `json:"overrulingQuotaGiB"`
@@ -659,61 +871,234 @@ type getProjectResponse struct {
 // GetProject returns getProjectResponse.Project, and is useful for accessing the field via an interface.
 func (v *getProjectResponse) GetProject() getProjectProject { return v.Project }
 
-// getProjectsProjectsProject includes the requested fields of the GraphQL type Project.
-type getProjectsProjectsProject struct {
-	Number string                              `json:"number"`
-	Title  string                              `json:"title"`
-	Kind   ProjectKind                         `json:"kind"`
-	Owner  getProjectsProjectsProjectOwnerUser `json:"owner"`
-	Status ProjectStatus                       `json:"status"`
-	Start  time.Time                           `json:"start"`
-	End    time.Time                           `json:"end"`
+// getProjectsPageProjectsConnectionProjectConnection includes the requested fields of the GraphQL type ProjectConnection.
+type getProjectsPageProjectsConnectionProjectConnection struct {
+	// Information to aid in pagination.
+	PageInfo getProjectsPageProjectsConnectionProjectConnectionPageInfo `json:"pageInfo"`
+	// A list of edges.
+	Edges []getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdge `json:"edges"`
+}
+
+// GetPageInfo returns getProjectsPageProjectsConnectionProjectConnection.PageInfo, and is useful for accessing the field via an interface.
+func (v *getProjectsPageProjectsConnectionProjectConnection) GetPageInfo() getProjectsPageProjectsConnectionProjectConnectionPageInfo {
+	return v.PageInfo
+}
+
+// GetEdges returns getProjectsPageProjectsConnectionProjectConnection.Edges, and is useful for accessing the field via an interface.
+func (v *getProjectsPageProjectsConnectionProjectConnection) GetEdges() []getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdge {
+	return v.Edges
+}
+
+// getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdge includes the requested fields of the GraphQL type ProjectEdge.
+// The GraphQL type's documentation follows.
+//
+// An edge in a connection.
+type getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdge struct {
+	// The item at the end of the edge
+	Node getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject `json:"node"`
+}
+
+// GetNode returns getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdge.Node, and is useful for accessing the field via an interface.
+func (v *getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdge) GetNode() getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject {
+	return v.Node
+}
+
+// getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject includes the requested fields of the GraphQL type Project.
+type getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject struct {
+	Number             string                                                                                 `json:"number"`
+	Title              string                                                                                 `json:"title"`
+	Kind               ProjectKind                                                                            `json:"kind"`
+	Owner              getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProjectOwnerUser `json:"owner"`
+	Status             ProjectStatus                                                                          `json:"status"`
+	Start              time.Time                                                                              `json:"start"`
+	End                time.Time                                                                              `json:"end"`
+	OverrulingQuotaGiB int                                                                                    `json:"overrulingQuotaGiB"`
+	Storage            getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProjectStorage   `json:"storage"`
+}
+
+// GetNumber returns getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject.Number, and is useful for accessing the field via an interface.
+func (v *getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject) GetNumber() string {
+	return v.Number
 }
 
-// GetNumber returns getProjectsProjectsProject.Number, and is useful for accessing the field via an interface.
-func (v *getProjectsProjectsProject) GetNumber() string { return v.Number }
+// GetTitle returns getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject.Title, and is useful for accessing the field via an interface.
+func (v *getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject) GetTitle() string {
+	return v.Title
+}
 
-// GetTitle returns getProjectsProjectsProject.Title, and is useful for accessing the field via an interface.
-func (v *getProjectsProjectsProject) GetTitle() string { return v.Title }
+// GetKind returns getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject.Kind, and is useful for accessing the field via an interface.
+func (v *getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject) GetKind() ProjectKind {
+	return v.Kind
+}
 
-// GetKind returns getProjectsProjectsProject.Kind, and is useful for accessing the field via an interface.
-func (v *getProjectsProjectsProject) GetKind() ProjectKind { return v.Kind }
+// GetOwner returns getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject.Owner, and is useful for accessing the field via an interface.
+func (v *getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject) GetOwner() getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProjectOwnerUser {
+	return v.Owner
+}
 
-// GetOwner returns getProjectsProjectsProject.Owner, and is useful for accessing the field via an interface.
-func (v *getProjectsProjectsProject) GetOwner() getProjectsProjectsProjectOwnerUser { return v.Owner }
+// GetStatus returns getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject.Status, and is useful for accessing the field via an interface.
+func (v *getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject) GetStatus() ProjectStatus {
+	return v.Status
+}
+
+// GetStart returns getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject.Start, and is useful for accessing the field via an interface.
+func (v *getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject) GetStart() time.Time {
+	return v.Start
+}
 
-// GetStatus returns getProjectsProjectsProject.Status, and is useful for accessing the field via an interface.
-func (v *getProjectsProjectsProject) GetStatus() ProjectStatus { return v.Status }
+// GetEnd returns getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject.End, and is useful for accessing the field via an interface.
+func (v *getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject) GetEnd() time.Time {
+	return v.End
+}
 
-// GetStart returns getProjectsProjectsProject.Start, and is useful for accessing the field via an interface.
-func (v *getProjectsProjectsProject) GetStart() time.Time { return v.Start }
+// GetOverrulingQuotaGiB returns getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject.OverrulingQuotaGiB, and is useful for accessing the field via an interface.
+func (v *getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject) GetOverrulingQuotaGiB() int {
+	return v.OverrulingQuotaGiB
+}
 
-// GetEnd returns getProjectsProjectsProject.End, and is useful for accessing the field via an interface.
-func (v *getProjectsProjectsProject) GetEnd() time.Time { return v.End }
+// GetStorage returns getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject.Storage, and is useful for accessing the field via an interface.
+func (v *getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProject) GetStorage() getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProjectStorage {
+	return v.Storage
+}
 
-// getProjectsProjectsProjectOwnerUser includes the requested fields of the GraphQL type User.
-type getProjectsProjectsProjectOwnerUser struct {
+// getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProjectOwnerUser includes the requested fields of the GraphQL type User.
+type getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProjectOwnerUser struct {
 	Username    string `json:"username"`
 	DisplayName string `json:"displayName"`
 	Email       string `json:"email"`
 }
 
-// GetUsername returns getProjectsProjectsProjectOwnerUser.Username, and is useful for accessing the field via an interface.
-func (v *getProjectsProjectsProjectOwnerUser) GetUsername() string { return v.Username }
+// GetUsername returns getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProjectOwnerUser.Username, and is useful for accessing the field via an interface.
+func (v *getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProjectOwnerUser) GetUsername() string {
+	return v.Username
+}
+
+// GetDisplayName returns getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProjectOwnerUser.DisplayName, and is useful for accessing the field via an interface.
+func (v *getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProjectOwnerUser) GetDisplayName() string {
+	return v.DisplayName
+}
+
+// GetEmail returns getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProjectOwnerUser.Email, and is useful for accessing the field via an interface.
+func (v *getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProjectOwnerUser) GetEmail() string {
+	return v.Email
+}
+
+// getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProjectStorage includes the requested fields of the GraphQL type Storage.
+type getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProjectStorage struct {
+	QuotaGiB int `json:"quotaGiB"`
+}
 
-// GetDisplayName returns getProjectsProjectsProjectOwnerUser.DisplayName, and is useful for accessing the field via an interface.
-func (v *getProjectsProjectsProjectOwnerUser) GetDisplayName() string { return v.DisplayName }
+// GetQuotaGiB returns getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProjectStorage.QuotaGiB, and is useful for accessing the field via an interface.
+func (v *getProjectsPageProjectsConnectionProjectConnectionEdgesProjectEdgeNodeProjectStorage) GetQuotaGiB() int {
+	return v.QuotaGiB
+}
 
-// GetEmail returns getProjectsProjectsProjectOwnerUser.Email, and is useful for accessing the field via an interface.
-func (v *getProjectsProjectsProjectOwnerUser) GetEmail() string { return v.Email }
+// getProjectsPageProjectsConnectionProjectConnectionPageInfo includes the requested fields of the GraphQL type PageInfo.
+// The GraphQL type's documentation follows.
+//
+// Information about pagination in a connection
+type getProjectsPageProjectsConnectionProjectConnectionPageInfo struct {
+	// When paginating forwards, are there more items?
+	HasNextPage bool `json:"hasNextPage"`
+	// When paginating forwards, the cursor to continue.
+	EndCursor string `json:"endCursor"`
+}
 
-// getProjectsResponse is returned by getProjects on success.
-type getProjectsResponse struct {
-	Projects []getProjectsProjectsProject `json:"projects"`
+// GetHasNextPage returns getProjectsPageProjectsConnectionProjectConnectionPageInfo.HasNextPage, and is useful for accessing the field via an interface.
+func (v *getProjectsPageProjectsConnectionProjectConnectionPageInfo) GetHasNextPage() bool {
+	return v.HasNextPage
 }
 
-// GetProjects returns getProjectsResponse.Projects, and is useful for accessing the field via an interface.
-func (v *getProjectsResponse) GetProjects() []getProjectsProjectsProject { return v.Projects }
+// GetEndCursor returns getProjectsPageProjectsConnectionProjectConnectionPageInfo.EndCursor, and is useful for accessing the field via an interface.
+func (v *getProjectsPageProjectsConnectionProjectConnectionPageInfo) GetEndCursor() string {
+	return v.EndCursor
+}
+
+// getProjectsPageResponse is returned by getProjectsPage on success.
+type getProjectsPageResponse struct {
+	ProjectsConnection getProjectsPageProjectsConnectionProjectConnection `json:"projectsConnection"`
+}
+
+// GetProjectsConnection returns getProjectsPageResponse.ProjectsConnection, and is useful for accessing the field via an interface.
+func (v *getProjectsPageResponse) GetProjectsConnection() getProjectsPageProjectsConnectionProjectConnection {
+	return v.ProjectsConnection
+}
+
+// getProjectsPendingMembersProjectsProject includes the requested fields of the GraphQL type Project.
+type getProjectsPendingMembersProjectsProject struct {
+	Number             string                                                         `json:"number"`
+	OverrulingQuotaGiB int                                                            `json:"overrulingQuotaGiB"`
+	Storage            getProjectsPendingMembersProjectsProjectStorage                `json:"storage"`
+	Members            []getProjectsPendingMembersProjectsProjectMembersProjectMember `json:"members"`
+}
+
+// GetNumber returns getProjectsPendingMembersProjectsProject.Number, and is useful for accessing the field via an interface.
+func (v *getProjectsPendingMembersProjectsProject) GetNumber() string { return v.Number }
+
+// GetOverrulingQuotaGiB returns getProjectsPendingMembersProjectsProject.OverrulingQuotaGiB, and is useful for accessing the field via an interface.
+func (v *getProjectsPendingMembersProjectsProject) GetOverrulingQuotaGiB() int {
+	return v.OverrulingQuotaGiB
+}
+
+// GetStorage returns getProjectsPendingMembersProjectsProject.Storage, and is useful for accessing the field via an interface.
+func (v *getProjectsPendingMembersProjectsProject) GetStorage() getProjectsPendingMembersProjectsProjectStorage {
+	return v.Storage
+}
+
+// GetMembers returns getProjectsPendingMembersProjectsProject.Members, and is useful for accessing the field via an interface.
+func (v *getProjectsPendingMembersProjectsProject) GetMembers() []getProjectsPendingMembersProjectsProjectMembersProjectMember {
+	return v.Members
+}
+
+// getProjectsPendingMembersProjectsProjectMembersProjectMember includes the requested fields of the GraphQL type ProjectMember.
+type getProjectsPendingMembersProjectsProjectMembersProjectMember struct {
+	User          getProjectsPendingMembersProjectsProjectMembersProjectMemberUser `json:"user"`
+	Role          ProjectMemberRole                                                `json:"role"`
+	PendingUpdate PendingProjectMemberUpdate                                       `json:"pendingUpdate"`
+}
+
+// GetUser returns getProjectsPendingMembersProjectsProjectMembersProjectMember.User, and is useful for accessing the field via an interface.
+func (v *getProjectsPendingMembersProjectsProjectMembersProjectMember) GetUser() getProjectsPendingMembersProjectsProjectMembersProjectMemberUser {
+	return v.User
+}
+
+// GetRole returns getProjectsPendingMembersProjectsProjectMembersProjectMember.Role, and is useful for accessing the field via an interface.
+func (v *getProjectsPendingMembersProjectsProjectMembersProjectMember) GetRole() ProjectMemberRole {
+	return v.Role
+}
+
+// GetPendingUpdate returns getProjectsPendingMembersProjectsProjectMembersProjectMember.PendingUpdate, and is useful for accessing the field via an interface.
+func (v *getProjectsPendingMembersProjectsProjectMembersProjectMember) GetPendingUpdate() PendingProjectMemberUpdate {
+	return v.PendingUpdate
+}
+
+// getProjectsPendingMembersProjectsProjectMembersProjectMemberUser includes the requested fields of the GraphQL type User.
+type getProjectsPendingMembersProjectsProjectMembersProjectMemberUser struct {
+	Username string `json:"username"`
+}
+
+// GetUsername returns getProjectsPendingMembersProjectsProjectMembersProjectMemberUser.Username, and is useful for accessing the field via an interface.
+func (v *getProjectsPendingMembersProjectsProjectMembersProjectMemberUser) GetUsername() string {
+	return v.Username
+}
+
+// getProjectsPendingMembersProjectsProjectStorage includes the requested fields of the GraphQL type Storage.
+type getProjectsPendingMembersProjectsProjectStorage struct {
+	QuotaGiB int `json:"quotaGiB"`
+}
+
+// GetQuotaGiB returns getProjectsPendingMembersProjectsProjectStorage.QuotaGiB, and is useful for accessing the field via an interface.
+func (v *getProjectsPendingMembersProjectsProjectStorage) GetQuotaGiB() int { return v.QuotaGiB }
+
+// getProjectsPendingMembersResponse is returned by getProjectsPendingMembers on success.
+type getProjectsPendingMembersResponse struct {
+	Projects []getProjectsPendingMembersProjectsProject `json:"projects"`
+}
+
+// GetProjects returns getProjectsPendingMembersResponse.Projects, and is useful for accessing the field via an interface.
+func (v *getProjectsPendingMembersResponse) GetProjects() []getProjectsPendingMembersProjectsProject {
+	return v.Projects
+}
 
 // getUserByEmailResponse is returned by getUserByEmail on success.
 type getUserByEmailResponse struct {
@@ -755,6 +1140,50 @@ func (v *getUserByEmailUsersUser) GetStatus() UserStatus { return v.Status }
 // GetFunction returns getUserByEmailUsersUser.Function, and is useful for accessing the field via an interface.
 func (v *getUserByEmailUsersUser) GetFunction() UserFunction { return v.Function }
 
+// getUserProjectsResponse is returned by getUserProjects on success.
+type getUserProjectsResponse struct {
+	User getUserProjectsUser `json:"user"`
+}
+
+// GetUser returns getUserProjectsResponse.User, and is useful for accessing the field via an interface.
+func (v *getUserProjectsResponse) GetUser() getUserProjectsUser { return v.User }
+
+// getUserProjectsUser includes the requested fields of the GraphQL type User.
+type getUserProjectsUser struct {
+	Username string                                     `json:"username"`
+	Projects []getUserProjectsUserProjectsProjectMember `json:"projects"`
+}
+
+// GetUsername returns getUserProjectsUser.Username, and is useful for accessing the field via an interface.
+func (v *getUserProjectsUser) GetUsername() string { return v.Username }
+
+// GetProjects returns getUserProjectsUser.Projects, and is useful for accessing the field via an interface.
+func (v *getUserProjectsUser) GetProjects() []getUserProjectsUserProjectsProjectMember {
+	return v.Projects
+}
+
+// getUserProjectsUserProjectsProjectMember includes the requested fields of the GraphQL type ProjectMember.
+type getUserProjectsUserProjectsProjectMember struct {
+	Project getUserProjectsUserProjectsProjectMemberProject `json:"project"`
+	Role    ProjectMemberRole                               `json:"role"`
+}
+
+// GetProject returns getUserProjectsUserProjectsProjectMember.Project, and is useful for accessing the field via an interface.
+func (v *getUserProjectsUserProjectsProjectMember) GetProject() getUserProjectsUserProjectsProjectMemberProject {
+	return v.Project
+}
+
+// GetRole returns getUserProjectsUserProjectsProjectMember.Role, and is useful for accessing the field via an interface.
+func (v *getUserProjectsUserProjectsProjectMember) GetRole() ProjectMemberRole { return v.Role }
+
+// getUserProjectsUserProjectsProjectMemberProject includes the requested fields of the GraphQL type Project.
+type getUserProjectsUserProjectsProjectMemberProject struct {
+	Number string `json:"number"`
+}
+
+// GetNumber returns getUserProjectsUserProjectsProjectMemberProject.Number, and is useful for accessing the field via an interface.
+func (v *getUserProjectsUserProjectsProjectMemberProject) GetNumber() string { return v.Number }
+
 // getUserResponse is returned by getUser on success.
 type getUserResponse struct {
 	User getUserUser `json:"user"`
@@ -795,16 +1224,50 @@ func (v *getUserUser) GetStatus() UserStatus { return v.Status }
 // GetFunction returns getUserUser.Function, and is useful for accessing the field via an interface.
 func (v *getUserUser) GetFunction() UserFunction { return v.Function }
 
-// getUsersResponse is returned by getUsers on success.
-type getUsersResponse struct {
-	Users []getUsersUsersUser `json:"users"`
+// getUsersPageResponse is returned by getUsersPage on success.
+type getUsersPageResponse struct {
+	UsersConnection getUsersPageUsersConnectionUserConnection `json:"usersConnection"`
 }
 
-// GetUsers returns getUsersResponse.Users, and is useful for accessing the field via an interface.
-func (v *getUsersResponse) GetUsers() []getUsersUsersUser { return v.Users }
+// GetUsersConnection returns getUsersPageResponse.UsersConnection, and is useful for accessing the field via an interface.
+func (v *getUsersPageResponse) GetUsersConnection() getUsersPageUsersConnectionUserConnection {
+	return v.UsersConnection
+}
+
+// getUsersPageUsersConnectionUserConnection includes the requested fields of the GraphQL type UserConnection.
+type getUsersPageUsersConnectionUserConnection struct {
+	// Information to aid in pagination.
+	PageInfo getUsersPageUsersConnectionUserConnectionPageInfo `json:"pageInfo"`
+	// A list of edges.
+	Edges []getUsersPageUsersConnectionUserConnectionEdgesUserEdge `json:"edges"`
+}
+
+// GetPageInfo returns getUsersPageUsersConnectionUserConnection.PageInfo, and is useful for accessing the field via an interface.
+func (v *getUsersPageUsersConnectionUserConnection) GetPageInfo() getUsersPageUsersConnectionUserConnectionPageInfo {
+	return v.PageInfo
+}
+
+// GetEdges returns getUsersPageUsersConnectionUserConnection.Edges, and is useful for accessing the field via an interface.
+func (v *getUsersPageUsersConnectionUserConnection) GetEdges() []getUsersPageUsersConnectionUserConnectionEdgesUserEdge {
+	return v.Edges
+}
 
-// getUsersUsersUser includes the requested fields of the GraphQL type User.
-type getUsersUsersUser struct {
+// getUsersPageUsersConnectionUserConnectionEdgesUserEdge includes the requested fields of the GraphQL type UserEdge.
+// The GraphQL type's documentation follows.
+//
+// An edge in a connection.
+type getUsersPageUsersConnectionUserConnectionEdgesUserEdge struct {
+	// The item at the end of the edge
+	Node getUsersPageUsersConnectionUserConnectionEdgesUserEdgeNodeUser `json:"node"`
+}
+
+// GetNode returns getUsersPageUsersConnectionUserConnectionEdgesUserEdge.Node, and is useful for accessing the field via an interface.
+func (v *getUsersPageUsersConnectionUserConnectionEdgesUserEdge) GetNode() getUsersPageUsersConnectionUserConnectionEdgesUserEdgeNodeUser {
+	return v.Node
+}
+
+// getUsersPageUsersConnectionUserConnectionEdgesUserEdgeNodeUser includes the requested fields of the GraphQL type User.
+type getUsersPageUsersConnectionUserConnectionEdgesUserEdgeNodeUser struct {
 	Username   string       `json:"username"`
 	FirstName  string       `json:"firstName"`
 	MiddleName string       `json:"middleName"`
@@ -814,26 +1277,141 @@ type getUsersUsersUser struct {
 	Function   UserFunction `json:"function"`
 }
 
-// GetUsername returns getUsersUsersUser.Username, and is useful for accessing the field via an interface.
-func (v *getUsersUsersUser) GetUsername() string { return v.Username }
+// GetUsername returns getUsersPageUsersConnectionUserConnectionEdgesUserEdgeNodeUser.Username, and is useful for accessing the field via an interface.
+func (v *getUsersPageUsersConnectionUserConnectionEdgesUserEdgeNodeUser) GetUsername() string {
+	return v.Username
+}
+
+// GetFirstName returns getUsersPageUsersConnectionUserConnectionEdgesUserEdgeNodeUser.FirstName, and is useful for accessing the field via an interface.
+func (v *getUsersPageUsersConnectionUserConnectionEdgesUserEdgeNodeUser) GetFirstName() string {
+	return v.FirstName
+}
+
+// GetMiddleName returns getUsersPageUsersConnectionUserConnectionEdgesUserEdgeNodeUser.MiddleName, and is useful for accessing the field via an interface.
+func (v *getUsersPageUsersConnectionUserConnectionEdgesUserEdgeNodeUser) GetMiddleName() string {
+	return v.MiddleName
+}
+
+// GetLastName returns getUsersPageUsersConnectionUserConnectionEdgesUserEdgeNodeUser.LastName, and is useful for accessing the field via an interface.
+func (v *getUsersPageUsersConnectionUserConnectionEdgesUserEdgeNodeUser) GetLastName() string {
+	return v.LastName
+}
+
+// GetEmail returns getUsersPageUsersConnectionUserConnectionEdgesUserEdgeNodeUser.Email, and is useful for accessing the field via an interface.
+func (v *getUsersPageUsersConnectionUserConnectionEdgesUserEdgeNodeUser) GetEmail() string {
+	return v.Email
+}
+
+// GetStatus returns getUsersPageUsersConnectionUserConnectionEdgesUserEdgeNodeUser.Status, and is useful for accessing the field via an interface.
+func (v *getUsersPageUsersConnectionUserConnectionEdgesUserEdgeNodeUser) GetStatus() UserStatus {
+	return v.Status
+}
 
-// GetFirstName returns getUsersUsersUser.FirstName, and is useful for accessing the field via an interface.
-func (v *getUsersUsersUser) GetFirstName() string { return v.FirstName }
+// GetFunction returns getUsersPageUsersConnectionUserConnectionEdgesUserEdgeNodeUser.Function, and is useful for accessing the field via an interface.
+func (v *getUsersPageUsersConnectionUserConnectionEdgesUserEdgeNodeUser) GetFunction() UserFunction {
+	return v.Function
+}
 
-// GetMiddleName returns getUsersUsersUser.MiddleName, and is useful for accessing the field via an interface.
-func (v *getUsersUsersUser) GetMiddleName() string { return v.MiddleName }
+// getUsersPageUsersConnectionUserConnectionPageInfo includes the requested fields of the GraphQL type PageInfo.
+// The GraphQL type's documentation follows.
+//
+// Information about pagination in a connection
+type getUsersPageUsersConnectionUserConnectionPageInfo struct {
+	// When paginating forwards, are there more items?
+	HasNextPage bool `json:"hasNextPage"`
+	// When paginating forwards, the cursor to continue.
+	EndCursor string `json:"endCursor"`
+}
 
-// GetLastName returns getUsersUsersUser.LastName, and is useful for accessing the field via an interface.
-func (v *getUsersUsersUser) GetLastName() string { return v.LastName }
+// GetHasNextPage returns getUsersPageUsersConnectionUserConnectionPageInfo.HasNextPage, and is useful for accessing the field via an interface.
+func (v *getUsersPageUsersConnectionUserConnectionPageInfo) GetHasNextPage() bool {
+	return v.HasNextPage
+}
 
-// GetEmail returns getUsersUsersUser.Email, and is useful for accessing the field via an interface.
-func (v *getUsersUsersUser) GetEmail() string { return v.Email }
+// GetEndCursor returns getUsersPageUsersConnectionUserConnectionPageInfo.EndCursor, and is useful for accessing the field via an interface.
+func (v *getUsersPageUsersConnectionUserConnectionPageInfo) GetEndCursor() string { return v.EndCursor }
 
-// GetStatus returns getUsersUsersUser.Status, and is useful for accessing the field via an interface.
-func (v *getUsersUsersUser) GetStatus() UserStatus { return v.Status }
+// removeUserFromProjectRemoveUserFromProjectProjectMember includes the requested fields of the GraphQL type ProjectMember.
+type removeUserFromProjectRemoveUserFromProjectProjectMember struct {
+	Role ProjectMemberRole `json:"role"`
+}
+
+// GetRole returns removeUserFromProjectRemoveUserFromProjectProjectMember.Role, and is useful for accessing the field via an interface.
+func (v *removeUserFromProjectRemoveUserFromProjectProjectMember) GetRole() ProjectMemberRole {
+	return v.Role
+}
 
-// GetFunction returns getUsersUsersUser.Function, and is useful for accessing the field via an interface.
-func (v *getUsersUsersUser) GetFunction() UserFunction { return v.Function }
+// removeUserFromProjectResponse is returned by removeUserFromProject on success.
+type removeUserFromProjectResponse struct {
+	RemoveUserFromProject removeUserFromProjectRemoveUserFromProjectProjectMember `json:"removeUserFromProject"`
+}
+
+// GetRemoveUserFromProject returns removeUserFromProjectResponse.RemoveUserFromProject, and is useful for accessing the field via an interface.
+func (v *removeUserFromProjectResponse) GetRemoveUserFromProject() removeUserFromProjectRemoveUserFromProjectProjectMember {
+	return v.RemoveUserFromProject
+}
+
+// updateProjectQuotaResponse is returned by updateProjectQuota on success.
+type updateProjectQuotaResponse struct {
+	UpdateProject updateProjectQuotaUpdateProject `json:"updateProject"`
+}
+
+// GetUpdateProject returns updateProjectQuotaResponse.UpdateProject, and is useful for accessing the field via an interface.
+func (v *updateProjectQuotaResponse) GetUpdateProject() updateProjectQuotaUpdateProject {
+	return v.UpdateProject
+}
+
+// updateProjectQuotaUpdateProject includes the requested fields of the GraphQL type Project.
+type updateProjectQuotaUpdateProject struct {
+	Number             string `json:"number"`
+	OverrulingQuotaGiB int    `json:"overrulingQuotaGiB"`
+}
+
+// GetNumber returns updateProjectQuotaUpdateProject.Number, and is useful for accessing the field via an interface.
+func (v *updateProjectQuotaUpdateProject) GetNumber() string { return v.Number }
+
+// GetOverrulingQuotaGiB returns updateProjectQuotaUpdateProject.OverrulingQuotaGiB, and is useful for accessing the field via an interface.
+func (v *updateProjectQuotaUpdateProject) GetOverrulingQuotaGiB() int { return v.OverrulingQuotaGiB }
+
+// The query or mutation executed by addUserToProject.
+const addUserToProject_Operation = `
+mutation addUserToProject ($number: ID!, $username: ID!, $role: ProjectMemberRole!) {
+	addUserToProject(project: $number, user: $username, role: $role) {
+		role
+		pendingUpdate
+	}
+}
+`
+
+func addUserToProject(
+	ctx context.Context,
+	client graphql.Client,
+	number string,
+	username string,
+	role ProjectMemberRole,
+) (*addUserToProjectResponse, error) {
+	req := &graphql.Request{
+		OpName: "addUserToProject",
+		Query:  addUserToProject_Operation,
+		Variables: &__addUserToProjectInput{
+			Number:   number,
+			Username: username,
+			Role:     role,
+		},
+	}
+	var err error
+
+	var data addUserToProjectResponse
+	resp := &graphql.Response{Data: &data}
+
+	err = client.MakeRequest(
+		ctx,
+		req,
+		resp,
+	)
+
+	return &data, err
+}
 
 // The query or mutation executed by getBookingEvents.
 const getBookingEvents_Operation = `
@@ -977,6 +1555,10 @@ query getProject ($number: ID!) {
 		status
 		start
 		end
+		overrulingQuotaGiB
+		storage {
+			quotaGiB
+		}
 	}
 }
 `
@@ -1007,6 +1589,48 @@ func getProject(
 	return &data, err
 }
 
+// The query or mutation executed by getProjectMembers.
+const getProjectMembers_Operation = `
+query getProjectMembers ($number: ID!) {
+	project(id: $number) {
+		number
+		members {
+			user {
+				username
+			}
+			role
+			pendingUpdate
+		}
+	}
+}
+`
+
+func getProjectMembers(
+	ctx context.Context,
+	client graphql.Client,
+	number string,
+) (*getProjectMembersResponse, error) {
+	req := &graphql.Request{
+		OpName: "getProjectMembers",
+		Query:  getProjectMembers_Operation,
+		Variables: &__getProjectMembersInput{
+			Number: number,
+		},
+	}
+	var err error
+
+	var data getProjectMembersResponse
+	resp := &graphql.Response{Data: &data}
+
+	err = client.MakeRequest(
+		ctx,
+		req,
+		resp,
+	)
+
+	return &data, err
+}
+
 // The query or mutation executed by getProjectQuota.
 const getProjectQuota_Operation = `
 query getProjectQuota ($number: ID!) {
@@ -1046,36 +1670,108 @@ func getProjectQuota(
 	return &data, err
 }
 
-// The query or mutation executed by getProjects.
-const getProjects_Operation = `
-query getProjects {
-	projects {
+// The query or mutation executed by getProjectsPage.
+const getProjectsPage_Operation = `
+query getProjectsPage ($first: Int!, $after: String, $status: [ProjectStatus!], $owner: ID, $endAfter: DateTime, $endBefore: DateTime) {
+	projectsConnection(first: $first, after: $after, orderBy: {field:Id}, filterBy: {status:{in:$status},owner:{username:{equals:$owner}},end:{after:$endAfter,before:$endBefore}}) {
+		pageInfo {
+			hasNextPage
+			endCursor
+		}
+		edges {
+			node {
+				number
+				title
+				kind
+				owner {
+					username
+					displayName
+					email
+				}
+				status
+				start
+				end
+				overrulingQuotaGiB
+				storage {
+					quotaGiB
+				}
+			}
+		}
+	}
+}
+`
+
+// Projects are retrieved page by page, starting after the cursor of the previous page.
+// The variables not given are omitted, so that the corresponding filters don't apply
+// (see also the issue of the filters below).
+func getProjectsPage(
+	ctx context.Context,
+	client graphql.Client,
+	first int,
+	after string,
+	status []ProjectStatus,
+	owner string,
+	endAfter *time.Time,
+	endBefore *time.Time,
+) (*getProjectsPageResponse, error) {
+	req := &graphql.Request{
+		OpName: "getProjectsPage",
+		Query:  getProjectsPage_Operation,
+		Variables: &__getProjectsPageInput{
+			First:     first,
+			After:     after,
+			Status:    status,
+			Owner:     owner,
+			EndAfter:  endAfter,
+			EndBefore: endBefore,
+		},
+	}
+	var err error
+
+	var data getProjectsPageResponse
+	resp := &graphql.Response{Data: &data}
+
+	err = client.MakeRequest(
+		ctx,
+		req,
+		resp,
+	)
+
+	return &data, err
+}
+
+// The query or mutation executed by getProjectsPendingMembers.
+const getProjectsPendingMembers_Operation = `
+query getProjectsPendingMembers {
+	projects(filterBy: {status:{equals:Active}}) {
 		number
-		title
-		kind
-		owner {
-			username
-			displayName
-			email
+		overrulingQuotaGiB
+		storage {
+			quotaGiB
+		}
+		members {
+			user {
+				username
+			}
+			role
+			pendingUpdate
 		}
-		status
-		start
-		end
 	}
 }
 `
 
-func getProjects(
+// pending member updates are only considered for active projects.
+func getProjectsPendingMembers(
 	ctx context.Context,
 	client graphql.Client,
-) (*getProjectsResponse, error) {
+) (*getProjectsPendingMembersResponse, error) {
 	req := &graphql.Request{
-		OpName: "getProjects",
-		Query:  getProjects_Operation,
+		OpName: "getProjectsPendingMembers",
+		Query:  getProjectsPendingMembers_Operation,
 	}
 	var err error
 
-	var data getProjectsResponse
+	var data getProjectsPendingMembersResponse
 	resp := &graphql.Response{Data: &data}
 
 	err = client.MakeRequest(
@@ -1173,32 +1869,167 @@ func getUserByEmail(
 	return &data, err
 }
 
-// The query or mutation executed by getUsers.
-const getUsers_Operation = `
-query getUsers {
-	users {
+// The query or mutation executed by getUserProjects.
+const getUserProjects_Operation = `
+query getUserProjects ($username: ID!) {
+	user(id: $username) {
 		username
-		firstName
-		middleName
-		lastName
-		email
-		status
-		function
+		projects {
+			project {
+				number
+			}
+			role
+		}
+	}
+}
+`
+
+func getUserProjects(
+	ctx context.Context,
+	client graphql.Client,
+	username string,
+) (*getUserProjectsResponse, error) {
+	req := &graphql.Request{
+		OpName: "getUserProjects",
+		Query:  getUserProjects_Operation,
+		Variables: &__getUserProjectsInput{
+			Username: username,
+		},
+	}
+	var err error
+
+	var data getUserProjectsResponse
+	resp := &graphql.Response{Data: &data}
+
+	err = client.MakeRequest(
+		ctx,
+		req,
+		resp,
+	)
+
+	return &data, err
+}
+
+// The query or mutation executed by getUsersPage.
+const getUsersPage_Operation = `
+query getUsersPage ($first: Int!, $after: String, $status: [UserStatus!], $function: [UserFunction!]) {
+	usersConnection(first: $first, after: $after, orderBy: {field:Username}, filterBy: {status:{in:$status},function:{in:$function}}) {
+		pageInfo {
+			hasNextPage
+			endCursor
+		}
+		edges {
+			node {
+				username
+				firstName
+				middleName
+				lastName
+				email
+				status
+				function
+			}
+		}
+	}
+}
+`
+
+// Users are retrieved page by page, as the projects.
+func getUsersPage(
+	ctx context.Context,
+	client graphql.Client,
+	first int,
+	after string,
+	status []UserStatus,
+	function []UserFunction,
+) (*getUsersPageResponse, error) {
+	req := &graphql.Request{
+		OpName: "getUsersPage",
+		Query:  getUsersPage_Operation,
+		Variables: &__getUsersPageInput{
+			First:    first,
+			After:    after,
+			Status:   status,
+			Function: function,
+		},
+	}
+	var err error
+
+	var data getUsersPageResponse
+	resp := &graphql.Response{Data: &data}
+
+	err = client.MakeRequest(
+		ctx,
+		req,
+		resp,
+	)
+
+	return &data, err
+}
+
+// The query or mutation executed by removeUserFromProject.
+const removeUserFromProject_Operation = `
+mutation removeUserFromProject ($number: ID!, $username: ID!) {
+	removeUserFromProject(project: $number, user: $username) {
+		role
+	}
+}
+`
+
+func removeUserFromProject(
+	ctx context.Context,
+	client graphql.Client,
+	number string,
+	username string,
+) (*removeUserFromProjectResponse, error) {
+	req := &graphql.Request{
+		OpName: "removeUserFromProject",
+		Query:  removeUserFromProject_Operation,
+		Variables: &__removeUserFromProjectInput{
+			Number:   number,
+			Username: username,
+		},
+	}
+	var err error
+
+	var data removeUserFromProjectResponse
+	resp := &graphql.Response{Data: &data}
+
+	err = client.MakeRequest(
+		ctx,
+		req,
+		resp,
+	)
+
+	return &data, err
+}
+
+// The query or mutation executed by updateProjectQuota.
+const updateProjectQuota_Operation = `
+mutation updateProjectQuota ($number: ID!, $quotaGiB: Int) {
+	updateProject(id: $number, data: {overrulingQuotaGiB:$quotaGiB}) {
+		number
+		overrulingQuotaGiB
 	}
 }
 `
 
-func getUsers(
+func updateProjectQuota(
 	ctx context.Context,
 	client graphql.Client,
-) (*getUsersResponse, error) {
+	number string,
+	quotaGiB int,
+) (*updateProjectQuotaResponse, error) {
 	req := &graphql.Request{
-		OpName: "getUsers",
-		Query:  getUsers_Operation,
+		OpName: "updateProjectQuota",
+		Query:  updateProjectQuota_Operation,
+		Variables: &__updateProjectQuotaInput{
+			Number:   number,
+			QuotaGiB: quotaGiB,
+		},
 	}
 	var err error
 
-	var data getUsersResponse
+	var data updateProjectQuotaResponse
 	resp := &graphql.Response{Data: &data}
 
 	err = client.MakeRequest(
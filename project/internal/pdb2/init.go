@@ -6,46 +6,56 @@ import (
 	"regexp"
 	"time"
 
-	"github.com/Khan/genqlient/graphql"
-	"github.com/dccn-tg/tg-toolset-golang/pkg/config"
 	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
 )
 
-// GetProjects queries PDB2 to get metadata of all projects, using GraphQL.
-func GetProjects(config config.CoreAPIConfiguration) (*getProjectsResponse, error) {
-
-	c1, err := oauth2HttpClient(
-		config.AuthClientID,
-		config.AuthClientSecret,
-		config.AuthURL,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return getProjects(
-		context.Background(),
-		graphql.NewClient(config.CoreAPIURL, c1),
+// GetProjectsPage queries PDB2 to get metadata of at most `first` projects, following the
+// cursor `after` of the previous page, using GraphQL.  The first page is retrieved with an
+// empty cursor.  The projects are selected on the server side by the `status`, the
+// username of the `owner` and the end date after `endAfter` and before `endBefore`; the
+// empty arguments select all projects.
+func (c *Client) GetProjectsPage(ctx context.Context, status []ProjectStatus, owner string, endAfter, endBefore time.Time, first int, after string) (*getProjectsPageResponse, error) {
+
+	return getProjectsPage(
+		ctx,
+		c.gql,
+		first,
+		after,
+		status,
+		owner,
+		timeOrNil(endAfter),
+		timeOrNil(endBefore),
 	)
 }
 
 // GetProject queries PDB2 to get the metadata of a project referred by `number`, using GraphQL.
-func GetProject(config config.CoreAPIConfiguration, number string) (*getProjectResponse, error) {
+func (c *Client) GetProject(ctx context.Context, number string) (*getProjectResponse, error) {
 
-	c1, err := oauth2HttpClient(
-		config.AuthClientID,
-		config.AuthClientSecret,
-		config.AuthURL,
+	resp, err := getProject(
+		ctx,
+		c.gql,
+		number,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := getProject(
-		context.Background(),
-		graphql.NewClient(config.CoreAPIURL, c1),
+	if resp.Project.Number != number {
+		return nil, fmt.Errorf("project not found, number: %s", number)
+	}
+
+	return resp, nil
+
+}
+
+// GetProjectMembers queries PDB2 to get the members of a project referred by `number`,
+// including the pending updates on the members, using GraphQL.
+func (c *Client) GetProjectMembers(ctx context.Context, number string) (*getProjectMembersResponse, error) {
+
+	resp, err := getProjectMembers(
+		ctx,
+		c.gql,
 		number,
 	)
 
@@ -58,44 +68,93 @@ func GetProject(config config.CoreAPIConfiguration, number string) (*getProjectR
 	}
 
 	return resp, nil
+}
+
+// GetProjectsPendingMembers queries PDB2 to get members and storage quota of all active
+// projects, using GraphQL.  The pending updates on the members are included.
+func (c *Client) GetProjectsPendingMembers(ctx context.Context) (*getProjectsPendingMembersResponse, error) {
 
+	return getProjectsPendingMembers(
+		ctx,
+		c.gql,
+	)
 }
 
-// GetUsers queries PDB2 to get metadata of all users, using GraphQL.
-func GetUsers(config config.CoreAPIConfiguration) (*getUsersResponse, error) {
+// AddUserToProject adds the user `username` to the project `number` with the given
+// `role`, using GraphQL mutation.  The role of an existing member is updated.
+func (c *Client) AddUserToProject(ctx context.Context, number, username string, role ProjectMemberRole) error {
 
-	c1, err := oauth2HttpClient(
-		config.AuthClientID,
-		config.AuthClientSecret,
-		config.AuthURL,
+	_, err := addUserToProject(
+		ctx,
+		c.gql,
+		number,
+		username,
+		role,
 	)
 
-	if err != nil {
-		return nil, err
-	}
+	return err
+}
 
-	return getUsers(
-		context.Background(),
-		graphql.NewClient(config.CoreAPIURL, c1),
+// RemoveUserFromProject removes the user `username` from the project `number`, using
+// GraphQL mutation.
+func (c *Client) RemoveUserFromProject(ctx context.Context, number, username string) error {
+
+	_, err := removeUserFromProject(
+		ctx,
+		c.gql,
+		number,
+		username,
 	)
+
+	return err
 }
 
-// GetUser queries PDB2 to get the metadata of a user referred by `username`, using GraphQL.
-func GetUser(config config.CoreAPIConfiguration, username string) (*getUserResponse, error) {
+// GetProjectQuota queries PDB2 to get the storage quota and usage of the project `number`,
+// using GraphQL.
+func (c *Client) GetProjectQuota(ctx context.Context, number string) (*getProjectQuotaResponse, error) {
 
-	c1, err := oauth2HttpClient(
-		config.AuthClientID,
-		config.AuthClientSecret,
-		config.AuthURL,
+	return getProjectQuota(
+		ctx,
+		c.gql,
+		number,
 	)
+}
 
-	if err != nil {
-		return nil, err
-	}
+// UpdateProjectQuota sets the overruling storage quota of the project `number` to
+// `quotaGiB`, using GraphQL mutation.
+func (c *Client) UpdateProjectQuota(ctx context.Context, number string, quotaGiB int) error {
+
+	_, err := updateProjectQuota(
+		ctx,
+		c.gql,
+		number,
+		quotaGiB,
+	)
+
+	return err
+}
+
+// GetUsersPage queries PDB2 to get metadata of at most `first` users, following the cursor
+// `after` of the previous page, using GraphQL.  The users are selected on the server side
+// by the `status` and the `function`; the empty lists select all users.
+func (c *Client) GetUsersPage(ctx context.Context, status []UserStatus, function []UserFunction, first int, after string) (*getUsersPageResponse, error) {
+
+	return getUsersPage(
+		ctx,
+		c.gql,
+		first,
+		after,
+		status,
+		function,
+	)
+}
+
+// GetUser queries PDB2 to get the metadata of a user referred by `username`, using GraphQL.
+func (c *Client) GetUser(ctx context.Context, username string) (*getUserResponse, error) {
 
 	resp, err := getUser(
-		context.Background(),
-		graphql.NewClient(config.CoreAPIURL, c1),
+		ctx,
+		c.gql,
 		username,
 	)
 
@@ -110,42 +169,43 @@ func GetUser(config config.CoreAPIConfiguration, username string) (*getUserRespo
 	return resp, nil
 }
 
-// GetUserByEmail queries PDB2 to get metadata of the user with the given `email`.
-func GetUserByEmail(config config.CoreAPIConfiguration, email string) (*getUserByEmailResponse, error) {
+// GetUserProjects queries PDB2 to get the projects of a user referred by `username`,
+// with the role of the user in each project, using GraphQL.
+func (c *Client) GetUserProjects(ctx context.Context, username string) (*getUserProjectsResponse, error) {
 
-	c1, err := oauth2HttpClient(
-		config.AuthClientID,
-		config.AuthClientSecret,
-		config.AuthURL,
+	resp, err := getUserProjects(
+		ctx,
+		c.gql,
+		username,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if resp.User.Username != username {
+		return nil, fmt.Errorf("user not found, username: %s", username)
+	}
+
+	return resp, nil
+}
+
+// GetUserByEmail queries PDB2 to get metadata of the user with the given `email`.
+func (c *Client) GetUserByEmail(ctx context.Context, email string) (*getUserByEmailResponse, error) {
+
 	return getUserByEmail(
-		context.Background(),
-		graphql.NewClient(config.CoreAPIURL, c1),
+		ctx,
+		c.gql,
 		email,
 	)
 }
 
 // GetLabs queries PDB2 to get the IDs of certain lab modality.
-func GetLabs(config config.CoreAPIConfiguration, modality *regexp.Regexp, bookableOnly bool) ([]string, error) {
-
-	c1, err := oauth2HttpClient(
-		config.AuthClientID,
-		config.AuthClientSecret,
-		config.AuthURL,
-	)
-
-	if err != nil {
-		return nil, err
-	}
+func (c *Client) GetLabs(ctx context.Context, modality *regexp.Regexp, bookableOnly bool) ([]string, error) {
 
 	resp, err := getLabs(
-		context.Background(),
-		graphql.NewClient(config.CoreAPIURL, c1),
+		ctx,
+		c.gql,
 	)
 
 	if err != nil {
@@ -170,29 +230,28 @@ func GetLabs(config config.CoreAPIConfiguration, modality *regexp.Regexp, bookab
 }
 
 // GetBookings queries PDB2 to get metadata of all bookings on the lab `resource` between `start` and `end`, using GraphQL.
-func GetBookingEvents(config config.CoreAPIConfiguration, resources []string, start, end time.Time) (*getBookingEventsResponse, error) {
-
-	c1, err := oauth2HttpClient(
-		config.AuthClientID,
-		config.AuthClientSecret,
-		config.AuthURL,
-	)
-
-	if err != nil {
-		return nil, err
-	}
+func (c *Client) GetBookingEvents(ctx context.Context, resources []string, start, end time.Time) (*getBookingEventsResponse, error) {
 
 	log.Debugf("%+v - %+v, %+v", start, end, resources)
 
 	return getBookingEvents(
-		context.Background(),
-		graphql.NewClient(config.CoreAPIURL, c1),
+		ctx,
+		c.gql,
 		start,
 		end,
 		resources,
 	)
 }
 
+// timeOrNil returns the pointer to the time `t`, or nil if `t` is zero so that the
+// time is omitted from the query variables.
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
 func LabResource(resource getBookingEventsBookingEventsBookingEventResource) (*getBookingEventsBookingEventsBookingEventResourceLab, error) {
 	if lab, ok := resource.(*getBookingEventsBookingEventsBookingEventResourceLab); ok {
 		return lab, nil
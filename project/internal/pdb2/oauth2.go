@@ -4,7 +4,8 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
-	"io/ioutil"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
@@ -12,82 +13,188 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Khan/genqlient/graphql"
+	"github.com/dccn-tg/tg-toolset-golang/pkg/config"
 	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
 	"golang.org/x/oauth2"
 )
 
+const (
+	// DefaultTimeout is the default timeout of a HTTP request to the core API or to the
+	// authentication server.
+	DefaultTimeout = 30 * time.Second
+
+	// defaultRefreshLeeway is the time before the expiry at which the access token is
+	// refreshed.
+	defaultRefreshLeeway = 5 * time.Minute
+
+	// maxLeewayFraction is the max. fraction of the lifetime of the access token taken as
+	// the refresh leeway, so that a short-living token is still reused.
+	maxLeewayFraction = 0.25
+
+	// tokenScope is the scope of the access token for the core API.
+	tokenScope = "urn:dccn:pdb:core-api:query"
+)
+
 var (
-	// apiToken is the global reusable api token before it becomes
-	// invalid.
-	apiToken token
+	// sharedClients are the global reusable clients, one per configuration.
+	sharedClients = make(map[config.CoreAPIConfiguration]*Client)
+	sharedMux     sync.Mutex
 )
 
-// token holds the data structure of the Core API access token.
-type token struct {
-	AccessToken string
-	TokenType   string
-	validUntil  time.Time
-	mux         sync.Mutex
+// Shared returns the global reusable `Client` of the core API with the given `config`,
+// so that the access token is shared by all the requests of the process.
+func Shared(config config.CoreAPIConfiguration) *Client {
+	sharedMux.Lock()
+	defer sharedMux.Unlock()
+
+	if c, ok := sharedClients[config]; ok {
+		return c
+	}
+
+	c := NewClient(config, DefaultTimeout)
+	sharedClients[config] = c
+	return c
 }
 
-// oauth2HttpClient returns a HTTP client with a valid OAuth2 access token.
-func oauth2HttpClient(authClientID, authClientSecret, authURL string) (*http.Client, error) {
+// Client is a concurrency-safe client of the core API.
+//
+// The OAuth2 access token is obtained with the client credentials, and cached until it is
+// about to expire.  A request rejected with HTTP status 401 (Unauthorized) is retried once
+// with a new access token.  The requests honour the timeout of the client and the
+// cancellation of the context given to each operation.
+type Client struct {
+	gql    graphql.Client
+	tokens *tokenSource
+}
 
-	// retrieve API token
-	token, err := getAuthToken(authClientID, authClientSecret, authURL)
-	if err != nil {
-		return nil, err
+// NewClient returns a new `Client` of the core API with the given `config`, of which every
+// HTTP request times out after `timeout`.
+func NewClient(config config.CoreAPIConfiguration, timeout time.Duration) *Client {
+
+	tokens := &tokenSource{
+		clientID:     config.AuthClientID,
+		clientSecret: config.AuthClientSecret,
+		tokenURL:     strings.Join([]string{strings.TrimSuffix(config.AuthURL, "/"), "connect/token"}, "/"),
+		leeway:       defaultRefreshLeeway,
+		httpClient:   newHTTPSClient(timeout, false),
+	}
+
+	httpClient := newHTTPSClient(timeout, false)
+	httpClient.Transport = &transport{
+		tokens: tokens,
+		base:   httpClient.Transport,
+	}
+
+	return &Client{
+		gql:    graphql.NewClient(config.CoreAPIURL, httpClient),
+		tokens: tokens,
 	}
+}
 
-	// setup and return graphql client
-	src := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: token.AccessToken},
-	)
+// tokenSource provides the OAuth2 access token obtained with the client credentials.  The
+// token is cached, and refreshed when it is about to expire.
+type tokenSource struct {
+	clientID     string
+	clientSecret string
+	tokenURL     string
+	// leeway is the time before the expiry at which the token is refreshed, capped at the
+	// `maxLeewayFraction` of the token lifetime.
+	leeway     time.Duration
+	httpClient *http.Client
 
-	return oauth2.NewClient(context.Background(), src), nil
+	token *oauth2.Token
+	// refresh is the time at which the token is refreshed; zero if the token doesn't expire.
+	refresh time.Time
+	mux     sync.Mutex
 }
 
-// getAuthToken returns a valid OAuth authentication token.
+// Token returns a valid access token.
 //
-// If the global `apiToken` is still valid, it is returned rightaway. Otherwise,
-// this function renews the `apiToken` using the given `clientSecret` before
-// returning the `apiToken`.
-func getAuthToken(clientID, clientSecret, authURL string) (*token, error) {
+// The cached token is returned if it is still valid beyond the leeway.  Otherwise, a new
+// token is requested.  If the request fails while the cached token is not yet expired, the
+// cached token is returned.  A token without expiry is used until it is rejected by the
+// core API.
+func (s *tokenSource) Token(ctx context.Context) (*oauth2.Token, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.token != nil && (s.refresh.IsZero() || time.Now().Before(s.refresh)) {
+		return s.token, nil
+	}
+
+	t, err := s.request(ctx)
+	if err != nil {
+		if s.token != nil && time.Now().Before(s.token.Expiry) {
+			log.Warnf("cannot refresh access token, use the current one: %s", err)
+			return s.token, nil
+		}
+		return nil, err
+	}
 
-	// lock the apiToken for eventual manipulation of it.
-	apiToken.mux.Lock()
+	s.token = t
+	s.refresh = refreshTime(t, s.leeway)
+	return s.token, nil
+}
+
+// refreshTime returns the time at which the token `t` is refreshed, i.e. the `leeway`
+// before the expiry, with the `leeway` capped at the `maxLeewayFraction` of the remaining
+// lifetime of the token.  It returns zero time if the token doesn't expire.
+func refreshTime(t *oauth2.Token, leeway time.Duration) time.Time {
+	if t.Expiry.IsZero() {
+		return time.Time{}
+	}
+
+	if max := time.Duration(float64(time.Until(t.Expiry)) * maxLeewayFraction); leeway > max {
+		leeway = max
+	}
+
+	return t.Expiry.Add(-leeway)
+}
 
-	// make sure apiToken is unlocked for concurrent processes to use it.
-	defer apiToken.mux.Unlock()
+// invalidate removes the cached token if it is the token `t`, e.g. the token rejected by
+// the core API, so that a new token is requested.
+func (s *tokenSource) invalidate(t *oauth2.Token) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
 
-	// return the global reusable token if it is still valid in 5 minutes.
-	if time.Now().Add(5 * time.Minute).Before(apiToken.validUntil) {
-		return &apiToken, nil
+	if s.token != nil && s.token.AccessToken == t.AccessToken {
+		s.token = nil
 	}
+}
 
-	// make a HTTP POST with FORM data to retrieve authentication token.
-	href := strings.Join([]string{authURL, "connect/token"}, "/")
+// request makes a HTTP POST with FORM data to the authentication server to retrieve a
+// new access token.
+func (s *tokenSource) request(ctx context.Context) (*oauth2.Token, error) {
 
 	v := url.Values{}
-	v.Set("client_id", clientID)
-	v.Set("client_secret", clientSecret)
+	v.Set("client_id", s.clientID)
+	v.Set("client_secret", s.clientSecret)
 	v.Set("grant_type", "client_credentials")
-	v.Set("scope", "urn:dccn:pdb:core-api:query")
+	v.Set("scope", tokenScope)
 
-	c := newHTTPSClient(5*time.Second, false)
-	res, err := c.PostForm(href, v)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, strings.NewReader(v.Encode()))
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
 
 	log.Debugf("status: %d message: %s", res.StatusCode, res.Status)
 
-	bodyBytes, err := ioutil.ReadAll(res.Body)
+	bodyBytes, err := io.ReadAll(res.Body)
 	if err != nil {
 		return nil, err
 	}
 
-	log.Debugf("response body: %s", string(bodyBytes))
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot get access token: %s: %s", res.Status, bodyBytes)
+	}
 
 	// unmarshal response body to Token struct
 	var t struct {
@@ -99,13 +206,78 @@ func getAuthToken(clientID, clientSecret, authURL string) (*token, error) {
 		return nil, err
 	}
 
-	log.Debugf("auth token: %s", t)
+	if t.AccessToken == "" {
+		return nil, fmt.Errorf("cannot get access token: empty token")
+	}
+
+	tok := &oauth2.Token{
+		AccessToken: t.AccessToken,
+		TokenType:   t.TokenType,
+	}
+
+	// without the lifetime, the token is taken as not expiring, i.e. the zero expiry.
+	if t.ExpiresIn > 0 {
+		log.Debugf("access token expires in %d seconds", t.ExpiresIn)
+		tok.Expiry = time.Now().Add(time.Second * time.Duration(t.ExpiresIn))
+	} else {
+		log.Debugf("access token without expiry")
+	}
+
+	return tok, nil
+}
+
+// transport is the `http.RoundTripper` authorizing the requests with the access token of
+// the `tokens`.  The request rejected with HTTP status 401 is retried once with a new
+// access token.
+type transport struct {
+	tokens *tokenSource
+	base   http.RoundTripper
+}
+
+// RoundTrip implements the `http.RoundTripper` interface.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
 
-	apiToken.AccessToken = t.AccessToken
-	apiToken.TokenType = t.TokenType
-	apiToken.validUntil = time.Now().Add(time.Second * time.Duration(t.ExpiresIn))
+	tok, err := t.tokens.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := t.base.RoundTrip(authorize(req, tok))
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+
+	// the request body cannot be sent again.
+	if req.Body != nil && req.GetBody == nil {
+		return res, nil
+	}
+
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+
+	log.Debugf("access token rejected, retry with a new token")
+	t.tokens.invalidate(tok)
+
+	if tok, err = t.tokens.Token(req.Context()); err != nil {
+		return nil, err
+	}
+
+	retry := authorize(req, tok)
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.base.RoundTrip(retry)
+}
 
-	return &apiToken, nil
+// authorize returns a copy of the request `req` with the authorization header of the
+// access token `tok`, as a `http.RoundTripper` should not modify the request.
+func authorize(req *http.Request, tok *oauth2.Token) *http.Request {
+	r := req.Clone(req.Context())
+	tok.SetAuthHeader(r)
+	return r
 }
 
 // newHTTPSClient initiates a new HTTPS client.
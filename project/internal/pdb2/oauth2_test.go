@@ -0,0 +1,258 @@
+package pdb2
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/config"
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+)
+
+func init() {
+	logCfg := log.Configuration{
+		EnableConsole:     true,
+		ConsoleJSONFormat: false,
+		ConsoleLevel:      log.Info,
+	}
+
+	// initialize logger
+	log.NewLogger(logCfg, log.InstanceLogrusLogger)
+}
+
+// testServers are the authentication server and the core API server for testing the
+// client.
+type testServers struct {
+	// expiresIn is the lifetime of the tokens issued, in seconds; it is left out of the
+	// response if negative.
+	expiresIn int
+	// failTokens makes the authentication server fail.
+	failTokens atomic.Bool
+	// reject is the access token rejected by the core API; all tokens are rejected if it
+	// is "*".
+	reject atomic.Value
+	// delay is the response time of the core API.
+	delay time.Duration
+
+	tokens   atomic.Int32
+	requests atomic.Int32
+
+	auth *httptest.Server
+	api  *httptest.Server
+}
+
+func newTestServers(t *testing.T, expiresIn int) *testServers {
+	s := &testServers{expiresIn: expiresIn}
+
+	s.auth = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/connect/token" || r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_id") != "toolset" {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		if s.failTokens.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		n := s.tokens.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if s.expiresIn < 0 {
+			fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer"}`, n)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":%d,"token_type":"Bearer"}`, n, s.expiresIn)
+	}))
+	t.Cleanup(s.auth.Close)
+
+	s.api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+
+		// the request body is consumed, so that the cancellation is noticed.
+		io.Copy(io.Discard, r.Body)
+
+		select {
+		case <-time.After(s.delay):
+		case <-r.Context().Done():
+			return
+		}
+
+		auth := r.Header.Get("Authorization")
+		if reject, _ := s.reject.Load().(string); auth == "" || reject == "*" || auth == "Bearer "+reject {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"data":{"usersConnection":{"pageInfo":{"hasNextPage":false},"edges":[{"node":{"username":"honlee","status":"CheckedIn"}}]}}}`)
+	}))
+	t.Cleanup(s.api.Close)
+
+	return s
+}
+
+func (s *testServers) client(timeout time.Duration) *Client {
+	return NewClient(config.CoreAPIConfiguration{
+		AuthClientID:     "toolset",
+		AuthClientSecret: "secret",
+		AuthURL:          s.auth.URL,
+		CoreAPIURL:       s.api.URL,
+	}, timeout)
+}
+
+func TestClientTokenCaching(t *testing.T) {
+	s := newTestServers(t, 3600)
+	c := s.client(5 * time.Second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.GetUsersPage(context.Background(), nil, nil, 10, "")
+			if err != nil {
+				t.Errorf("%s", err)
+				return
+			}
+			if users := resp.UsersConnection.Edges; len(users) != 1 || users[0].Node.Username != "honlee" {
+				t.Errorf("unexpected users: %+v", users)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := s.tokens.Load(); n != 1 {
+		t.Errorf("expect 1 token request, got %d", n)
+	}
+}
+
+func TestClientTokenRefresh(t *testing.T) {
+	s := newTestServers(t, 3600)
+	c := s.client(5 * time.Second)
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetUsersPage(context.Background(), nil, nil, 10, ""); err != nil {
+			t.Fatalf("%s", err)
+		}
+		// the token is refreshed once it is within the refresh leeway.
+		c.tokens.refresh = time.Now().Add(-time.Second)
+	}
+
+	if n := s.tokens.Load(); n != 3 {
+		t.Errorf("expect 3 token requests, got %d", n)
+	}
+
+	// the token not yet expired is used if it cannot be refreshed.
+	s.failTokens.Store(true)
+	if _, err := c.GetUsersPage(context.Background(), nil, nil, 10, ""); err != nil {
+		t.Errorf("unexpected error with valid token: %s", err)
+	}
+
+	// the expired token is not used.
+	c.tokens.token.Expiry = time.Now().Add(-time.Second)
+	if _, err := c.GetUsersPage(context.Background(), nil, nil, 10, ""); err == nil {
+		t.Errorf("expect error with expired token")
+	}
+}
+
+func TestClientTokenShortLifetime(t *testing.T) {
+
+	for name, c := range map[string]struct {
+		expiresIn int
+		// expected refresh time relative to the expiry; ignored if the token doesn't expire.
+		leeway time.Duration
+	}{
+		"lifetime within leeway":   {expiresIn: 60, leeway: 15 * time.Second},
+		"lifetime equal to leeway": {expiresIn: 300, leeway: 75 * time.Second},
+		"lifetime beyond leeway":   {expiresIn: 3600, leeway: defaultRefreshLeeway},
+		"missing lifetime":         {expiresIn: -1},
+		"zero lifetime":            {expiresIn: 0},
+	} {
+		s := newTestServers(t, c.expiresIn)
+		cli := s.client(5 * time.Second)
+
+		// the token is reused, however short its lifetime.
+		for i := 0; i < 3; i++ {
+			if _, err := cli.GetUsersPage(context.Background(), nil, nil, 10, ""); err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+		}
+		if n := s.tokens.Load(); n != 1 {
+			t.Errorf("%s: expect 1 token request, got %d", name, n)
+		}
+
+		tok := cli.tokens.token
+		if c.expiresIn <= 0 {
+			if !tok.Expiry.IsZero() || !cli.tokens.refresh.IsZero() {
+				t.Errorf("%s: unexpected expiry of token without lifetime: %s", name, tok.Expiry)
+			}
+			continue
+		}
+
+		if d := tok.Expiry.Sub(cli.tokens.refresh) - c.leeway; d < -time.Second || d > time.Second {
+			t.Errorf("%s: unexpected refresh leeway: %s", name, tok.Expiry.Sub(cli.tokens.refresh))
+		}
+	}
+
+	// the token without expiry is refreshed when it is rejected.
+	s := newTestServers(t, -1)
+	c := s.client(5 * time.Second)
+	if _, err := c.GetUsersPage(context.Background(), nil, nil, 10, ""); err != nil {
+		t.Fatalf("%s", err)
+	}
+	s.reject.Store("token-1")
+	if _, err := c.GetUsersPage(context.Background(), nil, nil, 10, ""); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if n := s.tokens.Load(); n != 2 {
+		t.Errorf("expect 2 token requests, got %d", n)
+	}
+}
+
+func TestClientRetryUnauthorized(t *testing.T) {
+	s := newTestServers(t, 3600)
+	s.reject.Store("token-1")
+	c := s.client(5 * time.Second)
+
+	if _, err := c.GetUsersPage(context.Background(), nil, nil, 10, ""); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if n := s.tokens.Load(); n != 2 {
+		t.Errorf("expect 2 token requests, got %d", n)
+	}
+	if n := s.requests.Load(); n != 2 {
+		t.Errorf("expect 2 api requests, got %d", n)
+	}
+
+	// the request is retried only once.
+	s.reject.Store("*")
+	if _, err := c.GetUsersPage(context.Background(), nil, nil, 10, ""); err == nil {
+		t.Errorf("expect error on rejected token")
+	}
+	if n := s.requests.Load(); n != 4 {
+		t.Errorf("expect 4 api requests, got %d", n)
+	}
+}
+
+func TestClientCancellation(t *testing.T) {
+	s := newTestServers(t, 3600)
+	s.delay = 5 * time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	t0 := time.Now()
+	if _, err := s.client(time.Minute).GetUsersPage(ctx, nil, nil, 10, ""); err == nil {
+		t.Errorf("expect error on cancelled context")
+	}
+	if _, err := s.client(100*time.Millisecond).GetUsersPage(context.Background(), nil, nil, 10, ""); err == nil {
+		t.Errorf("expect error on timeout")
+	}
+	if time.Since(t0) > 2*time.Second {
+		t.Errorf("requests not cancelled in time")
+	}
+}
@@ -172,7 +172,8 @@ func setACL(path string, aces []ACE, recursive bool, followLink bool) error {
 			continue
 		}
 
-		// ignore invalid principles
+		// drop ACEs of principles no longer known to the system, e.g. removed
+		// accounts.  Principles being granted are validated by the rolers beforehand.
 		if ace.IsValidPrinciple() {
 			naces = append(naces, ace.String())
 		} else {
@@ -0,0 +1,340 @@
+package acl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	ufp "github.com/dccn-tg/tg-toolset-golang/pkg/filepath"
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/identity"
+)
+
+// portableVersion is the version of the portable ACL format.
+const portableVersion = 1
+
+// PortableACL is a storage-independent representation of the roles in a directory
+// tree. It is used to migrate roles between storage systems using different ACL
+// implementations, e.g. from the NetApp filer (NFSv4) to the CephFS (POSIX ACL).
+type PortableACL struct {
+	// Version is the version of the portable format.
+	Version int `json:"version"`
+	// Root is the path from which the roles were exported.  It is informative only;
+	// the entries are replayed relative to the root of the import.
+	Root string `json:"root"`
+	// Created is the time the roles were exported.
+	Created time.Time `json:"created"`
+	// Entries are the roles of the exported paths.
+	Entries []PortableEntry `json:"entries"`
+}
+
+// PortableEntry holds the roles of a path.  The `Path` is relative to the root of
+// the export, and the `Roles` is keyed by the role name (e.g. "manager").
+type PortableEntry struct {
+	Path  string              `json:"path"`
+	Roles map[string][]string `json:"roles"`
+}
+
+// Write serializes the `PortableACL` to `w` in JSON.
+func (p PortableACL) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// ReadPortableACL deserializes the `PortableACL` from `r`.
+func ReadPortableACL(r io.Reader) (*PortableACL, error) {
+	var p PortableACL
+	if err := json.NewDecoder(r).Decode(&p); err != nil {
+		return nil, err
+	}
+	if p.Version != portableVersion {
+		return nil, fmt.Errorf("unsupported portable ACL version: %d", p.Version)
+	}
+	return &p, nil
+}
+
+// toPortableRoles converts the `RoleMap` into role names as keys.  The `System` role
+// and roles without users are left out as they are specific to the storage system.
+func toPortableRoles(roles RoleMap) map[string][]string {
+	out := make(map[string][]string)
+	for r, users := range roles {
+		if r == System || len(users) == 0 {
+			continue
+		}
+		out[r.String()] = append([]string{}, users...)
+	}
+	return out
+}
+
+// fromPortableRoles converts the role names back into a `RoleMap` that can be applied
+// by the `roler`, translating the roles not supported by the underlying ACL system:
+//
+// - POSIX ACL (CephFS) has no `Writer` role; it is translated into `Contributor`.
+// - POSIX ACL (CephFS) roles are only applied on users; group principals are left out.
+func fromPortableRoles(roles map[string][]string, roler Roler) (RoleMap, error) {
+
+	_, posix := roler.(CephFsRoler)
+
+	out := make(RoleMap)
+	for name, users := range roles {
+		r, err := ParseRole(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if r == System {
+			continue
+		}
+
+		if posix && r == Writer {
+			log.Debugf("translate %s role into %s for POSIX ACL", Writer, Contributor)
+			r = Contributor
+		}
+
+		for _, u := range users {
+			if posix && strings.HasPrefix(u, "g:") {
+				log.Warnf("group principal not supported by POSIX ACL roler, skipped: %s", u)
+				continue
+			}
+			out[r] = append(out[r], u)
+		}
+	}
+	return out, nil
+}
+
+// ExportRoles retrieves roles on the path specified by `Runner.RootPath`, and returns
+// them in the storage-independent `PortableACL`. Use the `recursion` argument to
+// enable/disable recursion through filesystem tree.
+func (r *Runner) ExportRoles(recursion bool) (*PortableACL, error) {
+
+	chanOut, err := r.GetRoles(recursion)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &PortableACL{
+		Version: portableVersion,
+		Root:    filepath.Clean(r.ppath),
+		Created: time.Now(),
+		Entries: []PortableEntry{},
+	}
+
+	for o := range chanOut {
+		rel, err := filepath.Rel(p.Root, filepath.Clean(o.Path))
+		if err != nil {
+			log.Errorf("cannot resolve relative path of %s: %s", o.Path, err)
+			continue
+		}
+		p.Entries = append(p.Entries, PortableEntry{
+			Path:  rel,
+			Roles: toPortableRoles(o.RoleMap),
+		})
+	}
+
+	// sort entries so that parents always come before their children.
+	sort.Slice(p.Entries, func(i, j int) bool {
+		return p.Entries[i].Path < p.Entries[j].Path
+	})
+
+	return p, nil
+}
+
+// ImportRoles replays the roles in the `PortableACL` onto the paths relative to
+// `Runner.RootPath`.  The roles are applied on each path individually (i.e. without
+// recursion) by the roler of the target path; existing roles not mentioned in the
+// `PortableACL` are kept.
+//
+// Before any role is applied, the principals are validated with the `Runner.Resolver`,
+// and the paths are resolved; an error is returned if any principal is invalid, or any
+// path resolves outside the `Runner.RootPath`.  Paths in the `PortableACL` that do not
+// exist under the `Runner.RootPath` are skipped.  The paths are applied level by level,
+// so that parents always get their roles before their children.
+//
+// Failures on individual paths do not stop the import; an error listing the failed
+// paths is returned after all paths are processed.
+func (r *Runner) ImportRoles(p *PortableACL) error {
+
+	// resolve any symlinks on RootPath
+	ppath, err := filepath.EvalSymlinks(r.RootPath)
+	if err != nil {
+		return fmt.Errorf("path not found or unaccessible: %s", r.RootPath)
+	}
+	r.ppath = ppath
+
+	fpinfo, err := ufp.GetFilePathMode(r.ppath)
+	if err != nil {
+		return fmt.Errorf("path not found or unaccessible: %s", r.RootPath)
+	}
+
+	if err := validatePortablePrincipals(r.Resolver, p.Entries); err != nil {
+		return err
+	}
+
+	levels, err := r.importTargets(p.Entries)
+	if err != nil {
+		return err
+	}
+
+	// acquiring operation lock file
+	if fpinfo.Mode.IsDir() {
+		flock := filepath.Join(r.ppath, ".prj_setacl.lock")
+		if err := ufp.AcquireLock(flock); err != nil {
+			return err
+		}
+		defer os.Remove(flock)
+	}
+
+	nthreads := r.Nthreads
+	if nthreads < 1 {
+		nthreads = 1
+	}
+
+	var mux sync.Mutex
+	var failed []string
+
+	for _, targets := range levels {
+		chanT := make(chan importTarget, nthreads*4)
+
+		var wg sync.WaitGroup
+		wg.Add(nthreads)
+		for i := 0; i < nthreads; i++ {
+			go func() {
+				defer wg.Done()
+				for t := range chanT {
+					if err := r.importRoles(t); err != nil {
+						log.Errorf("%s: %s", err, t.path)
+						mux.Lock()
+						failed = append(failed, t.path)
+						mux.Unlock()
+					}
+				}
+			}()
+		}
+
+		for _, t := range targets {
+			chanT <- t
+		}
+		close(chanT)
+
+		wg.Wait()
+	}
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("cannot import roles on %d path(s): %s", len(failed), strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// importTarget is a resolved path on which the portable roles are applied.
+type importTarget struct {
+	path  string
+	roles map[string][]string
+}
+
+// importTargets resolves the paths of the portable `entries` under `r.ppath`, and returns
+// the targets grouped by the depth of the paths.  An error is returned if any path
+// resolves outside `r.ppath`.
+func (r *Runner) importTargets(entries []PortableEntry) ([][]importTarget, error) {
+
+	var levels [][]importTarget
+
+	for _, e := range entries {
+		// the path is checked before and after the symlinks are resolved.
+		if filepath.IsAbs(e.Path) || isParentRef(filepath.Clean(e.Path)) {
+			return nil, fmt.Errorf("path outside %s: %s", r.RootPath, e.Path)
+		}
+
+		path, err := filepath.EvalSymlinks(filepath.Join(r.ppath, e.Path))
+		if err != nil {
+			log.Warnf("path not found, skipped: %s", filepath.Join(r.ppath, e.Path))
+			continue
+		}
+
+		rel, err := filepath.Rel(r.ppath, path)
+		if err != nil || isParentRef(rel) {
+			return nil, fmt.Errorf("path outside %s: %s", r.RootPath, e.Path)
+		}
+
+		depth := 0
+		if rel != "." {
+			depth = len(strings.Split(rel, string(os.PathSeparator)))
+		}
+		for len(levels) <= depth {
+			levels = append(levels, []importTarget{})
+		}
+		levels[depth] = append(levels[depth], importTarget{path: path, roles: e.Roles})
+	}
+
+	return levels, nil
+}
+
+// isParentRef checks whether the cleaned relative path `rel` refers to a path outside
+// its base, i.e. it starts with "..".
+func isParentRef(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+// importRoles applies the portable roles of the target `t`.  Targets no longer existing
+// are skipped.
+func (r *Runner) importRoles(t importTarget) error {
+
+	fpm, err := ufp.GetFilePathMode(t.path)
+	if err != nil {
+		log.Warnf("path not found, skipped: %s", t.path)
+		return nil
+	}
+
+	roler := GetRoler(*fpm)
+	if roler == nil {
+		return fmt.Errorf("roler not found")
+	}
+
+	roles, err := fromPortableRoles(t.roles, roler)
+	if err != nil {
+		return err
+	}
+
+	if len(roles) == 0 {
+		return nil
+	}
+
+	if _, err := roler.SetRoles(*fpm, roles, false, false); err != nil {
+		return err
+	}
+
+	if !r.Silence {
+		log.Infof("%s", fpm.Path)
+	}
+
+	return nil
+}
+
+// validatePortablePrincipals validates the principals of the portable `entries` with
+// `validatePrincipals`.
+func validatePortablePrincipals(resolver identity.Resolver, entries []PortableEntry) error {
+
+	seen := make(map[string]bool)
+	principals := []string{}
+
+	for _, e := range entries {
+		for _, us := range e.Roles {
+			for _, u := range us {
+				if !seen[u] {
+					seen[u] = true
+					principals = append(principals, u)
+				}
+			}
+		}
+	}
+
+	return validatePrincipals(resolver, principals)
+}
@@ -0,0 +1,196 @@
+package acl
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/identity"
+)
+
+func init() {
+	logCfg := log.Configuration{
+		EnableConsole:     true,
+		ConsoleJSONFormat: false,
+		ConsoleLevel:      log.Debug,
+	}
+
+	// initialize logger
+	log.NewLogger(logCfg, log.InstanceLogrusLogger)
+}
+
+func TestParseRole(t *testing.T) {
+	for _, r := range []Role{Manager, Contributor, Writer, Viewer, Traverse, System} {
+		if p, err := ParseRole(r.String()); err != nil || p != r {
+			t.Errorf("cannot parse role %s: %v", r, err)
+		}
+	}
+	if _, err := ParseRole("admin"); err == nil {
+		t.Errorf("expect error on parsing unknown role")
+	}
+}
+
+func TestPortableRoles(t *testing.T) {
+	roles := RoleMap{
+		Manager: {"honlee"},
+		Writer:  {"edwger", "g:tg"},
+		Viewer:  {},
+		System:  {"OWNER@"},
+	}
+
+	proles := toPortableRoles(roles)
+
+	expected := map[string][]string{
+		"manager": {"honlee"},
+		"writer":  {"edwger", "g:tg"},
+	}
+	if !reflect.DeepEqual(proles, expected) {
+		t.Errorf("unexpected portable roles: %+v", proles)
+	}
+
+	// NFSv4 roler keeps the roles as they are.
+	nfs4, err := fromPortableRoles(proles, NetAppRoler{})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !reflect.DeepEqual(nfs4, RoleMap{Manager: {"honlee"}, Writer: {"edwger", "g:tg"}}) {
+		t.Errorf("unexpected NFSv4 roles: %+v", nfs4)
+	}
+
+	// POSIX roler translates writer into contributor and skips groups.
+	posix, err := fromPortableRoles(proles, CephFsRoler{})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !reflect.DeepEqual(posix, RoleMap{Manager: {"honlee"}, Contributor: {"edwger"}}) {
+		t.Errorf("unexpected POSIX roles: %+v", posix)
+	}
+}
+
+func TestPortableACLReadWrite(t *testing.T) {
+	p := PortableACL{
+		Version: portableVersion,
+		Root:    "/project/3010000.01",
+		Entries: []PortableEntry{
+			{Path: ".", Roles: map[string][]string{"manager": {"honlee"}}},
+			{Path: "raw", Roles: map[string][]string{"viewer": {"edwger"}}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	out, err := ReadPortableACL(&buf)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if !reflect.DeepEqual(out.Entries, p.Entries) || out.Root != p.Root {
+		t.Errorf("unexpected portable ACL: %+v", out)
+	}
+}
+
+func TestImportTargets(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	for _, d := range []string{"raw", "raw/sub-01"} {
+		if err := os.Mkdir(filepath.Join(root, d), 0755); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	r := &Runner{RootPath: root, ppath: root}
+
+	levels, err := r.importTargets([]PortableEntry{
+		{Path: "raw/sub-01"},
+		{Path: "."},
+		{Path: "missing"},
+		{Path: "raw"},
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// targets are grouped by depth, missing paths are skipped.
+	if len(levels) != 3 || levels[0][0].path != root || levels[1][0].path != filepath.Join(root, "raw") || levels[2][0].path != filepath.Join(root, "raw/sub-01") {
+		t.Errorf("unexpected targets: %+v", levels)
+	}
+
+	if err := os.Mkdir(filepath.Join(outside, "x"), 0755); err != nil {
+		t.Fatalf("%s", err)
+	}
+	for _, p := range []string{"../", "raw/../../etc", "link", "link/x", "/etc"} {
+		if _, err := r.importTargets([]PortableEntry{{Path: p}}); err == nil {
+			t.Errorf("expect error on path outside root: %s", p)
+		}
+	}
+}
+
+// testResolver is the identity resolver of which the users are given as a map of the
+// username to the active state.
+type testResolver map[string]bool
+
+func (r testResolver) LookupUser(username string) (*identity.Identity, error) {
+	active, ok := r[username]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+	return &identity.Identity{Username: username, Active: active}, nil
+}
+
+func TestValidatePortablePrincipals(t *testing.T) {
+	resolver := testResolver{"honlee": true, "edwger": true, "left": false}
+
+	entries := []PortableEntry{
+		{Path: ".", Roles: map[string][]string{"manager": {"honlee"}}},
+		{Path: "raw", Roles: map[string][]string{"viewer": {"edwger"}}},
+	}
+	if err := validatePortablePrincipals(resolver, entries); err != nil {
+		t.Errorf("%s", err)
+	}
+
+	for _, u := range []string{"unknown", "left", "g:no-such-group-for-test"} {
+		e := append(entries, PortableEntry{Path: "raw", Roles: map[string][]string{"contributor": {u}}})
+		if err := validatePortablePrincipals(resolver, e); err == nil {
+			t.Errorf("expect error on principal %s", u)
+		}
+	}
+}
+
+func TestImportRolesFailures(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "raw"), 0755); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	r := &Runner{RootPath: root, Silence: true, Resolver: testResolver{"honlee": true}}
+
+	// no roler is available for the temporary directory, the import should fail on all paths.
+	err := r.ImportRoles(&PortableACL{Entries: []PortableEntry{
+		{Path: ".", Roles: map[string][]string{"manager": {"honlee"}}},
+		{Path: "raw", Roles: map[string][]string{"viewer": {"honlee"}}},
+		{Path: "missing", Roles: map[string][]string{"viewer": {"honlee"}}},
+	}})
+	if err == nil {
+		t.Fatalf("expect error on failed paths")
+	}
+	for _, p := range []string{root + ",", filepath.Join(root, "raw")} {
+		if !strings.Contains(err.Error(), p) {
+			t.Errorf("failed path %s not reported: %s", p, err)
+		}
+	}
+	if strings.Contains(err.Error(), "missing") {
+		t.Errorf("skipped path reported as failure: %s", err)
+	}
+}
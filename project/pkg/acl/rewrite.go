@@ -0,0 +1,528 @@
+package acl
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/xattr"
+
+	ufp "github.com/dccn-tg/tg-toolset-golang/pkg/filepath"
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+)
+
+// JournalKind is the type of change recorded in a `JournalEntry`.
+type JournalKind string
+
+const (
+	// JournalOwner refers to a change of the file owner.
+	JournalOwner JournalKind = "owner"
+	// JournalNfs4 refers to a change of the NFSv4 ACL.
+	JournalNfs4 JournalKind = "nfs4"
+	// JournalPosix refers to a change of the POSIX ACL.
+	JournalPosix JournalKind = "posix"
+	// JournalManagers refers to a change of the CephFS managers extended attribute.
+	JournalManagers JournalKind = "managers"
+)
+
+// JournalEntry records a change made on a path by the principal rewrite. The
+// `Before` value is sufficient to roll back the change.
+type JournalEntry struct {
+	Path   string      `json:"path"`
+	Kind   JournalKind `json:"kind"`
+	Before string      `json:"before"`
+	After  string      `json:"after"`
+}
+
+// String implements the `fmt.Stringer` interface.
+func (e JournalEntry) String() string {
+	return fmt.Sprintf("%s [%s] %s -> %s", e.Path, e.Kind, e.Before, e.After)
+}
+
+// JournalHeader is the first line of a journal.  It records the principal rewrite of
+// which the changes are recorded, so that a rollback is restricted to the same users.
+type JournalHeader struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Journal writes `JournalEntry` as JSON lines to an underlying writer.
+type Journal struct {
+	enc *json.Encoder
+}
+
+// NewJournal returns a `Journal` writing entries of the principal rewrite from user
+// `from` to user `to` into `w`.  The `JournalHeader` is written first.
+func NewJournal(w io.Writer, from, to string) (*Journal, error) {
+	j := &Journal{enc: json.NewEncoder(w)}
+	if err := j.enc.Encode(JournalHeader{From: from, To: to}); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Write appends the entry `e` to the journal.
+func (j *Journal) Write(e JournalEntry) error {
+	return j.enc.Encode(e)
+}
+
+// ReadJournal reads the header and all entries from a journal written by `Journal`.
+func ReadJournal(r io.Reader) (JournalHeader, []JournalEntry, error) {
+	var h JournalHeader
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		if h.From == "" {
+			if err := json.Unmarshal(scanner.Bytes(), &h); err != nil || h.From == "" || h.To == "" {
+				return h, nil, fmt.Errorf("invalid journal header: %s", scanner.Text())
+			}
+			continue
+		}
+		var e JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return h, entries, fmt.Errorf("invalid journal entry: %s", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return h, entries, err
+	}
+	if h.From == "" {
+		return h, nil, fmt.Errorf("empty journal")
+	}
+	return h, entries, nil
+}
+
+// PrincipalRewriter rewrites the file owner and the ACL entries referring to the user
+// `From` into the user `To`.  It is used for ownership transfer, e.g. when an account
+// is renamed.
+type PrincipalRewriter struct {
+	// From is the username of the old principal.
+	From string
+	// To is the username of the new principal.
+	To string
+	// Preview specifies whether the changes are only computed but not applied.
+	Preview bool
+
+	fromUID int
+	toUID   int
+}
+
+// NewPrincipalRewriter returns a `PrincipalRewriter` for rewriting user `from` into `to`.
+// Both users should be valid system users.
+func NewPrincipalRewriter(from, to string, preview bool) (*PrincipalRewriter, error) {
+
+	if from == "" || to == "" || from == to {
+		return nil, fmt.Errorf("invalid principal rewrite: %s -> %s", from, to)
+	}
+
+	uFrom, err := user.Lookup(from)
+	if err != nil {
+		return nil, fmt.Errorf("unknown user %s: %s", from, err)
+	}
+	uTo, err := user.Lookup(to)
+	if err != nil {
+		return nil, fmt.Errorf("unknown user %s: %s", to, err)
+	}
+
+	fromUID, _ := strconv.Atoi(uFrom.Uid)
+	toUID, _ := strconv.Atoi(uTo.Uid)
+
+	return &PrincipalRewriter{
+		From:    from,
+		To:      to,
+		Preview: preview,
+		fromUID: fromUID,
+		toUID:   toUID,
+	}, nil
+}
+
+// Rewrite rewrites the owner and the ACL entries on the path `p`. It returns the
+// changes made (or to be made in the preview mode) on the path.
+//
+// The ACL representation is determined by the roler of the path: NFSv4 ACEs for the
+// NetApp and FreeNAS filers, and POSIX ACL entries plus the managers extended attribute
+// for the CephFS.
+//
+// Only the owner of a symbolic link is changed.  The ACL tools follow the link, so that
+// rewriting ACLs on it would change the referent, which may be outside the directory
+// tree the caller manages.
+func (r *PrincipalRewriter) Rewrite(p ufp.FilePathMode) ([]JournalEntry, error) {
+
+	p.Path = filepath.Clean(p.Path)
+
+	var entries []JournalEntry
+
+	e, err := r.rewriteOwner(p.Path)
+	if err != nil {
+		return entries, err
+	}
+	if e != nil {
+		entries = append(entries, *e)
+	}
+
+	fi, err := os.Lstat(p.Path)
+	if err != nil {
+		return entries, err
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		log.Debugf("symbolic link, skip ACL rewrite: %s", p.Path)
+		return entries, nil
+	}
+
+	switch GetRoler(p).(type) {
+	case NetAppRoler, FreeNasRoler:
+		e, err := r.rewriteNfs4(p.Path)
+		if err != nil {
+			return entries, err
+		}
+		if e != nil {
+			entries = append(entries, *e)
+		}
+	case CephFsRoler:
+		e, err := r.rewritePosix(p.Path)
+		if err != nil {
+			return entries, err
+		}
+		if e != nil {
+			entries = append(entries, *e)
+		}
+
+		e, err = r.rewriteManagers(p.Path)
+		if err != nil {
+			return entries, err
+		}
+		if e != nil {
+			entries = append(entries, *e)
+		}
+	default:
+		log.Debugf("roler not found, skip ACL rewrite: %s", p.Path)
+	}
+
+	return entries, nil
+}
+
+// rewriteOwner changes the owner of `path` from `r.From` to `r.To`.  The group
+// ownership is left untouched.
+func (r *PrincipalRewriter) rewriteOwner(path string) (*JournalEntry, error) {
+
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || int(st.Uid) != r.fromUID {
+		return nil, nil
+	}
+
+	e := &JournalEntry{
+		Path:   path,
+		Kind:   JournalOwner,
+		Before: strconv.Itoa(r.fromUID),
+		After:  strconv.Itoa(r.toUID),
+	}
+
+	if r.Preview {
+		return e, nil
+	}
+
+	return e, os.Lchown(path, r.toUID, -1)
+}
+
+// rewriteNfs4 rewrites NFSv4 ACEs of which the principal is `r.From`.
+func (r *PrincipalRewriter) rewriteNfs4(path string) (*JournalEntry, error) {
+
+	acesNow, err := getACL(path)
+	if err != nil {
+		return nil, err
+	}
+
+	acesNew, changed := rewriteAces(acesNow, r.From, r.To)
+	if !changed {
+		return nil, nil
+	}
+
+	e := &JournalEntry{
+		Path:   path,
+		Kind:   JournalNfs4,
+		Before: acesToString(acesNow),
+		After:  acesToString(acesNew),
+	}
+
+	if r.Preview {
+		return e, nil
+	}
+
+	return e, setACL(path, acesNew, false, false)
+}
+
+// rewritePosix rewrites POSIX ACL entries, including the default entries, of which
+// the qualifier is `r.From`.
+func (r *PrincipalRewriter) rewritePosix(path string) (*JournalEntry, error) {
+
+	entriesNow, err := getfaclEntries(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entriesNew, changed := rewritePosixEntries(entriesNow, r.From, r.To)
+	if !changed {
+		return nil, nil
+	}
+
+	e := &JournalEntry{
+		Path:   path,
+		Kind:   JournalPosix,
+		Before: strings.Join(entriesNow, ","),
+		After:  strings.Join(entriesNew, ","),
+	}
+
+	if r.Preview {
+		return e, nil
+	}
+
+	return e, setfacl(path, []string{"-n", "--set", e.After})
+}
+
+// rewriteManagers rewrites `r.From` into `r.To` in the CephFS managers extended attribute.
+func (r *PrincipalRewriter) rewriteManagers(path string) (*JournalEntry, error) {
+
+	d, err := xattr.LGet(path, fattrManagers)
+	if err != nil {
+		// it is fine that files/sub-directories do not have the attribute.
+		log.Debugf("cannot get manager list of %s: %s", path, err)
+		return nil, nil
+	}
+
+	managers, changed := rewriteManagerList(string(d), r.From, r.To)
+	if !changed {
+		return nil, nil
+	}
+
+	e := &JournalEntry{
+		Path:   path,
+		Kind:   JournalManagers,
+		Before: string(d),
+		After:  managers,
+	}
+
+	if r.Preview {
+		return e, nil
+	}
+
+	return e, xattr.LSet(path, fattrManagers, []byte(managers))
+}
+
+// Rollback reverts the change on the path `e.Path` recorded in the journal entry `e`.
+//
+// The entry should be a change made by the rewriter, i.e. the `After` value is the
+// `Before` value with `r.From` rewritten into `r.To`, and the path should still be in
+// the `After` state.  Otherwise, the entry is refused, so that a tampered journal
+// cannot be used to set arbitrary owners or ACLs.  The caller is responsible for
+// resolving `e.Path` to a path it is allowed to change.  ACL changes on a symbolic
+// link are refused, as they would apply to the referent.
+func (r *PrincipalRewriter) Rollback(e JournalEntry) error {
+
+	if err := r.checkEntry(e); err != nil {
+		return err
+	}
+
+	if e.Kind != JournalOwner {
+		fi, err := os.Lstat(e.Path)
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%s change on symbolic link refused", e.Kind)
+		}
+	}
+
+	switch e.Kind {
+	case JournalOwner:
+		fi, err := os.Lstat(e.Path)
+		if err != nil {
+			return err
+		}
+		if st, ok := fi.Sys().(*syscall.Stat_t); !ok || int(st.Uid) != r.toUID {
+			return fmt.Errorf("owner changed since the rewrite")
+		}
+		return os.Lchown(e.Path, r.fromUID, -1)
+	case JournalNfs4:
+		aces, err := parseAces(e.Before)
+		if err != nil {
+			return err
+		}
+		acesNow, err := getACL(e.Path)
+		if err != nil {
+			return err
+		}
+		if acesToString(acesNow) != e.After {
+			return fmt.Errorf("ACL changed since the rewrite")
+		}
+		return setACL(e.Path, aces, false, false)
+	case JournalPosix:
+		entriesNow, err := getfaclEntries(e.Path)
+		if err != nil {
+			return err
+		}
+		if strings.Join(entriesNow, ",") != e.After {
+			return fmt.Errorf("ACL changed since the rewrite")
+		}
+		return setfacl(e.Path, []string{"-n", "--set", e.Before})
+	case JournalManagers:
+		d, err := xattr.LGet(e.Path, fattrManagers)
+		if err != nil {
+			return err
+		}
+		if string(d) != e.After {
+			return fmt.Errorf("managers changed since the rewrite")
+		}
+		return xattr.LSet(e.Path, fattrManagers, []byte(e.Before))
+	default:
+		return fmt.Errorf("unknown journal entry kind: %s", e.Kind)
+	}
+}
+
+// checkEntry checks that the journal entry `e` is a change the rewriter makes, i.e. the
+// `After` value is the `Before` value with `r.From` rewritten into `r.To`.
+func (r *PrincipalRewriter) checkEntry(e JournalEntry) error {
+
+	var after string
+	changed := false
+
+	switch e.Kind {
+	case JournalOwner:
+		if e.Before != strconv.Itoa(r.fromUID) || e.After != strconv.Itoa(r.toUID) {
+			return fmt.Errorf("owner change not from %s to %s", r.From, r.To)
+		}
+		return nil
+	case JournalNfs4:
+		aces, err := parseAces(e.Before)
+		if err != nil {
+			return err
+		}
+		var out []ACE
+		out, changed = rewriteAces(aces, r.From, r.To)
+		after = acesToString(out)
+	case JournalPosix:
+		var out []string
+		out, changed = rewritePosixEntries(strings.Split(e.Before, ","), r.From, r.To)
+		after = strings.Join(out, ",")
+	case JournalManagers:
+		after, changed = rewriteManagerList(e.Before, r.From, r.To)
+	default:
+		return fmt.Errorf("unknown journal entry kind: %s", e.Kind)
+	}
+
+	if !changed || after != e.After {
+		return fmt.Errorf("%s change not a rewrite from %s to %s", e.Kind, r.From, r.To)
+	}
+	return nil
+}
+
+// parseAces parses the comma-separated ACEs in the format of `nfs4_setfacl -s`.
+func parseAces(s string) ([]ACE, error) {
+	var aces []ACE
+	for _, a := range strings.Split(s, ",") {
+		ace, err := parseAce(a)
+		if err != nil {
+			return nil, err
+		}
+		aces = append(aces, *ace)
+	}
+	return aces, nil
+}
+
+// rewriteAces returns a copy of `aces` in which the user principal `from` is replaced
+// by `to`.  Group principals are left untouched.
+func rewriteAces(aces []ACE, from, to string) ([]ACE, bool) {
+	changed := false
+	out := make([]ACE, len(aces))
+	for i, ace := range aces {
+		if !ace.IsSysPermission() && !strings.Contains(ace.Flag, "g") && getPrincipleName(ace) == from {
+			ace.Principle = fmt.Sprintf("%s@%s", to, userDomain)
+			changed = true
+		}
+		out[i] = ace
+	}
+	return out, changed
+}
+
+// acesToString joins the `aces` into the comma-separated format of `nfs4_setfacl -s`.
+func acesToString(aces []ACE) string {
+	s := make([]string, len(aces))
+	for i, ace := range aces {
+		s[i] = ace.String()
+	}
+	return strings.Join(s, ",")
+}
+
+// rewritePosixEntries returns a copy of the POSIX ACL `entries` in which the user
+// qualifier `from` is replaced by `to`, for both the access and the default entries.
+func rewritePosixEntries(entries []string, from, to string) ([]string, bool) {
+	changed := false
+	out := make([]string, len(entries))
+	for i, l := range entries {
+		d := strings.Split(l, ":")
+		// access entry: user:<name>:<perm>, default entry: default:user:<name>:<perm>
+		idx := 1
+		if d[0] == "default" {
+			idx = 2
+		}
+		if len(d) > idx+1 && d[idx-1] == "user" && d[idx] == from {
+			d[idx] = to
+			changed = true
+		}
+		out[i] = strings.Join(d, ":")
+	}
+	return out, changed
+}
+
+// rewriteManagerList replaces `from` with `to` in the comma-separated manager list `list`.
+func rewriteManagerList(list, from, to string) (string, bool) {
+	changed := false
+	m := strings.Split(list, ",")
+	for i, u := range m {
+		if u == from {
+			m[i] = to
+			changed = true
+		}
+	}
+	return strings.Join(m, ","), changed
+}
+
+// getfaclEntries is a wrapper of the `getfacl` command and returns all ACL entries,
+// including the base and the default entries, in the short text form accepted by
+// `setfacl --set`.
+func getfaclEntries(path string) ([]string, error) {
+
+	out, err := exec.Command("getfacl", "--omit-header", "--absolute-names", path).Output()
+	if err != nil {
+		return nil, fmt.Errorf("getfacl exec failure: %s", err)
+	}
+
+	var entries []string
+	for _, l := range strings.Split(string(out), "\n") {
+		// trim the effective permission comment
+		l = strings.TrimSpace(strings.Split(l, "#")[0])
+		if l == "" {
+			continue
+		}
+		entries = append(entries, l)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,143 @@
+package acl
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRewriteAces(t *testing.T) {
+	aces := []ACE{}
+	for _, s := range []string{
+		"A:fd:olduser@dccn.nl:rwaDdxtTnNcy",
+		"A:fdg:olduser@dccn.nl:rxtncy",
+		"A:fd:other@dccn.nl:rxtncy",
+		"A:fd:OWNER@:rwaDdxtTnNcCoy",
+	} {
+		ace, _ := parseAce(s)
+		aces = append(aces, *ace)
+	}
+
+	out, changed := rewriteAces(aces, "olduser", "newuser")
+	if !changed {
+		t.Errorf("expect ACEs to be changed")
+	}
+
+	expected := "A:fd:newuser@dccn.nl:rwaDdxtTnNcy,A:fdg:olduser@dccn.nl:rxtncy,A:fd:other@dccn.nl:rxtncy,A:fd:OWNER@:rwaDdxtTnNcCoy"
+	if s := acesToString(out); s != expected {
+		t.Errorf("unexpected ACEs: %s", s)
+	}
+
+	// the input ACEs should stay untouched
+	if aces[0].Principle != "olduser@dccn.nl" {
+		t.Errorf("input ACEs modified: %s", aces[0])
+	}
+
+	if _, changed := rewriteAces(aces, "nobody", "newuser"); changed {
+		t.Errorf("expect ACEs not to be changed")
+	}
+}
+
+func TestRewritePosixEntries(t *testing.T) {
+	entries := []string{
+		"user::rwx",
+		"user:olduser:rwx",
+		"group::r-x",
+		"group:olduser:r-x",
+		"mask::rwx",
+		"other::---",
+		"default:user::rwx",
+		"default:user:olduser:rwx",
+	}
+
+	out, changed := rewritePosixEntries(entries, "olduser", "newuser")
+	if !changed {
+		t.Errorf("expect entries to be changed")
+	}
+
+	expected := []string{
+		"user::rwx",
+		"user:newuser:rwx",
+		"group::r-x",
+		"group:olduser:r-x",
+		"mask::rwx",
+		"other::---",
+		"default:user::rwx",
+		"default:user:newuser:rwx",
+	}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("unexpected entries: %+v", out)
+	}
+}
+
+func TestRewriteManagerList(t *testing.T) {
+	if l, changed := rewriteManagerList("a,olduser,b", "olduser", "newuser"); !changed || l != "a,newuser,b" {
+		t.Errorf("unexpected manager list: %s", l)
+	}
+	if _, changed := rewriteManagerList("a,olduser2", "olduser", "newuser"); changed {
+		t.Errorf("expect manager list not to be changed")
+	}
+}
+
+func TestJournal(t *testing.T) {
+	var buf bytes.Buffer
+
+	entries := []JournalEntry{
+		{Path: "/project/3010000.01/a", Kind: JournalOwner, Before: "1000", After: "1001"},
+		{Path: "/project/3010000.01/a", Kind: JournalNfs4, Before: "A:fd:a@dccn.nl:rxtncy", After: "A:fd:b@dccn.nl:rxtncy"},
+	}
+
+	j, err := NewJournal(&buf, "a", "b")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	for _, e := range entries {
+		if err := j.Write(e); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	h, out, err := ReadJournal(&buf)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if h.From != "a" || h.To != "b" {
+		t.Errorf("unexpected journal header: %+v", h)
+	}
+
+	if !reflect.DeepEqual(out, entries) {
+		t.Errorf("unexpected journal entries: %+v", out)
+	}
+
+	// journal without header is refused.
+	if _, _, err := ReadJournal(strings.NewReader(`{"path":"/etc/passwd","kind":"owner","before":"0","after":"1000"}`)); err == nil {
+		t.Errorf("expect error on journal without header")
+	}
+}
+
+func TestRewriterCheckEntry(t *testing.T) {
+	r := &PrincipalRewriter{From: "olduser", To: "newuser", fromUID: 1000, toUID: 1001}
+
+	for _, c := range []struct {
+		entry JournalEntry
+		valid bool
+	}{
+		{JournalEntry{Kind: JournalOwner, Before: "1000", After: "1001"}, true},
+		{JournalEntry{Kind: JournalOwner, Before: "0", After: "1001"}, false},
+		{JournalEntry{Kind: JournalOwner, Before: "1000", After: "0"}, false},
+		{JournalEntry{Kind: JournalNfs4, Before: "A:fd:olduser@dccn.nl:rxtncy", After: "A:fd:newuser@dccn.nl:rxtncy"}, true},
+		// the ACL to restore grants more than the rewritten one.
+		{JournalEntry{Kind: JournalNfs4, Before: "A:fd:olduser@dccn.nl:rwaDdxtTnNcCoy", After: "A:fd:newuser@dccn.nl:rxtncy"}, false},
+		{JournalEntry{Kind: JournalPosix, Before: "user::rwx,user:olduser:rwx", After: "user::rwx,user:newuser:rwx"}, true},
+		{JournalEntry{Kind: JournalPosix, Before: "user::rwx,user:other:rwx", After: "user::rwx,user:other:rwx"}, false},
+		{JournalEntry{Kind: JournalManagers, Before: "a,olduser", After: "a,newuser"}, true},
+		{JournalEntry{Kind: JournalManagers, Before: "attacker,olduser", After: "a,newuser"}, false},
+		{JournalEntry{Kind: "unknown"}, false},
+	} {
+		if err := r.checkEntry(c.entry); (err == nil) != c.valid {
+			t.Errorf("unexpected check result of %s: %v", c.entry, err)
+		}
+	}
+}
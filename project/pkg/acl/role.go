@@ -3,10 +3,13 @@
 package acl
 
 import (
+	"fmt"
 	"os"
+	"os/user"
 	"strings"
 
 	ufp "github.com/dccn-tg/tg-toolset-golang/pkg/filepath"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/identity"
 )
 
 var roleStrings = map[Role]string{
@@ -49,6 +52,16 @@ func (r Role) String() string {
 	return roleStrings[r]
 }
 
+// ParseRole returns the `Role` corresponding to the human-readable name `name`.
+func ParseRole(name string) (Role, error) {
+	for r, s := range roleStrings {
+		if s == name {
+			return r, nil
+		}
+	}
+	return System, fmt.Errorf("unknown role: %s", name)
+}
+
 // IsValidRole checks if the given role is a valid one.
 func IsValidRole(role Role) bool {
 	return role <= System
@@ -72,6 +85,29 @@ type Roler interface {
 	DelRoles(pinfo ufp.FilePathMode, roles RoleMap, recursive bool, followLink bool) (RoleMap, error)
 }
 
+// validatePrincipals checks the `principals` before they are granted with roles.  The
+// users are validated with the `resolver`, or the local passwd database if it is nil,
+// and the group principals (prefixed with `g:`) with the local group database.
+func validatePrincipals(resolver identity.Resolver, principals []string) error {
+
+	if resolver == nil {
+		resolver = identity.Passwd{}
+	}
+
+	users := []string{}
+	for _, u := range principals {
+		if g, ok := strings.CutPrefix(u, "g:"); ok {
+			if _, err := user.LookupGroup(g); err != nil {
+				return fmt.Errorf("invalid group %s: %s", g, err)
+			}
+			continue
+		}
+		users = append(users, u)
+	}
+
+	return identity.Validate(resolver, users)
+}
+
 // RolerMap defines a list of supported rolers with associated path as key of
 // the map.  The path is usually refers to the top-level mount point of the
 // fileserver on which the roler performs actions.
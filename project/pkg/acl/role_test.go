@@ -0,0 +1,28 @@
+package acl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePrincipals(t *testing.T) {
+
+	cases := []struct {
+		principals []string
+		invalid    string
+	}{
+		{principals: []string{"root", "g:root"}},
+		{principals: []string{"root", "no-such-user-3010000"}, invalid: "no-such-user-3010000"},
+		{principals: []string{"root", "g:no-such-group-3010000"}, invalid: "no-such-group-3010000"},
+	}
+
+	for _, c := range cases {
+		err := validatePrincipals(nil, c.principals)
+		if c.invalid == "" && err != nil {
+			t.Errorf("%v: unexpected error: %s", c.principals, err)
+		}
+		if c.invalid != "" && (err == nil || !strings.Contains(err.Error(), c.invalid)) {
+			t.Errorf("%v: expect error on %s, got %v", c.principals, c.invalid, err)
+		}
+	}
+}
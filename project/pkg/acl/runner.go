@@ -14,6 +14,7 @@ import (
 	ufp "github.com/dccn-tg/tg-toolset-golang/pkg/filepath"
 	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
 	ustr "github.com/dccn-tg/tg-toolset-golang/pkg/strings"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/identity"
 )
 
 var signalHandled = []os.Signal{
@@ -55,6 +56,9 @@ type Runner struct {
 	// SkipFiles specifies whether the set/delete action should skip applying role changes on
 	// existing files.
 	SkipFiles bool
+	// Resolver is used to validate the users before they are granted with roles; unknown
+	// and inactive users are rejected.  The local passwd database is used if it is not set.
+	Resolver identity.Resolver
 
 	// ppath is an absolute path evaluated from RootPath.  If RootPath is a symbolic link,
 	// the ppath will be pointed to the evaluated target.
@@ -78,6 +82,12 @@ func (r *Runner) SetRoles() (exitcode int, err error) {
 		return
 	}
 
+	// validate users against the identity source before any ACL is written.
+	if err = validatePrincipals(r.Resolver, usersT); err != nil {
+		exitcode = 1
+		return
+	}
+
 	// resolve any symlinks on ppathSym to actual path this program should work on.
 	r.ppath, _ = filepath.EvalSymlinks(r.RootPath)
 
@@ -0,0 +1,99 @@
+package alert
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/mailer"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
+)
+
+// Digest collects the project alerts per recipient, so that all the alerts to a recipient
+// are sent in one message.  It is safe for concurrent use.
+type Digest struct {
+	mutex   sync.Mutex
+	entries map[string]*DigestEntry
+}
+
+// DigestEntry is the project alerts collected for a recipient.
+type DigestEntry struct {
+	Recipient *pdb.User
+	// CarbonCopy is the union of the carbon copies of the collected alerts.
+	CarbonCopy []string
+	Projects   []mailer.ProjectAlertTemplateData
+}
+
+// NewDigest returns an empty `Digest`.
+func NewDigest() *Digest {
+	return &Digest{
+		entries: make(map[string]*DigestEntry),
+	}
+}
+
+// Add adds the project alert `data` with the carbon copies `cc` to the digest of the
+// recipient `u`.  Recipients are identified by the email address.
+func (d *Digest) Add(u *pdb.User, data mailer.ProjectAlertTemplateData, cc ...string) {
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	key := strings.ToLower(u.Email)
+
+	e, ok := d.entries[key]
+	if !ok {
+		e = &DigestEntry{Recipient: u}
+		d.entries[key] = e
+	}
+
+	data.RecipientName = u.DisplayName()
+	e.Projects = append(e.Projects, data)
+
+	for _, c := range cc {
+		if !containsFold(e.CarbonCopy, c) {
+			e.CarbonCopy = append(e.CarbonCopy, c)
+		}
+	}
+}
+
+// Entries returns the collected alerts, sorted by the recipient email.  The projects of
+// each entry are sorted by the project id.
+func (d *Digest) Entries() []*DigestEntry {
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	entries := make([]*DigestEntry, 0, len(d.entries))
+	for _, e := range d.entries {
+		sort.SliceStable(e.Projects, func(i, j int) bool {
+			return e.Projects[i].ProjectID < e.Projects[j].ProjectID
+		})
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return strings.ToLower(entries[i].Recipient.Email) < strings.ToLower(entries[j].Recipient.Email)
+	})
+
+	return entries
+}
+
+// TemplateData returns the data of the digest template of the entry, with the given
+// `sender` name.
+func (e *DigestEntry) TemplateData(sender string) mailer.ProjectAlertDigestTemplateData {
+	return mailer.ProjectAlertDigestTemplateData{
+		RecipientName: e.Recipient.DisplayName(),
+		SenderName:    sender,
+		Projects:      e.Projects,
+	}
+}
+
+// containsFold checks whether `s` is in `list`, with case-insensitive comparison.
+func containsFold(list []string, s string) bool {
+	for _, l := range list {
+		if strings.EqualFold(l, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package alert
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/mailer"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
+)
+
+const digestTemplate = `
+{{len .Projects}} project alert(s)
+
+Dear {{.RecipientName}},
+{{range .Projects}}
+{{- if eq .AlertType "ooq"}}
+  * {{.ProjectID}}: {{.QuotaUsageRatio}}% of quota used
+{{- else}}
+  * {{.ProjectID}}: ends on {{.ProjectEndDate}}
+{{- end}}
+{{- end}}
+
+Best regards, {{.SenderName}}
+`
+
+func TestDigest(t *testing.T) {
+
+	alice := &pdb.User{ID: "alice", Firstname: "Alice", Lastname: "A", Email: "alice@example.org"}
+	bob := &pdb.User{ID: "bob", Firstname: "Bob", Lastname: "B", Email: "Bob@example.org"}
+
+	d := NewDigest()
+
+	var wg sync.WaitGroup
+	for _, a := range []struct {
+		u    *pdb.User
+		data mailer.ProjectAlertTemplateData
+		cc   []string
+	}{
+		{alice, mailer.ProjectAlertTemplateData{ProjectID: "3010000.02", AlertType: "ooq", QuotaUsageRatio: 95}, nil},
+		{alice, mailer.ProjectAlertTemplateData{ProjectID: "3010000.01", AlertType: "oot", ProjectEndDate: "2024-02-01"}, []string{"cc@example.org"}},
+		{bob, mailer.ProjectAlertTemplateData{ProjectID: "3010000.01", AlertType: "oot", ProjectEndDate: "2024-02-01"}, []string{"cc@example.org"}},
+		{&pdb.User{ID: "bob", Email: "bob@example.org"}, mailer.ProjectAlertTemplateData{ProjectID: "3010000.03", AlertType: "ooq"}, []string{"CC@example.org"}},
+	} {
+		wg.Add(1)
+		go func(u *pdb.User, data mailer.ProjectAlertTemplateData, cc []string) {
+			defer wg.Done()
+			d.Add(u, data, cc...)
+		}(a.u, a.data, a.cc)
+	}
+	wg.Wait()
+
+	entries := d.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("expect 2 recipients, got %d", len(entries))
+	}
+
+	for _, e := range entries {
+		if len(e.Projects) != 2 || e.Projects[0].ProjectID > e.Projects[1].ProjectID {
+			t.Errorf("unexpected projects of %s: %+v", e.Recipient.Email, e.Projects)
+		}
+		if len(e.CarbonCopy) != 1 {
+			t.Errorf("unexpected carbon copies of %s: %+v", e.Recipient.Email, e.CarbonCopy)
+		}
+	}
+
+	subject, body, err := mailer.ComposeMessageFromTemplate(digestTemplate, entries[0].TemplateData("Helpdesk"))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if subject != "2 project alert(s)" {
+		t.Errorf("unexpected subject: %s", subject)
+	}
+
+	for _, s := range []string{"Dear Alice A,", "3010000.01: ends on 2024-02-01", "3010000.02: 95% of quota used", "Best regards, Helpdesk"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("%q not in body:\n%s", s, body)
+		}
+	}
+}
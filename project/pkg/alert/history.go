@@ -0,0 +1,218 @@
+package alert
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/store"
+)
+
+// Types of the project alerts.
+const (
+	TypeOoq = "ooq"
+	TypeOot = "oot"
+)
+
+// Buckets of the alert database.
+const (
+	// BucketOoqLastAlerts is the bucket in which the last out-of-quota alert of each project is kept.
+	BucketOoqLastAlerts = "ooqLastAlerts"
+	// BucketOotLastAlerts is the bucket in which the last out-of-time alert of each project is kept.
+	BucketOotLastAlerts = "ootLastAlerts"
+	// bucketHistory is the bucket in which every alert sent is recorded.
+	bucketHistory = "alertHistory"
+)
+
+// Record is the history record of an alert sent concerning a project.
+type Record struct {
+	ProjectID string    `json:"projectID"`
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	// Mode is the alert mode of the out-of-time alert.
+	Mode string `json:"mode,omitempty"`
+	// UsagePercent is the storage quota usage of the out-of-quota alert.
+	UsagePercent int    `json:"usagePercent,omitempty"`
+	Template     string `json:"template"`
+	// Digest indicates whether the alert is sent as part of the alert digests.
+	Digest     bool     `json:"digest,omitempty"`
+	Recipients []string `json:"recipients"`
+	CarbonCopy []string `json:"cc,omitempty"`
+}
+
+// Query selects the history records.  The empty fields select all records.
+type Query struct {
+	ProjectID string
+	Type      string
+	// Recipient is the email address of a recipient, compared case-insensitively.
+	Recipient string
+	// From and To bound the time of the records; To is exclusive.
+	From time.Time
+	To   time.Time
+}
+
+// match checks whether the record `r` is selected by the query.
+func (q Query) match(r *Record) bool {
+	switch {
+	case q.ProjectID != "" && r.ProjectID != q.ProjectID:
+		return false
+	case q.Type != "" && r.Type != q.Type:
+		return false
+	case q.Recipient != "" && !containsFold(r.Recipients, q.Recipient):
+		return false
+	case !q.From.IsZero() && r.Time.Before(q.From):
+		return false
+	case !q.To.IsZero() && !r.Time.Before(q.To):
+		return false
+	}
+	return true
+}
+
+// History bookkeeps the alerts sent in the `Store`, together with the last alert of each
+// project by which the alert policies are applied.
+type History struct {
+	Store *store.KVStore
+}
+
+// Init initializes the buckets of the history in the `Store`.
+func (h History) Init() error {
+	return h.Store.Init([]string{BucketOoqLastAlerts, BucketOotLastAlerts, bucketHistory})
+}
+
+// Add records the alert `r`.
+func (h History) Add(r Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%s/%s", r.ProjectID, r.Time.UTC().Format(time.RFC3339Nano), r.Type)
+	return h.Store.Set(bucketHistory, []byte(key), data)
+}
+
+// keyTimeBound is the layout of the time in the key range of the records.  Being a prefix
+// of the time in the record key, it sorts before all the records within the same second.
+const keyTimeBound = "2006-01-02T15:04:05"
+
+// keyRange returns the range of the record keys selected by the query `q`, i.e. the keys
+// with the project prefix, narrowed down by the time bounds.  The range is nil if all
+// the records are to be scanned.
+func (q Query) keyRange() (min, max []byte) {
+	if q.ProjectID == "" {
+		return nil, nil
+	}
+
+	prefix := q.ProjectID + "/"
+
+	min = []byte(prefix)
+	if !q.From.IsZero() {
+		min = []byte(prefix + q.From.UTC().Format(keyTimeBound))
+	}
+
+	// "0" is the byte after "/", bounding the keys with the project prefix.
+	max = []byte(q.ProjectID + "0")
+	if !q.To.IsZero() {
+		to := q.To.UTC()
+		if t := to.Truncate(time.Second); !t.Equal(to) {
+			to = t.Add(time.Second)
+		}
+		max = []byte(prefix + to.Format(keyTimeBound))
+	}
+
+	return min, max
+}
+
+// Records returns the records selected by the query `q` in chronological order.  The
+// records of a project are retrieved by a range scan over the keys of the project.
+func (h History) Records(q Query) ([]*Record, error) {
+
+	var kvs []store.KVPair
+	var err error
+	if min, max := q.keyRange(); min != nil {
+		kvs, err = h.Store.GetRange(bucketHistory, min, max)
+	} else {
+		kvs, err = h.Store.GetAll(bucketHistory)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*Record, 0)
+	for _, kv := range kvs {
+		r := &Record{}
+		if err := json.Unmarshal(kv.Value, r); err != nil {
+			return nil, fmt.Errorf("invalid alert record %s: %s", kv.Key, err)
+		}
+		if q.match(r) {
+			records = append(records, r)
+		}
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+
+	return records, nil
+}
+
+// Reset removes the last alert of the project `pid` of the given alert `types`, or of all
+// types if none is given, so that the alerts are sent again regardless of the previous ones.
+// The history records are kept.
+func (h History) Reset(pid string, types ...string) error {
+
+	if len(types) == 0 {
+		types = []string{TypeOoq, TypeOot}
+	}
+
+	for _, t := range types {
+		var bucket string
+		switch t {
+		case TypeOoq:
+			bucket = BucketOoqLastAlerts
+		case TypeOot:
+			bucket = BucketOotLastAlerts
+		default:
+			return fmt.Errorf("unknown alert type: %s", t)
+		}
+
+		if err := h.Store.Delete(bucket, []byte(pid)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteCSV writes the `records` to `w` in CSV format, with a header line.  The recipients
+// and the carbon copies are separated by ";".
+func WriteCSV(w io.Writer, records []*Record) error {
+
+	cw := csv.NewWriter(w)
+
+	cw.Write([]string{"project", "time", "type", "mode", "usage", "template", "digest", "recipients", "cc"})
+	for _, r := range records {
+		usage := ""
+		if r.Type == TypeOoq {
+			usage = strconv.Itoa(r.UsagePercent)
+		}
+		cw.Write([]string{
+			r.ProjectID,
+			r.Time.Format(time.RFC3339),
+			r.Type,
+			r.Mode,
+			usage,
+			r.Template,
+			strconv.FormatBool(r.Digest),
+			strings.Join(r.Recipients, ";"),
+			strings.Join(r.CarbonCopy, ";"),
+		})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
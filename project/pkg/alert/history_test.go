@@ -0,0 +1,122 @@
+package alert
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/store"
+)
+
+func newHistory(t *testing.T) History {
+	s := &store.KVStore{Path: filepath.Join(t.TempDir(), "alert.db")}
+	if err := s.Connect(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	t.Cleanup(func() { s.Disconnect() })
+
+	h := History{Store: s}
+	if err := h.Init(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	return h
+}
+
+func TestHistoryQuery(t *testing.T) {
+	h := newHistory(t)
+
+	t0 := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	for _, r := range []Record{
+		{ProjectID: "3010000.01", Time: t0, Type: TypeOoq, UsagePercent: 91, Template: "ooq.txt", Recipients: []string{"alice@example.org"}},
+		{ProjectID: "3010000.01", Time: t0.AddDate(0, 0, 7), Type: TypeOot, Mode: "p4w", Template: "oot.txt", Recipients: []string{"alice@example.org", "bob@example.org"}},
+		{ProjectID: "3010000.02", Time: t0.AddDate(0, 0, 1), Type: TypeOoq, UsagePercent: 99, Digest: true, Recipients: []string{"Bob@example.org"}},
+		// same time, different type
+		{ProjectID: "3010000.02", Time: t0.AddDate(0, 0, 1), Type: TypeOot, Mode: "now", Digest: true, Recipients: []string{"bob@example.org"}},
+	} {
+		if err := h.Add(r); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	for name, c := range map[string]struct {
+		q      Query
+		expect int
+	}{
+		"all":       {Query{}, 4},
+		"project":   {Query{ProjectID: "3010000.01"}, 2},
+		"prefix":    {Query{ProjectID: "3010000.0"}, 0},
+		"type":      {Query{Type: TypeOoq}, 2},
+		"recipient": {Query{Recipient: "bob@example.org"}, 3},
+		"from":      {Query{From: t0.AddDate(0, 0, 1)}, 3},
+		"range":     {Query{From: t0, To: t0.AddDate(0, 0, 1)}, 1},
+		// range scans over the keys of a project
+		"project from":      {Query{ProjectID: "3010000.01", From: t0.Add(500 * time.Millisecond)}, 1},
+		"project to":        {Query{ProjectID: "3010000.01", To: t0.AddDate(0, 0, 7)}, 1},
+		"project subsecond": {Query{ProjectID: "3010000.01", From: t0, To: t0.Add(500 * time.Millisecond)}, 1},
+		"project local":     {Query{ProjectID: "3010000.02", From: t0.AddDate(0, 0, 1).In(time.FixedZone("CET", 3600))}, 2},
+	} {
+		records, err := h.Records(c.q)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if len(records) != c.expect {
+			t.Errorf("%s: expect %d records, got %d", name, c.expect, len(records))
+		}
+		for i := 1; i < len(records); i++ {
+			if records[i].Time.Before(records[i-1].Time) {
+				t.Errorf("%s: records not in chronological order", name)
+			}
+		}
+	}
+
+	records, _ := h.Records(Query{ProjectID: "3010000.01"})
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, records); err != nil {
+		t.Fatalf("%s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("unexpected csv:\n%s", buf.String())
+	}
+	if lines[1] != "3010000.01,2024-03-01T09:00:00Z,ooq,,91,ooq.txt,false,alice@example.org," {
+		t.Errorf("unexpected csv line: %s", lines[1])
+	}
+	if !strings.HasSuffix(lines[2], ",alice@example.org;bob@example.org,") {
+		t.Errorf("unexpected csv line: %s", lines[2])
+	}
+}
+
+func TestHistoryReset(t *testing.T) {
+	h := newHistory(t)
+
+	for _, b := range []string{BucketOoqLastAlerts, BucketOotLastAlerts} {
+		h.Store.Set(b, []byte("3010000.01"), []byte("{}"))
+		h.Store.Set(b, []byte("3010000.02"), []byte("{}"))
+	}
+
+	if err := h.Reset("3010000.01", TypeOot); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := h.Store.Get(BucketOotLastAlerts, []byte("3010000.01")); err == nil {
+		t.Errorf("oot last alert not reset")
+	}
+	if _, err := h.Store.Get(BucketOoqLastAlerts, []byte("3010000.01")); err != nil {
+		t.Errorf("unexpected reset of ooq last alert")
+	}
+
+	if err := h.Reset("3010000.02"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	for _, b := range []string{BucketOoqLastAlerts, BucketOotLastAlerts} {
+		if _, err := h.Store.Get(b, []byte("3010000.02")); err == nil {
+			t.Errorf("%s not reset", b)
+		}
+	}
+
+	if err := h.Reset("3010000.02", "eol"); err == nil {
+		t.Errorf("expect error on unknown type")
+	}
+}
@@ -0,0 +1,152 @@
+package alert
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/mailer"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
+)
+
+// Kinds of the changes of the project roles.
+const (
+	ChangeGranted = "granted"
+	ChangeChanged = "changed"
+	ChangeRevoked = "revoked"
+)
+
+// RoleChanges returns the changes of the roles of the project `pid` from the members
+// `before` to the members `after`, sorted by the member.  Only the manager, contributor
+// and viewer roles are considered.
+func RoleChanges(pid string, before, after []pdb.Member) []mailer.ProjectRoleChangeTemplateData {
+
+	roles := func(members []pdb.Member) map[string]string {
+		m := make(map[string]string)
+		for _, mb := range members {
+			switch mb.Role {
+			case RoleManager, RoleContributor, RoleViewer:
+				m[mb.UserID] = mb.Role
+			}
+		}
+		return m
+	}
+
+	old, cur := roles(before), roles(after)
+
+	changes := []mailer.ProjectRoleChangeTemplateData{}
+	for uid, r := range cur {
+		switch o, ok := old[uid]; {
+		case !ok:
+			changes = append(changes, mailer.ProjectRoleChangeTemplateData{ProjectID: pid, MemberID: uid, Change: ChangeGranted, NewRole: r})
+		case o != r:
+			changes = append(changes, mailer.ProjectRoleChangeTemplateData{ProjectID: pid, MemberID: uid, Change: ChangeChanged, OldRole: o, NewRole: r})
+		}
+	}
+	for uid, o := range old {
+		if _, ok := cur[uid]; !ok {
+			changes = append(changes, mailer.ProjectRoleChangeTemplateData{ProjectID: pid, MemberID: uid, Change: ChangeRevoked, OldRole: o})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].MemberID < changes[j].MemberID
+	})
+
+	return changes
+}
+
+// Membership collects the changes of the project roles applied in a run, so that the
+// members and the managers are notified with one message per recipient.  It is safe for
+// concurrent use.
+type Membership struct {
+	mutex    sync.Mutex
+	changes  map[string][]mailer.ProjectRoleChangeTemplateData
+	managers map[string][]string
+}
+
+// MembershipNotification is the notification of the role changes to a recipient.
+type MembershipNotification struct {
+	// Recipient is the user id of the recipient.
+	Recipient string
+	// Manager indicates whether the recipient is notified as the manager of the projects,
+	// or as the member of whom the roles are changed.
+	Manager bool
+	Changes []mailer.ProjectRoleChangeTemplateData
+}
+
+// NewMembership returns an empty `Membership`.
+func NewMembership() *Membership {
+	return &Membership{
+		changes:  make(map[string][]mailer.ProjectRoleChangeTemplateData),
+		managers: make(map[string][]string),
+	}
+}
+
+// Add adds the role `changes` of the project `pid` managed by the `managers`.
+func (m *Membership) Add(pid string, changes []mailer.ProjectRoleChangeTemplateData, managers []string) {
+
+	if len(changes) == 0 {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.changes[pid] = append(m.changes[pid], changes...)
+	m.managers[pid] = managers
+}
+
+// Notifications returns the notifications to the members of whom the roles are changed,
+// and to the managers of the projects, sorted by the recipient.  The notification to a
+// manager leaves out the changes of the manager's own roles, which are notified to the
+// manager as a member.
+func (m *Membership) Notifications() []MembershipNotification {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	members := make(map[string]*MembershipNotification)
+	managers := make(map[string]*MembershipNotification)
+
+	add := func(ns map[string]*MembershipNotification, uid string, manager bool, c mailer.ProjectRoleChangeTemplateData) {
+		n, ok := ns[uid]
+		if !ok {
+			n = &MembershipNotification{Recipient: uid, Manager: manager}
+			ns[uid] = n
+		}
+		n.Changes = append(n.Changes, c)
+	}
+
+	for pid, changes := range m.changes {
+		for _, c := range changes {
+			add(members, c.MemberID, false, c)
+			for _, mgr := range m.managers[pid] {
+				if mgr != c.MemberID {
+					add(managers, mgr, true, c)
+				}
+			}
+		}
+	}
+
+	notifications := make([]MembershipNotification, 0, len(members)+len(managers))
+	for _, ns := range []map[string]*MembershipNotification{members, managers} {
+		for _, n := range ns {
+			sort.SliceStable(n.Changes, func(i, j int) bool {
+				if n.Changes[i].ProjectID != n.Changes[j].ProjectID {
+					return n.Changes[i].ProjectID < n.Changes[j].ProjectID
+				}
+				return n.Changes[i].MemberID < n.Changes[j].MemberID
+			})
+			notifications = append(notifications, *n)
+		}
+	}
+
+	sort.Slice(notifications, func(i, j int) bool {
+		if notifications[i].Recipient != notifications[j].Recipient {
+			return notifications[i].Recipient < notifications[j].Recipient
+		}
+		return !notifications[i].Manager && notifications[j].Manager
+	})
+
+	return notifications
+}
@@ -0,0 +1,118 @@
+package alert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/mailer"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
+)
+
+const membershipTemplate = `
+Changes of your roles in {{len .Changes}} project(s)
+
+Dear {{.RecipientName}},
+{{range .Changes}}
+{{- if eq .Change "granted"}}
+  * {{.ProjectID}}: {{.NewRole}} role granted
+{{- else if eq .Change "changed"}}
+  * {{.ProjectID}}: role changed from {{.OldRole}} to {{.NewRole}}
+{{- else}}
+  * {{.ProjectID}}: {{.OldRole}} role revoked
+{{- end}}
+{{- end}}
+
+Best regards, {{.SenderName}}
+`
+
+func TestRoleChanges(t *testing.T) {
+
+	before := []pdb.Member{
+		{UserID: "honlee", Role: RoleManager},
+		{UserID: "edwger", Role: RoleContributor},
+		{UserID: "olduser", Role: RoleViewer},
+		{UserID: "tg", Role: "traverse"},
+	}
+	after := []pdb.Member{
+		{UserID: "honlee", Role: RoleManager},
+		{UserID: "edwger", Role: RoleViewer},
+		{UserID: "rendbru", Role: RoleContributor},
+	}
+
+	changes := RoleChanges("3010000.01", before, after)
+
+	expect := []mailer.ProjectRoleChangeTemplateData{
+		{ProjectID: "3010000.01", MemberID: "edwger", Change: ChangeChanged, OldRole: RoleContributor, NewRole: RoleViewer},
+		{ProjectID: "3010000.01", MemberID: "olduser", Change: ChangeRevoked, OldRole: RoleViewer},
+		{ProjectID: "3010000.01", MemberID: "rendbru", Change: ChangeGranted, NewRole: RoleContributor},
+	}
+
+	if len(changes) != len(expect) {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	for i, c := range changes {
+		if c != expect[i] {
+			t.Errorf("unexpected change: %+v, expect %+v", c, expect[i])
+		}
+	}
+}
+
+func TestMembershipNotifications(t *testing.T) {
+
+	m := NewMembership()
+
+	m.Add("3010000.01", RoleChanges("3010000.01",
+		[]pdb.Member{{UserID: "honlee", Role: RoleManager}, {UserID: "olduser", Role: RoleViewer}},
+		[]pdb.Member{{UserID: "honlee", Role: RoleManager}, {UserID: "edwger", Role: RoleContributor}},
+	), []string{"honlee"})
+
+	m.Add("3010000.02", RoleChanges("3010000.02",
+		nil,
+		[]pdb.Member{{UserID: "edwger", Role: RoleManager}, {UserID: "honlee", Role: RoleViewer}},
+	), []string{"edwger"})
+
+	// no change, no notification.
+	m.Add("3010000.03", nil, []string{"rendbru"})
+
+	ns := m.Notifications()
+
+	expect := []struct {
+		recipient string
+		manager   bool
+		changes   int
+	}{
+		{"edwger", false, 2},
+		{"edwger", true, 1},
+		{"honlee", false, 1},
+		{"honlee", true, 2},
+		{"olduser", false, 1},
+	}
+
+	if len(ns) != len(expect) {
+		t.Fatalf("unexpected notifications: %+v", ns)
+	}
+	for i, n := range ns {
+		if n.Recipient != expect[i].recipient || n.Manager != expect[i].manager || len(n.Changes) != expect[i].changes {
+			t.Errorf("unexpected notification: %+v", n)
+		}
+	}
+
+	data := mailer.ProjectMembershipTemplateData{
+		RecipientName: "Edward Gerrits",
+		SenderName:    "Helpdesk",
+		Changes:       ns[0].Changes,
+	}
+	subject, body, err := mailer.ComposeMessageFromTemplate(membershipTemplate, data)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if subject != "Changes of your roles in 2 project(s)" {
+		t.Errorf("unexpected subject: %s", subject)
+	}
+	for _, s := range []string{"Dear Edward Gerrits,", "3010000.01: contributor role granted", "3010000.02: manager role granted"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("%q not in body:\n%s", s, body)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Package alert implements the policies of the project alerts, i.e. which projects are
+// alerted, to whom, how often and with which template, as configured in the `alerts`
+// section of the configuration file.
+package alert
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/config"
+)
+
+// Roles of the project to which alerts can be sent.
+const (
+	RoleOwner       = "owner"
+	RoleManager     = "manager"
+	RoleContributor = "contributor"
+	RoleViewer      = "viewer"
+)
+
+// defaultTemplate is the template file used if no template is configured.
+const defaultTemplate = "template.txt"
+
+// defaultDigestTemplate is the template file of the alert digest used if no template is configured.
+const defaultDigestTemplate = "digest.txt"
+
+// Template files of the membership notifications used if no template is configured.
+const (
+	defaultMembershipTemplate        = "membership.txt"
+	defaultMembershipManagerTemplate = "membership_manager.txt"
+)
+
+// Default returns the alert policies applied to the settings missing in the configuration.
+func Default() config.AlertsConfiguration {
+	return config.AlertsConfiguration{
+		Sender:      "DCCN TG Helpdesk",
+		SenderEmail: "helpdesk@donders.ru.nl",
+		Ooq: config.OoqAlertConfiguration{
+			Recipients: []string{RoleOwner, RoleManager, RoleContributor},
+			Template:   defaultTemplate,
+			Bands: []config.OoqAlertBand{
+				{Threshold: 90, Interval: 14 * 24 * time.Hour},
+				{Threshold: 95, Interval: 7 * 24 * time.Hour},
+				{Threshold: 99, Interval: 2 * 24 * time.Hour},
+			},
+		},
+		Oot: config.OotAlertConfiguration{
+			Recipients: []string{RoleOwner, RoleManager, RoleContributor},
+			CarbonCopy: []string{"rene.debruin@donders.ru.nl"},
+			Template:   defaultTemplate,
+			Modes: []config.OotAlertMode{
+				{Name: "p4w", Days: 28},
+				{Name: "p2w", Days: 14},
+				{Name: "p1w", Days: 7},
+				{Name: "now", Days: 0},
+				{Name: "g2m", Days: -60, Months: -2},
+			},
+		},
+		Digest: config.DigestAlertConfiguration{
+			Template: defaultDigestTemplate,
+		},
+		Membership: config.MembershipNotificationConfiguration{
+			Template:        defaultMembershipTemplate,
+			ManagerTemplate: defaultMembershipManagerTemplate,
+		},
+	}
+}
+
+// Policy is the validated alert policies.
+type Policy struct {
+	config.AlertsConfiguration
+}
+
+// NewPolicy returns the `Policy` of the configuration `c`, with the settings missing in
+// `c` taken from `Default`.  An error is returned if the resulting policy is invalid.
+func NewPolicy(c config.AlertsConfiguration) (*Policy, error) {
+
+	d := Default()
+
+	if c.Sender == "" {
+		c.Sender = d.Sender
+	}
+	if c.SenderEmail == "" {
+		c.SenderEmail = d.SenderEmail
+	}
+
+	if len(c.Ooq.Recipients) == 0 {
+		c.Ooq.Recipients = d.Ooq.Recipients
+	}
+	if c.Ooq.Template == "" {
+		c.Ooq.Template = d.Ooq.Template
+	}
+	if len(c.Ooq.Bands) == 0 {
+		c.Ooq.Bands = d.Ooq.Bands
+	}
+
+	if len(c.Oot.Recipients) == 0 {
+		c.Oot.Recipients = d.Oot.Recipients
+	}
+	if c.Oot.CarbonCopy == nil {
+		c.Oot.CarbonCopy = d.Oot.CarbonCopy
+	}
+	if c.Oot.Template == "" {
+		c.Oot.Template = d.Oot.Template
+	}
+	if len(c.Oot.Modes) == 0 {
+		c.Oot.Modes = d.Oot.Modes
+	}
+
+	if c.Digest.Template == "" {
+		c.Digest.Template = d.Digest.Template
+	}
+
+	if c.Membership.Template == "" {
+		c.Membership.Template = d.Membership.Template
+	}
+	if c.Membership.ManagerTemplate == "" {
+		c.Membership.ManagerTemplate = d.Membership.ManagerTemplate
+	}
+
+	// bands are sorted by the threshold for the band lookup.
+	bands := append([]config.OoqAlertBand{}, c.Ooq.Bands...)
+	sort.Slice(bands, func(i, j int) bool {
+		return bands[i].Threshold < bands[j].Threshold
+	})
+	c.Ooq.Bands = bands
+	c.Oot.Modes = append([]config.OotAlertMode{}, c.Oot.Modes...)
+
+	if err := Validate(c); err != nil {
+		return nil, err
+	}
+
+	return &Policy{c}, nil
+}
+
+// Validate checks the alert policies in the configuration `c`.
+func Validate(c config.AlertsConfiguration) error {
+
+	if !strings.Contains(c.SenderEmail, "@") {
+		return fmt.Errorf("invalid alert sender email: %q", c.SenderEmail)
+	}
+
+	if err := validateRecipients("ooq", c.Ooq.Recipients); err != nil {
+		return err
+	}
+	if err := validateRecipients("oot", c.Oot.Recipients); err != nil {
+		return err
+	}
+
+	thresholds := make(map[int]bool)
+	for _, b := range c.Ooq.Bands {
+		if b.Threshold <= 0 {
+			return fmt.Errorf("invalid ooq alert threshold: %d", b.Threshold)
+		}
+		if thresholds[b.Threshold] {
+			return fmt.Errorf("duplicated ooq alert threshold: %d", b.Threshold)
+		}
+		thresholds[b.Threshold] = true
+
+		if b.Interval <= 0 {
+			return fmt.Errorf("invalid ooq alert interval of threshold %d: %s", b.Threshold, b.Interval)
+		}
+		if b.Template == "" && c.Ooq.Template == "" {
+			return fmt.Errorf("no ooq alert template for threshold %d", b.Threshold)
+		}
+	}
+
+	modes := make(map[string]bool)
+	for _, m := range c.Oot.Modes {
+		if m.Name == "" {
+			return fmt.Errorf("oot alert mode without name")
+		}
+		if modes[m.Name] {
+			return fmt.Errorf("duplicated oot alert mode: %s", m.Name)
+		}
+		modes[m.Name] = true
+
+		if m.Template == "" && c.Oot.Template == "" {
+			return fmt.Errorf("no oot alert template for mode %s", m.Name)
+		}
+	}
+
+	return nil
+}
+
+// validateRecipients checks whether the `roles` are valid recipient roles of the alerts
+// of `kind`.
+func validateRecipients(kind string, roles []string) error {
+	for _, r := range roles {
+		switch r {
+		case RoleOwner, RoleManager, RoleContributor, RoleViewer:
+		default:
+			return fmt.Errorf("invalid %s alert recipient role: %q", kind, r)
+		}
+	}
+	return nil
+}
+
+// OoqBand returns the band of the storage usage `usage` in percent.  It returns false if the
+// usage is below the lowest threshold.
+func (p Policy) OoqBand(usage int) (config.OoqAlertBand, bool) {
+	for i := len(p.Ooq.Bands) - 1; i >= 0; i-- {
+		if usage >= p.Ooq.Bands[i].Threshold {
+			return p.Ooq.Bands[i], true
+		}
+	}
+	return config.OoqAlertBand{}, false
+}
+
+// OoqTemplate returns the template file of the band `b`.
+func (p Policy) OoqTemplate(b config.OoqAlertBand) string {
+	if b.Template != "" {
+		return b.Template
+	}
+	return p.Ooq.Template
+}
+
+// OotMode returns the alert mode of the given `name`.
+func (p Policy) OotMode(name string) (config.OotAlertMode, bool) {
+	for _, m := range p.Oot.Modes {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return config.OotAlertMode{}, false
+}
+
+// OotModeNames returns the names of the configured alert modes.
+func (p Policy) OotModeNames() []string {
+	names := make([]string, len(p.Oot.Modes))
+	for i, m := range p.Oot.Modes {
+		names[i] = m.Name
+	}
+	return names
+}
+
+// OotTemplate returns the template file of the mode `m`.
+func (p Policy) OotTemplate(m config.OotAlertMode) string {
+	if m.Template != "" {
+		return m.Template
+	}
+	return p.Oot.Template
+}
+
+// OotDate returns the project end date selected by the mode `m` on the day of `now`.
+func OotDate(m config.OotAlertMode, now time.Time) time.Time {
+	return now.AddDate(0, 0, m.Days)
+}
+
+// IsRecipient checks whether the project `role` is in the recipient `roles`.
+func IsRecipient(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package alert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/config"
+)
+
+func TestDefaultPolicy(t *testing.T) {
+	p, err := NewPolicy(config.AlertsConfiguration{})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// the default bands are the former hard-coded alert frequencies.
+	for usage, expect := range map[int]time.Duration{
+		89:  0,
+		90:  14 * 24 * time.Hour,
+		94:  14 * 24 * time.Hour,
+		95:  7 * 24 * time.Hour,
+		99:  2 * 24 * time.Hour,
+		120: 2 * 24 * time.Hour,
+	} {
+		b, ok := p.OoqBand(usage)
+		if ok != (expect > 0) || b.Interval != expect {
+			t.Errorf("usage %d: unexpected band %+v (%t)", usage, b, ok)
+		}
+	}
+
+	now := time.Date(2024, 1, 31, 9, 0, 0, 0, time.Local)
+	m, ok := p.OotMode("g2m")
+	if !ok || m.Months != -2 || OotDate(m, now).Format("2006-01-02") != "2023-12-02" {
+		t.Errorf("unexpected g2m mode: %+v", m)
+	}
+	if _, ok := p.OotMode("p3w"); ok {
+		t.Errorf("unexpected mode p3w")
+	}
+
+	if p.SenderEmail != "helpdesk@donders.ru.nl" || p.OotTemplate(m) != "template.txt" {
+		t.Errorf("unexpected defaults: %+v", p)
+	}
+}
+
+func TestPolicyFromConfig(t *testing.T) {
+
+	c := config.AlertsConfiguration{
+		SenderEmail: "alerts@example.org",
+		Ooq: config.OoqAlertConfiguration{
+			Recipients: []string{RoleManager},
+			Template:   "ooq.txt",
+			Bands: []config.OoqAlertBand{
+				{Threshold: 98, Interval: time.Hour, Template: "ooq-critical.txt"},
+				{Threshold: 80, Interval: 24 * time.Hour},
+			},
+		},
+	}
+
+	p, err := NewPolicy(c)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if b, ok := p.OoqBand(85); !ok || b.Threshold != 80 || p.OoqTemplate(b) != "ooq.txt" {
+		t.Errorf("unexpected band: %+v", b)
+	}
+	if b, ok := p.OoqBand(99); !ok || b.Threshold != 98 || p.OoqTemplate(b) != "ooq-critical.txt" {
+		t.Errorf("unexpected band: %+v", b)
+	}
+
+	if !IsRecipient(p.Ooq.Recipients, RoleManager) || IsRecipient(p.Ooq.Recipients, RoleOwner) {
+		t.Errorf("unexpected ooq recipients: %+v", p.Ooq.Recipients)
+	}
+
+	// unset oot section falls back to the defaults.
+	if len(p.OotModeNames()) != 5 || p.Sender != "DCCN TG Helpdesk" {
+		t.Errorf("unexpected oot defaults: %+v", p.Oot)
+	}
+}
+
+func TestPolicyValidation(t *testing.T) {
+
+	for name, c := range map[string]config.AlertsConfiguration{
+		"sender": {SenderEmail: "helpdesk"},
+		"role": {
+			Ooq: config.OoqAlertConfiguration{Recipients: []string{"pi"}},
+		},
+		"threshold": {
+			Ooq: config.OoqAlertConfiguration{Bands: []config.OoqAlertBand{{Threshold: 0, Interval: time.Hour}}},
+		},
+		"duplicated threshold": {
+			Ooq: config.OoqAlertConfiguration{Bands: []config.OoqAlertBand{
+				{Threshold: 90, Interval: time.Hour},
+				{Threshold: 90, Interval: time.Minute},
+			}},
+		},
+		"interval": {
+			Ooq: config.OoqAlertConfiguration{Bands: []config.OoqAlertBand{{Threshold: 90}}},
+		},
+		"mode name": {
+			Oot: config.OotAlertConfiguration{Modes: []config.OotAlertMode{{Days: 7}}},
+		},
+		"duplicated mode": {
+			Oot: config.OotAlertConfiguration{Modes: []config.OotAlertMode{{Name: "p1w", Days: 7}, {Name: "p1w", Days: 8}}},
+		},
+	} {
+		if _, err := NewPolicy(c); err == nil {
+			t.Errorf("%s: expect validation error", name)
+		}
+	}
+}
+
+func TestPolicyFromConfigFile(t *testing.T) {
+
+	cfile := filepath.Join(t.TempDir(), "config.yml")
+	os.WriteFile(cfile, []byte(`
+alerts:
+  sender: "Helpdesk"
+  sender_email: "helpdesk@example.org"
+  ooq:
+    recipients: [owner, manager]
+    template: ooq.txt
+    bands:
+      - threshold: 90
+        interval: 336h
+      - threshold: 99
+        interval: 12h
+        template: ooq-full.txt
+  oot:
+    cc: []
+    template: oot.txt
+    modes:
+      - name: p4w
+        days: 28
+      - name: g2m
+        days: -60
+        months: -2
+        template: eog.txt
+`), 0644)
+
+	conf, err := config.LoadConfig(cfile)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	p, err := NewPolicy(conf.Alerts)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if b, ok := p.OoqBand(99); !ok || b.Interval != 12*time.Hour || p.OoqTemplate(b) != "ooq-full.txt" {
+		t.Errorf("unexpected band: %+v", b)
+	}
+
+	if m, ok := p.OotMode("g2m"); !ok || p.OotTemplate(m) != "eog.txt" || m.Months != -2 {
+		t.Errorf("unexpected mode: %+v", m)
+	}
+
+	if len(p.Oot.CarbonCopy) != 0 || len(p.Oot.Recipients) != 3 {
+		t.Errorf("unexpected oot policy: %+v", p.Oot)
+	}
+}
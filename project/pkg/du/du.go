@@ -0,0 +1,296 @@
+// Package du provides functions for summarizing the disk usage of a project
+// storage by file owner and by top-level sub-directory.
+package du
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	ufp "github.com/dccn-tg/tg-toolset-golang/pkg/filepath"
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+)
+
+// AgeBin defines a bin of the file-age histogram.  A file falls into the bin
+// if its age (i.e. time since the last modification) is less than `MaxAge`.
+// A bin with `MaxAge` of 0 collects all files older than the previous bin.
+type AgeBin struct {
+	Label  string
+	MaxAge time.Duration
+}
+
+// AgeBins is the default set of bins for the file-age histogram.
+var AgeBins = []AgeBin{
+	{Label: "<1m", MaxAge: 30 * 24 * time.Hour},
+	{Label: "1m-6m", MaxAge: 182 * 24 * time.Hour},
+	{Label: "6m-1y", MaxAge: 365 * 24 * time.Hour},
+	{Label: "1y-3y", MaxAge: 3 * 365 * 24 * time.Hour},
+	{Label: ">3y", MaxAge: 0},
+}
+
+// rootDirKey is the key of `Usage.Subdirs` for files located directly in the
+// top-level directory of the scan.
+const rootDirKey = "."
+
+// Stat holds the aggregated usage of a set of files.
+type Stat struct {
+	// Size is the total size of the files in bytes.
+	Size int64 `json:"size"`
+	// Files is the number of regular files.
+	Files int64 `json:"files"`
+	// AgeSize is the total file size in bytes per age bin.
+	AgeSize map[string]int64 `json:"ageSize"`
+	// AgeFiles is the number of files per age bin.
+	AgeFiles map[string]int64 `json:"ageFiles"`
+}
+
+// newStat initializes a `Stat` with all age bins set to zero.
+func newStat() *Stat {
+	s := &Stat{
+		AgeSize:  make(map[string]int64, len(AgeBins)),
+		AgeFiles: make(map[string]int64, len(AgeBins)),
+	}
+	for _, b := range AgeBins {
+		s.AgeSize[b.Label] = 0
+		s.AgeFiles[b.Label] = 0
+	}
+	return s
+}
+
+// add accounts a file with `size` in the age bin `bin`.
+func (s *Stat) add(size int64, bin string) {
+	s.Size += size
+	s.Files++
+	s.AgeSize[bin] += size
+	s.AgeFiles[bin]++
+}
+
+// merge adds up the usage of `o` into `s`.
+func (s *Stat) merge(o *Stat) {
+	s.Size += o.Size
+	s.Files += o.Files
+	for k, v := range o.AgeSize {
+		s.AgeSize[k] += v
+	}
+	for k, v := range o.AgeFiles {
+		s.AgeFiles[k] += v
+	}
+}
+
+// Usage is the result of a disk-usage scan.
+type Usage struct {
+	// Path is the top-level directory of the scan.
+	Path string `json:"path"`
+	// ScanTime is the moment the scan was started.  File ages are calculated
+	// against it.
+	ScanTime time.Time `json:"scanTime"`
+	// Dirs is the number of visited directories.
+	Dirs int64 `json:"dirs"`
+	// Total is the overall usage of the scanned tree.
+	Total *Stat `json:"total"`
+	// Owners is the usage per file owner.  The key is the username, or the
+	// numerical uid if the owner cannot be resolved.
+	Owners map[string]*Stat `json:"owners"`
+	// Subdirs is the usage per top-level sub-directory.  Files directly in
+	// the scanned directory are accounted under the key ".".
+	Subdirs map[string]*Stat `json:"subdirs"`
+}
+
+// newUsage initializes an empty `Usage` for `path`.
+func newUsage(path string, t time.Time) *Usage {
+	return &Usage{
+		Path:     path,
+		ScanTime: t,
+		Total:    newStat(),
+		Owners:   make(map[string]*Stat),
+		Subdirs:  make(map[string]*Stat),
+	}
+}
+
+// merge adds up the usage of `o` into `u`.
+func (u *Usage) merge(o *Usage) {
+	u.Dirs += o.Dirs
+	u.Total.merge(o.Total)
+	for k, v := range o.Owners {
+		if _, ok := u.Owners[k]; !ok {
+			u.Owners[k] = newStat()
+		}
+		u.Owners[k].merge(v)
+	}
+	for k, v := range o.Subdirs {
+		if _, ok := u.Subdirs[k]; !ok {
+			u.Subdirs[k] = newStat()
+		}
+		u.Subdirs[k].merge(v)
+	}
+}
+
+// SortedOwners returns the keys of `Owners` sorted by size in descending order.
+func (u *Usage) SortedOwners() []string {
+	return sortBySize(u.Owners)
+}
+
+// SortedSubdirs returns the keys of `Subdirs` sorted by size in descending order.
+func (u *Usage) SortedSubdirs() []string {
+	return sortBySize(u.Subdirs)
+}
+
+// sortBySize returns the keys of `m` sorted by `Stat.Size` in descending order.
+func sortBySize(m map[string]*Stat) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if m[keys[i]].Size == m[keys[j]].Size {
+			return keys[i] < keys[j]
+		}
+		return m[keys[i]].Size > m[keys[j]].Size
+	})
+	return keys
+}
+
+// ageBin resolves the label of the age bin for a file with modification time `mtime`.
+func ageBin(now, mtime time.Time) string {
+	age := now.Sub(mtime)
+	for _, b := range AgeBins {
+		if b.MaxAge == 0 || age < b.MaxAge {
+			return b.Label
+		}
+	}
+	return AgeBins[len(AgeBins)-1].Label
+}
+
+// ownerResolver resolves numerical uids into usernames, with an internal cache
+// to avoid repeated lookups in the passwd database.
+type ownerResolver struct {
+	cache sync.Map
+}
+
+// name returns the username of `uid`, or the uid in string if the user cannot be found.
+func (r *ownerResolver) name(uid uint32) string {
+	if n, ok := r.cache.Load(uid); ok {
+		return n.(string)
+	}
+	n := strconv.FormatUint(uint64(uid), 10)
+	if u, err := user.LookupId(n); err == nil {
+		n = u.Username
+	}
+	r.cache.Store(uid, n)
+	return n
+}
+
+// Scan walks through the directory `root` using `nthreads` concurrent workers, and
+// aggregates size, file count and file-age histograms by owner and by top-level
+// sub-directory.
+//
+// Symbolic links are not followed; only regular files are accounted for the size.
+func Scan(root string, nthreads int) (*Usage, error) {
+
+	if nthreads < 1 {
+		nthreads = 1
+	}
+
+	// resolve any symlinks on root to the actual path the scan should work on.
+	ppath, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, fmt.Errorf("path not found or unaccessible: %s", root)
+	}
+
+	fpinfo, err := ufp.GetFilePathMode(ppath)
+	if err != nil {
+		return nil, fmt.Errorf("path not found or unaccessible: %s", root)
+	}
+
+	if !fpinfo.Mode.IsDir() {
+		return nil, fmt.Errorf("not a directory: %s", root)
+	}
+
+	ppath = filepath.Clean(ppath)
+
+	now := time.Now()
+	owners := &ownerResolver{}
+
+	chanF := ufp.GoFastWalk(ppath, false, false, nthreads*4)
+
+	// each worker aggregates into its own partial result; the results are merged
+	// afterwards to avoid lock contention.
+	partials := make([]*Usage, nthreads)
+
+	var wg sync.WaitGroup
+	wg.Add(nthreads)
+	for i := 0; i < nthreads; i++ {
+		partials[i] = newUsage(ppath, now)
+		go func(u *Usage) {
+			defer wg.Done()
+			for f := range chanF {
+
+				if f.Mode.IsDir() {
+					u.Dirs++
+					continue
+				}
+
+				fi, err := os.Lstat(f.Path)
+				if err != nil {
+					log.Warnf("cannot stat file %s: %s", f.Path, err)
+					continue
+				}
+
+				if !fi.Mode().IsRegular() {
+					log.Debugf("skip non-regular file: %s", f.Path)
+					continue
+				}
+
+				owner := "unknown"
+				if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+					owner = owners.name(st.Uid)
+				}
+
+				bin := ageBin(now, fi.ModTime())
+				size := fi.Size()
+
+				u.Total.add(size, bin)
+
+				if _, ok := u.Owners[owner]; !ok {
+					u.Owners[owner] = newStat()
+				}
+				u.Owners[owner].add(size, bin)
+
+				subdir := topLevelDir(ppath, f.Path)
+				if _, ok := u.Subdirs[subdir]; !ok {
+					u.Subdirs[subdir] = newStat()
+				}
+				u.Subdirs[subdir].add(size, bin)
+			}
+		}(partials[i])
+	}
+	wg.Wait()
+
+	usage := newUsage(ppath, now)
+	for _, p := range partials {
+		usage.merge(p)
+	}
+
+	return usage, nil
+}
+
+// topLevelDir returns the name of the first path element of `p` relative to `root`.
+// It returns "." if `p` is located directly in `root`.
+func topLevelDir(root, p string) string {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return rootDirKey
+	}
+	elems := strings.SplitN(rel, string(os.PathSeparator), 2)
+	if len(elems) < 2 {
+		return rootDirKey
+	}
+	return elems[0]
+}
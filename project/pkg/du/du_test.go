@@ -0,0 +1,93 @@
+package du
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScan(t *testing.T) {
+
+	root := t.TempDir()
+
+	old := time.Now().Add(-400 * 24 * time.Hour)
+
+	files := map[string]int{
+		"a.txt":         10,
+		"raw/b.dat":     100,
+		"raw/sub/c.dat": 1000,
+		"code/d.m":      5,
+	}
+
+	for p, size := range files {
+		fp := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(fp), 0755); err != nil {
+			t.Fatalf("%s", err)
+		}
+		if err := os.WriteFile(fp, make([]byte, size), 0644); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	// make raw/sub/c.dat older than 1 year
+	if err := os.Chtimes(filepath.Join(root, "raw/sub/c.dat"), old, old); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	usage, err := Scan(root, 4)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	t.Logf("total: %+v", usage.Total)
+
+	if usage.Total.Size != 1115 || usage.Total.Files != 4 {
+		t.Errorf("unexpected total: %+v", usage.Total)
+	}
+
+	// root, raw, raw/sub, code
+	if usage.Dirs != 4 {
+		t.Errorf("unexpected number of directories: %d", usage.Dirs)
+	}
+
+	expected := map[string]int64{
+		".":    10,
+		"raw":  1100,
+		"code": 5,
+	}
+	for d, size := range expected {
+		s, ok := usage.Subdirs[d]
+		if !ok {
+			t.Errorf("missing sub-directory: %s", d)
+			continue
+		}
+		if s.Size != size {
+			t.Errorf("unexpected size of %s: %d != %d", d, s.Size, size)
+		}
+	}
+
+	if sorted := usage.SortedSubdirs(); sorted[0] != "raw" {
+		t.Errorf("unexpected sorting of sub-directories: %+v", sorted)
+	}
+
+	if usage.Total.AgeSize["1y-3y"] != 1000 || usage.Total.AgeFiles["<1m"] != 3 {
+		t.Errorf("unexpected age histogram: %+v %+v", usage.Total.AgeSize, usage.Total.AgeFiles)
+	}
+
+	me, _ := user.Current()
+	if s, ok := usage.Owners[me.Username]; !ok || s.Size != 1115 {
+		t.Errorf("unexpected owners: %+v", usage.Owners)
+	}
+}
+
+func TestScanNotDirectory(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(f, []byte("x"), 0644); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := Scan(f, 1); err == nil {
+		t.Errorf("expect error on scanning a file")
+	}
+}
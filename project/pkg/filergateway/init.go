@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -67,6 +68,13 @@ func (e *ServiceError) Error() string {
 	return fmt.Sprintf("filer-gateway error: %s (ec:%d)", e.ErrorMessage, e.ExitCode)
 }
 
+// IsNotFound checks whether the error `err` returned by `GetProject` is due to the
+// project storage not being found on the filer-gateway.
+func IsNotFound(err error) bool {
+	var e *fgwops.GetProjectsIDNotFound
+	return errors.As(err, &e)
+}
+
 // Client implements client interfaces of the FilerGateway.
 type Client struct {
 	apiKey  string
@@ -0,0 +1,57 @@
+// Package identity provides resolvers for looking up the identity of users from
+// various identity sources, e.g. the local passwd database or the LDAP server.  It is
+// used to validate users before they are granted with project roles.
+//
+// The resolver using the project database is provided by the `pdb` package, so that
+// this package can be used by the capability-enabled programs without the project
+// database clients.
+package identity
+
+import (
+	"fmt"
+	"os/user"
+)
+
+// Identity is the identity of a user returned by a `Resolver`.
+type Identity struct {
+	// Username is the system username of the user.
+	Username string
+	// Active indicates whether the user is active, i.e. allowed to be granted with roles.
+	Active bool
+}
+
+// Resolver defines the interface for resolving the identity of a user.
+type Resolver interface {
+	// LookupUser returns the identity of the user `username`.  An error is returned if
+	// the user is not found in the identity source.
+	LookupUser(username string) (*Identity, error)
+}
+
+// Validate checks the given `users` against the resolver `r`.  An error is returned for
+// the first user that is unknown or inactive.
+func Validate(r Resolver, users []string) error {
+	for _, u := range users {
+		id, err := r.LookupUser(u)
+		if err != nil {
+			return fmt.Errorf("invalid user %s: %s", u, err)
+		}
+		if !id.Active {
+			return fmt.Errorf("inactive user: %s", u)
+		}
+	}
+	return nil
+}
+
+// Passwd implements the `Resolver` interface using the local passwd database (or any
+// source configured in the name service switch of the system).  Users found in the
+// database are considered active.
+type Passwd struct{}
+
+// LookupUser implements the `Resolver` interface.
+func (Passwd) LookupUser(username string) (*Identity, error) {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{Username: u.Username, Active: true}, nil
+}
@@ -0,0 +1,138 @@
+package identity
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/config"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// defaultUserFilter is the LDAP search filter for the user entry if it is not
+// specified in the configuration.
+const defaultUserFilter = "(uid=%s)"
+
+// LDAP implements the `Resolver` interface using a LDAP server.  Users are searched
+// under the `BaseDN` with the `UserFilter`; a user is inactive if the entry has the
+// `InactiveAttribute` with the `InactiveValue`.
+//
+// The connection to the LDAP server is made on the first lookup and reused by the
+// subsequent lookups; it is re-established if it is lost.  Call `Close` to release it.
+type LDAP struct {
+	Config config.LDAPConfiguration
+
+	conn *ldap.Conn
+	mux  sync.Mutex
+}
+
+// LookupUser implements the `Resolver` interface.
+func (r *LDAP) LookupUser(username string) (*Identity, error) {
+
+	filter := r.Config.UserFilter
+	if filter == "" {
+		filter = defaultUserFilter
+	}
+
+	attrs := []string{"uid"}
+	if r.Config.InactiveAttribute != "" {
+		attrs = append(attrs, r.Config.InactiveAttribute)
+	}
+
+	req := ldap.NewSearchRequest(
+		r.Config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		fmt.Sprintf(filter, ldap.EscapeFilter(username)),
+		attrs,
+		nil,
+	)
+
+	res, err := r.search(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(res.Entries) {
+	case 0:
+		return nil, fmt.Errorf("user not found in LDAP: %s", username)
+	case 1:
+	default:
+		return nil, fmt.Errorf("ambiguous LDAP entries for user: %s", username)
+	}
+
+	e := res.Entries[0]
+
+	active := true
+	if r.Config.InactiveAttribute != "" {
+		for _, v := range e.GetAttributeValues(r.Config.InactiveAttribute) {
+			if strings.EqualFold(v, r.Config.InactiveValue) {
+				active = false
+			}
+		}
+	}
+
+	return &Identity{Username: username, Active: active}, nil
+}
+
+// Close closes the connection to the LDAP server.
+func (r *LDAP) Close() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+// search performs the search request `req` on the shared connection.  The search is
+// retried once on a new connection if the existing one is lost.
+func (r *LDAP) search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	reused := r.conn != nil && !r.conn.IsClosing()
+	if !reused {
+		if err := r.connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := r.conn.Search(req)
+	if err != nil && reused && ldap.IsErrorWithCode(err, ldap.ErrorNetwork) {
+		if err := r.connect(); err != nil {
+			return nil, err
+		}
+		res, err = r.conn.Search(req)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("LDAP search failure: %s", err)
+	}
+	return res, nil
+}
+
+// connect (re-)establishes the connection to the LDAP server, and binds it with the
+// `BindDN` if it is configured.
+func (r *LDAP) connect() error {
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+
+	conn, err := ldap.DialURL(r.Config.URL)
+	if err != nil {
+		return fmt.Errorf("cannot connect LDAP server: %s", err)
+	}
+
+	if r.Config.BindDN != "" {
+		if err := conn.Bind(r.Config.BindDN, r.Config.BindPassword); err != nil {
+			conn.Close()
+			return fmt.Errorf("cannot bind LDAP server: %s", err)
+		}
+	}
+
+	r.conn = conn
+	return nil
+}
@@ -0,0 +1,174 @@
+package identity
+
+import (
+	"net"
+	"regexp"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/config"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// fakeLDAPServer is a minimal in-process LDAP server serving the bind and the search
+// operations on a static set of user entries keyed by the `uid`.
+type fakeLDAPServer struct {
+	listener net.Listener
+	users    map[string]map[string]string
+	// conns is the number of accepted connections.
+	conns int32
+}
+
+var reUIDFilter = regexp.MustCompile(`\(uid=([^)]+)\)`)
+
+func newFakeLDAPServer(t *testing.T, users map[string]map[string]string) *fakeLDAPServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	s := &fakeLDAPServer{listener: l, users: users}
+	go s.serve()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *fakeLDAPServer) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *fakeLDAPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		atomic.AddInt32(&s.conns, 1)
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeLDAPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		p, err := ber.ReadPacket(conn)
+		if err != nil || len(p.Children) < 2 {
+			return
+		}
+		id := p.Children[0].Value.(int64)
+		op := p.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			conn.Write(response(id, ldap.ApplicationBindResponse).Bytes())
+		case ldap.ApplicationSearchRequest:
+			filter, _ := ldap.DecompileFilter(op.Children[6])
+			if m := reUIDFilter.FindStringSubmatch(filter); m != nil {
+				if attrs, ok := s.users[m[1]]; ok {
+					conn.Write(entry(id, "uid="+m[1]+",ou=people,dc=dccn,dc=nl", attrs).Bytes())
+				}
+			}
+			conn.Write(response(id, ldap.ApplicationSearchResultDone).Bytes())
+		default:
+			return
+		}
+	}
+}
+
+// message wraps the protocol operation `op` into a LDAP message with the message `id`.
+func message(id int64, op *ber.Packet) *ber.Packet {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	p.AppendChild(op)
+	return p
+}
+
+// response returns a successful LDAP result of the application `tag`.
+func response(id int64, tag ber.Tag) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, 0, "resultCode"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	return message(id, op)
+}
+
+// entry returns a search result entry with the `dn` and the `attrs`.
+func entry(id int64, dn string, attrs map[string]string) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "objectName"))
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for k, v := range attrs {
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, k, "type"))
+		vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "value"))
+		attr.AppendChild(vals)
+		list.AppendChild(attr)
+	}
+	op.AppendChild(list)
+	return message(id, op)
+}
+
+func TestLDAPLookupUser(t *testing.T) {
+
+	s := newFakeLDAPServer(t, map[string]map[string]string{
+		"honlee": {"uid": "honlee", "loginShell": "/bin/bash"},
+		"edwger": {"uid": "edwger", "loginShell": "/sbin/nologin"},
+	})
+
+	r := &LDAP{
+		Config: config.LDAPConfiguration{
+			URL:               s.url(),
+			BindDN:            "cn=reader,dc=dccn,dc=nl",
+			BindPassword:      "secret",
+			BaseDN:            "ou=people,dc=dccn,dc=nl",
+			UserFilter:        "(&(objectClass=posixAccount)(uid=%s))",
+			InactiveAttribute: "loginShell",
+			InactiveValue:     "/sbin/nologin",
+		},
+	}
+
+	id, err := r.LookupUser("honlee")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !id.Active {
+		t.Errorf("expect honlee to be active")
+	}
+
+	id, err = r.LookupUser("edwger")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if id.Active {
+		t.Errorf("expect edwger to be inactive")
+	}
+
+	if _, err := r.LookupUser("nobody"); err == nil {
+		t.Errorf("expect error on unknown user")
+	}
+
+	if err := Validate(r, []string{"honlee", "edwger"}); err == nil {
+		t.Errorf("expect validation error on inactive user")
+	}
+
+	if err := Validate(r, []string{"honlee"}); err != nil {
+		t.Errorf("unexpected validation error: %s", err)
+	}
+
+	// all lookups share one connection.
+	if n := atomic.LoadInt32(&s.conns); n != 1 {
+		t.Errorf("unexpected number of LDAP connections: %d", n)
+	}
+
+	// the connection is re-established after it is closed.
+	if err := r.Close(); err != nil {
+		t.Errorf("%s", err)
+	}
+	if _, err := r.LookupUser("honlee"); err != nil {
+		t.Errorf("%s", err)
+	}
+	if n := atomic.LoadInt32(&s.conns); n != 2 {
+		t.Errorf("unexpected number of LDAP connections: %d", n)
+	}
+}
@@ -0,0 +1,289 @@
+// Package journal implements a local journal of the pending project actions retrieved
+// from the project database.
+//
+// The journal bookkeeps the attempts of executing the pending action of each project.
+// A failed action is retried with an exponential backoff until the maximum number of
+// attempts is reached; the action is then moved into the dead-letter state, and is not
+// retried until it is reset.  Every attempt is recorded in the history.
+package journal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/store"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
+)
+
+// Status is the status of a pending action in the journal.
+type Status string
+
+const (
+	// StatusPending refers to an action not yet attempted.
+	StatusPending Status = "pending"
+	// StatusFailed refers to an action of which the last attempt failed; it will be retried.
+	StatusFailed Status = "failed"
+	// StatusDead refers to an action that failed too many times; it is not retried.
+	StatusDead Status = "dead"
+	// StatusApplied refers to an action applied successfully.
+	StatusApplied Status = "applied"
+)
+
+const (
+	// bucketEntries is the bucket of the store in which the entries of actions in process are kept.
+	bucketEntries = "actions"
+	// bucketHistory is the bucket of the store in which the attempts are recorded.
+	bucketHistory = "history"
+)
+
+// Entry is the journal entry of the pending action of a project.
+type Entry struct {
+	ProjectID   string                 `json:"projectID"`
+	Action      *pdb.DataProjectUpdate `json:"action"`
+	Digest      string                 `json:"digest"`
+	Status      Status                 `json:"status"`
+	Attempts    int                    `json:"attempts"`
+	LastError   string                 `json:"lastError,omitempty"`
+	Created     time.Time              `json:"created"`
+	LastAttempt time.Time              `json:"lastAttempt"`
+	NextAttempt time.Time              `json:"nextAttempt"`
+}
+
+// Record is the history record of an attempt on the pending action of a project.
+type Record struct {
+	ProjectID string                 `json:"projectID"`
+	Time      time.Time              `json:"time"`
+	Status    Status                 `json:"status"`
+	Attempt   int                    `json:"attempt"`
+	Error     string                 `json:"error,omitempty"`
+	By        string                 `json:"by"`
+	Action    *pdb.DataProjectUpdate `json:"action"`
+}
+
+// Journal bookkeeps the pending actions in the `Store`.
+//
+// The backoff after the n-th failed attempt is `Backoff * 2^(n-1)`, limited to `MaxBackoff`.
+// The action moves into the dead-letter state after `MaxAttempts` failed attempts.
+type Journal struct {
+	Store       *store.KVStore
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+// Init initializes the buckets of the journal in the `Store`.
+func (j Journal) Init() error {
+	return j.Store.Init([]string{bucketEntries, bucketHistory})
+}
+
+// digest returns the checksum of the action for detecting changes in it.
+func digest(act *pdb.DataProjectUpdate) string {
+	data, _ := json.Marshal(act)
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// Get returns the journal entry of the project `pid`, or nil if there is none.
+func (j Journal) Get(pid string) (*Entry, error) {
+	data, err := j.Store.Get(bucketEntries, []byte(pid))
+	if errors.Is(err, store.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot get journal entry of %s: %w", pid, err)
+	}
+
+	e := &Entry{}
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, fmt.Errorf("invalid journal entry of %s: %s", pid, err)
+	}
+	return e, nil
+}
+
+// Entries returns all journal entries of actions in process, sorted by project id.
+func (j Journal) Entries() ([]*Entry, error) {
+	kvs, err := j.Store.GetAll(bucketEntries)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*Entry, 0, len(kvs))
+	for _, kv := range kvs {
+		e := &Entry{}
+		if err := json.Unmarshal(kv.Value, e); err != nil {
+			return nil, fmt.Errorf("invalid journal entry of %s: %s", kv.Key, err)
+		}
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, k int) bool {
+		return entries[i].ProjectID < entries[k].ProjectID
+	})
+
+	return entries, nil
+}
+
+// Begin registers the pending action `act` of the project `pid` in the journal, and returns
+// its entry with a flag indicating whether the action is due for an attempt at `now`.
+//
+// An action that differs from the one in the journal, e.g. updated in the project database
+// after a failure, is considered as a new action.
+func (j Journal) Begin(pid string, act *pdb.DataProjectUpdate, now time.Time) (*Entry, bool, error) {
+	e, err := j.Get(pid)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if d := digest(act); e == nil || e.Digest != d {
+		e = &Entry{
+			ProjectID: pid,
+			Action:    act,
+			Digest:    d,
+			Status:    StatusPending,
+			Created:   now,
+		}
+		if err := j.save(e); err != nil {
+			return nil, false, err
+		}
+	}
+
+	switch e.Status {
+	case StatusDead:
+		return e, false, nil
+	case StatusFailed:
+		return e, !now.Before(e.NextAttempt), nil
+	default:
+		return e, true, nil
+	}
+}
+
+// Succeed records the successful attempt on the action of entry `e`, performed by `by`.
+// The entry is removed from the journal.
+func (j Journal) Succeed(e *Entry, by string, now time.Time) error {
+	e.Attempts++
+	e.Status = StatusApplied
+	e.LastAttempt = now
+	e.LastError = ""
+
+	if err := j.record(e, by); err != nil {
+		return err
+	}
+	return j.Store.Delete(bucketEntries, []byte(e.ProjectID))
+}
+
+// Fail records the failed attempt on the action of entry `e`, performed by `by`, with
+// the error `cause`.  It schedules the next attempt, or moves the entry into the
+// dead-letter state if the maximum number of attempts is reached.
+func (j Journal) Fail(e *Entry, cause error, by string, now time.Time) error {
+	e.Attempts++
+	e.LastAttempt = now
+	e.LastError = cause.Error()
+
+	if j.MaxAttempts > 0 && e.Attempts >= j.MaxAttempts {
+		e.Status = StatusDead
+		e.NextAttempt = time.Time{}
+	} else {
+		e.Status = StatusFailed
+		e.NextAttempt = now.Add(j.backoff(e.Attempts))
+	}
+
+	if err := j.record(e, by); err != nil {
+		return err
+	}
+	return j.save(e)
+}
+
+// Reset resets the entry of the project `pid` (e.g. in the dead-letter state) so that
+// its action is attempted again at the next run.
+func (j Journal) Reset(pid string) error {
+	e, err := j.Get(pid)
+	if err != nil {
+		return err
+	}
+	if e == nil {
+		return fmt.Errorf("no journal entry of project: %s", pid)
+	}
+
+	e.Status = StatusPending
+	e.Attempts = 0
+	e.NextAttempt = time.Time{}
+	return j.save(e)
+}
+
+// History returns the recorded attempts in chronological order.  Only attempts of the
+// project `pid` are returned if `pid` is not empty.
+func (j Journal) History(pid string) ([]*Record, error) {
+	kvs, err := j.Store.GetAll(bucketHistory)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*Record, 0)
+	for _, kv := range kvs {
+		if pid != "" && !strings.HasPrefix(string(kv.Key), pid+"/") {
+			continue
+		}
+		r := &Record{}
+		if err := json.Unmarshal(kv.Value, r); err != nil {
+			return nil, fmt.Errorf("invalid history record %s: %s", kv.Key, err)
+		}
+		records = append(records, r)
+	}
+
+	sort.SliceStable(records, func(i, k int) bool {
+		return records[i].Time.Before(records[k].Time)
+	})
+
+	return records, nil
+}
+
+// backoff returns the duration to wait after the `n`-th failed attempt.
+func (j Journal) backoff(n int) time.Duration {
+	d := j.Backoff
+	for i := 1; i < n; i++ {
+		d *= 2
+		if j.MaxBackoff > 0 && d >= j.MaxBackoff {
+			return j.MaxBackoff
+		}
+	}
+	if j.MaxBackoff > 0 && d > j.MaxBackoff {
+		return j.MaxBackoff
+	}
+	return d
+}
+
+// save stores the entry `e` in the journal.
+func (j Journal) save(e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return j.Store.Set(bucketEntries, []byte(e.ProjectID), data)
+}
+
+// record appends the history record of the latest attempt on the entry `e`.
+func (j Journal) record(e *Entry, by string) error {
+	r := Record{
+		ProjectID: e.ProjectID,
+		Time:      e.LastAttempt,
+		Status:    e.Status,
+		Attempt:   e.Attempts,
+		Error:     e.LastError,
+		By:        by,
+		Action:    e.Action,
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%s", e.ProjectID, r.Time.UTC().Format(time.RFC3339Nano))
+	return j.Store.Set(bucketHistory, []byte(key), data)
+}
@@ -0,0 +1,138 @@
+package journal
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/store"
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb"
+)
+
+func newJournal(t *testing.T) Journal {
+	s := &store.KVStore{Path: filepath.Join(t.TempDir(), "journal.db")}
+	if err := s.Connect(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	t.Cleanup(func() { s.Disconnect() })
+
+	j := Journal{
+		Store:       s,
+		MaxAttempts: 3,
+		Backoff:     time.Minute,
+		MaxBackoff:  90 * time.Second,
+	}
+	if err := j.Init(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	return j
+}
+
+var action = &pdb.DataProjectUpdate{
+	Members: []pdb.Member{{UserID: "honlee", Role: "manager"}},
+	Storage: pdb.Storage{QuotaGb: 100, System: "netapp"},
+}
+
+func TestJournalRetryAndDeadLetter(t *testing.T) {
+	j := newJournal(t)
+	now := time.Now()
+
+	e, due, err := j.Begin("3010000.01", action, now)
+	if err != nil || !due || e.Status != StatusPending {
+		t.Fatalf("unexpected entry: %+v due=%t err=%v", e, due, err)
+	}
+
+	// first failure, backoff 1 minute
+	if err := j.Fail(e, fmt.Errorf("filer unreachable"), "tg", now); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, due, _ := j.Begin("3010000.01", action, now.Add(30*time.Second)); due {
+		t.Errorf("action should be in backoff")
+	}
+
+	// second failure, backoff limited to 90 seconds
+	e, due, _ = j.Begin("3010000.01", action, now.Add(time.Minute))
+	if !due {
+		t.Fatalf("action should be due after backoff")
+	}
+	j.Fail(e, fmt.Errorf("filer unreachable"), "tg", now.Add(time.Minute))
+	if e.NextAttempt != now.Add(150*time.Second) {
+		t.Errorf("unexpected next attempt: %s", e.NextAttempt)
+	}
+
+	// third failure, dead letter
+	e, _, _ = j.Begin("3010000.01", action, now.Add(time.Hour))
+	j.Fail(e, fmt.Errorf("filer unreachable"), "tg", now.Add(time.Hour))
+	if e, due, _ := j.Begin("3010000.01", action, now.Add(24*time.Hour)); due || e.Status != StatusDead || e.LastError != "filer unreachable" {
+		t.Errorf("unexpected entry: %+v due=%t", e, due)
+	}
+
+	// reset makes the action due again
+	if err := j.Reset("3010000.01"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	e, due, _ = j.Begin("3010000.01", action, now.Add(24*time.Hour))
+	if !due || e.Attempts != 0 {
+		t.Errorf("unexpected entry after reset: %+v due=%t", e, due)
+	}
+
+	if err := j.Succeed(e, "tg", now.Add(25*time.Hour)); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if e, _ := j.Get("3010000.01"); e != nil {
+		t.Errorf("applied entry not removed: %+v", e)
+	}
+
+	records, err := j.History("3010000.01")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(records) != 4 || records[2].Status != StatusDead || records[3].Status != StatusApplied || records[3].By != "tg" {
+		t.Errorf("unexpected history: %+v", records)
+	}
+}
+
+func TestJournalChangedAction(t *testing.T) {
+	j := newJournal(t)
+	now := time.Now()
+
+	e, _, _ := j.Begin("3010000.01", action, now)
+	j.Fail(e, fmt.Errorf("failure"), "tg", now)
+
+	// an updated action in the project database is attempted rightaway.
+	changed := *action
+	changed.Storage.QuotaGb = 200
+	e, due, err := j.Begin("3010000.01", &changed, now)
+	if err != nil || !due || e.Attempts != 0 {
+		t.Errorf("unexpected entry of changed action: %+v due=%t err=%v", e, due, err)
+	}
+
+	j.Begin("3010000.02", action, now)
+	if entries, _ := j.Entries(); len(entries) != 2 || entries[1].ProjectID != "3010000.02" {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+
+	if records, _ := j.History(""); len(records) != 1 {
+		t.Errorf("unexpected history: %+v", records)
+	}
+}
+
+func TestJournalGetStoreError(t *testing.T) {
+	j := newJournal(t)
+
+	// a missing entry is not an error.
+	if e, err := j.Get("3010000.01"); e != nil || err != nil {
+		t.Errorf("unexpected result of missing entry: %+v %v", e, err)
+	}
+
+	// a failure of the store is not taken as a missing entry.
+	j.Store.Disconnect()
+
+	if _, err := j.Get("3010000.01"); err == nil {
+		t.Errorf("expect error on store failure")
+	}
+	if _, _, err := j.Begin("3010000.01", action, time.Now()); err == nil {
+		t.Errorf("expect error on store failure")
+	}
+}
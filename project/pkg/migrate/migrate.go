@@ -0,0 +1,577 @@
+// Package migrate implements the data migration of a project directory between
+// storage systems, e.g. from the NetApp filer (`/project`) to the CephFS
+// (`/project_cephfs`).
+//
+// The migration is resumable: the progress of each project is bookkept in a local
+// key-value store, so that an interrupted migration continues from where it stopped,
+// and files that are already copied and verified are not copied again.
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	"github.com/dccn-tg/tg-toolset-golang/pkg/store"
+)
+
+// Stage is the stage of a project migration.
+type Stage string
+
+const (
+	// StageNew refers to a migration not yet started.
+	StageNew Stage = ""
+	// StageProvisioned refers to a migration of which the target storage is provisioned.
+	StageProvisioned Stage = "provisioned"
+	// StageCopied refers to a migration of which the data is copied and verified.
+	StageCopied Stage = "copied"
+	// StageRolesApplied refers to a migration of which the roles are applied on the target.
+	StageRolesApplied Stage = "roles-applied"
+	// StageSwitched refers to a completed migration of which the project path is switched
+	// to the target.
+	StageSwitched Stage = "switched"
+)
+
+// bucketStates is the bucket of the store in which the migration states are kept.
+const bucketStates = "migrations"
+
+// State is the bookkeeping data structure of a project migration.
+type State struct {
+	ProjectID string    `json:"projectID"`
+	Source    string    `json:"source"`
+	Target    string    `json:"target"`
+	Stage     Stage     `json:"stage"`
+	Backup    string    `json:"backup,omitempty"`
+	Updated   time.Time `json:"updated"`
+}
+
+// LoadState retrieves the migration state of the project `pid` from the store `s`.
+// A new state is returned if the project has no migration bookkept in the store.
+func LoadState(s *store.KVStore, pid string) (*State, error) {
+
+	if err := s.Init([]string{bucketStates}); err != nil {
+		return nil, err
+	}
+
+	state := &State{ProjectID: pid}
+
+	data, err := s.Get(bucketStates, []byte(pid))
+	if err != nil {
+		log.Debugf("[%s] no migration state: %s", pid, err)
+		return state, nil
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid migration state of %s: %s", pid, err)
+	}
+
+	return state, nil
+}
+
+// SaveState stores the migration state `state` into the store `s`.
+func SaveState(s *store.KVStore, state *State) error {
+	state.Updated = time.Now()
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return s.Set(bucketStates, []byte(state.ProjectID), data)
+}
+
+// fileRecord is the bookkeeping data of a copied and verified file.
+type fileRecord struct {
+	Size     int64  `json:"size"`
+	ModTime  int64  `json:"mtime"`
+	Checksum string `json:"sha256"`
+}
+
+// Result is the summary of a `Copier.Copy` run.
+type Result struct {
+	Dirs    int64
+	Files   int64
+	Links   int64
+	Bytes   int64
+	Skipped int64
+	Deleted int64
+	Failed  int64
+}
+
+// Copier copies the content of a project directory from `Source` to `Target`,
+// preserving the ownership, the permission and the modification time.  Each copied
+// file is verified by comparing the SHA256 checksum of the source and the target.
+//
+// Copied files are bookkept in the `Store`; when the copy is resumed, files with
+// unchanged size and modification time on both sides are skipped.
+//
+// With `Prune` set, paths in the `Target` that no longer exist in the `Source`, e.g.
+// files removed from the source between incremental copies, are deleted from the
+// `Target` and the `Store`.
+type Copier struct {
+	ProjectID string
+	Source    string
+	Target    string
+	Store     *store.KVStore
+	Nthreads  int
+	Prune     bool
+}
+
+// bucket returns the name of the store bucket in which the copied files of the
+// project are bookkept.
+func (c *Copier) bucket() string {
+	return fmt.Sprintf("files:%s", c.ProjectID)
+}
+
+// Copy walks through the `Source` and copies directories, regular files and symbolic
+// links to the `Target`.  Other types of files are skipped with a warning.  An error
+// is returned if the `Source` is not an existing directory, or if the copy of any path
+// is failed.
+func (c *Copier) Copy() (Result, error) {
+
+	var res Result
+
+	// a missing source would otherwise be taken as a source with all data deleted.
+	fi, err := os.Lstat(c.Source)
+	if err != nil {
+		return res, fmt.Errorf("invalid source: %s", err)
+	}
+	if !fi.IsDir() {
+		return res, fmt.Errorf("invalid source: not a directory: %s", c.Source)
+	}
+
+	if err := c.Store.Init([]string{c.bucket()}); err != nil {
+		return res, err
+	}
+
+	nthreads := c.Nthreads
+	if nthreads < 1 {
+		nthreads = 1
+	}
+
+	var mux sync.Mutex
+	count := func(f func(r *Result)) {
+		mux.Lock()
+		defer mux.Unlock()
+		f(&res)
+	}
+
+	chanF := make(chan string, nthreads*4)
+
+	var wg sync.WaitGroup
+	wg.Add(nthreads)
+	for i := 0; i < nthreads; i++ {
+		go func() {
+			defer wg.Done()
+			for rel := range chanF {
+				n, copied, err := c.copyPath(rel)
+				if err != nil {
+					log.Errorf("[%s] cannot copy %s: %s", c.ProjectID, rel, err)
+					count(func(r *Result) { r.Failed++ })
+					continue
+				}
+				if !copied {
+					count(func(r *Result) { r.Skipped++ })
+					continue
+				}
+				count(func(r *Result) {
+					if n < 0 {
+						r.Links++
+					} else {
+						r.Files++
+						r.Bytes += n
+					}
+				})
+			}
+		}()
+	}
+
+	// directories are created by the walker, so that they exist before the files in
+	// them are copied by the workers.
+	dirs := []string{}
+	err = filepath.WalkDir(c.Source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Errorf("[%s] %s", c.ProjectID, err)
+			count(func(r *Result) { r.Failed++ })
+			return nil
+		}
+
+		rel, err := filepath.Rel(c.Source, path)
+		if err != nil {
+			return err
+		}
+
+		switch t := d.Type(); {
+		case t.IsDir():
+			if err := c.makeDir(rel); err != nil {
+				log.Errorf("[%s] cannot create directory %s: %s", c.ProjectID, rel, err)
+				count(func(r *Result) { r.Failed++ })
+				return fs.SkipDir
+			}
+			dirs = append(dirs, rel)
+			count(func(r *Result) { r.Dirs++ })
+		case t.IsRegular(), t&fs.ModeSymlink != 0:
+			chanF <- rel
+		default:
+			log.Warnf("[%s] skip unsupported file: %s (type: %s)", c.ProjectID, rel, t)
+		}
+		return nil
+	})
+	close(chanF)
+	wg.Wait()
+
+	if err != nil {
+		return res, err
+	}
+
+	// paths failed to be read from the source are not distinguishable from deleted
+	// paths; the target is therefore not pruned if there is any failure.
+	if c.Prune && res.Failed == 0 {
+		if err := c.prune(&res); err != nil {
+			return res, err
+		}
+	}
+
+	// the attributes of directories are applied at last as copying files into the
+	// directory changes its modification time.  Children are done before parents.
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, rel := range dirs {
+		if err := c.copyAttrs(rel); err != nil {
+			log.Errorf("[%s] cannot set attributes of directory %s: %s", c.ProjectID, rel, err)
+			res.Failed++
+		}
+	}
+
+	if res.Failed > 0 {
+		return res, fmt.Errorf("copy of %d paths failed", res.Failed)
+	}
+
+	return res, nil
+}
+
+// prune removes paths from the target that don't exist in the source, together with
+// the bookkeeping records of the files in them.
+func (c *Copier) prune(res *Result) error {
+	return filepath.WalkDir(c.Target, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Errorf("[%s] %s", c.ProjectID, err)
+			res.Failed++
+			return nil
+		}
+
+		rel, err := filepath.Rel(c.Target, path)
+		if err != nil {
+			return err
+		}
+
+		// the root is never removed.
+		if rel == "." {
+			return nil
+		}
+
+		if _, err := os.Lstat(filepath.Join(c.Source, rel)); !os.IsNotExist(err) {
+			return nil
+		}
+
+		if err := c.removePath(rel); err != nil {
+			log.Errorf("[%s] cannot remove %s: %s", c.ProjectID, rel, err)
+			res.Failed++
+			return nil
+		}
+		log.Debugf("[%s] removed %s deleted from the source", c.ProjectID, rel)
+		res.Deleted++
+
+		if d.IsDir() {
+			return fs.SkipDir
+		}
+		return nil
+	})
+}
+
+// removePath removes the path `rel` from the target, and removes the bookkeeping
+// records of the files underneath it from the store.
+func (c *Copier) removePath(rel string) error {
+	err := filepath.WalkDir(filepath.Join(c.Target, rel), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		r, err := filepath.Rel(c.Target, path)
+		if err != nil {
+			return err
+		}
+		return c.Store.Delete(c.bucket(), []byte(r))
+	})
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(c.Target, rel))
+}
+
+// makeDir creates the directory `rel` in the target if it doesn't exist.
+func (c *Copier) makeDir(rel string) error {
+	fi, err := os.Lstat(filepath.Join(c.Source, rel))
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(c.Target, rel)
+	if err := os.Mkdir(dst, fi.Mode().Perm()|0700); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return nil
+}
+
+// copyAttrs copies the ownership, the permission and the modification time of the
+// directory `rel` from the source to the target.
+func (c *Copier) copyAttrs(rel string) error {
+	src := filepath.Join(c.Source, rel)
+	dst := filepath.Join(c.Target, rel)
+
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		if err := os.Lchown(dst, int(st.Uid), int(st.Gid)); err != nil {
+			return err
+		}
+	}
+	if err := os.Chmod(dst, fileMode(fi)); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+}
+
+// fileMode returns the permission bits of `fi` to be applied on the target, including
+// the setuid, setgid and sticky bits.  The bits are applied after the ownership, as
+// changing the owner clears the setuid and setgid bits.
+func fileMode(fi os.FileInfo) os.FileMode {
+	return fi.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+}
+
+// copyPath copies the regular file or the symbolic link `rel` from the source to the
+// target.  It returns the number of bytes copied (-1 for a symbolic link), and whether
+// the path is actually copied or skipped as it is up to date.
+func (c *Copier) copyPath(rel string) (int64, bool, error) {
+
+	src := filepath.Join(c.Source, rel)
+	dst := filepath.Join(c.Target, rel)
+
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		copied, err := copySymlink(src, dst, fi)
+		return -1, copied, err
+	}
+
+	if c.isUpToDate(rel, dst, fi) {
+		return 0, false, nil
+	}
+
+	sum, err := copyFile(src, dst, fi)
+	if err != nil {
+		return 0, false, err
+	}
+
+	rec := fileRecord{
+		Size:     fi.Size(),
+		ModTime:  fi.ModTime().UnixNano(),
+		Checksum: sum,
+	}
+	data, _ := json.Marshal(&rec)
+	if err := c.Store.Set(c.bucket(), []byte(rel), data); err != nil {
+		return fi.Size(), true, fmt.Errorf("cannot bookkeep copied file: %s", err)
+	}
+
+	return fi.Size(), true, nil
+}
+
+// isUpToDate checks whether the file `rel` has been copied and verified, and remains
+// unchanged on both the source and the target since then.
+func (c *Copier) isUpToDate(rel, dst string, fi os.FileInfo) bool {
+
+	data, err := c.Store.Get(c.bucket(), []byte(rel))
+	if err != nil {
+		return false
+	}
+
+	var rec fileRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return false
+	}
+
+	if rec.Size != fi.Size() || rec.ModTime != fi.ModTime().UnixNano() {
+		return false
+	}
+
+	dfi, err := os.Lstat(dst)
+	if err != nil {
+		return false
+	}
+
+	return dfi.Mode().IsRegular() && dfi.Size() == fi.Size() && dfi.ModTime().Equal(fi.ModTime())
+}
+
+// copySymlink recreates the symbolic link `src` at `dst`, unless `dst` is already a
+// symbolic link to the same referent.
+func copySymlink(src, dst string, fi os.FileInfo) (bool, error) {
+
+	referent, err := os.Readlink(src)
+	if err != nil {
+		return false, err
+	}
+
+	if r, err := os.Readlink(dst); err == nil && r == referent {
+		return false, nil
+	}
+
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	if err := os.Symlink(referent, dst); err != nil {
+		return false, err
+	}
+
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		if err := os.Lchown(dst, int(st.Uid), int(st.Gid)); err != nil {
+			return true, err
+		}
+	}
+
+	return true, nil
+}
+
+// copyFile copies the content of the regular file `src` to `dst`, and verifies the
+// copy by the SHA256 checksum.  The ownership, the permission and the modification
+// time of `src` are applied to `dst`.  It returns the checksum of the file.
+func copyFile(src, dst string, fi os.FileInfo) (string, error) {
+
+	fsrc, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer fsrc.Close()
+
+	fdst, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	hsrc := sha256.New()
+	if _, err := io.Copy(fdst, io.TeeReader(fsrc, hsrc)); err != nil {
+		fdst.Close()
+		return "", err
+	}
+	if err := fdst.Close(); err != nil {
+		return "", err
+	}
+
+	sumSrc := hex.EncodeToString(hsrc.Sum(nil))
+
+	sumDst, err := checksum(dst)
+	if err != nil {
+		return "", err
+	}
+
+	if sumSrc != sumDst {
+		return "", fmt.Errorf("checksum mismatch: %s (source) != %s (target)", sumSrc, sumDst)
+	}
+
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		if err := os.Lchown(dst, int(st.Uid), int(st.Gid)); err != nil {
+			return "", err
+		}
+	}
+
+	if err := os.Chmod(dst, fileMode(fi)); err != nil {
+		return "", err
+	}
+
+	if err := os.Chtimes(dst, fi.ModTime(), fi.ModTime()); err != nil {
+		return "", err
+	}
+
+	return sumSrc, nil
+}
+
+// checksum returns the SHA256 checksum of the file `path` as a hex string.
+func checksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// SwitchLink makes `link` a symbolic link referring to `target`.  If `link` is an
+// existing symbolic link, it is replaced atomically.  If `link` is a directory, it is
+// renamed with the suffix `.premigrate` to keep the original data; the new path of the
+// directory is returned.
+//
+// The new path of the directory is passed to `saveBackup` before the directory is
+// renamed, so that it is bookkept even if the process is interrupted right after the
+// rename.  The directory is not renamed if `saveBackup` returns an error; if the rename
+// fails, `saveBackup` is called again with an empty path.
+func SwitchLink(link, target string, saveBackup func(backup string) error) (string, error) {
+
+	backup := ""
+
+	fi, err := os.Lstat(link)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return backup, err
+	case fi.Mode()&os.ModeSymlink != 0:
+		if r, err := os.Readlink(link); err == nil && r == target {
+			return backup, nil
+		}
+	case fi.IsDir():
+		backup = link + ".premigrate"
+		if err := saveBackup(backup); err != nil {
+			return "", fmt.Errorf("cannot save backup path %s: %s", backup, err)
+		}
+		if err := os.Rename(link, backup); err != nil {
+			if err := saveBackup(""); err != nil {
+				log.Errorf("cannot reset backup path %s: %s", backup, err)
+			}
+			return "", fmt.Errorf("cannot move %s aside: %s", link, err)
+		}
+	default:
+		return backup, fmt.Errorf("not a directory or symlink: %s", link)
+	}
+
+	// create the link with a temporary name and rename it over the `link` so that
+	// the switch is atomic.
+	tmp := filepath.Join(filepath.Dir(link), fmt.Sprintf(".%s.migrate", filepath.Base(link)))
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return backup, err
+	}
+
+	if err := os.Rename(tmp, link); err != nil {
+		os.Remove(tmp)
+		return backup, err
+	}
+
+	return backup, nil
+}
@@ -0,0 +1,379 @@
+package migrate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	"github.com/dccn-tg/tg-toolset-golang/pkg/store"
+)
+
+func init() {
+	logCfg := log.Configuration{
+		EnableConsole:     true,
+		ConsoleJSONFormat: false,
+		ConsoleLevel:      log.Debug,
+	}
+
+	// initialize logger
+	log.NewLogger(logCfg, log.InstanceLogrusLogger)
+}
+
+func newStore(t *testing.T) *store.KVStore {
+	s := &store.KVStore{Path: filepath.Join(t.TempDir(), "migrate.db")}
+	if err := s.Connect(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	t.Cleanup(func() { s.Disconnect() })
+	return s
+}
+
+func TestCopy(t *testing.T) {
+
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	mtime := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+
+	files := map[string]string{
+		"a.txt":         "hello",
+		"raw/b.dat":     "raw data",
+		"raw/sub/c.dat": "more raw data",
+	}
+	for p, content := range files {
+		fp := filepath.Join(src, p)
+		if err := os.MkdirAll(filepath.Dir(fp), 0750); err != nil {
+			t.Fatalf("%s", err)
+		}
+		if err := os.WriteFile(fp, []byte(content), 0640); err != nil {
+			t.Fatalf("%s", err)
+		}
+		if err := os.Chtimes(fp, mtime, mtime); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+	if err := os.Symlink("raw/b.dat", filepath.Join(src, "link")); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	c := Copier{
+		ProjectID: "3010000.01",
+		Source:    src,
+		Target:    dst,
+		Store:     newStore(t),
+		Nthreads:  2,
+	}
+
+	res, err := c.Copy()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if res.Files != 3 || res.Links != 1 || res.Dirs != 3 || res.Skipped != 0 {
+		t.Errorf("unexpected result of first copy: %+v", res)
+	}
+
+	for p, content := range files {
+		data, err := os.ReadFile(filepath.Join(dst, p))
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		if string(data) != content {
+			t.Errorf("unexpected content of %s: %s", p, data)
+		}
+		fi, _ := os.Stat(filepath.Join(dst, p))
+		if !fi.ModTime().Equal(mtime) || fi.Mode().Perm() != 0640 {
+			t.Errorf("unexpected attributes of %s: %s %s", p, fi.ModTime(), fi.Mode())
+		}
+	}
+	if r, _ := os.Readlink(filepath.Join(dst, "link")); r != "raw/b.dat" {
+		t.Errorf("unexpected symlink referent: %s", r)
+	}
+
+	// resume: only the changed file is copied again.
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("changed"), 0640); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	res, err = c.Copy()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if res.Files != 1 || res.Skipped != 3 {
+		t.Errorf("unexpected result of resumed copy: %+v", res)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "a.txt")); string(data) != "changed" {
+		t.Errorf("changed file not copied: %s", data)
+	}
+}
+
+func TestCopyModeBits(t *testing.T) {
+
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	if err := os.Mkdir(filepath.Join(src, "shared"), 0750); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := os.Chmod(filepath.Join(src, "shared"), 0770|os.ModeSetgid|os.ModeSticky); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "shared", "run.sh"), []byte("#!/bin/sh"), 0750); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := os.Chmod(filepath.Join(src, "shared", "run.sh"), 0750|os.ModeSetgid); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	c := Copier{ProjectID: "3010000.01", Source: src, Target: dst, Store: newStore(t)}
+	if _, err := c.Copy(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for p, mode := range map[string]os.FileMode{
+		"shared":        0770 | os.ModeSetgid | os.ModeSticky,
+		"shared/run.sh": 0750 | os.ModeSetgid,
+	} {
+		fi, err := os.Lstat(filepath.Join(dst, p))
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		if m := fi.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky); m != mode {
+			t.Errorf("unexpected mode of %s: %s, expect %s", p, m, mode)
+		}
+	}
+}
+
+func TestCopyPrune(t *testing.T) {
+
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	for _, p := range []string{"a.txt", "raw/b.dat", "raw/sub/c.dat"} {
+		fp := filepath.Join(src, p)
+		if err := os.MkdirAll(filepath.Dir(fp), 0750); err != nil {
+			t.Fatalf("%s", err)
+		}
+		if err := os.WriteFile(fp, []byte(p), 0640); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	s := newStore(t)
+	c := Copier{ProjectID: "3010000.01", Source: src, Target: dst, Store: s}
+	if _, err := c.Copy(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// files deleted from the source between incremental copies.
+	if err := os.Remove(filepath.Join(src, "a.txt")); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := os.RemoveAll(filepath.Join(src, "raw", "sub")); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// without pruning, the deleted files remain on the target.
+	if _, err := c.Copy(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dst, "a.txt")); err != nil {
+		t.Errorf("unexpected removal without pruning: %s", err)
+	}
+
+	c.Prune = true
+	res, err := c.Copy()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if res.Deleted != 2 {
+		t.Errorf("unexpected result of pruning copy: %+v", res)
+	}
+
+	for _, p := range []string{"a.txt", "raw/sub"} {
+		if _, err := os.Lstat(filepath.Join(dst, p)); !os.IsNotExist(err) {
+			t.Errorf("deleted path not removed from target: %s", p)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(dst, "raw", "b.dat")); err != nil {
+		t.Errorf("%s", err)
+	}
+
+	// the bookkeeping records of the removed files are deleted.
+	for _, p := range []string{"a.txt", "raw/sub/c.dat"} {
+		if _, err := s.Get(c.bucket(), []byte(p)); err == nil {
+			t.Errorf("record of %s not deleted", p)
+		}
+	}
+	if _, err := s.Get(c.bucket(), []byte("raw/b.dat")); err != nil {
+		t.Errorf("record of raw/b.dat: %s", err)
+	}
+}
+
+func TestState(t *testing.T) {
+	s := newStore(t)
+
+	state, err := LoadState(s, "3010000.01")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if state.Stage != StageNew {
+		t.Errorf("unexpected stage of new migration: %s", state.Stage)
+	}
+
+	state.Stage = StageCopied
+	if err := SaveState(s, state); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	state, err = LoadState(s, "3010000.01")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if state.Stage != StageCopied {
+		t.Errorf("unexpected stage: %s", state.Stage)
+	}
+}
+
+func TestCopyInvalidSource(t *testing.T) {
+
+	root := t.TempDir()
+	dst := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dst, "a.txt"), []byte("a.txt"), 0640); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if err := os.Mkdir(filepath.Join(root, "data"), 0750); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := os.Symlink("data", filepath.Join(root, "link")); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	s := newStore(t)
+	for _, src := range []string{"missing", "link"} {
+		c := Copier{ProjectID: "3010000.01", Source: filepath.Join(root, src), Target: dst, Store: s, Prune: true}
+		if _, err := c.Copy(); err == nil {
+			t.Errorf("expected error on source %s", src)
+		}
+		if _, err := os.Lstat(filepath.Join(dst, "a.txt")); err != nil {
+			t.Errorf("target pruned with source %s: %s", src, err)
+		}
+	}
+}
+
+func TestCopyResumeAfterSwitch(t *testing.T) {
+
+	root := t.TempDir()
+	src := filepath.Join(root, "3010000.01")
+	dst := t.TempDir()
+
+	for _, p := range []string{"a.txt", "raw/b.dat"} {
+		fp := filepath.Join(src, p)
+		if err := os.MkdirAll(filepath.Dir(fp), 0750); err != nil {
+			t.Fatalf("%s", err)
+		}
+		if err := os.WriteFile(fp, []byte(p), 0640); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	s := newStore(t)
+	state, err := LoadState(s, "3010000.01")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	state.Source = src
+	state.Target = dst
+
+	c := Copier{ProjectID: "3010000.01", Source: src, Target: dst, Store: s, Prune: true}
+	if _, err := c.Copy(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// the switch is interrupted right after the source is moved aside.
+	backup := src + ".premigrate"
+	state.Backup = backup
+	if err := SaveState(s, state); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := os.Rename(src, backup); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// a resumed copy from the moved source doesn't touch the target.
+	if _, err := c.Copy(); err == nil {
+		t.Errorf("expected error on moved source")
+	}
+
+	state, err = LoadState(s, state.ProjectID)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if state.Backup != backup {
+		t.Fatalf("unexpected backup path: %s", state.Backup)
+	}
+
+	c.Source = state.Backup
+	res, err := c.Copy()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if res.Deleted != 0 || res.Skipped != 2 {
+		t.Errorf("unexpected result of resumed copy: %+v", res)
+	}
+	for _, p := range []string{"a.txt", "raw/b.dat"} {
+		if _, err := os.Lstat(filepath.Join(dst, p)); err != nil {
+			t.Errorf("%s", err)
+		}
+	}
+}
+
+func TestSwitchLink(t *testing.T) {
+	root := t.TempDir()
+
+	link := filepath.Join(root, "3010000.01")
+	if err := os.Mkdir(link, 0750); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// the directory is not moved aside if the backup path cannot be saved.
+	if _, err := SwitchLink(link, "/project_cephfs/3010000.01", func(string) error {
+		return errors.New("store not available")
+	}); err == nil {
+		t.Errorf("expected error on failure saving backup path")
+	}
+	if fi, err := os.Lstat(link); err != nil || !fi.IsDir() {
+		t.Fatalf("directory moved without saving backup path: %v", err)
+	}
+
+	saved := ""
+	backup, err := SwitchLink(link, "/project_cephfs/3010000.01", func(b string) error {
+		// the backup path is saved before the directory is moved.
+		if fi, err := os.Lstat(link); err != nil || !fi.IsDir() {
+			t.Errorf("backup path saved after moving directory")
+		}
+		saved = b
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if backup != link+".premigrate" || saved != backup {
+		t.Errorf("unexpected backup path: %s (saved: %s)", backup, saved)
+	}
+	if r, _ := os.Readlink(link); r != "/project_cephfs/3010000.01" {
+		t.Errorf("unexpected link referent: %s", r)
+	}
+
+	// switching an existing link again is a no-op.
+	if backup, err := SwitchLink(link, "/project_cephfs/3010000.01", func(string) error {
+		t.Errorf("unexpected save of backup path")
+		return nil
+	}); err != nil || backup != "" {
+		t.Errorf("unexpected switch of existing link: %s %v", backup, err)
+	}
+}
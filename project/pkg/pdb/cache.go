@@ -0,0 +1,433 @@
+package pdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+)
+
+// cacheBucket is the bucket name of the `CacheStore` in which the cached data are stored.
+const cacheBucket = "pdbcache"
+
+// CacheStore defines the interface of the storage behind the `Cached` PDB.  It is
+// satisfied by the `store.KVStore` for caching data persistently across runs.
+type CacheStore interface {
+	Init(buckets []string) error
+	Get(bucket string, key []byte) ([]byte, error)
+	ForEach(bucket string, fn func(key, value []byte) error) error
+	Set(bucket string, key []byte, value []byte) error
+	Delete(bucket string, key []byte) error
+	DeleteRange(bucket string, min, max []byte) error
+	Clear(bucket string) error
+}
+
+// cacheEntry is the data structure of a cached value in the `CacheStore`.
+type cacheEntry struct {
+	Expires time.Time       `json:"expires"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Cached implements the `PDB` interface as a caching decorator around another `PDB`.
+//
+// Results of the read methods are cached for the duration of `TTL`; errors are not
+// cached.  Pending actions are always retrieved from the underlying `PDB` as they are
+// consumed by the caller.  The update methods are passed through and invalidate the
+// cached data of the project concerned.
+type Cached struct {
+	PDB   PDB
+	TTL   time.Duration
+	store CacheStore
+}
+
+// NewCached returns the `Cached` PDB around `p` with the given `ttl`.  Data are cached
+// in memory if the `store` is nil.
+func NewCached(p PDB, ttl time.Duration, store CacheStore) (Cached, error) {
+
+	if store == nil {
+		store = &memStore{data: make(map[string][]byte)}
+	}
+
+	if err := store.Init([]string{cacheBucket}); err != nil {
+		return Cached{}, fmt.Errorf("cannot initialize pdb cache: %s", err)
+	}
+
+	return Cached{PDB: p, TTL: ttl, store: store}, nil
+}
+
+// Invalidate removes the cached data of the given keys.  Keys are composed of the
+// method name and the arguments separated by `:`, e.g. `GetUser:honlee`.  All cached
+// data are removed if no key is given.
+func (c Cached) Invalidate(keys ...string) error {
+	if len(keys) == 0 {
+		return c.store.Clear(cacheBucket)
+	}
+
+	for _, k := range keys {
+		if err := c.store.Delete(cacheBucket, []byte(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Purge removes the expired data from the cache, and returns the number of removed
+// entries.  Expired data are not used, but they are only replaced when the same query
+// is made again.
+func (c Cached) Purge() (int, error) {
+	now := time.Now()
+
+	var expired [][]byte
+	if err := c.store.ForEach(cacheBucket, func(k, v []byte) error {
+		var e cacheEntry
+		if err := json.Unmarshal(v, &e); err != nil || now.After(e.Expires) {
+			expired = append(expired, append([]byte(nil), k...))
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	for _, k := range expired {
+		if err := c.store.Delete(cacheBucket, k); err != nil {
+			return 0, err
+		}
+	}
+	return len(expired), nil
+}
+
+// cacheKey composes the cache key from the method `name` and its arguments.
+func cacheKey(name string, args ...interface{}) string {
+	k := []string{name}
+	for _, a := range args {
+		k = append(k, fmt.Sprintf("%v", a))
+	}
+	return strings.Join(k, ":")
+}
+
+// get unmarshals the unexpired cached data of the `key` into `v`.  It returns `false`
+// if there is no such data in the cache.
+func (c Cached) get(key string, v interface{}) bool {
+	b, err := c.store.Get(cacheBucket, []byte(key))
+	if err != nil || b == nil {
+		return false
+	}
+
+	var e cacheEntry
+	if err := json.Unmarshal(b, &e); err != nil || time.Now().After(e.Expires) {
+		return false
+	}
+
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return false
+	}
+
+	log.Debugf("[cache] hit: %s", key)
+	return true
+}
+
+// set stores `v` in the cache with the `key`.  Failures are logged and otherwise ignored
+// as they only affect the efficiency.
+func (c Cached) set(key string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Warnf("[cache] cannot marshal data of %s: %s", key, err)
+		return
+	}
+
+	b, _ := json.Marshal(cacheEntry{
+		Expires: time.Now().Add(c.TTL),
+		Data:    data,
+	})
+
+	if err := c.store.Set(cacheBucket, []byte(key), b); err != nil {
+		log.Warnf("[cache] cannot store data of %s: %s", key, err)
+	}
+}
+
+// GetProjectPendingActions retrieves the pending actions from the underlying `PDB`.
+func (c Cached) GetProjectPendingActions() (map[string]*DataProjectUpdate, error) {
+	return c.PDB.GetProjectPendingActions()
+}
+
+// DelProjectPendingActions deletes the pending actions from the underlying `PDB`.
+func (c Cached) DelProjectPendingActions(actions map[string]*DataProjectUpdate) error {
+	if err := c.PDB.DelProjectPendingActions(actions); err != nil {
+		return err
+	}
+	for pid := range actions {
+		c.invalidateProject(pid)
+	}
+	c.invalidateUserRoles()
+	return nil
+}
+
+// UpdateProjectMembers updates the project members in the underlying `PDB`.
+func (c Cached) UpdateProjectMembers(projectID string, members []Member) error {
+	defer c.invalidateUserRoles()
+	defer c.invalidateProject(projectID)
+	return c.PDB.UpdateProjectMembers(projectID, members)
+}
+
+// UpdateProjectStorageQuota updates the project storage in the underlying `PDB`.
+func (c Cached) UpdateProjectStorageQuota(projectID string, quotaGB, usageGB int) error {
+	defer c.invalidateProject(projectID)
+	return c.PDB.UpdateProjectStorageQuota(projectID, quotaGB, usageGB)
+}
+
+// invalidateProject removes the cached data concerning the project `projectID`.
+func (c Cached) invalidateProject(projectID string) {
+	if err := c.Invalidate(
+		cacheKey("GetProject", projectID),
+		cacheKey("GetProjects", true),
+		cacheKey("GetProjects", false),
+	); err != nil {
+		log.Warnf("[cache] cannot invalidate data of project %s: %s", projectID, err)
+	}
+}
+
+// invalidateUserRoles removes the cached project roles of all users.  Besides the users
+// given in a member update, the users removed from the project are also concerned; they
+// are not known without querying the project members.
+func (c Cached) invalidateUserRoles() {
+	// keys of the user roles are in the range of ["GetUserProjectRoles:", "GetUserProjectRoles;").
+	prefix := cacheKey("GetUserProjectRoles")
+	if err := c.store.DeleteRange(cacheBucket, []byte(prefix+":"), []byte(prefix+";")); err != nil {
+		log.Warnf("[cache] cannot invalidate cached project roles of users: %s", err)
+	}
+}
+
+// GetProjects returns the projects from the cache or the underlying `PDB`.
+func (c Cached) GetProjects(activeOnly bool) ([]*Project, error) {
+	key := cacheKey("GetProjects", activeOnly)
+
+	var projects []*Project
+	if c.get(key, &projects) {
+		return projects, nil
+	}
+
+	projects, err := c.PDB.GetProjects(activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	c.set(key, projects)
+	return projects, nil
+}
+
+// WalkProjects implements the `ProjectWalker` interface.  The projects are streamed
+// from the underlying `PDB` without caching if it implements the `ProjectWalker`
+// interface; otherwise, they are retrieved at once with the cached `GetProjects`.
+func (c Cached) WalkProjects(ctx context.Context, f ProjectFilter, fn func(p *Project) error) error {
+	if w, ok := c.PDB.(ProjectWalker); ok {
+		return w.WalkProjects(ctx, f, fn)
+	}
+	return walkProjects(ctx, c, f, fn)
+}
+
+// GetProject returns the project from the cache or the underlying `PDB`.
+func (c Cached) GetProject(projectID string) (*Project, error) {
+	key := cacheKey("GetProject", projectID)
+
+	project := &Project{}
+	if c.get(key, project) {
+		return project, nil
+	}
+
+	project, err := c.PDB.GetProject(projectID)
+	if err != nil {
+		return nil, err
+	}
+	c.set(key, project)
+	return project, nil
+}
+
+// GetUsers returns the users from the cache or the underlying `PDB`.
+func (c Cached) GetUsers(activeOnly bool) ([]*User, error) {
+	key := cacheKey("GetUsers", activeOnly)
+
+	var users []*User
+	if c.get(key, &users) {
+		return users, nil
+	}
+
+	users, err := c.PDB.GetUsers(activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	c.set(key, users)
+	return users, nil
+}
+
+// GetUser returns the user from the cache or the underlying `PDB`.
+func (c Cached) GetUser(userID string) (*User, error) {
+	key := cacheKey("GetUser", userID)
+
+	user := &User{}
+	if c.get(key, user) {
+		return user, nil
+	}
+
+	user, err := c.PDB.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	c.set(key, user)
+	return user, nil
+}
+
+// GetUserProjectRoles returns the project roles of the user from the cache or the
+// underlying `PDB`.
+func (c Cached) GetUserProjectRoles(userID string) ([]*ProjectRole, error) {
+	key := cacheKey("GetUserProjectRoles", userID)
+
+	var roles []*ProjectRole
+	if c.get(key, &roles) {
+		return roles, nil
+	}
+
+	roles, err := c.PDB.GetUserProjectRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+	c.set(key, roles)
+	return roles, nil
+}
+
+// GetUserByEmail returns the user from the cache or the underlying `PDB`.
+func (c Cached) GetUserByEmail(email string) (*User, error) {
+	key := cacheKey("GetUserByEmail", strings.ToLower(email))
+
+	user := &User{}
+	if c.get(key, user) {
+		return user, nil
+	}
+
+	user, err := c.PDB.GetUserByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	c.set(key, user)
+	return user, nil
+}
+
+// GetLabBookingsForWorklist returns the lab bookings from the cache or the underlying `PDB`.
+func (c Cached) GetLabBookingsForWorklist(lab Lab, date string) ([]*LabBooking, error) {
+	key := cacheKey("GetLabBookingsForWorklist", lab, date)
+
+	var bookings []*LabBooking
+	if c.get(key, &bookings) {
+		return bookings, nil
+	}
+
+	bookings, err := c.PDB.GetLabBookingsForWorklist(lab, date)
+	if err != nil {
+		return nil, err
+	}
+	c.set(key, bookings)
+	return bookings, nil
+}
+
+// GetLabBookingsForReport returns the lab bookings from the cache or the underlying `PDB`.
+func (c Cached) GetLabBookingsForReport(lab Lab, from, to string) ([]*LabBooking, error) {
+	key := cacheKey("GetLabBookingsForReport", lab, from, to)
+
+	var bookings []*LabBooking
+	if c.get(key, &bookings) {
+		return bookings, nil
+	}
+
+	bookings, err := c.PDB.GetLabBookingsForReport(lab, from, to)
+	if err != nil {
+		return nil, err
+	}
+	c.set(key, bookings)
+	return bookings, nil
+}
+
+// GetExperimentersForSharedAnatomicalMR returns the experimenters from the cache or
+// the underlying `PDB`.
+func (c Cached) GetExperimentersForSharedAnatomicalMR() ([]*User, error) {
+	key := cacheKey("GetExperimentersForSharedAnatomicalMR")
+
+	var users []*User
+	if c.get(key, &users) {
+		return users, nil
+	}
+
+	users, err := c.PDB.GetExperimentersForSharedAnatomicalMR()
+	if err != nil {
+		return nil, err
+	}
+	c.set(key, users)
+	return users, nil
+}
+
+// memStore is the in-memory `CacheStore`.
+type memStore struct {
+	mutex sync.Mutex
+	data  map[string][]byte
+}
+
+func (s *memStore) Init(buckets []string) error {
+	return nil
+}
+
+func (s *memStore) Get(bucket string, key []byte) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.data[bucket+"/"+string(key)], nil
+}
+
+func (s *memStore) ForEach(bucket string, fn func(key, value []byte) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for k, v := range s.data {
+		if key, ok := strings.CutPrefix(k, bucket+"/"); ok {
+			if err := fn([]byte(key), v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *memStore) Set(bucket string, key []byte, value []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data[bucket+"/"+string(key)] = value
+	return nil
+}
+
+func (s *memStore) Delete(bucket string, key []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.data, bucket+"/"+string(key))
+	return nil
+}
+
+func (s *memStore) DeleteRange(bucket string, min, max []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for k := range s.data {
+		key, ok := strings.CutPrefix(k, bucket+"/")
+		if ok && key >= string(min) && (max == nil || key < string(max)) {
+			delete(s.data, k)
+		}
+	}
+	return nil
+}
+
+func (s *memStore) Clear(bucket string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for k := range s.data {
+		if strings.HasPrefix(k, bucket+"/") {
+			delete(s.data, k)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package pdb
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/store"
+)
+
+// countingPDB counts the calls of `GetUser` on the embedded `PDB`.
+type countingPDB struct {
+	PDB
+	calls *int
+}
+
+func (c countingPDB) GetUser(userID string) (*User, error) {
+	*c.calls++
+	return c.PDB.GetUser(userID)
+}
+
+func newCountingPDB(t *testing.T) (countingPDB, *int) {
+	m, err := NewMock("testdata/fixture.yml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	calls := 0
+	return countingPDB{PDB: m, calls: &calls}, &calls
+}
+
+func TestCachedGetUser(t *testing.T) {
+	p, calls := newCountingPDB(t)
+
+	c, err := NewCached(p, time.Minute, nil)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		u, err := c.GetUser("honlee")
+		if err != nil || u.ID != "honlee" {
+			t.Fatalf("unexpected user: %+v %v", u, err)
+		}
+	}
+	if *calls != 1 {
+		t.Errorf("expect 1 call on pdb, got %d", *calls)
+	}
+
+	// errors are not cached
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetUser("nobody"); err == nil {
+			t.Errorf("expect error on unknown user")
+		}
+	}
+	if *calls != 3 {
+		t.Errorf("expect 3 calls on pdb, got %d", *calls)
+	}
+
+	// explicit invalidation
+	if err := c.Invalidate(cacheKey("GetUser", "honlee")); err != nil {
+		t.Fatalf("%s", err)
+	}
+	c.GetUser("honlee")
+	if *calls != 4 {
+		t.Errorf("expect 4 calls on pdb, got %d", *calls)
+	}
+}
+
+func TestCachedExpiry(t *testing.T) {
+	p, calls := newCountingPDB(t)
+
+	c, err := NewCached(p, 10*time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	c.GetUser("honlee")
+	time.Sleep(20 * time.Millisecond)
+	c.GetUser("honlee")
+
+	if *calls != 2 {
+		t.Errorf("expect 2 calls on pdb, got %d", *calls)
+	}
+}
+
+func TestCachedKVStore(t *testing.T) {
+	s := &store.KVStore{Path: filepath.Join(t.TempDir(), "cache.db")}
+	if err := s.Connect(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer s.Disconnect()
+
+	p, calls := newCountingPDB(t)
+
+	// data cached by one instance is used by another instance on the same store.
+	for i := 0; i < 2; i++ {
+		c, err := NewCached(p, time.Minute, s)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		if u, err := c.GetUser("edwger"); err != nil || u.ID != "edwger" {
+			t.Fatalf("unexpected user: %+v %v", u, err)
+		}
+	}
+	if *calls != 1 {
+		t.Errorf("expect 1 call on pdb, got %d", *calls)
+	}
+
+	c, _ := NewCached(p, time.Minute, s)
+	if err := c.Invalidate(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	c.GetUser("edwger")
+	if *calls != 2 {
+		t.Errorf("expect 2 calls on pdb, got %d", *calls)
+	}
+}
+
+func TestCachedUpdateInvalidates(t *testing.T) {
+	m, err := NewMock("testdata/fixture.yml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	c, err := NewCached(m, time.Minute, nil)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if _, err := c.GetProject("3010000.01"); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if err := c.UpdateProjectStorageQuota("3010000.01", 200, 10); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if b, _ := c.store.Get(cacheBucket, []byte(cacheKey("GetProject", "3010000.01"))); b != nil {
+		t.Errorf("cached project not invalidated after update")
+	}
+}
+
+func TestCachedUpdateMembersInvalidatesRoles(t *testing.T) {
+	m, err := NewMock("testdata/fixture.yml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	c, err := NewCached(m, time.Minute, nil)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for _, u := range []string{"honlee", "edwger"} {
+		if _, err := c.GetUserProjectRoles(u); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+	c.GetUser("honlee")
+
+	// the roles of users not in the update, e.g. removed from the project, are also invalidated.
+	if err := c.UpdateProjectMembers("3010000.01", []Member{{UserID: "honlee", Role: "viewer"}}); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for _, u := range []string{"honlee", "edwger"} {
+		if b, _ := c.store.Get(cacheBucket, []byte(cacheKey("GetUserProjectRoles", u))); b != nil {
+			t.Errorf("cached project roles of %s not invalidated after member update", u)
+		}
+	}
+
+	if b, _ := c.store.Get(cacheBucket, []byte(cacheKey("GetUser", "honlee"))); b == nil {
+		t.Errorf("cached user unexpectedly invalidated")
+	}
+}
+
+func TestCachedPurge(t *testing.T) {
+	p, _ := newCountingPDB(t)
+
+	// data cached by `expired` are expired immediately.
+	expired, err := NewCached(p, -time.Second, nil)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	expired.GetUser("honlee")
+
+	c := Cached{PDB: p, TTL: time.Minute, store: expired.store}
+	c.GetUser("edwger")
+
+	n, err := c.Purge()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if n != 1 {
+		t.Errorf("expect 1 purged entry, got %d", n)
+	}
+
+	if b, _ := c.store.Get(cacheBucket, []byte(cacheKey("GetUser", "honlee"))); b != nil {
+		t.Errorf("expired entry not purged")
+	}
+	if b, _ := c.store.Get(cacheBucket, []byte(cacheKey("GetUser", "edwger"))); b == nil {
+		t.Errorf("unexpired entry purged")
+	}
+}
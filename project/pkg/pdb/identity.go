@@ -0,0 +1,24 @@
+package pdb
+
+import (
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/identity"
+)
+
+// IdentityResolver implements the `identity.Resolver` interface using the user list of
+// the project database.  Users checked in or with an extended checkout are considered
+// active.
+type IdentityResolver struct {
+	PDB PDB
+}
+
+// LookupUser implements the `identity.Resolver` interface.
+func (r IdentityResolver) LookupUser(username string) (*identity.Identity, error) {
+	u, err := r.PDB.GetUser(username)
+	if err != nil {
+		return nil, err
+	}
+
+	active := u.Status == UserStatusCheckedIn || u.Status == UserStatusCheckedOutExtended
+
+	return &identity.Identity{Username: u.ID, Active: active}, nil
+}
@@ -0,0 +1,29 @@
+package pdb
+
+import (
+	"testing"
+
+	"github.com/dccn-tg/tg-toolset-golang/project/pkg/identity"
+)
+
+func TestIdentityResolver(t *testing.T) {
+	m, err := NewMock("testdata/fixture.yml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	r := IdentityResolver{PDB: m}
+
+	if err := identity.Validate(r, []string{"honlee", "edwger"}); err != nil {
+		t.Errorf("unexpected validation error: %s", err)
+	}
+
+	// checked-out user is rejected
+	if err := identity.Validate(r, []string{"honlee", "olduser"}); err == nil {
+		t.Errorf("expect validation error on inactive user")
+	}
+
+	if err := identity.Validate(r, []string{"nobody"}); err == nil {
+		t.Errorf("expect validation error on unknown user")
+	}
+}
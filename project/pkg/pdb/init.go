@@ -2,6 +2,7 @@
 package pdb
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/dccn-tg/tg-toolset-golang/pkg/config"
@@ -23,11 +24,29 @@ func init() {
 }
 
 // New returns the `PDBClient` corresponding to the given
-// PDB `version`.
+// PDB `version`.  The labs of the configuration, if any, are registered in the lab
+// registry.
 func New(c config.PDBConfiguration) (PDB, error) {
+
+	if len(c.Labs) > 0 {
+		if err := RegisterLabs(c.Labs); err != nil {
+			return nil, err
+		}
+	}
+
 	switch c.Version {
+	case 0:
+		m, err := NewMock(c.Mock.Fixture)
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
 	case 1:
-		return V1{config: c.V1}, nil
+		v1, err := NewV1(c.V1)
+		if err != nil {
+			return nil, err
+		}
+		return v1, nil
 	case 2:
 		return V2{config: c.V2}, nil
 	default:
@@ -44,7 +63,72 @@ type PDB interface {
 	GetUser(userID string) (*User, error)
 	GetProject(projectID string) (*Project, error)
 	GetUserByEmail(email string) (*User, error)
+	GetUserProjectRoles(userID string) ([]*ProjectRole, error)
 	GetLabBookingsForWorklist(lab Lab, date string) ([]*LabBooking, error)
 	GetLabBookingsForReport(lab Lab, from, to string) ([]*LabBooking, error)
 	GetExperimentersForSharedAnatomicalMR() ([]*User, error)
+	PDBWriter
 }
+
+// PDBWriter defines the interface for updating project members and storage quota
+// in the project database.
+type PDBWriter interface {
+	UpdateProjectMembers(projectID string, members []Member) error
+	UpdateProjectStorageQuota(projectID string, quotaGB, usageGB int) error
+}
+
+// ProjectWalker defines the interface of the PDB that retrieves the projects page by
+// page, so that not all the projects are held in memory.
+type ProjectWalker interface {
+	// WalkProjects calls `fn` on every project selected by the filter `f`.  It stops at
+	// the first error returned by `fn`.
+	WalkProjects(ctx context.Context, f ProjectFilter, fn func(p *Project) error) error
+}
+
+// WalkProjects calls `fn` on every project of `p` selected by the filter `f`.  The projects
+// are streamed if `p` implements the `ProjectWalker` interface; otherwise, they are
+// retrieved at once with `GetProjects` and filtered.
+func WalkProjects(ctx context.Context, p PDB, f ProjectFilter, fn func(p *Project) error) error {
+	if w, ok := p.(ProjectWalker); ok {
+		return w.WalkProjects(ctx, f, fn)
+	}
+	return walkProjects(ctx, p, f, fn)
+}
+
+// walkProjects calls `fn` on the projects of `p.GetProjects` selected by the filter `f`.
+func walkProjects(ctx context.Context, p PDB, f ProjectFilter, fn func(p *Project) error) error {
+
+	activeOnly := len(f.Status) > 0
+	for _, s := range f.Status {
+		activeOnly = activeOnly && s == ProjectStatusActive
+	}
+
+	projects, err := p.GetProjects(activeOnly)
+	if err != nil {
+		return err
+	}
+
+	for _, prj := range projects {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !f.match(prj) {
+			continue
+		}
+		if err := fn(prj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compile-time checks that all implementations satisfy the `PDB` interface.
+var (
+	_ PDB = V1{}
+	_ PDB = V2{}
+	_ PDB = Mock{}
+	_ PDB = Cached{}
+
+	_ ProjectWalker = V2{}
+	_ ProjectWalker = Cached{}
+)
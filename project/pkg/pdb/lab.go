@@ -0,0 +1,247 @@
+package pdb
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/config"
+)
+
+// Lab is the name of a lab in the lab registry, e.g. "MRI".  The labs are defined in the
+// configuration, and registered by `RegisterLabs`.
+type Lab string
+
+// Set implements the interface for flag.Var().  The lab is resolved in the lab registry
+// when it is used, so that the labs loaded from the configuration after the flags are
+// parsed are taken into account.
+func (l *Lab) Set(v string) error {
+	if v == "" {
+		return fmt.Errorf("empty lab name")
+	}
+	*l = Lab(strings.ToUpper(v))
+	return nil
+}
+
+// String implements the interface for flag.Var().  It returns the
+// name of the lab.
+func (l Lab) String() string {
+	return string(l)
+}
+
+// GetDescriptionRegex returns a regular expression pattern for the description of
+// a lab.
+func (l Lab) GetDescriptionRegex() (*regexp.Regexp, error) {
+	info, err := LookupLab(l)
+	if err != nil {
+		return nil, err
+	}
+	return info.Description, nil
+}
+
+const (
+	// EEG is the lab of the EEG labs.
+	EEG Lab = "EEG"
+	// MEG is the lab of the MEG labs.
+	MEG Lab = "MEG"
+	// MRI is the lab of the MRI labs.
+	MRI Lab = "MRI"
+	// ALL is the lab matching all the labs.  It is always available in the registry.
+	ALL Lab = "ALL"
+)
+
+// LabInfo is the definition of a lab in the lab registry.
+type LabInfo struct {
+	Name Lab
+	// Description matches the upper-cased calendar description of the lab resources in
+	// the project database v1; the first submatch is taken as the lab of the bookings.
+	Description *regexp.Regexp
+	// Modality matches the modality ids of the lab resources in the project database v2.
+	Modality *regexp.Regexp
+	// Scanners are the scanners of the lab for which the DICOM worklist can be generated.
+	Scanners []Scanner
+}
+
+// Scanner is a scanner of a lab.
+type Scanner struct {
+	// Name is the lower-cased name of the scanner.
+	Name string
+	// AETitle is the DICOM application entity title of the scanner.
+	AETitle string
+}
+
+// Scanner returns the scanner of the lab with the `name`, e.g. the lab of a booking,
+// compared case-insensitively.
+func (l LabInfo) Scanner(name string) (Scanner, bool) {
+	for _, s := range l.Scanners {
+		if strings.EqualFold(s.Name, name) {
+			return s, true
+		}
+	}
+	return Scanner{}, false
+}
+
+// ScannerNames returns the names of the scanners of the lab.
+func (l LabInfo) ScannerNames() []string {
+	names := make([]string, len(l.Scanners))
+	for i, s := range l.Scanners {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// maxAETitleLength is the maximum length of a DICOM application entity title.
+const maxAETitleLength = 16
+
+// defaultLabs are the labs registered until the labs are loaded from the configuration.
+var defaultLabs = []config.LabConfiguration{
+	{
+		Name:        "EEG",
+		Description: ".*(EEG).*",
+		Modality:    "^eeg$",
+	},
+	{
+		Name:        "MEG",
+		Description: ".*(MEG).*",
+		Modality:    "^meg.*",
+	},
+	{
+		Name:        "MRI",
+		Description: ".*(SKYRA|PRISMA(FIT){0,1}).*",
+		Modality:    "^mr[0-9.]t$",
+		Scanners: []config.ScannerConfiguration{
+			{Name: "prisma", AETitle: "PRISMA"},
+			{Name: "prismafit", AETitle: "PRISMAFIT"},
+			{Name: "skyra", AETitle: "SKYRA"},
+		},
+	},
+}
+
+// allLab is the definition of the lab `ALL`.
+var allLab = LabInfo{
+	Name:        ALL,
+	Description: regexp.MustCompile(".*"),
+	Modality:    regexp.MustCompile(".*"),
+}
+
+var (
+	// labs is the lab registry.
+	labs   map[Lab]LabInfo
+	labMux sync.RWMutex
+)
+
+func init() {
+	if err := RegisterLabs(defaultLabs); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterLabs replaces the labs in the lab registry with the labs defined in the
+// configuration `cs`.  The registry is left unchanged if any of the labs is invalid.
+func RegisterLabs(cs []config.LabConfiguration) error {
+
+	registry := make(map[Lab]LabInfo)
+
+	for _, c := range cs {
+		name := Lab(strings.ToUpper(c.Name))
+		switch _, dup := registry[name]; {
+		case name == "":
+			return fmt.Errorf("lab without name")
+		case name == ALL:
+			return fmt.Errorf("reserved lab name: %s", name)
+		case dup:
+			return fmt.Errorf("duplicated lab: %s", name)
+		}
+
+		// an empty pattern would match all the bookings.
+		if c.Description == "" {
+			return fmt.Errorf("lab %s without description pattern", name)
+		}
+		if c.Modality == "" {
+			return fmt.Errorf("lab %s without modality pattern", name)
+		}
+
+		desc, err := regexp.Compile(c.Description)
+		if err != nil {
+			return fmt.Errorf("invalid description of lab %s: %w", name, err)
+		}
+
+		mod, err := regexp.Compile(c.Modality)
+		if err != nil {
+			return fmt.Errorf("invalid modality of lab %s: %w", name, err)
+		}
+
+		info := LabInfo{
+			Name:        name,
+			Description: desc,
+			Modality:    mod,
+			Scanners:    make([]Scanner, 0, len(c.Scanners)),
+		}
+
+		for _, s := range c.Scanners {
+			if s.Name == "" {
+				return fmt.Errorf("scanner without name in lab %s", name)
+			}
+			if _, dup := info.Scanner(s.Name); dup {
+				return fmt.Errorf("duplicated scanner in lab %s: %s", name, s.Name)
+			}
+
+			ae := s.AETitle
+			if ae == "" {
+				ae = strings.ToUpper(s.Name)
+			}
+			if len(ae) > maxAETitleLength {
+				return fmt.Errorf("AE title of scanner %s longer than %d characters: %s", s.Name, maxAETitleLength, ae)
+			}
+
+			info.Scanners = append(info.Scanners, Scanner{
+				Name:    strings.ToLower(s.Name),
+				AETitle: ae,
+			})
+		}
+
+		registry[name] = info
+	}
+
+	labMux.Lock()
+	defer labMux.Unlock()
+	labs = registry
+
+	return nil
+}
+
+// LookupLab returns the definition of the `lab` in the lab registry.  The lab name is
+// compared case-insensitively.
+func LookupLab(lab Lab) (LabInfo, error) {
+
+	lab = Lab(strings.ToUpper(string(lab)))
+
+	if lab == ALL {
+		return allLab, nil
+	}
+
+	labMux.RLock()
+	defer labMux.RUnlock()
+
+	info, ok := labs[lab]
+	if !ok {
+		return LabInfo{}, fmt.Errorf("unknown lab: %s", lab)
+	}
+	return info, nil
+}
+
+// Labs returns the names of the labs in the lab registry, sorted by name.
+func Labs() []Lab {
+	labMux.RLock()
+	defer labMux.RUnlock()
+
+	names := make([]Lab, 0, len(labs))
+	for l := range labs {
+		names = append(names, l)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	return names
+}
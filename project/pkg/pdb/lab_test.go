@@ -0,0 +1,111 @@
+package pdb
+
+import (
+	"testing"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/config"
+)
+
+// registerTestLabs registers the labs `cs` for the test, and restores the default labs
+// afterwards.
+func registerTestLabs(t *testing.T, cs []config.LabConfiguration) {
+	if err := RegisterLabs(cs); err != nil {
+		t.Fatalf("%s", err)
+	}
+	t.Cleanup(func() {
+		if err := RegisterLabs(defaultLabs); err != nil {
+			t.Fatalf("%s", err)
+		}
+	})
+}
+
+func TestDefaultLabs(t *testing.T) {
+	for _, l := range []Lab{EEG, MEG, MRI, ALL} {
+		if _, err := LookupLab(l); err != nil {
+			t.Errorf("%s", err)
+		}
+	}
+
+	mri, _ := LookupLab("mri")
+	if s, ok := mri.Scanner("PrismaFit"); !ok || s.AETitle != "PRISMAFIT" {
+		t.Errorf("unexpected scanner: %+v", s)
+	}
+}
+
+func TestRegisterLabs(t *testing.T) {
+	registerTestLabs(t, []config.LabConfiguration{
+		{
+			Name:        "mr3t",
+			Description: ".*(SKYRA).*",
+			Modality:    "^mr3t$",
+			Scanners:    []config.ScannerConfiguration{{Name: "Skyra"}, {Name: "skyra2", AETitle: "SKYRA_2"}},
+		},
+	})
+
+	if labs := Labs(); len(labs) != 1 || labs[0] != "MR3T" {
+		t.Errorf("unexpected labs: %v", labs)
+	}
+
+	if _, err := LookupLab(MRI); err == nil {
+		t.Errorf("expect error on unregistered lab")
+	}
+
+	info, err := LookupLab("MR3T")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !info.Modality.MatchString("mr3t") || info.Modality.MatchString("mr7t") {
+		t.Errorf("unexpected modality regex: %s", info.Modality)
+	}
+	if s, ok := info.Scanner("SKYRA"); !ok || s.Name != "skyra" || s.AETitle != "SKYRA" {
+		t.Errorf("unexpected scanner: %+v", s)
+	}
+	if s, ok := info.Scanner("skyra2"); !ok || s.AETitle != "SKYRA_2" {
+		t.Errorf("unexpected scanner: %+v", s)
+	}
+
+	// the bookings are selected with the registered labs.
+	m, err := NewMock("testdata/fixture.yml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	var l Lab
+	if err := l.Set("mr3t"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	bookings, err := m.GetLabBookingsForReport(l, "2023-04-01", "2023-04-30")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(bookings) != 1 || bookings[0].Lab != "SKYRA" {
+		t.Errorf("unexpected bookings: %+v", bookings)
+	}
+}
+
+func TestRegisterInvalidLabs(t *testing.T) {
+	// valid patterns of the labs, so that each case fails for the intended reason.
+	const desc, mod = ".*(EEG).*", "^eeg$"
+
+	for name, cs := range map[string][]config.LabConfiguration{
+		"no name":           {{Description: desc, Modality: mod}},
+		"reserved name":     {{Name: "all", Description: desc, Modality: mod}},
+		"duplicated lab":    {{Name: "EEG", Description: desc, Modality: mod}, {Name: "eeg", Description: desc, Modality: mod}},
+		"no description":    {{Name: "EEG", Modality: mod}},
+		"no modality":       {{Name: "EEG", Description: desc}},
+		"bad description":   {{Name: "EEG", Description: "(EEG", Modality: mod}},
+		"bad modality":      {{Name: "EEG", Description: desc, Modality: "[eeg"}},
+		"dup scanner":       {{Name: "MRI", Description: desc, Modality: mod, Scanners: []config.ScannerConfiguration{{Name: "prisma"}, {Name: "PRISMA"}}}},
+		"long AE title":     {{Name: "MRI", Description: desc, Modality: mod, Scanners: []config.ScannerConfiguration{{Name: "prisma", AETitle: "PRISMA_AE_TITLE_TOO_LONG"}}}},
+		"no scanner name":   {{Name: "MRI", Description: desc, Modality: mod, Scanners: []config.ScannerConfiguration{{AETitle: "PRISMA"}}}},
+		"one invalid among": {{Name: "EEG", Description: desc, Modality: mod}, {Name: "MEG", Description: desc}},
+	} {
+		if err := RegisterLabs(cs); err == nil {
+			t.Errorf("%s: expect error", name)
+		}
+	}
+
+	// the registry is unchanged.
+	if _, err := LookupLab(MRI); err != nil {
+		t.Errorf("%s", err)
+	}
+}
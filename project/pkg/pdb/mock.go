@@ -0,0 +1,315 @@
+package pdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/dccn-tg/tg-toolset-golang/pkg/logger"
+	"gopkg.in/yaml.v3"
+)
+
+// mockData is the data structure of the fixture file loaded by the `Mock`.
+type mockData struct {
+	Users          []*User                       `json:"users"`
+	Projects       []*Project                    `json:"projects"`
+	PendingActions map[string]*DataProjectUpdate `json:"pendingActions"`
+	LabBookings    []*LabBooking                 `json:"labBookings"`
+	ProjectMembers map[string][]Member           `json:"projectMembers"`
+	ProjectStorage map[string]*StorageInfo       `json:"projectStorage"`
+}
+
+// Mock implements the `PDB` interface with data loaded from a YAML or JSON fixture file.
+// It is meant for testing and demonstrating the tools offline, without a connection to
+// the project database.
+//
+// The data are kept in memory; changes (e.g. deletion of pending actions) are not written
+// back to the fixture file.
+type Mock struct {
+	mutex *sync.Mutex
+	data  *mockData
+}
+
+// NewMock returns the `Mock` with data loaded from the `fixture` file.  The file is
+// parsed as JSON if it has the `.json` extension; otherwise as YAML.
+func NewMock(fixture string) (Mock, error) {
+
+	m := Mock{
+		mutex: &sync.Mutex{},
+		data:  &mockData{},
+	}
+
+	if fixture == "" {
+		return m, fmt.Errorf("fixture file not specified for mock pdb")
+	}
+
+	content, err := os.ReadFile(fixture)
+	if err != nil {
+		return m, fmt.Errorf("cannot read fixture file: %s", err)
+	}
+
+	// YAML is converted into JSON so that the JSON tags of the data structures apply.
+	if strings.ToLower(filepath.Ext(fixture)) != ".json" {
+		var v interface{}
+		if err := yaml.Unmarshal(content, &v); err != nil {
+			return m, fmt.Errorf("cannot parse fixture file %s: %s", fixture, err)
+		}
+		if content, err = json.Marshal(v); err != nil {
+			return m, fmt.Errorf("cannot parse fixture file %s: %s", fixture, err)
+		}
+	}
+
+	if err := json.Unmarshal(content, m.data); err != nil {
+		return m, fmt.Errorf("cannot parse fixture file %s: %s", fixture, err)
+	}
+
+	if m.data.PendingActions == nil {
+		m.data.PendingActions = make(map[string]*DataProjectUpdate)
+	}
+	if m.data.ProjectMembers == nil {
+		m.data.ProjectMembers = make(map[string][]Member)
+	}
+	if m.data.ProjectStorage == nil {
+		m.data.ProjectStorage = make(map[string]*StorageInfo)
+	}
+
+	log.Debugf("mock pdb loaded from %s: %d users, %d projects, %d pending actions, %d lab bookings",
+		fixture, len(m.data.Users), len(m.data.Projects), len(m.data.PendingActions), len(m.data.LabBookings))
+
+	return m, nil
+}
+
+// GetProjectPendingActions returns the pending actions in the fixture.
+func (m Mock) GetProjectPendingActions() (map[string]*DataProjectUpdate, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	actions := make(map[string]*DataProjectUpdate, len(m.data.PendingActions))
+	for pid, act := range m.data.PendingActions {
+		a := *act
+		actions[pid] = &a
+	}
+	return actions, nil
+}
+
+// DelProjectPendingActions removes the pending actions of the given projects.
+func (m Mock) DelProjectPendingActions(actions map[string]*DataProjectUpdate) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for pid := range actions {
+		delete(m.data.PendingActions, pid)
+	}
+	return nil
+}
+
+// UpdateProjectMembers replaces the members of the project with the given `members`.
+func (m Mock) UpdateProjectMembers(projectID string, members []Member) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.data.ProjectMembers[projectID] = append([]Member{}, members...)
+	return nil
+}
+
+// UpdateProjectStorageQuota sets the storage quota and usage of the project.
+func (m Mock) UpdateProjectStorageQuota(projectID string, quotaGB, usageGB int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.data.ProjectStorage[projectID] = &StorageInfo{
+		QuotaGb: quotaGB,
+		UsageMb: usageGB * 1024,
+	}
+	for _, p := range m.data.Projects {
+		if p.ID == projectID {
+			p.QuotaGb = quotaGB
+		}
+	}
+	return nil
+}
+
+// GetProjects returns projects in the fixture.
+func (m Mock) GetProjects(activeOnly bool) ([]*Project, error) {
+	var projects []*Project
+	for _, p := range m.data.Projects {
+		if activeOnly && p.Status != ProjectStatusActive {
+			continue
+		}
+		projects = append(projects, p)
+	}
+	return projects, nil
+}
+
+// GetProject returns the project with the given `projectID` in the fixture.
+func (m Mock) GetProject(projectID string) (*Project, error) {
+	for _, p := range m.data.Projects {
+		if p.ID == projectID {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("project not found: %s", projectID)
+}
+
+// GetUsers returns users in the fixture.  Active users are those checked in or with
+// an extended checkout.
+func (m Mock) GetUsers(activeOnly bool) ([]*User, error) {
+	var users []*User
+	for _, u := range m.data.Users {
+		if activeOnly && !u.Status.IsActive() {
+			continue
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+// GetUser returns the user with the given `userID` in the fixture.
+func (m Mock) GetUser(userID string) (*User, error) {
+	for _, u := range m.data.Users {
+		if u.ID == userID {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found: %s", userID)
+}
+
+// GetUserByEmail returns the user with the given `email` in the fixture.
+func (m Mock) GetUserByEmail(email string) (*User, error) {
+	for _, u := range m.data.Users {
+		if strings.EqualFold(u.Email, email) {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found, email: %s", email)
+}
+
+// GetUserProjectRoles returns the roles of the user in the project members of the fixture.
+func (m Mock) GetUserProjectRoles(userID string) ([]*ProjectRole, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	roles := make([]*ProjectRole, 0)
+	for pid, members := range m.data.ProjectMembers {
+		for _, mb := range members {
+			if mb.UserID == userID {
+				roles = append(roles, &ProjectRole{ProjectID: pid, Role: mb.Role})
+			}
+		}
+	}
+
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].ProjectID < roles[j].ProjectID
+	})
+
+	return roles, nil
+}
+
+// GetLabBookingsForWorklist returns TENTATIVE and CONFIRMED bookings of the `Lab`
+// starting on the given `date` string. The `date` string is in the format of `2020-04-22`.
+func (m Mock) GetLabBookingsForWorklist(lab Lab, date string) ([]*LabBooking, error) {
+	loc, _ := time.LoadLocation(Location)
+
+	dtime, err := time.ParseInLocation("2006-01-02", date, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.getLabBookings(lab, dtime, dtime.Add(86399*time.Second), true)
+}
+
+// GetLabBookingsForReport returns bookings in all status of the given `Lab` in a date range
+// of `[from, to]`. The `from` and `to` date strings are in the format of `2020-04-22`.
+func (m Mock) GetLabBookingsForReport(lab Lab, from, to string) ([]*LabBooking, error) {
+	loc, _ := time.LoadLocation(Location)
+
+	dfrom, err := time.ParseInLocation("2006-01-02", from, loc)
+	if err != nil {
+		return nil, err
+	}
+	dto, err := time.ParseInLocation("2006-01-02", to, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.getLabBookings(lab, dfrom, dto.Add(86399*time.Second), false)
+}
+
+// getLabBookings selects bookings of the `lab` within the time range of `[from, to]`.
+// The lab of a booking is matched by the description regex of the `lab`.
+func (m Mock) getLabBookings(lab Lab, from, to time.Time, forWorklist bool) ([]*LabBooking, error) {
+
+	labPat, err := lab.GetDescriptionRegex()
+	if err != nil {
+		return nil, err
+	}
+
+	bookings := make([]*LabBooking, 0)
+	for _, b := range m.data.LabBookings {
+
+		if !labPat.MatchString(strings.ToUpper(b.Lab)) {
+			continue
+		}
+
+		if b.StartTime.After(to) || b.EndTime.Before(from) {
+			continue
+		}
+
+		if forWorklist {
+			// for worklist, we only want the event's `start time` later or at `from`.
+			if b.StartTime.Before(from) {
+				continue
+			}
+
+			// for worklist, only confirmed and tentative bookings are needed.
+			if s := strings.ToUpper(b.Status); s != "CONFIRMED" && s != "TENTATIVE" {
+				continue
+			}
+		}
+
+		bookings = append(bookings, b)
+	}
+
+	sort.Slice(bookings, func(i, j int) bool {
+		return bookings[i].StartTime.Before(bookings[j].StartTime)
+	})
+
+	return bookings, nil
+}
+
+// GetExperimentersForSharedAnatomicalMR returns active operators of EEG and MEG bookings
+// within three months from now.
+func (m Mock) GetExperimentersForSharedAnatomicalMR() ([]*User, error) {
+
+	now := time.Now()
+
+	experimenters := make([]*User, 0)
+	added := make(map[string]bool)
+	for _, lab := range []Lab{EEG, MEG} {
+		bookings, err := m.getLabBookings(lab, now.AddDate(0, -3, 0), now.AddDate(0, 3, 0), false)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, b := range bookings {
+			if added[b.Operator.ID] {
+				continue
+			}
+			u, err := m.GetUser(b.Operator.ID)
+			if err != nil {
+				continue
+			}
+			if u.Status == UserStatusCheckedIn || u.Status == UserStatusCheckedOutExtended {
+				experimenters = append(experimenters, u)
+				added[u.ID] = true
+			}
+		}
+	}
+
+	return experimenters, nil
+}
@@ -0,0 +1,157 @@
+package pdb
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMockPendingActions(t *testing.T) {
+	m, err := NewMock("testdata/fixture.yml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	acts, err := m.GetProjectPendingActions()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if act, ok := acts["3010000.01"]; !ok || len(act.Members) != 2 || act.Storage.QuotaGb != 100 {
+		t.Fatalf("unexpected pending actions: %+v", acts)
+	}
+
+	if err := m.DelProjectPendingActions(acts); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if acts, _ := m.GetProjectPendingActions(); len(acts) != 0 {
+		t.Errorf("pending actions not deleted: %+v", acts)
+	}
+}
+
+func TestMockLabBookings(t *testing.T) {
+	m, err := NewMock("testdata/fixture.yml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// the rejected booking is not in the worklist, but in the report.
+	bookings, err := m.GetLabBookingsForWorklist(MRI, "2023-04-28")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(bookings) != 1 || bookings[0].Lab != "PRISMA" {
+		t.Errorf("unexpected worklist bookings: %+v", bookings)
+	}
+
+	bookings, err = m.GetLabBookingsForReport(MRI, "2023-04-01", "2023-04-30")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(bookings) != 2 {
+		t.Errorf("unexpected report bookings: %+v", bookings)
+	}
+
+	bookings, err = m.GetLabBookingsForReport(ALL, "2023-04-01", "2023-05-31")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(bookings) != 4 {
+		t.Errorf("unexpected report bookings: %+v", bookings)
+	}
+}
+
+func TestMockJSONFixture(t *testing.T) {
+	data, _ := json.Marshal(mockData{
+		Users: []*User{{ID: "honlee", Email: "h.lee@donders.ru.nl"}},
+	})
+
+	fixture := filepath.Join(t.TempDir(), "fixture.json")
+	if err := os.WriteFile(fixture, data, 0644); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	m, err := NewMock(fixture)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if u, err := m.GetUserByEmail("H.Lee@donders.ru.nl"); err != nil || u.ID != "honlee" {
+		t.Errorf("unexpected user: %+v %v", u, err)
+	}
+
+	if _, err := m.GetProject("3010000.01"); err == nil {
+		t.Errorf("expect error on unknown project")
+	}
+}
+
+func TestMockProjectQuota(t *testing.T) {
+	m, err := NewMock("testdata/fixture.yml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if p, err := m.GetProject("3010000.01"); err != nil || p.QuotaGb != 100 {
+		t.Fatalf("unexpected project: %+v %v", p, err)
+	}
+
+	if err := m.UpdateProjectStorageQuota("3010000.01", 200, 10); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if p, _ := m.GetProject("3010000.01"); p.QuotaGb != 200 {
+		t.Errorf("quota not updated: %d", p.QuotaGb)
+	}
+
+	users, _ := m.GetUsers(false)
+	active, _ := m.GetUsers(true)
+	if len(users)-len(active) != 1 {
+		t.Errorf("expect 1 inactive user, got %d", len(users)-len(active))
+	}
+}
+
+func TestMockWalkProjects(t *testing.T) {
+	m, err := NewMock("testdata/fixture.yml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	c, err := NewCached(m, time.Minute, nil)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	end := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		filter   ProjectFilter
+		expected []string
+	}{
+		{ProjectFilter{}, []string{"3010000.01", "3010000.02"}},
+		{ProjectFilter{Status: []ProjectStatus{ProjectStatusActive}}, []string{"3010000.01"}},
+		{ProjectFilter{Status: []ProjectStatus{ProjectStatusInactive}}, []string{"3010000.02"}},
+		{ProjectFilter{EndBefore: end}, []string{"3010000.02"}},
+		{ProjectFilter{EndAfter: end, Owner: "rendbru"}, []string{"3010000.01"}},
+		{ProjectFilter{Owner: "nobody"}, []string{}},
+	}
+
+	// the mock doesn't implement the `ProjectWalker`; the projects are filtered by the walk.
+	for _, p := range []PDB{m, c} {
+		for _, tc := range cases {
+			pids := []string{}
+			if err := WalkProjects(context.Background(), p, tc.filter, func(prj *Project) error {
+				pids = append(pids, prj.ID)
+				return nil
+			}); err != nil {
+				t.Fatalf("%s", err)
+			}
+			if !reflect.DeepEqual(pids, tc.expected) {
+				t.Errorf("%T %+v: unexpected projects %v", p, tc.filter, pids)
+			}
+		}
+	}
+}
@@ -2,7 +2,7 @@ package pdb
 
 import (
 	"fmt"
-	"regexp"
+	"strings"
 	"time"
 )
 
@@ -17,7 +17,18 @@ type Member struct {
 	Timestamp time.Time `json:"-"`
 }
 
+// ProjectRole defines the data structure of the role of a user in a project, as registered
+// in the project database.
+type ProjectRole struct {
+	ProjectID string `json:"projectID"`
+	Role      string `json:"role"`
+}
+
 // Storage defines the data structure for the storage resource of a project.
+//
+// The `System` is not registered in the project database; it is left empty in the
+// pending actions retrieved from the project database, and determined by the caller
+// before the actions are executed.
 type Storage struct {
 	QuotaGb int    `json:"quotaGb"`
 	System  string `json:"system"`
@@ -63,6 +74,19 @@ type Project struct {
 	Status ProjectStatus `json:"status"`
 	Start  time.Time     `json:"start"`
 	End    time.Time     `json:"end"`
+	// QuotaGb is the storage quota of the project granted in the project database.
+	QuotaGb int `json:"quotaGb"`
+}
+
+// ProjectFilter selects projects by status, owner and end date.  The empty fields select
+// all projects.
+type ProjectFilter struct {
+	Status []ProjectStatus
+	// Owner is the user id of the project owner.
+	Owner string
+	// EndAfter and EndBefore bound the end date of the projects.
+	EndAfter  time.Time
+	EndBefore time.Time
 }
 
 // ProjectStatus defines PDB project status.
@@ -77,6 +101,19 @@ const (
 	ProjectStatusInactive
 )
 
+// String implements the interface for `fmt.Stringer`.  It returns the
+// human-readable name of the status.
+func (s ProjectStatus) String() string {
+	n := "Unknown"
+	switch s {
+	case ProjectStatusActive:
+		n = "Active"
+	case ProjectStatusInactive:
+		n = "Inactive"
+	}
+	return n
+}
+
 // ProjectKind defines PDB project type, either Research or Dataset.
 type ProjectKind int
 
@@ -87,6 +124,19 @@ const (
 	Dataset
 )
 
+// String implements the interface for `fmt.Stringer`.  It returns the
+// human-readable name of the kind.
+func (k ProjectKind) String() string {
+	n := "Unknown"
+	switch k {
+	case Research:
+		n = "Research"
+	case Dataset:
+		n = "Dataset"
+	}
+	return n
+}
+
 // User defines the data structure of a user in the project database.
 type User struct {
 	ID         string       `json:"userID"`
@@ -107,6 +157,12 @@ func (u User) DisplayName() string {
 	}
 }
 
+// UserFilter selects users by status and function.  Empty lists select all users.
+type UserFilter struct {
+	Status   []UserStatus
+	Function []UserFunction
+}
+
 // UserFunction defines PDB user function.
 // TODO: refine the fine-grained user functions.
 type UserFunction int
@@ -140,8 +196,51 @@ const (
 	UserFunctionSeniorResearcher
 	// UserFunctionUnknown for unknown/unexpected user function.
 	UserFunctionUnknown
+	// UserFunctionResearchFellow for research fellows.
+	UserFunctionResearchFellow
+	// UserFunctionStudentAssistant for student assistants.
+	UserFunctionStudentAssistant
 )
 
+// userFunctionNames are the human-readable names of the user functions.
+var userFunctionNames = map[UserFunction]string{
+	UserFunctionOther:                 "Other",
+	UserFunctionPrincipalInvestigator: "PrincipalInvestigator",
+	UserFunctionTrainee:               "Trainee",
+	UserFunctionPhD:                   "PhD",
+	UserFunctionPostdoc:               "Postdoc",
+	UserFunctionResearchSupport:       "ResearchSupport",
+	UserFunctionOtherSupport:          "OtherSupport",
+	UserFunctionSupportingStaff:       "SupportingStaff",
+	UserFunctionResearchStaff:         "ResearchStaff",
+	UserFunctionResearchAssistant:     "ResearchAssistant",
+	UserFunctionStaffScientist:        "StaffScientist",
+	UserFunctionOtherResearcher:       "OtherResearcher",
+	UserFunctionSeniorResearcher:      "SeniorResearcher",
+	UserFunctionResearchFellow:        "ResearchFellow",
+	UserFunctionStudentAssistant:      "StudentAssistant",
+}
+
+// String implements the interface for `fmt.Stringer`.  It returns the
+// human-readable name of the function.
+func (f UserFunction) String() string {
+	if n, ok := userFunctionNames[f]; ok {
+		return n
+	}
+	return "Unknown"
+}
+
+// ParseUserFunction returns the user function of the human-readable `name`, compared
+// case-insensitively.
+func ParseUserFunction(name string) (UserFunction, error) {
+	for f, n := range userFunctionNames {
+		if strings.EqualFold(n, name) {
+			return f, nil
+		}
+	}
+	return UserFunctionUnknown, fmt.Errorf("unknown user function: %s", name)
+}
+
 // UserStatus defines PDB user status.
 type UserStatus int
 
@@ -175,71 +274,23 @@ func (u UserStatus) String() string {
 	return s
 }
 
-// Lab defines an enumerator for the lab categories.
-type Lab int
-
-// Set implements the interface for flag.Var().
-func (l *Lab) Set(v string) error {
-	switch v {
-	case "EEG":
-		*l = EEG
-	case "MEG":
-		*l = MEG
-	case "MRI":
-		*l = MRI
-	case "ALL":
-		*l = ALL
-	default:
-		return fmt.Errorf("unknown modality: %s", v)
-	}
-	return nil
+// IsActive checks whether the user of the status is active, i.e. checked in or with
+// extended checkout.
+func (u UserStatus) IsActive() bool {
+	return u == UserStatusCheckedIn || u == UserStatusCheckedOutExtended
 }
 
-// String implements the interface for flag.Var().  It returns the
-// name of the lab modality.
-func (l *Lab) String() string {
-	s := "unknown"
-	switch *l {
-	case EEG:
-		s = "EEG"
-	case MEG:
-		s = "MEG"
-	case MRI:
-		s = "MRI"
-	case ALL:
-		s = "ALL"
-	}
-	return s
-}
-
-// GetDescriptionRegex returns a regular expression pattern for the description of
-// a modality.
-func (l *Lab) GetDescriptionRegex() (*regexp.Regexp, error) {
-	switch *l {
-	case EEG:
-		return regexp.MustCompile(".*(EEG).*"), nil
-	case MEG:
-		return regexp.MustCompile(".*(MEG).*"), nil
-	case MRI:
-		return regexp.MustCompile(".*(SKYRA|PRISMA(FIT){0,1}).*"), nil
-	case ALL:
-		return regexp.MustCompile(".*"), nil
-	default:
-		return nil, fmt.Errorf("unknown modality: %s", l.String())
+// ParseUserStatus returns the user status of the human-readable `name`, compared
+// case-insensitively.
+func ParseUserStatus(name string) (UserStatus, error) {
+	for _, s := range []UserStatus{UserStatusCheckedIn, UserStatusCheckedOut, UserStatusCheckedOutExtended, UserStatusTentative} {
+		if strings.EqualFold(s.String(), name) {
+			return s, nil
+		}
 	}
+	return UserStatusUnknown, fmt.Errorf("unknown user status: %s", name)
 }
 
-const (
-	// EEG is a lab modality of the EEG labs.
-	EEG Lab = iota
-	// MEG is a lab modality of the MEG labs.
-	MEG
-	// MRI is a lab modality of the MRI labs.
-	MRI
-	// all lab modalities
-	ALL
-)
-
 // LabBooking defines the data structure of a booking event in the lab calendar.
 type LabBooking struct {
 	// Project is the id of the project to which the experiment belongs.
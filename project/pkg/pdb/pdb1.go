@@ -3,9 +3,11 @@ package pdb
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dccn-tg/tg-toolset-golang/pkg/config"
@@ -13,9 +15,298 @@ import (
 	"github.com/go-sql-driver/mysql"
 )
 
+// v1Query is a SQL query on the legacy project database.  The `name` describes the query
+// in the errors.
+type v1Query struct {
+	name string
+	sql  string
+}
+
+// errorf wraps the error `err` of the query with the query name.
+func (q v1Query) errorf(err error) error {
+	return fmt.Errorf("%s: %w", q.name, err)
+}
+
+// queries on the legacy project database; they are prepared once per pool of connections.
+var (
+	queryPendingActions = v1Query{
+		name: "select pending actions",
+		sql: `
+	SELECT
+		a.user_id,a.project_id,a.role,a.created,a.action,b.calculatedProjectSpace
+	FROM
+		projectmembers as a,
+		projects as b
+	WHERE
+		a.activated='no' AND 
+		b.calculatedProjectSpace > 0 AND
+		a.project_id=b.id
+	ORDER BY
+		a.created
+	`,
+	}
+
+	// queryDelPendingActions sets actions concerning pid/uid created before the creation
+	// timestamp of the perfomed actions.
+	queryDelPendingActions = v1Query{
+		name: "update pending actions",
+		sql: `
+	UPDATE
+		projectmembers
+	SET
+		activated=?, updated=?
+	WHERE
+		project_id=? AND user_id=? AND created<=?
+	`,
+	}
+
+	queryProjects = v1Query{
+		name: "select projects",
+		sql: `
+	SELECT
+		id, projectName, owner_id, calculatedProjectSpace
+	FROM
+		projects
+	`,
+	}
+
+	queryActiveProjects = v1Query{
+		name: "select active projects",
+		sql: `
+	SELECT
+		id, projectName, owner_id, calculatedProjectSpace
+	FROM
+		projects
+	WHERE
+		calculatedProjectSpace > 0
+	`,
+	}
+
+	queryProject = v1Query{
+		name: "select project",
+		sql: `
+	SELECT
+		id, projectName, owner_id, calculatedProjectSpace
+	FROM
+		projects
+	WHERE
+		id=?
+	`,
+	}
+
+	queryDelProjectRoles = v1Query{
+		name: "delete project roles",
+		sql:  "DELETE FROM acls WHERE project=?",
+	}
+
+	queryAddProjectRole = v1Query{
+		name: "insert project role",
+		sql:  "INSERT INTO acls (project, projectRole, user) VALUES (?,?,?)",
+	}
+
+	queryUpdateQuota = v1Query{
+		name: "update project quota",
+		sql: `
+	UPDATE
+		projects
+	SET
+		totalProjectSpace=?, usedProjectSpace=?
+	WHERE
+		id=?
+	`,
+	}
+
+	queryUser = v1Query{
+		name: "select user",
+		sql: `
+	SELECT
+		id,firstName,middleName,lastName,email,function,status
+	FROM
+		users
+	WHERE
+		id = ?
+	`,
+	}
+
+	// queryUserByEmail relies on the case insensitive comparison of MariaDB; no need for
+	// case conversion.
+	queryUserByEmail = v1Query{
+		name: "select user by email",
+		sql: `
+	SELECT
+		id,firstName,middleName,lastName,email,function,status
+	FROM
+		users
+	WHERE
+		email = ?
+	`,
+	}
+
+	queryUserProjectRoles = v1Query{
+		name: "select user project roles",
+		sql: `
+	SELECT
+		project, projectRole
+	FROM
+		acls
+	WHERE
+		user = ?
+	ORDER BY
+		project
+	`,
+	}
+
+	queryLabBookings = v1Query{
+		name: "select lab bookings",
+		sql: `
+	SELECT
+		a.id,a.project_id,a.subj_ses,a.start_time,a.stop_time,a.status,a.user_id,b.projectName,c.description
+	FROM
+		calendar_items_new AS a,
+		projects AS b,
+		calendars AS c
+	WHERE
+		a.status IN ('CONFIRMED','TENTATIVE') AND
+		a.subj_ses NOT IN ('Cancellation','0') AND
+		a.start_date = ? AND
+		a.project_id = b.id AND
+		a.calendar_id = c.id
+	ORDER BY
+		a.start_time
+	`,
+	}
+
+	queryExperimentersForSharedAnatomicalMR = v1Query{
+		name: "select experimenters for shared anatomical MR",
+		sql: `
+	SELECT
+    	DISTINCT id,firstName,middleName,lastName,email,function,status
+	FROM
+    	users
+	WHERE
+		id IN (
+			SELECT 
+				experimenter_id
+			FROM 
+				experiments
+			WHERE 
+				imaging_method_id IN ('eeg','meg151','meg275') AND
+				date_sub(startingDate, interval 3 month) < now() AND
+				date_add(endingDate, interval 3 month) > now()
+		) AND
+		status IN ('checked in','checked out extended')
+	GROUP BY id;
+	`,
+	}
+)
+
+var (
+	// sharedV1DBs are the pools of connections shared by the `V1` clients, one per
+	// configuration.
+	sharedV1DBs = make(map[config.DBConfiguration]*v1DB)
+	sharedV1Mux sync.Mutex
+)
+
 // V1 implements interfaces of the legacy project database implemented with MySQL database.
+//
+// The queries are made on a pool of connections shared by the `V1` clients of the same
+// configuration, with statements prepared once per pool.  The updates of the project
+// members and the storage quota are made in transactions.
 type V1 struct {
 	config config.DBConfiguration
+	db     *v1DB
+}
+
+// NewV1 returns the `V1` client of the legacy project database with the given `config`.
+// The pool of connections is created with the limits of the `config` on the first call,
+// and reused afterwards.
+func NewV1(config config.DBConfiguration) (V1, error) {
+	sharedV1Mux.Lock()
+	defer sharedV1Mux.Unlock()
+
+	if db, ok := sharedV1DBs[config]; ok {
+		return V1{config: config, db: db}, nil
+	}
+
+	db, err := newClientMySQL(config)
+	if err != nil {
+		return V1{}, err
+	}
+
+	if config.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	if config.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
+
+	v1db := newV1DB(db)
+	sharedV1DBs[config] = v1db
+
+	return V1{config: config, db: v1db}, nil
+}
+
+// v1DB is the pool of connections to the legacy project database, together with the
+// statements prepared on it.
+type v1DB struct {
+	*sql.DB
+	stmts map[string]*sql.Stmt
+	mux   sync.Mutex
+}
+
+// newV1DB returns the `v1DB` on the pool of connections `db`.
+func newV1DB(db *sql.DB) *v1DB {
+	return &v1DB{
+		DB:    db,
+		stmts: make(map[string]*sql.Stmt),
+	}
+}
+
+// prepare returns the statement of the query `q`, which is prepared on the first call.
+func (db *v1DB) prepare(q v1Query) (*sql.Stmt, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if stmt, ok := db.stmts[q.sql]; ok {
+		return stmt, nil
+	}
+
+	stmt, err := db.Prepare(q.sql)
+	if err != nil {
+		return nil, fmt.Errorf("prepare %s: %w", q.name, err)
+	}
+	db.stmts[q.sql] = stmt
+
+	return stmt, nil
+}
+
+// inTx runs the function `f` in a transaction.  The transaction is committed if `f`
+// succeeds, and rolled back otherwise.
+//
+// The statements used in the transaction via `tx.Stmt` should be prepared before, so
+// that they are not prepared again on the connection of the transaction.
+func (db *v1DB) inTx(f func(tx *sql.Tx) error) error {
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	if err := f(tx); err != nil {
+		if rerr := tx.Rollback(); rerr != nil {
+			log.Errorf("cannot rollback transaction: %s", rerr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
 }
 
 func (v1 V1) GetUsers(activeOnly bool) ([]*User, error) {
@@ -30,31 +321,14 @@ func (v1 V1) GetProjectPendingActions() (map[string]*DataProjectUpdate, error) {
 
 	actions := make(map[string]*DataProjectUpdate)
 
-	db, err := newClientMySQL(v1.config)
+	stmt, err := v1.db.prepare(queryPendingActions)
 	if err != nil {
 		return actions, err
 	}
 
-	// make sure the db client is closed before exiting the function.
-	defer db.Close()
-
-	query := `
-	SELECT
-		a.user_id,a.project_id,a.role,a.created,a.action,b.calculatedProjectSpace
-	FROM
-		projectmembers as a,
-		projects as b
-	WHERE
-		a.activated='no' AND 
-		b.calculatedProjectSpace > 0 AND
-		a.project_id=b.id
-	ORDER BY
-		a.created
-	`
-
-	rows, err := db.Query(query)
+	rows, err := stmt.Query()
 	if err != nil {
-		return nil, err
+		return nil, queryPendingActions.errorf(err)
 	}
 	defer rows.Close()
 
@@ -82,7 +356,7 @@ func (v1 V1) GetProjectPendingActions() (map[string]*DataProjectUpdate, error) {
 		)
 
 		if err := rows.Scan(&uid, &pid, &role, &ctime, &action, &quota); err != nil {
-			return nil, err
+			return nil, queryPendingActions.errorf(err)
 		}
 
 		// there is already a pending role action on pid+uid, check which one we should consider
@@ -115,19 +389,16 @@ func (v1 V1) GetProjectPendingActions() (map[string]*DataProjectUpdate, error) {
 		log.Debugf("%s user %s to role %s in project %s", action, uid, role, pid)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, queryPendingActions.errorf(err)
 	}
 
 	// convert rawActions map into actions
-	// NOTE: we are missing system parameter so that all actions are assumed to be
-	//       executed on the `netapp`, the default filer system.
 	for _, a := range rawActions {
 		if _, ok := actions[a.pid]; !ok {
 			actions[a.pid] = &DataProjectUpdate{
 				Members: []Member{},
 				Storage: Storage{
 					QuotaGb: a.quota,
-					System:  "netapp",
 				},
 			}
 		}
@@ -148,15 +419,8 @@ func (v1 V1) GetProjectPendingActions() (map[string]*DataProjectUpdate, error) {
 }
 
 // DelProjectPendingActions performs deletion on the pending-role actions from the project
-// database.
+// database.  The actions are deleted in one transaction.
 func (v1 V1) DelProjectPendingActions(actions map[string]*DataProjectUpdate) error {
-	db, err := newClientMySQL(v1.config)
-	if err != nil {
-		return err
-	}
-
-	// make sure the db client is closed before exiting the function.
-	defer db.Close()
 
 	type rawAction struct {
 		pid        string
@@ -176,61 +440,41 @@ func (v1 V1) DelProjectPendingActions(actions map[string]*DataProjectUpdate) err
 		}
 	}
 
-	// prepare sql to set actions concerning pid/uid created before the creation timestamp of
-	// the perfomed actions.
-	query := `
-	UPDATE
-		projectmembers
-	SET
-		activated=?, updated=?
-	WHERE
-		project_id=? AND user_id=? AND created<=?
-	`
-
-	stmt, err := db.Prepare(query)
+	stmt, err := v1.db.prepare(queryDelPendingActions)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
 
-	for _, a := range rawActions {
-		if _, err := stmt.Exec("yes", time.Now(), a.pid, a.uid, a.createTime); err != nil {
-			return err
+	return v1.db.inTx(func(tx *sql.Tx) error {
+		stmt := tx.Stmt(stmt)
+		for _, a := range rawActions {
+			if _, err := stmt.Exec("yes", time.Now(), a.pid, a.uid, a.createTime); err != nil {
+				return fmt.Errorf("%s of user %s in project %s: %w", queryDelPendingActions.name, a.uid, a.pid, err)
+			}
 		}
-	}
 
-	return nil
+		return nil
+	})
 }
 
 // GetProjects retrieves list of project identifiers from the project database.
 func (v1 V1) GetProjects(activeOnly bool) ([]*Project, error) {
 
-	db, err := newClientMySQL(v1.config)
-	if err != nil {
-		return nil, err
+	q := queryProjects
+	if activeOnly {
+		q = queryActiveProjects
 	}
-	defer db.Close()
 
-	// prepare db query
-	query := `
-	SELECT
-		id, projectName, owner_id, calculatedProjectSpace
-	FROM
-		projects
-	`
-
-	if activeOnly {
-		query += `
-		WHERE
-			calculatedProjectSpace > 0
-		`
+	stmt, err := v1.db.prepare(q)
+	if err != nil {
+		return nil, err
 	}
 
 	projects := make([]*Project, 0)
 
-	rows, err := db.Query(query)
+	rows, err := stmt.Query()
 	if err != nil {
-		return nil, err
+		return nil, q.errorf(err)
 	}
 	defer rows.Close()
 
@@ -241,19 +485,20 @@ func (v1 V1) GetProjects(activeOnly bool) ([]*Project, error) {
 		var cspace int
 		err := rows.Scan(&pid, &pname, &oid, &cspace)
 		if err != nil {
-			return nil, err
+			return nil, q.errorf(err)
 		}
 
 		projects = append(projects, &Project{
-			ID:     pid,
-			Name:   pname,
-			Owner:  oid,
-			Status: parseProjectStatusByCalculatedSpace(cspace),
+			ID:      pid,
+			Name:    pname,
+			Owner:   oid,
+			Status:  parseProjectStatusByCalculatedSpace(cspace),
+			QuotaGb: cspace,
 		})
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, q.errorf(err)
 	}
 
 	return projects, nil
@@ -262,27 +507,10 @@ func (v1 V1) GetProjects(activeOnly bool) ([]*Project, error) {
 // GetProject retrieves attributes of a project.
 func (v1 V1) GetProject(projectID string) (*Project, error) {
 
-	db, err := newClientMySQL(v1.config)
+	stmt, err := v1.db.prepare(queryProject)
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
-
-	// prepare db query
-	query := `
-	SELECT
-		id, projectName, owner_id, calculatedProjectSpace
-	FROM
-		projects
-	WHERE
-		id=?
-	`
-
-	stmt, err := db.Prepare(query)
-	if err != nil {
-		return nil, err
-	}
-	defer stmt.Close()
 
 	var pid string
 	var pname string
@@ -291,14 +519,15 @@ func (v1 V1) GetProject(projectID string) (*Project, error) {
 
 	err = stmt.QueryRow(projectID).Scan(&pid, &pname, &oid, &cspace)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s %s: %w", queryProject.name, projectID, err)
 	}
 
 	return &Project{
-		ID:     pid,
-		Name:   pname,
-		Owner:  oid,
-		Status: parseProjectStatusByCalculatedSpace(cspace),
+		ID:      pid,
+		Name:    pname,
+		Owner:   oid,
+		Status:  parseProjectStatusByCalculatedSpace(cspace),
+		QuotaGb: cspace,
 	}, nil
 }
 
@@ -313,118 +542,123 @@ func parseProjectStatusByCalculatedSpace(space int) ProjectStatus {
 
 // UpdateProjectMembers updates the project database with the given project roles.
 func (v1 V1) UpdateProjectMembers(project string, members []Member) error {
-
-	db, err := newClientMySQL(v1.config)
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-
-	return updateProjectRoles(db, project, members)
+	return updateProjectRoles(v1.db, project, members)
 }
 
 // UpdateProjectStorageQuota updates the project database with the current project storage usage.
 func (v1 V1) UpdateProjectStorageQuota(project string, quotaGB, usageGB int) error {
-
-	db, err := newClientMySQL(v1.config)
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-
-	return updateQuota(db, project, quotaGB, usageGB)
+	return updateQuota(v1.db, project, quotaGB, usageGB)
 }
 
 // GetUser gets the user identified by the given uid in the project database.
 // It returns the pointer to the user data represented in the User data structure.
 func (v1 V1) GetUser(uid string) (*User, error) {
-
-	db, err := newClientMySQL(v1.config)
+	stmt, err := v1.db.prepare(queryUser)
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
-
-	return selectUser(db, "id = ?", uid)
+	return selectUser(stmt, queryUser, uid)
 }
 
 // GetUserByEmail gets the user identified by the given email address.
 func (v1 V1) GetUserByEmail(email string) (*User, error) {
-	db, err := newClientMySQL(v1.config)
+	stmt, err := v1.db.prepare(queryUserByEmail)
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
-
-	// MariaDB query in case insensitive way; no need for case conversion.
-	return selectUser(db, "email = ?", email)
+	return selectUser(stmt, queryUserByEmail, email)
 }
 
-// GetLabBookingsForWorklist retrieves TENTATIVE and CONFIRMED calendar bookings concerning the given `Lab` on a given `date` string.
-// The `date` string is in the format of `2020-04-22`.
-func (v1 V1) GetLabBookingsForWorklist(lab Lab, date string) ([]*LabBooking, error) {
-	bookings := make([]*LabBooking, 0)
+// GetUserProjectRoles retrieves the roles of the user `uid` in the projects.
+func (v1 V1) GetUserProjectRoles(uid string) ([]*ProjectRole, error) {
 
-	db, err := newClientMySQL(v1.config)
+	stmt, err := v1.db.prepare(queryUserProjectRoles)
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
-	query := `
-	SELECT
-		a.id,a.project_id,a.subj_ses,a.start_time,a.stop_time,a.status,a.user_id,b.projectName,c.description
-	FROM
-		calendar_items_new AS a,
-		projects AS b,
-		calendars AS c
-	WHERE
-		a.status IN ('CONFIRMED','TENTATIVE') AND
-		a.subj_ses NOT IN ('Cancellation','0') AND
-		a.start_date = ? AND
-		a.project_id = b.id AND
-		a.calendar_id = c.id
-	ORDER BY
-		a.start_time
-	`
-
-	rows, err := db.Query(query, date)
+	rows, err := stmt.Query(uid)
 	if err != nil {
-		return nil, err
+		return nil, queryUserProjectRoles.errorf(err)
 	}
 	defer rows.Close()
 
+	roles := make([]*ProjectRole, 0)
+	for rows.Next() {
+		r := &ProjectRole{}
+		if err := rows.Scan(&r.ProjectID, &r.Role); err != nil {
+			return nil, queryUserProjectRoles.errorf(err)
+		}
+		roles = append(roles, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, queryUserProjectRoles.errorf(err)
+	}
+
+	return roles, nil
+}
+
+// GetLabBookingsForWorklist retrieves TENTATIVE and CONFIRMED calendar bookings concerning the given `Lab` on a given `date` string.
+// The `date` string is in the format of `2020-04-22`.
+func (v1 V1) GetLabBookingsForWorklist(lab Lab, date string) ([]*LabBooking, error) {
+
 	// regular expression for matching lab description
 	labPat, err := lab.GetDescriptionRegex()
 	if err != nil {
 		return nil, err
 	}
 
-	// regular expression for spliting subject and session identifiers
-	subjsesSpliter := regexp.MustCompile(`\s*(-)\s*`)
+	stmt, err := v1.db.prepare(queryLabBookings)
+	if err != nil {
+		return nil, err
+	}
 
-	// loop over results of the query
-	for rows.Next() {
-		var (
-			id      string
-			pid     string
-			subjSes string
-			stime   []uint8
-			etime   []uint8
-			status  string
-			uid     string
-			pname   string
-			labdesc string
-		)
+	// the operators of the bookings are looked up after all the bookings are read, so
+	// that the connection of the query is released to the pool in the meantime.
+	type booking struct {
+		*LabBooking
+		uid string
+	}
 
-		err := rows.Scan(&id, &pid, &subjSes, &stime, &etime, &status, &uid, &pname, &labdesc)
+	found, err := func() ([]booking, error) {
+		rows, err := stmt.Query(date)
 		if err != nil {
-			return nil, err
+			return nil, queryLabBookings.errorf(err)
 		}
+		defer rows.Close()
+
+		// regular expression for spliting subject and session identifiers
+		subjsesSpliter := regexp.MustCompile(`\s*(-)\s*`)
+
+		found := make([]booking, 0)
+
+		// loop over results of the query
+		for rows.Next() {
+			var (
+				id      string
+				pid     string
+				subjSes string
+				stime   []uint8
+				etime   []uint8
+				status  string
+				uid     string
+				pname   string
+				labdesc string
+			)
+
+			err := rows.Scan(&id, &pid, &subjSes, &stime, &etime, &status, &uid, &pname, &labdesc)
+			if err != nil {
+				return nil, queryLabBookings.errorf(err)
+			}
+
+			log.Debugf("%s %s %s %s", id, pid, subjSes, labdesc)
 
-		log.Debugf("%s %s %s %s", id, pid, subjSes, labdesc)
+			m := labPat.FindStringSubmatch(strings.ToUpper(labdesc))
+			if len(m) < 2 {
+				continue
+			}
 
-		if m := labPat.FindStringSubmatch(strings.ToUpper(labdesc)); len(m) >= 2 {
 			var (
 				subj string
 				sess string
@@ -451,29 +685,41 @@ func (v1 V1) GetLabBookingsForWorklist(lab Lab, date string) ([]*LabBooking, err
 				continue
 			}
 
-			pdbUser, err := selectUser(db, "id = ?", uid)
-			if err != nil {
-				log.Errorf("cannot find user in PDB: %s", uid)
-				continue
-			}
-
-			bookings = append(bookings, &LabBooking{
-				Project:      pid,
-				Subject:      subj,
-				Session:      sess,
-				Lab:          m[1],
-				ProjectTitle: pname,
-				Operator:     *pdbUser,
-				StartTime:    st,
-				EndTime:      et,
-				Status:       status,
+			found = append(found, booking{
+				LabBooking: &LabBooking{
+					Project:      pid,
+					Subject:      subj,
+					Session:      sess,
+					Lab:          m[1],
+					ProjectTitle: pname,
+					StartTime:    st,
+					EndTime:      et,
+					Status:       status,
+				},
+				uid: uid,
 			})
 		}
-	}
 
-	if err := rows.Err(); err != nil {
+		if err := rows.Err(); err != nil {
+			return nil, queryLabBookings.errorf(err)
+		}
+		return found, nil
+	}()
+	if err != nil {
 		return nil, err
 	}
+
+	bookings := make([]*LabBooking, 0, len(found))
+	for _, b := range found {
+		pdbUser, err := v1.GetUser(b.uid)
+		if err != nil {
+			log.Errorf("cannot find user in PDB: %s", b.uid)
+			continue
+		}
+		b.Operator = *pdbUser
+		bookings = append(bookings, b.LabBooking)
+	}
+
 	return bookings, nil
 }
 
@@ -487,71 +733,38 @@ func (v1 V1) GetLabBookingsForReport(lab Lab, from, to string) ([]*LabBooking, e
 // Those are experiments of projects that are conducting data acquisition using the
 // EEG and MEG modalities.
 func (v1 V1) GetExperimentersForSharedAnatomicalMR() ([]*User, error) {
-	db, err := newClientMySQL(v1.config)
+
+	q := queryExperimentersForSharedAnatomicalMR
+
+	stmt, err := v1.db.prepare(q)
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
-	query := `
-	SELECT
-    	DISTINCT id,firstName,middleName,lastName,email,function,status
-	FROM
-    	users
-	WHERE
-		id IN (
-			SELECT 
-				experimenter_id
-			FROM 
-				experiments
-			WHERE 
-				imaging_method_id IN ('eeg','meg151','meg275') AND
-				date_sub(startingDate, interval 3 month) < now() AND
-				date_add(endingDate, interval 3 month) > now()
-		) AND
-		status IN ('checked in','checked out extended')
-	GROUP BY id;
-	`
-
-	rows, err := db.Query(query)
+	rows, err := stmt.Query()
 	if err != nil {
-		return nil, err
+		return nil, q.errorf(err)
 	}
 	defer rows.Close()
 
 	experimenters := make([]*User, 0)
 
 	for rows.Next() {
-
-		var (
-			id         string
-			firstname  string
-			middlename string
-			lastname   string
-			email      string
-			function   string
-			status     string
-		)
-
-		if err := rows.Scan(&id, &firstname, &middlename, &lastname, &email, &function, &status); err != nil {
-			return nil, err
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, q.errorf(err)
 		}
+		experimenters = append(experimenters, u)
+	}
 
-		experimenters = append(experimenters, &User{
-			ID:         id,
-			Firstname:  firstname,
-			Middlename: middlename,
-			Lastname:   lastname,
-			Email:      email,
-			Function:   parseUserFunction(function),
-			Status:     parseUserStatus(status),
-		})
+	if err := rows.Err(); err != nil {
+		return nil, q.errorf(err)
 	}
 
 	return experimenters, nil
 }
 
-// newClientMySQL establishes the MySQL client connection with the configuration.
+// newClientMySQL opens the pool of MySQL client connections with the configuration.
 func newClientMySQL(config config.DBConfiguration) (*sql.DB, error) {
 	mycfg := mysql.Config{
 		Net:                  "tcp",
@@ -567,116 +780,90 @@ func newClientMySQL(config config.DBConfiguration) (*sql.DB, error) {
 }
 
 // updateProjectRoles updates the registry of the data-access roles of the given project
-// in the project database, according to the roles provided as a acl.RoleMap.
-func updateProjectRoles(db *sql.DB, project string, members []Member) error {
-
-	if err := db.Ping(); err != nil {
-		return fmt.Errorf("PDB not connected")
-	}
-
-	// variables for transaction statements
-	var (
-		delStmt *sql.Stmt
-		setStmt *sql.Stmt
-	)
+// in the project database in one transaction.  The existing roles are replaced by the
+// roles of the `members`; members not found in the project database are skipped.
+func updateProjectRoles(db *v1DB, project string, members []Member) error {
 
-	// start db transaction
-	tx, err := db.Begin()
+	delStmt, err := db.prepare(queryDelProjectRoles)
 	if err != nil {
 		return err
 	}
 
-	// defer function to ensure statements and transactions are closed properly
-	defer func() {
-		if delStmt != nil {
-			delStmt.Close()
-		}
-		if setStmt != nil {
-			setStmt.Close()
-		}
-		if err != nil {
-			tx.Rollback()
-		}
-		err = tx.Commit()
-	}()
-
-	// delete all acls from the project
-	delStmt, err = tx.Prepare("DELETE FROM acls WHERE project=?")
-	if err != nil {
-		return err
-	}
-	_, err = delStmt.Exec(project)
+	setStmt, err := db.prepare(queryAddProjectRole)
 	if err != nil {
 		return err
 	}
 
-	// insert new roles into the project
-	setStmt, err = tx.Prepare("INSERT INTO acls (project, projectRole, user) VALUES (?,?,?)")
+	userStmt, err := db.prepare(queryUser)
 	if err != nil {
 		return err
 	}
-	for _, m := range members {
-		// check if the user in question is available in the project database.
-		if _, err := selectUser(db, "id = ?", m.UserID); err != nil {
-			// ignore user cannot be found in the project database.
-			log.Warnf("cannot found users in pdb: %s, reason: %+v", m.UserID, err)
-			continue
+
+	return db.inTx(func(tx *sql.Tx) error {
+
+		// delete all acls from the project
+		if _, err := tx.Stmt(delStmt).Exec(project); err != nil {
+			return fmt.Errorf("%s %s: %w", queryDelProjectRoles.name, project, err)
 		}
-		log.Debugf("Updating project %s, %s: %s", project, m.Role, m.UserID)
-		_, err := setStmt.Exec(project, m.Role, m.UserID)
-		if err != nil {
-			return err
+
+		// insert new roles into the project
+		setStmt, userStmt := tx.Stmt(setStmt), tx.Stmt(userStmt)
+		for _, m := range members {
+			// check if the user in question is available in the project database.
+			if _, err := selectUser(userStmt, queryUser, m.UserID); err != nil {
+				if !errors.Is(err, sql.ErrNoRows) {
+					return err
+				}
+				// ignore user cannot be found in the project database.
+				log.Warnf("cannot found users in pdb: %s, reason: %+v", m.UserID, err)
+				continue
+			}
+			log.Debugf("Updating project %s, %s: %s", project, m.Role, m.UserID)
+			if _, err := setStmt.Exec(project, m.Role, m.UserID); err != nil {
+				return fmt.Errorf("%s %s of user %s in project %s: %w", queryAddProjectRole.name, m.Role, m.UserID, project, err)
+			}
 		}
-	}
 
-	return err
+		return nil
+	})
 }
 
-// updateQuota updates current quota usage of the given project.
-func updateQuota(db *sql.DB, project string, quotaGB, usageGB int) error {
-
-	if err := db.Ping(); err != nil {
-		return fmt.Errorf("PDB not connected")
-	}
+// updateQuota updates current quota usage of the given project in a transaction.
+func updateQuota(db *v1DB, project string, quotaGB, usageGB int) error {
 
-	query := `
-		UPDATE
-			projects
-		SET
-			totalProjectSpace=?, usedProjectSpace=?
-		WHERE
-			id=?
-	`
-
-	stmt, err := db.Prepare(query)
+	stmt, err := db.prepare(queryUpdateQuota)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
-
-	log.Debugf("Updating quota of project %s, total: %d, usage: %d", project, quotaGB, usageGB)
-	_, err = stmt.Exec(quotaGB, usageGB, project)
 
-	return err
+	return db.inTx(func(tx *sql.Tx) error {
+		log.Debugf("Updating quota of project %s, total: %d, usage: %d", project, quotaGB, usageGB)
+		if _, err := tx.Stmt(stmt).Exec(quotaGB, usageGB, project); err != nil {
+			return fmt.Errorf("%s %s: %w", queryUpdateQuota.name, project, err)
+		}
+		return nil
+	})
 }
 
-// selectUser gets the user identified by the given uid in the project database.
-//
-// The input `clauseCond` and `clauseValue` should
+// selectUser gets the user with the prepared statement `stmt` of the query `q`, which
+// selects a single user with the `args`.
 // It returns the pointer to the user data represented in the User data structure.
-func selectUser(db *sql.DB, clauseCond string, clauseValues ...interface{}) (*User, error) {
-
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("PDB not connected: %+v", err)
+func selectUser(stmt *sql.Stmt, q v1Query, args ...interface{}) (*User, error) {
+	u, err := scanUser(stmt.QueryRow(args...))
+	if err != nil {
+		return nil, fmt.Errorf("%s %v: %w", q.name, args, err)
 	}
+	return u, nil
+}
 
-	query := fmt.Sprintf(`
-	SELECT
-		id,firstName,middleName,lastName,email,function,status
-	FROM
-		users
-	WHERE %s
-	`, clauseCond)
+// rowScanner is the result row of a query, i.e. `*sql.Row` or `*sql.Rows`.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUser scans the user from the result `row` of the columns
+// id,firstName,middleName,lastName,email,function,status.
+func scanUser(row rowScanner) (*User, error) {
 
 	var (
 		id         string
@@ -688,7 +875,7 @@ func selectUser(db *sql.DB, clauseCond string, clauseValues ...interface{}) (*Us
 		status     string
 	)
 
-	if err := db.QueryRow(query, clauseValues...).Scan(&id, &firstname, &middlename, &lastname, &email, &function, &status); err != nil {
+	if err := row.Scan(&id, &firstname, &middlename, &lastname, &email, &function, &status); err != nil {
 		return nil, err
 	}
 
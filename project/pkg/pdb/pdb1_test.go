@@ -0,0 +1,173 @@
+package pdb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// newMockV1 returns the `V1` client on the SQL mock driver.  The queries are matched
+// exactly, apart from the white spaces.
+func newMockV1(t *testing.T) (V1, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("cannot create sql mock: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return V1{db: newV1DB(db)}, mock
+}
+
+// userColumns are the columns of the queries selecting users.
+var userColumns = []string{"id", "firstName", "middleName", "lastName", "email", "function", "status"}
+
+func TestV1PreparedStatements(t *testing.T) {
+	v1, mock := newMockV1(t)
+
+	// the statement is prepared only once.
+	prep := mock.ExpectPrepare(queryUser.sql)
+	prep.ExpectQuery().WithArgs("honlee").
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("honlee", "Hurng-Chun", "", "Lee", "h.lee@donders.ru.nl", "Other", "checked in"))
+	prep.ExpectQuery().WithArgs("nobody").
+		WillReturnRows(sqlmock.NewRows(userColumns))
+
+	u, err := v1.GetUser("honlee")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if u.Lastname != "Lee" || u.Status != UserStatusCheckedIn {
+		t.Errorf("unexpected user: %+v", u)
+	}
+
+	_, err = v1.GetUser("nobody")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expect ErrNoRows, got %v", err)
+	}
+	if err != nil && !strings.HasPrefix(err.Error(), queryUser.name) {
+		t.Errorf("error without query context: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("%s", err)
+	}
+}
+
+func TestV1UpdateProjectMembers(t *testing.T) {
+	v1, mock := newMockV1(t)
+
+	del := mock.ExpectPrepare(queryDelProjectRoles.sql)
+	add := mock.ExpectPrepare(queryAddProjectRole.sql)
+	usr := mock.ExpectPrepare(queryUser.sql)
+
+	mock.ExpectBegin()
+	del.ExpectExec().WithArgs("3010000.01").WillReturnResult(sqlmock.NewResult(0, 2))
+	usr.ExpectQuery().WithArgs("honlee").
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("honlee", "Hurng-Chun", "", "Lee", "h.lee@donders.ru.nl", "Other", "checked in"))
+	add.ExpectExec().WithArgs("3010000.01", "manager", "honlee").WillReturnResult(sqlmock.NewResult(1, 1))
+	// the user not in the project database is skipped.
+	usr.ExpectQuery().WithArgs("nobody").WillReturnRows(sqlmock.NewRows(userColumns))
+	mock.ExpectCommit()
+
+	err := v1.UpdateProjectMembers("3010000.01", []Member{
+		{UserID: "honlee", Role: "manager"},
+		{UserID: "nobody", Role: "viewer"},
+	})
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("%s", err)
+	}
+}
+
+func TestV1UpdateProjectMembersRollback(t *testing.T) {
+	v1, mock := newMockV1(t)
+
+	del := mock.ExpectPrepare(queryDelProjectRoles.sql)
+	add := mock.ExpectPrepare(queryAddProjectRole.sql)
+	usr := mock.ExpectPrepare(queryUser.sql)
+
+	mock.ExpectBegin()
+	del.ExpectExec().WithArgs("3010000.01").WillReturnResult(sqlmock.NewResult(0, 2))
+	usr.ExpectQuery().WithArgs("honlee").
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("honlee", "Hurng-Chun", "", "Lee", "h.lee@donders.ru.nl", "Other", "checked in"))
+	add.ExpectExec().WithArgs("3010000.01", "manager", "honlee").WillReturnError(fmt.Errorf("connection lost"))
+	mock.ExpectRollback()
+
+	err := v1.UpdateProjectMembers("3010000.01", []Member{{UserID: "honlee", Role: "manager"}})
+	if err == nil {
+		t.Fatalf("expect error on failing insert")
+	}
+	if !strings.Contains(err.Error(), queryAddProjectRole.name) || !strings.Contains(err.Error(), "connection lost") {
+		t.Errorf("error without query context: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("%s", err)
+	}
+}
+
+func TestV1UpdateProjectStorageQuota(t *testing.T) {
+	v1, mock := newMockV1(t)
+
+	upd := mock.ExpectPrepare(queryUpdateQuota.sql)
+	mock.ExpectBegin()
+	upd.ExpectExec().WithArgs(100, 20, "3010000.01").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// the prepared statement is reused by the next transaction.
+	mock.ExpectBegin()
+	upd.ExpectExec().WithArgs(100, 30, "3010000.01").WillReturnError(fmt.Errorf("lock wait timeout"))
+	mock.ExpectRollback()
+
+	if err := v1.UpdateProjectStorageQuota("3010000.01", 100, 20); err != nil {
+		t.Errorf("%s", err)
+	}
+
+	err := v1.UpdateProjectStorageQuota("3010000.01", 100, 30)
+	if err == nil || !strings.HasPrefix(err.Error(), queryUpdateQuota.name+" 3010000.01") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("%s", err)
+	}
+}
+
+func TestV1GetProjectPendingActions(t *testing.T) {
+	v1, mock := newMockV1(t)
+
+	t0 := time.Date(2023, 4, 28, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectPrepare(queryPendingActions.sql).ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "project_id", "role", "created", "action", "calculatedProjectSpace"}).
+			AddRow("honlee", "3010000.01", "viewer", t0, "set", 100).
+			AddRow("honlee", "3010000.01", "contributor", t0.Add(time.Hour), "delete", 100).
+			AddRow("edwger", "3010000.01", "manager", t0, "set", 100))
+
+	actions, err := v1.GetProjectPendingActions()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	act, ok := actions["3010000.01"]
+	if !ok || len(act.Members) != 2 || act.Storage.QuotaGb != 100 {
+		t.Fatalf("unexpected actions: %+v", actions)
+	}
+	for _, m := range act.Members {
+		// the latest action on the same user is taken.
+		if m.UserID == "honlee" && (m.Role != "none" || !m.Timestamp.Equal(t0.Add(time.Hour))) {
+			t.Errorf("unexpected member action: %+v", m)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("%s", err)
+	}
+}
@@ -1,8 +1,8 @@
 package pdb
 
 import (
+	"context"
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
 
@@ -75,34 +75,214 @@ func userFunctionEnum(status api.UserFunction) UserFunction {
 		return UserFunctionOtherResearcher
 	case api.UserFunctionTrainee:
 		return UserFunctionTrainee
+	case api.UserFunctionSeniorresearcher:
+		return UserFunctionSeniorResearcher
+	case api.UserFunctionResearchfellow:
+		return UserFunctionResearchFellow
+	case api.UserFunctionStudentassistant:
+		return UserFunctionStudentAssistant
 	default:
 		return UserFunctionUnknown
 	}
 }
 
-// map the corresponding modality id to a given `Lab`
-func modality(lab Lab) *regexp.Regexp {
-	switch lab {
-	case MEG:
-		return regexp.MustCompile("^meg.*")
-	case EEG:
-		return regexp.MustCompile("^eeg$")
-	case MRI:
-		return regexp.MustCompile("^mr[0-9.]t$")
+// apiProjectStatus converts the `ProjectStatus` enum to the project status of the core-api.
+func apiProjectStatus(status ProjectStatus) (api.ProjectStatus, error) {
+	switch status {
+	case ProjectStatusActive:
+		return api.ProjectStatusActive, nil
+	case ProjectStatusInactive:
+		return api.ProjectStatusInactive, nil
+	default:
+		return "", fmt.Errorf("unsupported project status: %s", status)
+	}
+}
+
+// apiUserStatus converts the `UserStatus` enum to the user status of the core-api.
+func apiUserStatus(status UserStatus) (api.UserStatus, error) {
+	switch status {
+	case UserStatusTentative:
+		return api.UserStatusTentative, nil
+	case UserStatusCheckedIn:
+		return api.UserStatusCheckedin, nil
+	case UserStatusCheckedOut:
+		return api.UserStatusCheckedout, nil
+	case UserStatusCheckedOutExtended:
+		return api.UserStatusCheckedoutextended, nil
 	default:
-		return regexp.MustCompile(".*")
+		return "", fmt.Errorf("unsupported user status: %s", status)
+	}
+}
+
+// apiUserFunction converts the `UserFunction` enum to the user function of the core-api.
+// The functions of the legacy project database (e.g. `UserFunctionOther`) have no
+// counterpart in the core-api.
+func apiUserFunction(function UserFunction) (api.UserFunction, error) {
+	switch function {
+	case UserFunctionPrincipalInvestigator:
+		return api.UserFunctionPrincipalinvestigator, nil
+	case UserFunctionResearchAssistant:
+		return api.UserFunctionResearchassistant, nil
+	case UserFunctionResearchStaff:
+		return api.UserFunctionResearchstaff, nil
+	case UserFunctionStaffScientist:
+		return api.UserFunctionStaffscientist, nil
+	case UserFunctionPostdoc:
+		return api.UserFunctionPostdoctoralresearcher, nil
+	case UserFunctionPhD:
+		return api.UserFunctionPhdstudent, nil
+	case UserFunctionSupportingStaff:
+		return api.UserFunctionSupportingstaff, nil
+	case UserFunctionOtherResearcher:
+		return api.UserFunctionOtherresearcher, nil
+	case UserFunctionTrainee:
+		return api.UserFunctionTrainee, nil
+	case UserFunctionSeniorResearcher:
+		return api.UserFunctionSeniorresearcher, nil
+	case UserFunctionResearchFellow:
+		return api.UserFunctionResearchfellow, nil
+	case UserFunctionStudentAssistant:
+		return api.UserFunctionStudentassistant, nil
+	case UserFunctionUnknown:
+		return api.UserFunctionUnknown, nil
+	default:
+		return "", fmt.Errorf("unsupported user function: %s", function)
+	}
+}
+
+// v2PageSize is the number of projects or users retrieved from the core-api per request.
+const v2PageSize = 500
+
+// pageFunc retrieves the page of items following the `cursor` of the previous page; the
+// first page is retrieved with an empty cursor.  It returns the items, the cursor of the
+// next page, and whether there are more pages.
+type pageFunc[T any] func(ctx context.Context, cursor string) (items []T, next string, more bool, err error)
+
+// walkPages retrieves the pages with `fetch`, following the cursors from page to page,
+// and calls `fn` on every page.  Only one page is held in memory at a time.  It stops
+// when the context `ctx` is cancelled, or `fn` returns an error.
+func walkPages[T any](ctx context.Context, fetch pageFunc[T], fn func(page []T) error) error {
+
+	cursor := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		page, next, more, err := fetch(ctx, cursor)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(page); err != nil {
+			return err
+		}
+
+		if !more {
+			return nil
+		}
+
+		// guard against retrieving the same page over and over again.
+		if next == "" || next == cursor {
+			return fmt.Errorf("invalid cursor of the next page: %q", next)
+		}
+		cursor = next
 	}
 }
 
+// fetchPages retrieves the items of all the pages with `fetch` using `walkPages`.
+func fetchPages[T any](ctx context.Context, fetch pageFunc[T]) ([]T, error) {
+
+	items := make([]T, 0)
+	err := walkPages(ctx, fetch, func(page []T) error {
+		items = append(items, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 // V2 implements interfaces of the new project database implemented with GraphQL-based core-api.
 type V2 struct {
 	config config.CoreAPIConfiguration
 }
 
-// DelProjectPendingActions performs deletion on the pending-role actions from the project
-// database.
+// client returns the core-api client shared by all `V2` instances of the same configuration,
+// so that the access token is reused across the calls.
+func (v2 V2) client() *api.Client {
+	return api.Shared(v2.config)
+}
+
+// memberRole converts the role name (e.g. `manager`) to the project member role of the core-api.
+func memberRole(role string) (api.ProjectMemberRole, error) {
+	switch role {
+	case "manager":
+		return api.ProjectMemberRoleManager, nil
+	case "contributor":
+		return api.ProjectMemberRoleContributor, nil
+	case "viewer":
+		return api.ProjectMemberRoleViewer, nil
+	case "traverse":
+		return api.ProjectMemberRoleTraverse, nil
+	default:
+		return "", fmt.Errorf("unsupported member role: %s", role)
+	}
+}
+
+// roleName converts the project member role of the core-api to the role name, i.e. the
+// reverse of `memberRole`.
+func roleName(role api.ProjectMemberRole) string {
+	return strings.ToLower(string(role))
+}
+
+// pendingRole converts the pending member update of the core-api to the role name of
+// the pending action. The role name "none" refers to the removal of the member.
+func pendingRole(update api.PendingProjectMemberUpdate) string {
+	switch update {
+	case api.PendingProjectMemberUpdateChangetomanager:
+		return "manager"
+	case api.PendingProjectMemberUpdateChangetocontributor:
+		return "contributor"
+	case api.PendingProjectMemberUpdateChangetoviewer:
+		return "viewer"
+	case api.PendingProjectMemberUpdateRemove:
+		return "none"
+	default:
+		return ""
+	}
+}
+
+// DelProjectPendingActions acknowledges the performed pending-role actions in the project
+// database by applying the pending update on the project members, i.e. setting the new
+// role or removing the member.
 func (v2 V2) DelProjectPendingActions(actions map[string]*DataProjectUpdate) error {
-	return fmt.Errorf("not implemented")
+
+	for pid, act := range actions {
+		for _, m := range act.Members {
+
+			log.Debugf("acknowledging pending action on project %s, %s: %s", pid, m.Role, m.UserID)
+
+			if m.Role == "none" {
+				if err := v2.client().RemoveUserFromProject(context.Background(), pid, m.UserID); err != nil {
+					return err
+				}
+				continue
+			}
+
+			role, err := memberRole(m.Role)
+			if err != nil {
+				return err
+			}
+
+			if err := v2.client().AddUserToProject(context.Background(), pid, m.UserID, role); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
 }
 
 // GetProjectPendingActions performs queries to get project pending roles and project storage
@@ -110,91 +290,295 @@ func (v2 V2) DelProjectPendingActions(actions map[string]*DataProjectUpdate) err
 // sending project update request to the filer-gateway API:
 // https://github.com/dccn-tg/filer-gateway
 func (v2 V2) GetProjectPendingActions() (map[string]*DataProjectUpdate, error) {
-	return nil, fmt.Errorf("not implemented")
+
+	resp, err := v2.client().GetProjectsPendingMembers(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	actions := make(map[string]*DataProjectUpdate)
+
+	for _, p := range resp.Projects {
+		for _, m := range p.Members {
+
+			role := pendingRole(m.PendingUpdate)
+			if role == "" {
+				continue
+			}
+
+			if _, ok := actions[p.Number]; !ok {
+				actions[p.Number] = &DataProjectUpdate{
+					Members: []Member{},
+					Storage: Storage{
+						QuotaGb: projectQuota(p.Storage.QuotaGiB, p.OverrulingQuotaGiB),
+					},
+				}
+			}
+
+			log.Debugf("%s user %s to role %s in project %s", m.PendingUpdate, m.User.Username, role, p.Number)
+
+			actions[p.Number].Members = append(actions[p.Number].Members, Member{
+				UserID: m.User.Username,
+				Role:   role,
+			})
+		}
+	}
+
+	return actions, nil
+}
+
+// UpdateProjectMembers updates the members of the project in the project database with
+// the given `members`; roles of existing members are changed, new members are added, and
+// members not in `members` are removed.
+//
+// Members with a pending update are left untouched, as the update is yet to be performed
+// on the storage.
+func (v2 V2) UpdateProjectMembers(project string, members []Member) error {
+
+	resp, err := v2.client().GetProjectMembers(context.Background(), project)
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]api.ProjectMemberRole)
+	pending := make(map[string]bool)
+	for _, m := range resp.Project.Members {
+		current[m.User.Username] = m.Role
+		if m.PendingUpdate != "" {
+			pending[m.User.Username] = true
+		}
+	}
+
+	updated := make(map[string]bool)
+	for _, m := range members {
+		updated[m.UserID] = true
+
+		if pending[m.UserID] {
+			log.Debugf("skip member with pending update in project %s: %s", project, m.UserID)
+			continue
+		}
+
+		role, err := memberRole(m.Role)
+		if err != nil {
+			log.Warnf("skip member %s of project %s: %s", m.UserID, project, err)
+			continue
+		}
+
+		if r, ok := current[m.UserID]; ok && r == role {
+			continue
+		}
+
+		log.Debugf("Updating project %s, %s: %s", project, m.Role, m.UserID)
+		if err := v2.client().AddUserToProject(context.Background(), project, m.UserID, role); err != nil {
+			return err
+		}
+	}
+
+	for u := range current {
+		if updated[u] || pending[u] {
+			continue
+		}
+		log.Debugf("Removing member from project %s: %s", project, u)
+		if err := v2.client().RemoveUserFromProject(context.Background(), project, u); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateProjectStorageQuota updates the storage quota of the project in the project database.
+// The quota is set as the overruling quota if it differs from the effective quota of the
+// project in the project database, i.e. the overruling quota if it is set.
+//
+// The storage usage is not stored in the project database v2, as it is retrieved by the
+// core-api from the storage directly; the `usageGB` argument is therefore ignored.
+func (v2 V2) UpdateProjectStorageQuota(project string, quotaGB, usageGB int) error {
+
+	resp, err := v2.client().GetProjectQuota(context.Background(), project)
+	if err != nil {
+		return err
+	}
+
+	if projectQuota(resp.Project.Storage.QuotaGiB, resp.Project.OverrulingQuotaGiB) == quotaGB {
+		return nil
+	}
+
+	log.Debugf("Updating quota of project %s, total: %d, usage: %d", project, quotaGB, usageGB)
+	return v2.client().UpdateProjectQuota(context.Background(), project, quotaGB)
 }
 
 // GetProjects retrieves list of project identifiers from the project database.
 func (v2 V2) GetProjects(activeOnly bool) ([]*Project, error) {
-	resp, err := api.GetProjects(v2.config)
 
+	f := ProjectFilter{}
+	if activeOnly {
+		f.Status = []ProjectStatus{ProjectStatusActive}
+	}
+
+	return v2.projects(context.Background(), f)
+}
+
+// projects retrieves the projects selected by the filter `f`.
+func (v2 V2) projects(ctx context.Context, f ProjectFilter) ([]*Project, error) {
+
+	projects := make([]*Project, 0)
+	err := v2.WalkProjects(ctx, f, func(p *Project) error {
+		projects = append(projects, p)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return projects, nil
+}
 
-	var projects []*Project
-	for _, p := range resp.Projects {
-		if activeOnly && projectStatusEnum(p.Status) != ProjectStatusActive {
-			continue
+// WalkProjects implements the `ProjectWalker` interface.  The projects are filtered by
+// the core-api, and retrieved page by page; only one page is held in memory at a time.
+func (v2 V2) WalkProjects(ctx context.Context, f ProjectFilter, fn func(p *Project) error) error {
+
+	var status []api.ProjectStatus
+	for _, s := range f.Status {
+		as, err := apiProjectStatus(s)
+		if err != nil {
+			return err
 		}
-		projects = append(projects, &Project{
-			ID:     p.Number,
-			Name:   p.Title,
-			Kind:   projectKindEnum(p.Kind),
-			Owner:  p.Owner.Username,
-			Status: projectStatusEnum(p.Status),
-			Start:  p.Start,
-			End:    p.End,
-		})
+		status = append(status, as)
 	}
 
-	return projects, nil
+	fetch := func(ctx context.Context, cursor string) ([]*Project, string, bool, error) {
+
+		resp, err := v2.client().GetProjectsPage(ctx, status, f.Owner, f.EndAfter, f.EndBefore, v2PageSize, cursor)
+		if err != nil {
+			return nil, "", false, err
+		}
+
+		conn := resp.ProjectsConnection
+
+		projects := make([]*Project, 0, len(conn.Edges))
+		for _, e := range conn.Edges {
+			p := e.Node
+			projects = append(projects, &Project{
+				ID:      p.Number,
+				Name:    p.Title,
+				Kind:    projectKindEnum(p.Kind),
+				Owner:   p.Owner.Username,
+				Status:  projectStatusEnum(p.Status),
+				Start:   p.Start,
+				End:     p.End,
+				QuotaGb: projectQuota(p.Storage.QuotaGiB, p.OverrulingQuotaGiB),
+			})
+		}
+
+		return projects, conn.PageInfo.EndCursor, conn.PageInfo.HasNextPage, nil
+	}
+
+	return walkPages(ctx, fetch, func(page []*Project) error {
+		for _, p := range page {
+			if err := fn(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // GetProject retrieves attributes of a project.
 func (v2 V2) GetProject(projectID string) (*Project, error) {
 
-	resp, err := api.GetProject(v2.config, projectID)
+	resp, err := v2.client().GetProject(context.Background(), projectID)
 
 	if err != nil {
 		return nil, err
 	}
 
 	return &Project{
-		ID:     resp.Project.Number,
-		Name:   resp.Project.Title,
-		Kind:   projectKindEnum(resp.Project.Kind),
-		Owner:  resp.Project.Owner.Username,
-		Status: projectStatusEnum(resp.Project.Status),
-		Start:  resp.Project.Start,
-		End:    resp.Project.End,
+		ID:      resp.Project.Number,
+		Name:    resp.Project.Title,
+		Kind:    projectKindEnum(resp.Project.Kind),
+		Owner:   resp.Project.Owner.Username,
+		Status:  projectStatusEnum(resp.Project.Status),
+		Start:   resp.Project.Start,
+		End:     resp.Project.End,
+		QuotaGb: projectQuota(resp.Project.Storage.QuotaGiB, resp.Project.OverrulingQuotaGiB),
 	}, nil
 }
 
-func (v2 V2) GetUsers(activeOnly bool) ([]*User, error) {
+// projectQuota returns the effective storage quota of a project, i.e. the overruling quota
+// if it is set, or the quota from the quota rules.
+func projectQuota(quotaGiB, overrulingQuotaGiB int) int {
+	if overrulingQuotaGiB > 0 {
+		return overrulingQuotaGiB
+	}
+	return quotaGiB
+}
 
-	resp, err := api.GetUsers(v2.config)
+// GetUsers retrieves the users from the project database.  Only the users checked in, or
+// with extended checkout, are retrieved if `activeOnly` is set.
+func (v2 V2) GetUsers(activeOnly bool) ([]*User, error) {
 
-	if err != nil {
-		return nil, err
+	f := UserFilter{}
+	if activeOnly {
+		f.Status = []UserStatus{UserStatusCheckedIn, UserStatusCheckedOutExtended}
 	}
 
-	var users []*User
-	for _, u := range resp.Users {
+	return v2.users(context.Background(), f)
+}
 
-		s := userStatusEnum(u.Status)
+// users retrieves the users selected by the filter `f`.  The users are filtered by the
+// core-api, and retrieved page by page.
+func (v2 V2) users(ctx context.Context, f UserFilter) ([]*User, error) {
 
-		if activeOnly && !(s == UserStatusCheckedIn || s == UserStatusCheckedOutExtended) {
-			continue
+	var status []api.UserStatus
+	for _, s := range f.Status {
+		as, err := apiUserStatus(s)
+		if err != nil {
+			return nil, err
 		}
+		status = append(status, as)
+	}
 
-		users = append(users, &User{
-			ID:         u.Username,
-			Firstname:  u.FirstName,
-			Middlename: u.MiddleName,
-			Lastname:   u.LastName,
-			Email:      u.Email,
-			Status:     s,
-		})
+	var function []api.UserFunction
+	for _, fn := range f.Function {
+		af, err := apiUserFunction(fn)
+		if err != nil {
+			return nil, err
+		}
+		function = append(function, af)
 	}
 
-	return users, nil
+	return fetchPages(ctx, func(ctx context.Context, cursor string) ([]*User, string, bool, error) {
+
+		resp, err := v2.client().GetUsersPage(ctx, status, function, v2PageSize, cursor)
+		if err != nil {
+			return nil, "", false, err
+		}
+
+		conn := resp.UsersConnection
+
+		users := make([]*User, 0, len(conn.Edges))
+		for _, e := range conn.Edges {
+			u := e.Node
+			users = append(users, &User{
+				ID:         u.Username,
+				Firstname:  u.FirstName,
+				Middlename: u.MiddleName,
+				Lastname:   u.LastName,
+				Email:      u.Email,
+				Status:     userStatusEnum(u.Status),
+				Function:   userFunctionEnum(u.Function),
+			})
+		}
+
+		return users, conn.PageInfo.EndCursor, conn.PageInfo.HasNextPage, nil
+	})
 }
 
 // GetUser gets the user identified by the given uid in the project database.
 // It returns the pointer to the user data represented in the User data structure.
 func (v2 V2) GetUser(uid string) (*User, error) {
 
-	resp, err := api.GetUser(v2.config, uid)
+	resp, err := v2.client().GetUser(context.Background(), uid)
 
 	if err != nil {
 		return nil, err
@@ -211,10 +595,33 @@ func (v2 V2) GetUser(uid string) (*User, error) {
 	}, nil
 }
 
+// GetUserProjectRoles retrieves the roles of the user `uid` in the projects.
+func (v2 V2) GetUserProjectRoles(uid string) ([]*ProjectRole, error) {
+
+	resp, err := v2.client().GetUserProjects(context.Background(), uid)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]*ProjectRole, 0, len(resp.User.Projects))
+	for _, m := range resp.User.Projects {
+		// a member without role is not (yet) given access to the project.
+		if m.Role == "" {
+			continue
+		}
+		roles = append(roles, &ProjectRole{
+			ProjectID: m.Project.Number,
+			Role:      roleName(m.Role),
+		})
+	}
+
+	return roles, nil
+}
+
 // GetUserByEmail gets the user identified by the given email address.
 func (v2 V2) GetUserByEmail(email string) (*User, error) {
 
-	resp, err := api.GetUserByEmail(v2.config, email)
+	resp, err := v2.client().GetUserByEmail(context.Background(), email)
 
 	if err != nil {
 		return nil, err
@@ -273,10 +680,15 @@ func (v2 V2) getLabBookingEvents(lab Lab, from, to time.Time, forWorklist bool)
 
 	loc, _ := time.LoadLocation(Location)
 
+	info, err := LookupLab(lab)
+	if err != nil {
+		return nil, err
+	}
+
 	// retrieve resources of given modalities corresponding to the `lab` type
-	resources, err := api.GetLabs(
-		v2.config,
-		modality(lab),
+	resources, err := v2.client().GetLabs(
+		context.Background(),
+		info.Modality,
 		true,
 	)
 
@@ -286,8 +698,8 @@ func (v2 V2) getLabBookingEvents(lab Lab, from, to time.Time, forWorklist bool)
 		return nil, err
 	}
 
-	resp, err := api.GetBookingEvents(
-		v2.config,
+	resp, err := v2.client().GetBookingEvents(
+		context.Background(),
 		resources,
 		from,
 		to,
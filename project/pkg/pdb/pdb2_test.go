@@ -0,0 +1,485 @@
+package pdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dccn-tg/tg-toolset-golang/pkg/config"
+	api "github.com/dccn-tg/tg-toolset-golang/project/internal/pdb2"
+)
+
+func TestMemberRoleConversion(t *testing.T) {
+	for update, role := range map[api.PendingProjectMemberUpdate]string{
+		api.PendingProjectMemberUpdateChangetomanager:     "manager",
+		api.PendingProjectMemberUpdateChangetocontributor: "contributor",
+		api.PendingProjectMemberUpdateChangetoviewer:      "viewer",
+		api.PendingProjectMemberUpdateRemove:              "none",
+	} {
+		if r := pendingRole(update); r != role {
+			t.Errorf("unexpected role of pending update %s: %s", update, r)
+		}
+
+		if role == "none" {
+			continue
+		}
+
+		if _, err := memberRole(role); err != nil {
+			t.Errorf("%s", err)
+		}
+	}
+
+	if _, err := memberRole("writer"); err == nil {
+		t.Errorf("expect error on unsupported role")
+	}
+}
+
+// newV2 returns the `V2` connected to a core-api test server serving `n` projects in pages
+// of 2 projects.  The variables of the queries are returned through `vars`.
+func newV2(t *testing.T, n int) (V2, *[]map[string]interface{}) {
+
+	var mutex sync.Mutex
+	vars := []map[string]interface{}{}
+
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"access_token":"token","expires_in":3600,"token_type":"Bearer"}`)
+	}))
+	t.Cleanup(auth.Close)
+
+	core := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mutex.Lock()
+		vars = append(vars, req.Variables)
+		mutex.Unlock()
+
+		start := 0
+		if after, ok := req.Variables["after"].(string); ok {
+			start, _ = strconv.Atoi(after)
+		}
+		end := start + 2
+		if end > n {
+			end = n
+		}
+
+		edges := []string{}
+		for i := start; i < end; i++ {
+			edges = append(edges, fmt.Sprintf(`{"node":{"number":"30100%02d.01","status":"Active","owner":{"username":"rendbru"},"storage":{"quotaGiB":100}}}`, i))
+		}
+
+		fmt.Fprintf(w, `{"data":{"projectsConnection":{"pageInfo":{"hasNextPage":%t,"endCursor":"%d"},"edges":[%s]}}}`,
+			end < n, end, strings.Join(edges, ","))
+	}))
+	t.Cleanup(core.Close)
+
+	return V2{config: config.CoreAPIConfiguration{AuthURL: auth.URL, CoreAPIURL: core.URL}}, &vars
+}
+
+func TestV2Projects(t *testing.T) {
+	v2, vars := newV2(t, 5)
+
+	projects, err := v2.projects(context.Background(), ProjectFilter{Status: []ProjectStatus{ProjectStatusActive}, Owner: "rendbru"})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for n, p := range projects {
+		if p.ID != fmt.Sprintf("30100%02d.01", n) || p.Status != ProjectStatusActive || p.QuotaGb != 100 {
+			t.Errorf("unexpected project: %+v", p)
+		}
+	}
+
+	if len(projects) != 5 || len(*vars) != 3 {
+		t.Errorf("expect 5 projects in 3 pages, got %d projects in %d pages", len(projects), len(*vars))
+	}
+
+	v := (*vars)[0]
+	if _, ok := v["after"]; ok {
+		t.Errorf("unexpected cursor of the first page: %+v", v)
+	}
+	if _, ok := v["endAfter"]; ok {
+		t.Errorf("unexpected end date filter: %+v", v)
+	}
+	if fmt.Sprint(v["status"]) != "[Active]" || v["owner"] != "rendbru" {
+		t.Errorf("unexpected filter: %+v", v)
+	}
+	if (*vars)[2]["after"] != "4" {
+		t.Errorf("unexpected cursor of the last page: %+v", (*vars)[2])
+	}
+
+	projects, err = v2.GetProjects(false)
+	if err != nil || len(projects) != 5 {
+		t.Errorf("unexpected projects: %d %v", len(projects), err)
+	}
+
+	if _, err := v2.projects(context.Background(), ProjectFilter{Status: []ProjectStatus{ProjectStatusUnknown}}); err == nil {
+		t.Errorf("expect error on unsupported status")
+	}
+
+	if _, err := v2.users(context.Background(), UserFilter{Function: []UserFunction{UserFunctionOther}}); err == nil {
+		t.Errorf("expect error on unsupported user function")
+	}
+}
+
+func TestV2WalkProjects(t *testing.T) {
+	v2, vars := newV2(t, 5)
+
+	// the projects are streamed page by page; the walk stops at the first error of `fn`.
+	stop := fmt.Errorf("stop")
+	n := 0
+	err := WalkProjects(context.Background(), v2, ProjectFilter{}, func(p *Project) error {
+		n++
+		if n == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Errorf("expect walk to stop, got %v", err)
+	}
+	if n != 3 || len(*vars) != 2 {
+		t.Errorf("expect 3 projects in 2 pages, got %d projects in %d pages", n, len(*vars))
+	}
+}
+
+func TestFetchPages(t *testing.T) {
+
+	// pages of the items following the cursor; the cursor "loop" refers to itself.
+	pages := map[string]struct {
+		items []int
+		next  string
+	}{
+		"":     {[]int{1, 2}, "a"},
+		"a":    {[]int{3}, "b"},
+		"b":    {[]int{4}, ""},
+		"loop": {[]int{5}, "loop"},
+	}
+
+	fetch := func(start string, cancel context.CancelFunc) pageFunc[int] {
+		return func(ctx context.Context, cursor string) ([]int, string, bool, error) {
+			if cursor == "" {
+				cursor = start
+			}
+			if cancel != nil {
+				cancel()
+			}
+			p, ok := pages[cursor]
+			if !ok {
+				return nil, "", false, fmt.Errorf("unknown cursor: %s", cursor)
+			}
+			return p.items, p.next, p.next != "", nil
+		}
+	}
+
+	items, err := fetchPages(context.Background(), fetch("", nil))
+	if err != nil || !reflect.DeepEqual(items, []int{1, 2, 3, 4}) {
+		t.Errorf("unexpected items: %v %v", items, err)
+	}
+
+	if _, err := fetchPages(context.Background(), fetch("loop", nil)); err == nil {
+		t.Errorf("expect error on repeated cursor")
+	}
+
+	if _, err := fetchPages(context.Background(), fetch("x", nil)); err == nil {
+		t.Errorf("expect error on failed page")
+	}
+
+	// the retrieval stops on the cancelled context.
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := fetchPages(ctx, fetch("", cancel)); err != context.Canceled {
+		t.Errorf("expect cancellation, got %v", err)
+	}
+}
+
+func TestUserFunctionConversion(t *testing.T) {
+
+	// every user function of the core-api is converted back and forth.
+	for _, af := range []api.UserFunction{
+		api.UserFunctionTrainee,
+		api.UserFunctionPhdstudent,
+		api.UserFunctionPostdoctoralresearcher,
+		api.UserFunctionPrincipalinvestigator,
+		api.UserFunctionResearchstaff,
+		api.UserFunctionResearchassistant,
+		api.UserFunctionOtherresearcher,
+		api.UserFunctionStaffscientist,
+		api.UserFunctionSupportingstaff,
+		api.UserFunctionSeniorresearcher,
+		api.UserFunctionResearchfellow,
+		api.UserFunctionStudentassistant,
+		api.UserFunctionUnknown,
+	} {
+		f := userFunctionEnum(af)
+		if f == UserFunctionUnknown && af != api.UserFunctionUnknown {
+			t.Errorf("user function %s not converted", af)
+		}
+		if back, err := apiUserFunction(f); err != nil || back != af {
+			t.Errorf("user function %s converted back to %s: %v", af, back, err)
+		}
+	}
+
+	// the functions of the legacy project database have no counterpart.
+	for _, f := range []UserFunction{UserFunctionOther, UserFunctionResearchSupport, UserFunctionOtherSupport} {
+		if af, err := apiUserFunction(f); err == nil {
+			t.Errorf("expect error on user function %s, got %s", f, af)
+		}
+	}
+}
+
+// gqlRequest is a GraphQL request received by the core-api test server.
+type gqlRequest struct {
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// newCoreAPI returns the `V2` connected to a core-api test server responding to each
+// GraphQL operation with the `responses` keyed by the operation name.  Operations
+// without a response are failed with HTTP status 500.  The requests received by the
+// server are returned through `reqs`.
+func newCoreAPI(t *testing.T, responses map[string]string) (V2, *[]gqlRequest) {
+
+	var mutex sync.Mutex
+	reqs := []gqlRequest{}
+
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"access_token":"token","expires_in":3600,"token_type":"Bearer"}`)
+	}))
+	t.Cleanup(auth.Close)
+
+	core := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req gqlRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mutex.Lock()
+		reqs = append(reqs, req)
+		mutex.Unlock()
+
+		resp, ok := responses[req.OperationName]
+		if !ok {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, resp)
+	}))
+	t.Cleanup(core.Close)
+
+	return V2{config: config.CoreAPIConfiguration{AuthURL: auth.URL, CoreAPIURL: core.URL}}, &reqs
+}
+
+// mutations returns the mutations in `reqs` as strings of the operation name and the
+// sorted variables.
+func mutations(reqs []gqlRequest) []string {
+	m := []string{}
+	for _, r := range reqs {
+		if r.OperationName == "getProjectMembers" || r.OperationName == "getProjectQuota" {
+			continue
+		}
+		keys := make([]string, 0, len(r.Variables))
+		for k := range r.Variables {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		vars := []string{}
+		for _, k := range keys {
+			vars = append(vars, fmt.Sprintf("%s=%v", k, r.Variables[k]))
+		}
+		m = append(m, fmt.Sprintf("%s(%s)", r.OperationName, strings.Join(vars, ",")))
+	}
+	return m
+}
+
+func TestV2UpdateProjectMembers(t *testing.T) {
+	v2, reqs := newCoreAPI(t, map[string]string{
+		"getProjectMembers": `{"data":{"project":{"number":"3010000.01","members":[
+			{"user":{"username":"honlee"},"role":"Manager"},
+			{"user":{"username":"rendbru"},"role":"Contributor"},
+			{"user":{"username":"edwger"},"role":"Viewer","pendingUpdate":"Remove"},
+			{"user":{"username":"marvdam"},"role":"Viewer"}
+		]}}}`,
+		"addUserToProject":      `{"data":{"addUserToProject":{"role":"Viewer"}}}`,
+		"removeUserFromProject": `{"data":{"removeUserFromProject":{"role":"Viewer"}}}`,
+	})
+
+	err := v2.UpdateProjectMembers("3010000.01", []Member{
+		{UserID: "honlee", Role: "manager"},
+		{UserID: "rendbru", Role: "viewer"},
+		{UserID: "edwger", Role: "manager"},
+		{UserID: "dirkx", Role: "contributor"},
+		{UserID: "lenvdam", Role: "writer"},
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// unchanged members, members with a pending update and unsupported roles are
+	// left untouched.
+	expected := []string{
+		"addUserToProject(number=3010000.01,role=Viewer,username=rendbru)",
+		"addUserToProject(number=3010000.01,role=Contributor,username=dirkx)",
+		"removeUserFromProject(number=3010000.01,username=marvdam)",
+	}
+	if m := mutations(*reqs); strings.Join(m, ";") != strings.Join(expected, ";") {
+		t.Errorf("unexpected mutations: %v", m)
+	}
+}
+
+func TestV2GetUserProjectRoles(t *testing.T) {
+	v2, _ := newCoreAPI(t, map[string]string{
+		"getUserProjects": `{"data":{"user":{"username":"honlee","projects":[
+			{"project":{"number":"3010000.01"},"role":"Manager"},
+			{"project":{"number":"3010000.02"},"role":"Viewer"},
+			{"project":{"number":"3010000.03"},"role":null,"pendingUpdate":"ChangeToContributor"}
+		]}}}`,
+	})
+
+	roles, err := v2.GetUserProjectRoles("honlee")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// members without role are left out.
+	expected := []*ProjectRole{
+		{ProjectID: "3010000.01", Role: "manager"},
+		{ProjectID: "3010000.02", Role: "viewer"},
+	}
+	if !reflect.DeepEqual(roles, expected) {
+		t.Errorf("unexpected roles: %+v", roles)
+	}
+
+	if _, err := v2.GetUserProjectRoles("nobody"); err == nil {
+		t.Errorf("expect error on unknown user")
+	}
+}
+
+func TestV2UpdateProjectMembersError(t *testing.T) {
+	v2, reqs := newCoreAPI(t, map[string]string{
+		"getProjectMembers": `{"data":{"project":{"number":"3010000.01","members":[]}}}`,
+		"addUserToProject":  `{"errors":[{"message":"user not found: nobody"}],"data":null}`,
+	})
+
+	err := v2.UpdateProjectMembers("3010000.01", []Member{
+		{UserID: "nobody", Role: "viewer"},
+		{UserID: "honlee", Role: "viewer"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "user not found: nobody") {
+		t.Errorf("expect error of the failed mutation, got %v", err)
+	}
+
+	// the update stops at the first failure.
+	if m := mutations(*reqs); len(m) != 1 {
+		t.Errorf("unexpected mutations: %v", m)
+	}
+
+	// the failure of the query is returned.
+	v2, _ = newCoreAPI(t, map[string]string{})
+	if err := v2.UpdateProjectMembers("3010000.01", []Member{{UserID: "honlee", Role: "viewer"}}); err == nil {
+		t.Errorf("expect error on failed query")
+	}
+}
+
+func TestV2UpdateProjectStorageQuota(t *testing.T) {
+	v2, reqs := newCoreAPI(t, map[string]string{
+		"getProjectQuota":    `{"data":{"project":{"overrulingQuotaGiB":200,"storage":{"quotaGiB":100,"usageMiB":1024}}}}`,
+		"updateProjectQuota": `{"data":{"updateProject":{"number":"3010000.01","overrulingQuotaGiB":150}}}`,
+	})
+
+	// the effective quota is the overruling quota.
+	if err := v2.UpdateProjectStorageQuota("3010000.01", 200, 1); err != nil {
+		t.Errorf("%s", err)
+	}
+	if m := mutations(*reqs); len(m) != 0 {
+		t.Errorf("unexpected mutations: %v", m)
+	}
+
+	if err := v2.UpdateProjectStorageQuota("3010000.01", 100, 1); err != nil {
+		t.Errorf("%s", err)
+	}
+	expected := []string{"updateProjectQuota(number=3010000.01,quotaGiB=100)"}
+	if m := mutations(*reqs); strings.Join(m, ";") != strings.Join(expected, ";") {
+		t.Errorf("unexpected mutations: %v", m)
+	}
+
+	v2, _ = newCoreAPI(t, map[string]string{
+		"getProjectQuota":    `{"data":{"project":{"storage":{"quotaGiB":100,"usageMiB":1024}}}}`,
+		"updateProjectQuota": `{"errors":[{"message":"permission denied"}],"data":null}`,
+	})
+	if err := v2.UpdateProjectStorageQuota("3010000.01", 150, 1); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("expect error of the failed mutation, got %v", err)
+	}
+}
+
+func TestV2DelProjectPendingActions(t *testing.T) {
+	v2, reqs := newCoreAPI(t, map[string]string{
+		"addUserToProject":      `{"data":{"addUserToProject":{"role":"Manager"}}}`,
+		"removeUserFromProject": `{"data":{"removeUserFromProject":{"role":"Viewer"}}}`,
+	})
+
+	err := v2.DelProjectPendingActions(map[string]*DataProjectUpdate{
+		"3010000.01": {Members: []Member{{UserID: "honlee", Role: "manager"}, {UserID: "edwger", Role: "none"}}},
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := []string{
+		"addUserToProject(number=3010000.01,role=Manager,username=honlee)",
+		"removeUserFromProject(number=3010000.01,username=edwger)",
+	}
+	if m := mutations(*reqs); strings.Join(m, ";") != strings.Join(expected, ";") {
+		t.Errorf("unexpected mutations: %v", m)
+	}
+
+	if err := v2.DelProjectPendingActions(map[string]*DataProjectUpdate{
+		"3010000.01": {Members: []Member{{UserID: "honlee", Role: "writer"}}},
+	}); err == nil {
+		t.Errorf("expect error on unsupported role")
+	}
+}
+
+func TestV2GetProjectPendingActions(t *testing.T) {
+
+	v2, _ := newCoreAPI(t, map[string]string{
+		"getProjectsPendingMembers": `{"data":{"projects":[
+			{"number":"3010000.01","overrulingQuotaGiB":200,"storage":{"quotaGiB":100},"members":[
+				{"user":{"username":"honlee"},"role":"Viewer","pendingUpdate":"ChangeToManager"},
+				{"user":{"username":"rendbru"},"role":"Viewer"}
+			]},
+			{"number":"3010000.02","storage":{"quotaGiB":100},"members":[
+				{"user":{"username":"edwger"},"role":"Viewer","pendingUpdate":"Remove"}
+			]}
+		]}}`,
+	})
+
+	actions, err := v2.GetProjectPendingActions()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if act := actions["3010000.01"]; act == nil || act.Storage.System != "" || act.Storage.QuotaGb != 200 ||
+		len(act.Members) != 1 || act.Members[0].Role != "manager" {
+		t.Errorf("unexpected actions on 3010000.01: %+v", act)
+	}
+
+	// the storage system is not known to the project database.
+	if act := actions["3010000.02"]; act == nil || act.Storage.System != "" ||
+		len(act.Members) != 1 || act.Members[0].Role != "none" {
+		t.Errorf("unexpected actions on 3010000.02: %+v", act)
+	}
+}
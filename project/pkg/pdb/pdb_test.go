@@ -8,6 +8,9 @@ package pdb
 //   TEST_BOOKING_DATE=2023-04-28 \
 //   TEST_CONFIG=/path/of/config/file \
 //   go test -v github.com/dccn-tg/tg-toolset-golang/project/pkg/pdb/...
+//
+// Without `TEST_CONFIG`, the tests run against the mock pdb loaded from
+// `testdata/fixture.yml`.
 
 import (
 	"math"
@@ -41,10 +44,23 @@ func init() {
 	log.NewLogger(logCfg, log.InstanceLogrusLogger)
 
 	var err error
-	testConf, err = config.LoadConfig(configFile)
 
-	if err != nil {
-		log.Fatalf("cannot load config file: %s\n", err)
+	// without `TEST_CONFIG`, tests run offline against the mock pdb with the fixture
+	// in the testdata directory.
+	if configFile == "" {
+		testConf.PDB = config.PDBConfiguration{
+			Version: 0,
+			Mock:    config.MockPDBConfiguration{Fixture: "testdata/fixture.yml"},
+		}
+		projectNumber = defaultEnv(projectNumber, "3010000.01")
+		username = defaultEnv(username, "honlee")
+		userEmail = defaultEnv(userEmail, "h.lee@donders.ru.nl")
+		bookingDate = defaultEnv(bookingDate, "2023-04-28")
+	} else {
+		testConf, err = config.LoadConfig(configFile)
+		if err != nil {
+			log.Fatalf("cannot load config file: %s\n", err)
+		}
 	}
 
 	testPDB, err = New(testConf.PDB)
@@ -54,6 +70,14 @@ func init() {
 	}
 }
 
+// defaultEnv returns `v` if it is not empty; otherwise `d`.
+func defaultEnv(v, d string) string {
+	if v == "" {
+		return d
+	}
+	return v
+}
+
 func TestGetUsers(t *testing.T) {
 	users, err := testPDB.GetUsers(true)
 	if err != nil {
@@ -0,0 +1,235 @@
+package pdb
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// match checks whether the project `p` is selected by the filter.
+func (f ProjectFilter) match(p *Project) bool {
+
+	if len(f.Status) > 0 {
+		ok := false
+		for _, s := range f.Status {
+			ok = ok || p.Status == s
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	if f.Owner != "" && p.Owner != f.Owner {
+		return false
+	}
+
+	if !f.EndAfter.IsZero() && !p.End.After(f.EndAfter) {
+		return false
+	}
+
+	if !f.EndBefore.IsZero() && !p.End.Before(f.EndBefore) {
+		return false
+	}
+
+	return true
+}
+
+// match checks whether the user `u` is selected by the filter.
+func (f UserFilter) match(u *User) bool {
+
+	if len(f.Status) > 0 {
+		ok := false
+		for _, s := range f.Status {
+			ok = ok || u.Status == s
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	if len(f.Function) > 0 {
+		ok := false
+		for _, fn := range f.Function {
+			ok = ok || u.Function == fn
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// UserMatch is a user matching a search query, with the score of the match.
+type UserMatch struct {
+	User  *User `json:"user"`
+	Score int   `json:"score"`
+}
+
+// SearchUsers returns the `users` selected by the filter `f` and matching the `query`,
+// ranked by the score of the match.
+//
+// The query is split into words, and every word has to match the first name, last name,
+// display name, email or the user id of the user.  A word matches a field exactly, as a
+// prefix (of the field or a word in it), as a substring, as a subsequence of characters,
+// or with a few typos, in the order of decreasing score.  All users selected by the filter
+// are returned with a score of zero if the query is empty.
+func SearchUsers(users []*User, query string, f UserFilter) []UserMatch {
+
+	words := strings.Fields(strings.ToLower(query))
+
+	matches := make([]UserMatch, 0)
+	for _, u := range users {
+
+		if !f.match(u) {
+			continue
+		}
+
+		fields := []string{
+			strings.ToLower(u.ID),
+			strings.ToLower(u.Firstname),
+			strings.ToLower(u.Middlename),
+			strings.ToLower(u.Lastname),
+			strings.ToLower(u.DisplayName()),
+			strings.ToLower(u.Email),
+		}
+
+		score := 0
+		for _, w := range words {
+			best := 0
+			for _, field := range fields {
+				if s := matchScore(w, field); s > best {
+					best = s
+				}
+			}
+			if best == 0 {
+				score = 0
+				break
+			}
+			score += best
+		}
+
+		if len(words) > 0 && score == 0 {
+			continue
+		}
+
+		// the query matching the whole display name ranks higher than the words matching
+		// different fields.
+		if len(words) > 1 && strings.Contains(fields[4], strings.Join(words, " ")) {
+			score += 50
+		}
+
+		matches = append(matches, UserMatch{User: u, Score: score})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].User.ID < matches[j].User.ID
+	})
+
+	return matches
+}
+
+// matchScore returns the score of the word `w` matching the `field`; zero means no match.
+// Both are expected to be in lower case.
+func matchScore(w, field string) int {
+
+	switch {
+	case field == "":
+		return 0
+	case field == w:
+		return 100
+	case strings.HasPrefix(field, w):
+		return 80
+	}
+
+	// words in the field, e.g. the parts of an email address.
+	parts := strings.FieldsFunc(field, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for _, p := range parts {
+		if p == w {
+			return 75
+		}
+		if strings.HasPrefix(p, w) {
+			return 70
+		}
+	}
+
+	if strings.Contains(field, w) {
+		return 60
+	}
+
+	// typos are tolerated for words of at least 4 characters: one per 4 characters.
+	if maxEdits := len(w) / 4; maxEdits > 0 {
+		best := maxEdits + 1
+		for _, p := range append(parts, field) {
+			if d := levenshtein(w, p); d < best {
+				best = d
+			}
+		}
+		if best <= maxEdits {
+			return 50 - 10*best
+		}
+	}
+
+	if len(w) >= 3 && isSubsequence(w, field) {
+		return 20
+	}
+
+	return 0
+}
+
+// isSubsequence checks whether the characters of `w` appear in `s` in the same order.
+func isSubsequence(w, s string) bool {
+	rs := []rune(s)
+	i := 0
+	for _, r := range w {
+		for i < len(rs) && rs[i] != r {
+			i++
+		}
+		if i == len(rs) {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// levenshtein returns the edit distance between `a` and `b`.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,64 @@
+package pdb
+
+import (
+	"testing"
+)
+
+func TestSearchUsers(t *testing.T) {
+	m, err := NewMock("testdata/fixture.yml")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	users, err := m.GetUsers(false)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for query, expect := range map[string]string{
+		"honlee":        "honlee",
+		"brui":          "rendbru",
+		"Rene de Bruin": "rendbru",
+		"h.lee@":        "honlee",
+		"gerits":        "edwger", // typo
+		"edward g":      "edwger",
+		"hrng":          "honlee", // subsequence
+	} {
+		matches := SearchUsers(users, query, UserFilter{})
+		if len(matches) == 0 || matches[0].User.ID != expect {
+			t.Errorf("%q: expect %s first, got %+v", query, expect, matches)
+		}
+	}
+
+	if matches := SearchUsers(users, "xyzzy", UserFilter{}); len(matches) != 0 {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+
+	// all words have to match
+	if matches := SearchUsers(users, "lee gerrits", UserFilter{}); len(matches) != 0 {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+
+	// filters
+	matches := SearchUsers(users, "", UserFilter{Status: []UserStatus{UserStatusCheckedOut}})
+	if len(matches) != 1 || matches[0].User.ID != "olduser" {
+		t.Errorf("unexpected matches by status: %+v", matches)
+	}
+
+	matches = SearchUsers(users, "donders", UserFilter{Function: []UserFunction{UserFunctionPrincipalInvestigator, UserFunctionPostdoc}})
+	if len(matches) != 2 || matches[0].User.ID != "edwger" || matches[1].User.ID != "rendbru" {
+		t.Errorf("unexpected matches by function: %+v", matches)
+	}
+}
+
+func TestParseUserStatusFunction(t *testing.T) {
+	if s, err := ParseUserStatus("checkedin"); err != nil || s != UserStatusCheckedIn {
+		t.Errorf("unexpected status: %s, %v", s, err)
+	}
+	if f, err := ParseUserFunction("phd"); err != nil || f != UserFunctionPhD || f.String() != "PhD" {
+		t.Errorf("unexpected function: %s, %v", f, err)
+	}
+	if _, err := ParseUserFunction("wizard"); err == nil {
+		t.Errorf("expect error on unknown function")
+	}
+}